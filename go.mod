@@ -7,6 +7,7 @@ replace github.com/pkoukk/tiktoken-go => github.com/thedadams/tiktoken-go v0.0.0
 require (
 	github.com/JohannesKaufmann/html-to-markdown/v2 v2.5.0
 	github.com/adrg/xdg v0.5.3
+	github.com/coder/websocket v1.8.14
 	github.com/dop251/goja v0.0.0-20250630131328-58d95d85e994
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/glebarez/sqlite v1.11.0
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/nanobot-ai/nanobot/pkg/cli"
 	"github.com/nanobot-ai/nanobot/pkg/cmd"
+	"github.com/nanobot-ai/nanobot/pkg/mcp/sandbox"
 	"github.com/nanobot-ai/nanobot/pkg/supervise"
 )
 
@@ -15,5 +17,19 @@ func main() {
 		}
 		return
 	}
+	if len(os.Args) > 2 && os.Args[1] == "_rlimit" {
+		if err := supervise.ExecLimited(os.Args[2:]); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "_sandbox" {
+		if err := sandbox.Main(os.Args[2:]); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	cmd.Main(cli.New())
 }
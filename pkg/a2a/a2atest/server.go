@@ -0,0 +1,326 @@
+// Package a2atest provides a local A2A agent for tests.
+package a2atest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/a2a"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+// Handler answers a message sent to the agent. It updates the task of the message through the reply,
+// the task is new or the task that required input if the message continues one. A task that is not
+// finished or waiting for input when the handler returns is completed.
+type Handler func(msg a2a.Message, reply *Reply)
+
+// Server is an A2A agent served over HTTP. The card is served at the well-known paths of its URL and
+// message/send, message/stream, tasks/get and tasks/cancel are answered at the URL. Tasks are
+// handled synchronously, a stream gets all events of the message at once.
+type Server struct {
+	URL  string
+	Card a2a.AgentCard
+
+	handler Handler
+	lock    sync.Mutex
+	tasks   map[string]*a2a.Task
+	// messages are the messages received in order, headers the headers of the last request.
+	messages []a2a.Message
+	headers  http.Header
+}
+
+// NewServer starts an agent with the card that answers messages with handler, the URL of the card is
+// set to the URL of the server. The server is closed when the test ends.
+func NewServer(t testing.TB, card a2a.AgentCard, handler Handler) *Server {
+	t.Helper()
+
+	s := &Server{
+		handler: handler,
+		tasks:   map[string]*a2a.Task{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+a2a.WellKnownPath, s.card)
+	mux.HandleFunc("GET "+a2a.WellKnownCardPath, s.card)
+	mux.HandleFunc("POST /", s.rpc)
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	card.URL = ts.URL
+	s.URL = ts.URL
+	s.Card = card
+	return s
+}
+
+// Messages returns the messages the agent received.
+func (s *Server) Messages() []a2a.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]a2a.Message(nil), s.messages...)
+}
+
+// Headers returns the headers of the last request to the agent.
+func (s *Server) Headers() http.Header {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.headers.Clone()
+}
+
+func (s *Server) card(rw http.ResponseWriter, req *http.Request) {
+	s.lock.Lock()
+	s.headers = req.Header.Clone()
+	s.lock.Unlock()
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(s.Card)
+}
+
+func (s *Server) rpc(rw http.ResponseWriter, req *http.Request) {
+	var msg mcp.Message
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	s.headers = req.Header.Clone()
+	s.lock.Unlock()
+
+	switch msg.Method {
+	case a2a.MethodMessageSend:
+		reply, err := s.send(msg.Params)
+		if err != nil {
+			writeResponse(rw, msg.ID, nil, err)
+			return
+		}
+		writeResponse(rw, msg.ID, reply.task, nil)
+	case a2a.MethodMessageStream:
+		reply, err := s.send(msg.Params)
+		if err != nil {
+			writeResponse(rw, msg.ID, nil, err)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range reply.events {
+			data, _ := json.Marshal(response(msg.ID, event, nil))
+			_, _ = fmt.Fprintf(rw, "data: %s\n\n", data)
+		}
+	case a2a.MethodTasksGet:
+		var params a2a.TaskQueryParams
+		_ = json.Unmarshal(msg.Params, &params)
+		task, err := s.task(params.ID)
+		writeResponse(rw, msg.ID, task, err)
+	case a2a.MethodTasksCancel:
+		var params a2a.TaskIDParams
+		_ = json.Unmarshal(msg.Params, &params)
+		task, err := s.cancel(params.ID)
+		writeResponse(rw, msg.ID, task, err)
+	default:
+		writeResponse(rw, msg.ID, nil, mcp.ErrRPCMethodNotFound.WithMessage("%s", msg.Method))
+	}
+}
+
+func (s *Server) task(id string) (*a2a.Task, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil, a2a.ErrTaskNotFound.WithMessage("%s", id)
+	}
+	copied := *task
+	return &copied, nil
+}
+
+func (s *Server) cancel(id string) (*a2a.Task, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil, a2a.ErrTaskNotFound.WithMessage("%s", id)
+	}
+	if a2a.IsFinalState(task.Status.State) {
+		return nil, a2a.ErrTaskNotCancelable.WithMessage("%s", id)
+	}
+	task.Status = a2a.TaskStatus{State: a2a.TaskStateCanceled, Timestamp: now()}
+	copied := *task
+	return &copied, nil
+}
+
+func (s *Server) send(data json.RawMessage) (*Reply, error) {
+	var params a2a.MessageSendParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, mcp.ErrRPCInvalidParams.WithError(err)
+	}
+	msg := params.Message
+
+	s.lock.Lock()
+	s.messages = append(s.messages, msg)
+	task, ok := s.tasks[msg.TaskID]
+	switch {
+	case msg.TaskID != "" && !ok:
+		s.lock.Unlock()
+		return nil, a2a.ErrTaskNotFound.WithMessage("%s", msg.TaskID)
+	case ok && task.Status.State != a2a.TaskStateInputRequired:
+		s.lock.Unlock()
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("task %s does not require input", msg.TaskID)
+	case !ok:
+		contextID := msg.ContextID
+		if contextID == "" {
+			contextID = uuid.String()
+		}
+		task = &a2a.Task{
+			Kind:      "task",
+			ID:        uuid.String(),
+			ContextID: contextID,
+			Status:    a2a.TaskStatus{State: a2a.TaskStateSubmitted, Timestamp: now()},
+		}
+		s.tasks[task.ID] = task
+	}
+	msg.TaskID, msg.ContextID = task.ID, task.ContextID
+	task.History = append(task.History, msg)
+	snapshot := *task
+	s.lock.Unlock()
+
+	reply := &Reply{task: &snapshot}
+	if !ok {
+		reply.events = append(reply.events, snapshot)
+	}
+	reply.setStatus(a2a.TaskStateWorking, nil)
+	s.handler(msg, reply)
+	if state := reply.task.Status.State; !a2a.IsFinalState(state) && state != a2a.TaskStateInputRequired {
+		reply.setStatus(a2a.TaskStateCompleted, nil)
+	}
+
+	s.lock.Lock()
+	s.tasks[task.ID] = reply.task
+	s.lock.Unlock()
+	return reply, nil
+}
+
+// Reply updates the task of a message, the updates are sent as events to a stream.
+type Reply struct {
+	task   *a2a.Task
+	events []any
+}
+
+// Task returns the task of the message.
+func (r *Reply) Task() a2a.Task {
+	return *r.task
+}
+
+func (r *Reply) setStatus(state string, msg *a2a.Message) {
+	r.task.Status = a2a.TaskStatus{State: state, Message: msg, Timestamp: now()}
+	if msg != nil {
+		r.task.History = append(r.task.History, *msg)
+	}
+	r.events = append(r.events, a2a.TaskStatusUpdateEvent{
+		Kind:      "status-update",
+		TaskID:    r.task.ID,
+		ContextID: r.task.ContextID,
+		Status:    r.task.Status,
+		Final:     a2a.IsFinalState(state) || state == a2a.TaskStateInputRequired,
+	})
+}
+
+func (r *Reply) message(parts []a2a.Part) *a2a.Message {
+	return &a2a.Message{
+		Kind:      "message",
+		MessageID: uuid.String(),
+		Role:      "agent",
+		ContextID: r.task.ContextID,
+		TaskID:    r.task.ID,
+		Parts:     parts,
+	}
+}
+
+// Working sends a status update with the text while the agent works on the task.
+func (r *Reply) Working(text string) {
+	r.setStatus(a2a.TaskStateWorking, r.message([]a2a.Part{{Kind: "text", Text: text}}))
+}
+
+// Stream adds a chunk of text to the response artifact of the task.
+func (r *Reply) Stream(text string) {
+	update := a2a.TaskArtifactUpdateEvent{
+		Kind:      "artifact-update",
+		TaskID:    r.task.ID,
+		ContextID: r.task.ContextID,
+		Artifact: a2a.Artifact{
+			ArtifactID: "response",
+			Parts:      []a2a.Part{{Kind: "text", Text: text}},
+		},
+	}
+	if len(r.task.Artifacts) > 0 && r.task.Artifacts[len(r.task.Artifacts)-1].ArtifactID == "response" {
+		update.Append = true
+		last := &r.task.Artifacts[len(r.task.Artifacts)-1]
+		last.Parts[0].Text += text
+	} else {
+		r.task.Artifacts = append(r.task.Artifacts, a2a.Artifact{
+			ArtifactID: "response",
+			Parts:      []a2a.Part{{Kind: "text", Text: text}},
+		})
+	}
+	r.events = append(r.events, update)
+}
+
+// Artifact adds an artifact with the parts to the task.
+func (r *Reply) Artifact(parts ...a2a.Part) {
+	artifact := a2a.Artifact{
+		ArtifactID: uuid.String(),
+		Parts:      parts,
+	}
+	r.task.Artifacts = append(r.task.Artifacts, artifact)
+	r.events = append(r.events, a2a.TaskArtifactUpdateEvent{
+		Kind:      "artifact-update",
+		TaskID:    r.task.ID,
+		ContextID: r.task.ContextID,
+		Artifact:  artifact,
+		LastChunk: true,
+	})
+}
+
+// InputRequired asks the client for input with a message of the parts, the next message of the
+// client continues the task.
+func (r *Reply) InputRequired(parts ...a2a.Part) {
+	r.setStatus(a2a.TaskStateInputRequired, r.message(parts))
+}
+
+// Fail fails the task with the text as its status message.
+func (r *Reply) Fail(text string) {
+	r.setStatus(a2a.TaskStateFailed, r.message([]a2a.Part{{Kind: "text", Text: text}}))
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func response(id, result any, err error) mcp.Message {
+	msg := mcp.Message{
+		JSONRPC: "2.0",
+		ID:      id,
+	}
+	if err != nil {
+		rpcErr, ok := errors.AsType[*mcp.RPCError](err)
+		if !ok {
+			rpcErr = mcp.ErrRPCInternal.WithError(err)
+		}
+		msg.Error = rpcErr
+		return msg
+	}
+	msg.Result, _ = json.Marshal(result)
+	return msg
+}
+
+func writeResponse(rw http.ResponseWriter, id, result any, err error) {
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(response(id, result, err))
+}
@@ -0,0 +1,305 @@
+package a2a
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+// Client calls the JSON-RPC endpoint of a remote A2A agent.
+type Client struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the agent at url, the headers are sent with every request.
+func NewClient(url string, headers map[string]string) *Client {
+	return &Client{
+		url:        url,
+		headers:    headers,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// FetchCard fetches the agent card at cardURL. If cardURL is not the URL of a JSON document, the card
+// is looked up at the well-known paths below it. A card without a URL gets the URL it was found at.
+func FetchCard(ctx context.Context, cardURL string, headers map[string]string) (*AgentCard, error) {
+	u, err := url.Parse(cardURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid agent card URL %q", cardURL)
+	}
+
+	candidates := []string{cardURL}
+	if !strings.HasSuffix(u.Path, ".json") {
+		base := strings.TrimSuffix(cardURL, "/")
+		candidates = []string{base + WellKnownCardPath, base + WellKnownPath}
+	}
+
+	var errs []error
+	for _, candidate := range candidates {
+		card, err := fetchCard(ctx, candidate, headers)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if card.URL == "" {
+			card.URL = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(candidate, WellKnownCardPath), WellKnownPath), "/")
+		}
+		return card, nil
+	}
+	return nil, fmt.Errorf("failed to fetch agent card from %s: %w", cardURL, errors.Join(errs...))
+}
+
+func fetchCard(ctx context.Context, cardURL string, headers map[string]string) (*AgentCard, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cardURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, cardURL)
+	}
+
+	var card AgentCard
+	if err := json.NewDecoder(resp.Body).Decode(&card); err != nil {
+		return nil, fmt.Errorf("failed to decode agent card from %s: %w", cardURL, err)
+	}
+	return &card, nil
+}
+
+// Event is a result of message/stream or tasks/resubscribe, Kind selects the field that is set.
+type Event struct {
+	Kind           string
+	Task           *Task
+	Message        *Message
+	StatusUpdate   *TaskStatusUpdateEvent
+	ArtifactUpdate *TaskArtifactUpdateEvent
+}
+
+func decodeEvent(data json.RawMessage) (Event, error) {
+	var (
+		header struct {
+			Kind string `json:"kind"`
+		}
+		event Event
+		err   error
+	)
+	if err := json.Unmarshal(data, &header); err != nil {
+		return event, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	event.Kind = header.Kind
+	switch header.Kind {
+	case "task":
+		event.Task = new(Task)
+		err = json.Unmarshal(data, event.Task)
+	case "message":
+		event.Message = new(Message)
+		err = json.Unmarshal(data, event.Message)
+	case "status-update":
+		event.StatusUpdate = new(TaskStatusUpdateEvent)
+		err = json.Unmarshal(data, event.StatusUpdate)
+	case "artifact-update":
+		event.ArtifactUpdate = new(TaskArtifactUpdateEvent)
+		err = json.Unmarshal(data, event.ArtifactUpdate)
+	default:
+		return event, fmt.Errorf("unknown event kind %q", header.Kind)
+	}
+	if err != nil {
+		return event, fmt.Errorf("failed to decode %s event: %w", header.Kind, err)
+	}
+	return event, nil
+}
+
+// Send sends a message and returns the task it started or continued. An agent that answers with a
+// message instead of a task is returned as a completed task with the message as its status.
+func (c *Client) Send(ctx context.Context, params MessageSendParams) (*Task, error) {
+	var result json.RawMessage
+	if err := c.call(ctx, MethodMessageSend, params, &result); err != nil {
+		return nil, err
+	}
+
+	event, err := decodeEvent(result)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case event.Task != nil:
+		return event.Task, nil
+	case event.Message != nil:
+		return messageTask(*event.Message), nil
+	}
+	return nil, fmt.Errorf("unexpected %s result of %s", event.Kind, MethodMessageSend)
+}
+
+// Stream sends a message and calls onEvent with the events of the task until the stream ends.
+func (c *Client) Stream(ctx context.Context, params MessageSendParams, onEvent func(Event) error) error {
+	return c.stream(ctx, MethodMessageStream, params, onEvent)
+}
+
+// Resubscribe calls onEvent with the events of a running task until the stream ends.
+func (c *Client) Resubscribe(ctx context.Context, taskID string, onEvent func(Event) error) error {
+	return c.stream(ctx, MethodTasksResubscribe, TaskIDParams{ID: taskID}, onEvent)
+}
+
+func (c *Client) Get(ctx context.Context, taskID string) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, MethodTasksGet, TaskQueryParams{ID: taskID}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) Cancel(ctx context.Context, taskID string) (*Task, error) {
+	var task Task
+	if err := c.call(ctx, MethodTasksCancel, TaskIDParams{ID: taskID}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func messageTask(msg Message) *Task {
+	return &Task{
+		Kind:      "task",
+		ID:        msg.TaskID,
+		ContextID: msg.ContextID,
+		Status: TaskStatus{
+			State:   TaskStateCompleted,
+			Message: &msg,
+		},
+	}
+}
+
+func (c *Client) request(ctx context.Context, method string, params any, accept string) (*http.Response, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal params: %w", err)
+	}
+	body, err := json.Marshal(mcp.Message{
+		JSONRPC: "2.0",
+		ID:      uuid.String(),
+		Method:  method,
+		Params:  data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("failed to call %s: unexpected status %s: %s", method, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	resp, err := c.request(ctx, method, params, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var msg mcp.Message
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", method, err)
+	}
+	return decodeResult(method, msg, out)
+}
+
+func decodeResult(method string, msg mcp.Message, out any) error {
+	if msg.Error != nil {
+		return fmt.Errorf("failed to call %s: %w", method, msg.Error)
+	}
+	if err := json.Unmarshal(msg.Result, out); err != nil {
+		return fmt.Errorf("failed to decode result of %s: %w", method, err)
+	}
+	return nil
+}
+
+// stream reads the server-sent events of a streaming method, each event is a JSON-RPC response.
+func (c *Client) stream(ctx context.Context, method string, params any, onEvent func(Event) error) error {
+	resp, err := c.request(ctx, method, params, "text/event-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var (
+		reader = bufio.NewReader(resp.Body)
+		data   []string
+	)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("failed to read stream of %s: %w", method, readErr)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+		if (line == "" || readErr != nil) && len(data) > 0 {
+			if err := dispatchEvent(method, strings.Join(data, "\n"), onEvent); err != nil {
+				return err
+			}
+			data = data[:0]
+		}
+		if readErr != nil {
+			return nil
+		}
+	}
+}
+
+func dispatchEvent(method, data string, onEvent func(Event) error) error {
+	var (
+		msg    mcp.Message
+		result json.RawMessage
+	)
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return fmt.Errorf("failed to decode event of %s: %w", method, err)
+	}
+	if err := decodeResult(method, msg, &result); err != nil {
+		return err
+	}
+	event, err := decodeEvent(result)
+	if err != nil {
+		return err
+	}
+	return onEvent(event)
+}
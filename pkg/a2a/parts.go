@@ -0,0 +1,203 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+// toChatRequest converts a message to the arguments of a chat tool. Text parts become the prompt,
+// data parts are added to the prompt as JSON and file parts become attachments.
+func toChatRequest(msg Message) (types.SampleCallRequest, error) {
+	var (
+		result types.SampleCallRequest
+		prompt []string
+	)
+	for _, part := range msg.Parts {
+		switch part.Kind {
+		case "text":
+			prompt = append(prompt, part.Text)
+		case "data":
+			data, err := json.Marshal(part.Data)
+			if err != nil {
+				return result, fmt.Errorf("failed to marshal data part: %w", err)
+			}
+			prompt = append(prompt, string(data))
+		case "file":
+			if part.File == nil {
+				continue
+			}
+			url := part.File.URI
+			if part.File.Bytes != "" {
+				mimeType := part.File.MimeType
+				if mimeType == "" {
+					mimeType = "application/octet-stream"
+				}
+				url = fmt.Sprintf("data:%s;base64,%s", mimeType, part.File.Bytes)
+			}
+			if url == "" {
+				continue
+			}
+			result.Attachments = append(result.Attachments, types.Attachment{
+				Name:     part.File.Name,
+				URL:      url,
+				MimeType: part.File.MimeType,
+			})
+		default:
+			return result, ErrContentTypeNotSupported.WithMessage("part kind %q", part.Kind)
+		}
+	}
+	result.Prompt = strings.Join(prompt, "\n\n")
+	if result.Prompt == "" && len(result.Attachments) == 0 {
+		return result, mcp.ErrRPCInvalidParams.WithMessage("message has no content")
+	}
+	return result, nil
+}
+
+// toParts converts the result of a chat tool to the parts of an artifact.
+func toParts(result *mcp.CallToolResult) (parts []Part) {
+	for _, content := range result.Content {
+		switch content.Type {
+		case "text":
+			parts = append(parts, Part{Kind: "text", Text: content.Text})
+		case "image", "audio":
+			parts = append(parts, Part{Kind: "file", File: &FileContent{
+				MimeType: content.MIMEType,
+				Bytes:    content.Data,
+			}})
+		case "resource_link":
+			parts = append(parts, Part{Kind: "file", File: &FileContent{
+				Name:     content.Name,
+				MimeType: content.MIMEType,
+				URI:      content.URI,
+			}})
+		case "resource":
+			if content.Resource == nil {
+				continue
+			}
+			if content.Resource.Blob == "" {
+				parts = append(parts, Part{Kind: "text", Text: content.Resource.Text})
+				continue
+			}
+			parts = append(parts, Part{Kind: "file", File: &FileContent{
+				Name:     content.Resource.Name,
+				MimeType: content.Resource.MIMEType,
+				Bytes:    content.Resource.Blob,
+			}})
+		}
+	}
+	if result.StructuredContent != nil {
+		parts = append(parts, Part{Kind: "data", Data: result.StructuredContent})
+	}
+	return parts
+}
+
+func textMessage(role, contextID, taskID, text string) *Message {
+	return &Message{
+		Kind:      "message",
+		MessageID: uuid.String(),
+		Role:      role,
+		ContextID: contextID,
+		TaskID:    taskID,
+		Parts: []Part{
+			{Kind: "text", Text: text},
+		},
+	}
+}
+
+// elicitationMessage asks the client for the input of an elicitation, the requested schema is sent
+// as a data part.
+func elicitationMessage(contextID, taskID string, req mcp.ElicitRequest) *Message {
+	text := req.Message
+	if req.URL != "" {
+		text = strings.TrimSpace(text + "\n\n" + req.URL)
+	}
+	msg := textMessage("agent", contextID, taskID, text)
+	if len(req.RequestedSchema.Properties) > 0 {
+		msg.Parts = append(msg.Parts, Part{Kind: "data", Data: map[string]any{
+			"requestedSchema": req.RequestedSchema,
+		}})
+	}
+	return msg
+}
+
+// elicitationResult converts the answer of the client to the result of an elicitation. A data part
+// is the content of the answer, or the full result if it has an action. A text answer fills the
+// only property of the requested schema, or accepts a request without properties.
+func elicitationResult(req mcp.ElicitRequest, msg Message) (mcp.ElicitResult, error) {
+	var texts []string
+	for _, part := range msg.Parts {
+		switch part.Kind {
+		case "data":
+			if _, ok := part.Data["action"].(string); ok {
+				var result mcp.ElicitResult
+				if err := mcp.JSONCoerce(part.Data, &result); err != nil {
+					return result, mcp.ErrRPCInvalidParams.WithError(err)
+				}
+				return result, validateAction(result.Action)
+			}
+			return mcp.ElicitResult{Action: "accept", Content: part.Data}, nil
+		case "text":
+			texts = append(texts, part.Text)
+		}
+	}
+
+	text := strings.TrimSpace(strings.Join(texts, "\n"))
+	switch strings.ToLower(text) {
+	case "decline", "cancel":
+		return mcp.ElicitResult{Action: strings.ToLower(text)}, nil
+	}
+
+	properties := req.RequestedSchema.Properties
+	switch len(properties) {
+	case 0:
+		return mcp.ElicitResult{Action: "accept"}, nil
+	case 1:
+		name := slices.Collect(maps.Keys(properties))[0]
+		value, err := coerceText(properties[name], text)
+		if err != nil {
+			return mcp.ElicitResult{}, mcp.ErrRPCInvalidParams.WithMessage("invalid value for %s: %v", name, err)
+		}
+		return mcp.ElicitResult{Action: "accept", Content: map[string]any{name: value}}, nil
+	}
+
+	var content map[string]any
+	if err := json.Unmarshal([]byte(text), &content); err != nil {
+		return mcp.ElicitResult{}, mcp.ErrRPCInvalidParams.WithMessage(
+			"the requested input has several fields, answer with a data part matching the requested schema")
+	}
+	return mcp.ElicitResult{Action: "accept", Content: content}, nil
+}
+
+func validateAction(action string) error {
+	switch action {
+	case "accept", "decline", "cancel":
+		return nil
+	}
+	return mcp.ErrRPCInvalidParams.WithMessage("invalid action %q, must be accept, decline or cancel", action)
+}
+
+func coerceText(property mcp.PrimitiveProperty, text string) (any, error) {
+	switch property.Type {
+	case "number":
+		return strconv.ParseFloat(text, 64)
+	case "integer":
+		return strconv.ParseInt(text, 10, 64)
+	case "boolean":
+		switch strings.ToLower(text) {
+		case "yes", "y":
+			return true, nil
+		case "no", "n":
+			return false, nil
+		}
+		return strconv.ParseBool(text)
+	}
+	return text, nil
+}
@@ -0,0 +1,149 @@
+package a2a
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+const pushTimeout = 10 * time.Second
+
+// nonPublicPrefixes are the ranges that are not public, besides the ones netip.Addr reports.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+func validatePushConfig(config *PushNotificationConfig) error {
+	if config == nil {
+		return nil
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return mcp.ErrRPCInvalidParams.WithMessage("invalid push notification URL %q", config.URL)
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !isPublic(ip) {
+		return mcp.ErrRPCInvalidParams.WithMessage("push notification URL %q is not a public address", config.URL)
+	}
+	return nil
+}
+
+// isPublic reports whether ip is a public unicast address, not a loopback, private, link-local or
+// shared one.
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// pushTransport only connects to public addresses. The address is checked when connecting, after
+// DNS resolution, so that push notifications can't reach the network nanobot runs in through a host
+// name that resolves to a private address or a redirect either.
+func pushTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: pushTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !isPublic(ip) {
+				return fmt.Errorf("push notifications to %s are not allowed, it is not a public address", ip)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: pushTimeout,
+	}
+}
+
+// notify queues the task to be pushed to the push notification URL of the task, if it has one. The
+// notifications of a task are sent one at a time, in order.
+func (s *server) notify(t *task, snapshot Task) {
+	t.lock.Lock()
+	if t.pushConfig == nil {
+		t.lock.Unlock()
+		return
+	}
+	t.pending = append(t.pending, snapshot)
+	if t.pushing {
+		t.lock.Unlock()
+		return
+	}
+	t.pushing = true
+	t.lock.Unlock()
+
+	go func() {
+		for {
+			t.lock.Lock()
+			if len(t.pending) == 0 {
+				t.pushing = false
+				t.lock.Unlock()
+				return
+			}
+			next, config := t.pending[0], *t.pushConfig
+			t.pending = t.pending[1:]
+			t.lock.Unlock()
+
+			if err := s.push(config, next); err != nil {
+				slog.Warn("a2a: failed to send push notification", "task_id", next.ID, "url", config.URL, "error", err)
+			}
+		}
+	}()
+}
+
+func (s *server) push(config PushNotificationConfig, task Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if config.Token != "" {
+		req.Header.Set(NotificationTokenHeader, config.Token)
+	}
+	if auth := config.Authentication; auth != nil && auth.Credentials != "" && len(auth.Schemes) > 0 {
+		req.Header.Set("Authorization", auth.Schemes[0]+" "+auth.Credentials)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
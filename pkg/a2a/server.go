@@ -0,0 +1,625 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/sessiondata"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"github.com/nanobot-ai/nanobot/pkg/version"
+)
+
+var defaultModes = []string{"text/plain", "application/json"}
+
+type server struct {
+	config         types.ConfigFactory
+	sessionManager *session.Manager
+	chatURL        string
+	httpClient     *http.Client
+	tasks          taskRegistry
+}
+
+// Handler serves the agents of the configuration that are published over A2A. Each agent has a card
+// at /a2a/{agent}/.well-known/agent.json and a JSON-RPC endpoint at /a2a/{agent}, the card of the
+// first agent is also served at /.well-known/agent.json. Messages are sent to the chat tool of the
+// agent through the MCP server at address, with the credentials of the caller, and the context ID
+// of a task is the ID of its thread.
+//
+// Tasks are kept in memory, they are only found on the replica that runs them.
+func Handler(config types.ConfigFactory, sessionManager *session.Manager, address string) http.Handler {
+	address = strings.ReplaceAll(address, "0.0.0.0", "localhost")
+
+	s := &server{
+		config:         config,
+		sessionManager: sessionManager,
+		chatURL:        fmt.Sprintf("http://%s/mcp/chat", address),
+		httpClient: &http.Client{
+			Timeout:   pushTimeout,
+			Transport: pushTransport(),
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+WellKnownPath, s.defaultCard)
+	mux.HandleFunc("GET "+WellKnownCardPath, s.defaultCard)
+	mux.HandleFunc("GET /a2a/{agent}"+WellKnownPath, s.agentCard)
+	mux.HandleFunc("GET /a2a/{agent}"+WellKnownCardPath, s.agentCard)
+	mux.HandleFunc("POST /a2a/{agent}", s.rpc)
+	return mux
+}
+
+func (s *server) loadConfig(req *http.Request) (types.Config, error) {
+	if nctx := types.NanobotContext(req.Context()); nctx.Config != nil {
+		return nctx.Config(req.Context(), strings.Join(nctx.Profile, ","))
+	}
+	return s.config(req.Context(), "")
+}
+
+func (s *server) defaultCard(rw http.ResponseWriter, req *http.Request) {
+	c, err := s.loadConfig(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	agents := c.A2AAgents()
+	if len(agents) == 0 {
+		http.NotFound(rw, req)
+		return
+	}
+	writeJSON(rw, card(c, agents[0], agentURL(req, agents[0])))
+}
+
+func (s *server) agentCard(rw http.ResponseWriter, req *http.Request) {
+	c, err := s.loadConfig(req)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	agent := req.PathValue("agent")
+	if !slices.Contains(c.A2AAgents(), agent) {
+		http.NotFound(rw, req)
+		return
+	}
+	writeJSON(rw, card(c, agent, agentURL(req, agent)))
+}
+
+func writeJSON(rw http.ResponseWriter, obj any) {
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(obj)
+}
+
+func agentURL(req *http.Request, agent string) string {
+	u, err := url.Parse(sessiondata.GetHostURL(req))
+	if err != nil {
+		u = &url.URL{Scheme: "http", Host: req.Host}
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/a2a/" + agent}).String()
+}
+
+// card describes an agent as an A2A agent with a single skill, chatting with the agent.
+func card(c types.Config, name, url string) AgentCard {
+	agent := c.Agents[name]
+	display := agent.ToDisplay(name)
+	description := display.Description
+	if description == "" {
+		description = display.Name
+	}
+
+	cardVersion := c.Publish.Version
+	if cardVersion == "" {
+		cardVersion = version.Get().Tag
+	}
+
+	result := AgentCard{
+		ProtocolVersion:    ProtocolVersion,
+		Name:               display.Name,
+		Description:        description,
+		URL:                url,
+		PreferredTransport: "JSONRPC",
+		Version:            cardVersion,
+		Capabilities: AgentCapabilities{
+			Streaming:         true,
+			PushNotifications: true,
+		},
+		DefaultInputModes:  slices.Concat(defaultModes, agent.MimeTypes),
+		DefaultOutputModes: defaultModes,
+		Skills: []AgentSkill{
+			{
+				ID:          name,
+				Name:        display.Name,
+				Description: description,
+				Tags:        []string{"chat"},
+				Examples:    display.StarterMessages,
+			},
+		},
+	}
+	if strings.HasPrefix(agent.Icon, "https://") || strings.HasPrefix(agent.Icon, "http://") {
+		result.IconURL = agent.Icon
+	}
+	return result
+}
+
+func (s *server) rpc(rw http.ResponseWriter, req *http.Request) {
+	var msg mcp.Message
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		writeResponse(rw, nil, nil, mcp.ErrRPCParse.WithError(err))
+		return
+	}
+
+	c, err := s.loadConfig(req)
+	if err != nil {
+		writeResponse(rw, msg.ID, nil, err)
+		return
+	}
+	agent := req.PathValue("agent")
+	if !slices.Contains(c.A2AAgents(), agent) {
+		http.NotFound(rw, req)
+		return
+	}
+
+	var result any
+	switch msg.Method {
+	case MethodMessageSend, MethodTasksSend:
+		result, err = s.send(req, agent, msg.Params)
+	case MethodMessageStream, MethodTasksSendSubscribe:
+		err = s.sendStream(rw, req, msg, agent)
+	case MethodTasksResubscribe:
+		err = s.resubscribe(rw, req, msg)
+	case MethodTasksGet:
+		result, err = s.get(req, msg.Params)
+	case MethodTasksCancel:
+		result, err = s.cancel(req, msg.Params)
+	case MethodPushConfigSet:
+		result, err = s.setPushConfig(req, msg.Params)
+	case MethodPushConfigGet:
+		result, err = s.getPushConfig(req, msg.Params)
+	default:
+		err = mcp.ErrRPCMethodNotFound.WithMessage("%s", msg.Method)
+	}
+	if result != nil || err != nil {
+		writeResponse(rw, msg.ID, result, err)
+	}
+}
+
+func rpcError(err error) *mcp.RPCError {
+	if rpcErr, ok := errors.AsType[*mcp.RPCError](err); ok {
+		return rpcErr
+	}
+	return mcp.ErrRPCInternal.WithError(err)
+}
+
+func response(id, result any, err error) mcp.Message {
+	resp := mcp.Message{
+		JSONRPC: "2.0",
+		ID:      id,
+	}
+	if err != nil {
+		resp.Error = rpcError(err)
+		return resp
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = mcp.ErrRPCInternal.WithError(err)
+		return resp
+	}
+	resp.Result = data
+	return resp
+}
+
+func writeResponse(rw http.ResponseWriter, id, result any, err error) {
+	writeJSON(rw, response(id, result, err))
+}
+
+func unmarshalParams(data json.RawMessage, out any) error {
+	if err := json.Unmarshal(data, out); err != nil {
+		return mcp.ErrRPCInvalidParams.WithError(err)
+	}
+	return nil
+}
+
+func accountID(req *http.Request) string {
+	return types.NanobotContext(req.Context()).User.ID
+}
+
+// dispatch starts a task for the message of params, or answers the task the message is for if it
+// waits for input. The task and the number of its events before the message are returned.
+func (s *server) dispatch(req *http.Request, agent string, params MessageSendParams) (*task, int, error) {
+	if err := validatePushConfig(params.Configuration.pushConfig()); err != nil {
+		return nil, 0, err
+	}
+	if params.Message.TaskID != "" {
+		return s.answer(req, params)
+	}
+
+	t, err := s.start(req, agent, params)
+	return t, 0, err
+}
+
+func (s *server) send(req *http.Request, agent string, data json.RawMessage) (any, error) {
+	var params MessageSendParams
+	if err := unmarshalParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	t, _, err := s.dispatch(req, agent, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Configuration == nil || params.Configuration.Blocking == nil || *params.Configuration.Blocking {
+		t.wait(req.Context())
+	}
+
+	result, _ := t.snapshot(params.Configuration.historyLength())
+	return result, nil
+}
+
+func (s *server) sendStream(rw http.ResponseWriter, req *http.Request, msg mcp.Message, agent string) error {
+	var params MessageSendParams
+	if err := unmarshalParams(msg.Params, &params); err != nil {
+		return err
+	}
+
+	t, from, err := s.dispatch(req, agent, params)
+	if err != nil {
+		return err
+	}
+
+	s.stream(rw, req, msg.ID, t, from, params.Configuration.historyLength())
+	return nil
+}
+
+func (s *server) resubscribe(rw http.ResponseWriter, req *http.Request, msg mcp.Message) error {
+	var params TaskQueryParams
+	if err := unmarshalParams(msg.Params, &params); err != nil {
+		return err
+	}
+
+	t, err := s.tasks.get(params.ID, accountID(req))
+	if err != nil {
+		return err
+	}
+
+	_, from := t.snapshot(nil)
+	s.stream(rw, req, msg.ID, t, from, params.HistoryLength)
+	return nil
+}
+
+// stream writes the task and then its events from the event at index from, as server-sent events,
+// until the task is finished or requires input.
+func (s *server) stream(rw http.ResponseWriter, req *http.Request, id any, t *task, from int, historyLength *int) {
+	flusher, _ := rw.(http.Flusher)
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+
+	write := func(event any) bool {
+		data, err := json.Marshal(response(id, event, nil))
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(rw, "data: %s\n\n", data); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	snapshot, _ := t.snapshot(historyLength)
+	if !write(snapshot) {
+		return
+	}
+
+	for {
+		t.lock.Lock()
+		events, changed := t.events[from:], t.changed
+		from = len(t.events)
+		t.lock.Unlock()
+
+		for _, event := range events {
+			if !write(event) {
+				return
+			}
+			if status, ok := event.(TaskStatusUpdateEvent); ok && status.Final {
+				return
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *server) get(req *http.Request, data json.RawMessage) (any, error) {
+	var params TaskQueryParams
+	if err := unmarshalParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	t, err := s.tasks.get(params.ID, accountID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	result, _ := t.snapshot(params.HistoryLength)
+	return result, nil
+}
+
+func (s *server) cancel(req *http.Request, data json.RawMessage) (any, error) {
+	var params TaskIDParams
+	if err := unmarshalParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	t, err := s.tasks.get(params.ID, accountID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, changed := t.setStatus(TaskStateCanceled, nil)
+	if !changed {
+		return nil, ErrTaskNotCancelable.WithMessage("%s", params.ID)
+	}
+	t.stop()
+	s.notify(t, snapshot)
+
+	result, _ := t.snapshot(nil)
+	return result, nil
+}
+
+func (s *server) setPushConfig(req *http.Request, data json.RawMessage) (any, error) {
+	var params TaskPushNotificationConfig
+	if err := unmarshalParams(data, &params); err != nil {
+		return nil, err
+	}
+	if err := validatePushConfig(&params.PushNotificationConfig); err != nil {
+		return nil, err
+	}
+
+	t, err := s.tasks.get(params.TaskID, accountID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	t.pushConfig = &params.PushNotificationConfig
+	t.lock.Unlock()
+	return params, nil
+}
+
+func (s *server) getPushConfig(req *http.Request, data json.RawMessage) (any, error) {
+	var params TaskIDParams
+	if err := unmarshalParams(data, &params); err != nil {
+		return nil, err
+	}
+
+	t, err := s.tasks.get(params.ID, accountID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.pushConfig == nil {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("task %s has no push notification config", params.ID)
+	}
+	return TaskPushNotificationConfig{
+		TaskID:                 params.ID,
+		PushNotificationConfig: *t.pushConfig,
+	}, nil
+}
+
+// chatServer is the MCP server of the chat tools, called with the credentials of the caller.
+func (s *server) chatServer(req *http.Request) mcp.Server {
+	return mcp.Server{
+		BaseURL: s.chatURL,
+		Headers: map[string]string{
+			"User-Agent":    req.Header.Get("User-Agent"),
+			"Authorization": req.Header.Get("Authorization"),
+			"Cookie":        req.Header.Get("Cookie"),
+		},
+	}
+}
+
+// start runs the message in a new task. The task continues the thread of the context ID of the
+// message, or starts a new thread if it has none.
+func (s *server) start(req *http.Request, agent string, params MessageSendParams) (*task, error) {
+	chatRequest, err := toChatRequest(params.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	var state *mcp.SessionState
+	if contextID := params.Message.ContextID; contextID != "" {
+		if s.sessionManager.DB != nil {
+			if _, err := s.sessionManager.DB.GetByIDByAccountID(req.Context(), contextID, accountID(req)); err != nil {
+				return nil, mcp.ErrRPCInvalidParams.WithMessage("context %s not found", contextID)
+			}
+		}
+		state = &mcp.SessionState{
+			ID: contextID,
+			InitializeRequest: mcp.InitializeRequest{
+				Capabilities: mcp.ClientCapabilities{
+					Elicitation: &struct{}{},
+				},
+			},
+		}
+	}
+
+	// The task outlives the request unless the client waits for it.
+	ctx, stop := context.WithCancel(context.WithoutCancel(req.Context()))
+	t := newTask(ctx, stop, accountID(req), agent)
+
+	client, err := mcp.NewClient(ctx, "nanobot.a2a", s.chatServer(req), mcp.ClientOption{
+		ClientName:   "nanobot-a2a",
+		SessionState: state,
+		OnNotify: func(_ context.Context, msg mcp.Message) error {
+			t.progress(msg)
+			return nil
+		},
+		OnElicit: func(ctx context.Context, _ mcp.Message, req mcp.ElicitRequest) (mcp.ElicitResult, error) {
+			return s.elicit(ctx, t, req)
+		},
+	})
+	if err != nil {
+		stop()
+		return nil, fmt.Errorf("failed to connect to agent %s: %w", agent, err)
+	}
+
+	msg := params.Message
+	msg.Kind = "message"
+	msg.TaskID = uuid.String()
+	msg.ContextID = client.Session.ID()
+	if msg.MessageID == "" {
+		msg.MessageID = uuid.String()
+	}
+
+	t.lock.Lock()
+	t.task = Task{
+		Kind:      "task",
+		ID:        msg.TaskID,
+		ContextID: msg.ContextID,
+		Status: TaskStatus{
+			State:     TaskStateSubmitted,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+		History: []Message{msg},
+	}
+	t.pushConfig = params.Configuration.pushConfig()
+	t.lock.Unlock()
+	s.tasks.add(t)
+
+	go s.run(t, client, chatRequest)
+	return t, nil
+}
+
+func (s *server) run(t *task, client *mcp.Client, request types.SampleCallRequest) {
+	defer t.stop()
+	defer client.Close(false)
+
+	s.setStatus(t, TaskStateWorking, nil)
+
+	result, err := client.Call(t.ctx, types.AgentTool+t.agent, request, mcp.CallOption{
+		ProgressToken: uuid.String(),
+	})
+	switch {
+	case t.ctx.Err() != nil:
+		s.setStatus(t, TaskStateCanceled, nil)
+	case err != nil:
+		s.setStatus(t, TaskStateFailed, textMessage("agent", t.task.ContextID, t.task.ID, err.Error()))
+	default:
+		parts := toParts(result)
+		msg := &Message{
+			Kind:      "message",
+			MessageID: uuid.String(),
+			Role:      "agent",
+			ContextID: t.task.ContextID,
+			TaskID:    t.task.ID,
+			Parts:     parts,
+		}
+		if result.IsError {
+			s.setStatus(t, TaskStateFailed, msg)
+			return
+		}
+		t.addArtifact(Artifact{
+			ArtifactID: uuid.String(),
+			Name:       "result",
+			Parts:      parts,
+		})
+		s.setStatus(t, TaskStateCompleted, msg)
+	}
+}
+
+func (s *server) setStatus(t *task, state string, msg *Message) {
+	if snapshot, changed := t.setStatus(state, msg); changed {
+		s.notify(t, snapshot)
+	}
+}
+
+// elicit asks the client of the task for the input of an elicitation and waits for the answer.
+func (s *server) elicit(ctx context.Context, t *task, req mcp.ElicitRequest) (mcp.ElicitResult, error) {
+	answer := make(chan mcp.ElicitResult, 1)
+
+	t.lock.Lock()
+	t.elicit, t.answer = &req, answer
+	t.lock.Unlock()
+
+	s.setStatus(t, TaskStateInputRequired, elicitationMessage(t.task.ContextID, t.task.ID, req))
+
+	select {
+	case result := <-answer:
+		return result, nil
+	case <-ctx.Done():
+		return mcp.ElicitResult{Action: "cancel"}, nil
+	case <-t.ctx.Done():
+		return mcp.ElicitResult{Action: "cancel"}, nil
+	}
+}
+
+// answer sends the message of params to the task it is for as the answer to its elicitation.
+func (s *server) answer(req *http.Request, params MessageSendParams) (*task, int, error) {
+	t, err := s.tasks.get(params.Message.TaskID, accountID(req))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	t.lock.Lock()
+	if t.task.Status.State != TaskStateInputRequired || t.elicit == nil {
+		state := t.task.Status.State
+		t.lock.Unlock()
+		return nil, 0, mcp.ErrRPCInvalidParams.WithMessage("task %s is %s and does not wait for input", t.task.ID, state)
+	}
+	result, err := elicitationResult(*t.elicit, params.Message)
+	if err != nil {
+		t.lock.Unlock()
+		return nil, 0, err
+	}
+
+	msg := params.Message
+	msg.Kind = "message"
+	msg.ContextID = t.task.ContextID
+	if msg.MessageID == "" {
+		msg.MessageID = uuid.String()
+	}
+	t.task.History = append(t.task.History, msg)
+	if config := params.Configuration.pushConfig(); config != nil {
+		t.pushConfig = config
+	}
+	answer := t.answer
+	t.elicit, t.answer = nil, nil
+	from := len(t.events)
+	t.lock.Unlock()
+
+	s.setStatus(t, TaskStateWorking, nil)
+	answer <- result
+	return t, from, nil
+}
+
+func (c *MessageSendConfiguration) pushConfig() *PushNotificationConfig {
+	if c == nil {
+		return nil
+	}
+	return c.PushNotificationConfig
+}
+
+func (c *MessageSendConfiguration) historyLength() *int {
+	if c == nil {
+		return nil
+	}
+	return c.HistoryLength
+}
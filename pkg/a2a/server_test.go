@@ -0,0 +1,311 @@
+package a2a
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// chatServer is an MCP server with the chat tool of the helper agent. It streams its answer, and
+// asks for a name first if the prompt is "ask".
+func chatServer(ctx context.Context, t *testing.T) http.Handler {
+	handler := mcp.MessageHandlerFunc(func(ctx context.Context, msg mcp.Message) {
+		switch msg.Method {
+		case "initialize":
+			_ = msg.Reply(ctx, mcp.InitializeResult{
+				ProtocolVersion: "2025-06-18",
+				ServerInfo:      mcp.ServerInfo{Name: "chat"},
+			})
+		case "tools/call":
+			var call mcp.CallToolRequest
+			_ = json.Unmarshal(msg.Params, &call)
+			if call.Name != types.AgentTool+"helper" {
+				msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%s", call.Name))
+				return
+			}
+
+			for _, text := range []string{"Hel", "lo"} {
+				_ = msg.Session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
+					ProgressToken: msg.ProgressToken(),
+					Meta: map[string]any{
+						types.CompletionProgressMetaKey: types.CompletionProgress{
+							Role: "assistant",
+							Item: types.CompletionItem{ID: "item-1", Partial: true, Content: &mcp.Content{Type: "text", Text: text}},
+						},
+					},
+				})
+			}
+
+			answer := "Hello"
+			if call.Arguments["prompt"] == "ask" {
+				var result mcp.ElicitResult
+				if err := msg.Session.Exchange(ctx, "elicitation/create", mcp.ElicitRequest{
+					Message: "What is your name?",
+					RequestedSchema: mcp.PrimitiveSchema{
+						Type:       "object",
+						Properties: map[string]mcp.PrimitiveProperty{"name": {Type: "string"}},
+					},
+				}, &result); err != nil {
+					msg.SendError(ctx, err)
+					return
+				}
+				answer += " " + result.Content["name"].(string)
+			}
+			_ = msg.Reply(ctx, mcp.CallToolResult{
+				Content: []mcp.Content{{Type: "text", Text: answer}},
+			})
+		}
+	})
+
+	server, err := mcp.NewHTTPServer(ctx, nil, handler, mcp.HTTPServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func testServer(t *testing.T) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp/chat", chatServer(ctx, t))
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	config := func(context.Context, string) (types.Config, error) {
+		return types.Config{
+			Agents: map[string]types.Agent{
+				"helper": {HookAgent: types.HookAgent{
+					Name:            "Helper",
+					Description:     "Says hello",
+					StarterMessages: []string{"Say hello"},
+				}},
+			},
+			Publish: types.Publish{Entrypoint: []string{"helper"}},
+		}, nil
+	}
+	handler := Handler(config, session.NewManager(nil), strings.TrimPrefix(ts.URL, "http://"))
+	mux.Handle("/a2a/", handler)
+	mux.Handle(WellKnownPath, handler)
+	return ts.URL
+}
+
+func call(t *testing.T, url, method string, params, out any) {
+	t.Helper()
+
+	data, _ := json.Marshal(params)
+	body, _ := json.Marshal(mcp.Message{JSONRPC: "2.0", ID: 1, Method: method, Params: data})
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var msg mcp.Message
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Error != nil {
+		t.Fatalf("%s failed: %v", method, msg.Error)
+	}
+	if err := json.Unmarshal(msg.Result, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func userMessage(text, taskID string) Message {
+	return Message{
+		Kind:      "message",
+		MessageID: "msg-" + text,
+		Role:      "user",
+		TaskID:    taskID,
+		Parts:     []Part{{Kind: "text", Text: text}},
+	}
+}
+
+func artifactText(task Task) string {
+	if len(task.Artifacts) != 1 || len(task.Artifacts[0].Parts) != 1 {
+		return ""
+	}
+	return task.Artifacts[0].Parts[0].Text
+}
+
+func TestAgentCard(t *testing.T) {
+	url := testServer(t)
+
+	resp, err := http.Get(url + WellKnownPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var card AgentCard
+	if err := json.NewDecoder(resp.Body).Decode(&card); err != nil {
+		t.Fatal(err)
+	}
+	if card.Name != "Helper" || card.URL != url+"/a2a/helper" || !card.Capabilities.Streaming {
+		t.Fatalf("unexpected card %+v", card)
+	}
+	if len(card.Skills) != 1 || card.Skills[0].ID != "helper" || card.Skills[0].Examples[0] != "Say hello" {
+		t.Fatalf("expected a skill for the agent, got %+v", card.Skills)
+	}
+
+	resp, err = http.Get(url + "/a2a/unknown" + WellKnownPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected agents that are not published to not be found, got %s", resp.Status)
+	}
+}
+
+func TestMessageSend(t *testing.T) {
+	url := testServer(t) + "/a2a/helper"
+
+	var task Task
+	call(t, url, MethodMessageSend, MessageSendParams{Message: userMessage("hi", "")}, &task)
+	if task.Status.State != TaskStateCompleted || artifactText(task) != "Hello" {
+		t.Fatalf("expected a completed task, got %+v", task)
+	}
+	if task.ContextID == "" {
+		t.Fatal("expected the task to have the thread as its context")
+	}
+
+	var got Task
+	call(t, url, MethodTasksGet, TaskQueryParams{ID: task.ID}, &got)
+	if got.Status.State != TaskStateCompleted || len(got.History) != 2 {
+		t.Fatalf("expected the task with its history, got %+v", got)
+	}
+}
+
+func TestMessageStreamInputRequired(t *testing.T) {
+	url := testServer(t) + "/a2a/helper"
+
+	data, _ := json.Marshal(MessageSendParams{Message: userMessage("ask", "")})
+	body, _ := json.Marshal(mcp.Message{JSONRPC: "2.0", ID: 1, Method: MethodMessageStream, Params: data})
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var (
+		taskID   string
+		streamed string
+		status   TaskStatusUpdateEvent
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Result struct {
+				Kind     string   `json:"kind"`
+				ID       string   `json:"id"`
+				Artifact Artifact `json:"artifact"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		switch event.Result.Kind {
+		case "task":
+			taskID = event.Result.ID
+		case "artifact-update":
+			streamed += event.Result.Artifact.Parts[0].Text
+		case "status-update":
+			var msg struct {
+				Result TaskStatusUpdateEvent `json:"result"`
+			}
+			_ = json.Unmarshal([]byte(line), &msg)
+			status = msg.Result
+		}
+	}
+
+	if streamed != "Hello" {
+		t.Fatalf("expected the text of the agent to be streamed, got %q", streamed)
+	}
+	if status.Status.State != TaskStateInputRequired || !status.Final {
+		t.Fatalf("expected the stream to end when input is required, got %+v", status)
+	}
+
+	var task Task
+	call(t, url, MethodMessageSend, MessageSendParams{Message: userMessage("Ada", taskID)}, &task)
+	if task.Status.State != TaskStateCompleted || artifactText(task) != "Hello Ada" {
+		t.Fatalf("expected the answer to complete the task, got %+v", task)
+	}
+}
+
+func TestElicitationResult(t *testing.T) {
+	req := mcp.ElicitRequest{
+		RequestedSchema: mcp.PrimitiveSchema{
+			Type:       "object",
+			Properties: map[string]mcp.PrimitiveProperty{"count": {Type: "integer"}},
+		},
+	}
+
+	result, err := elicitationResult(req, Message{Parts: []Part{{Kind: "text", Text: "5"}}})
+	if err != nil || result.Action != "accept" || result.Content["count"] != int64(5) {
+		t.Fatalf("expected the text to fill the property, got %+v, %v", result, err)
+	}
+
+	result, err = elicitationResult(req, Message{Parts: []Part{{Kind: "data", Data: map[string]any{"action": "decline"}}}})
+	if err != nil || result.Action != "decline" {
+		t.Fatalf("expected the action of the data part, got %+v, %v", result, err)
+	}
+
+	if _, err := elicitationResult(req, Message{Parts: []Part{{Kind: "text", Text: "five"}}}); err == nil {
+		t.Fatal("expected an error for a value of the wrong type")
+	}
+}
+
+func TestPushToPrivateAddress(t *testing.T) {
+	for _, target := range []string{"http://127.0.0.1:8080/hook", "http://[::1]/hook", "http://169.254.169.254/latest", "http://10.0.0.1/hook"} {
+		if err := validatePushConfig(&PushNotificationConfig{URL: target}); err == nil {
+			t.Errorf("expected %s to be rejected", target)
+		}
+	}
+	if err := validatePushConfig(&PushNotificationConfig{URL: "https://hooks.example.com/a2a"}); err != nil {
+		t.Fatalf("expected a public host to be allowed, got %v", err)
+	}
+
+	for _, ip := range []string{"100.64.0.1", "192.168.1.1", "fd00::1", "fe80::1", "::ffff:127.0.0.1", "0.0.0.0"} {
+		if isPublic(netip.MustParseAddr(ip)) {
+			t.Errorf("expected %s to not be public", ip)
+		}
+	}
+	if !isPublic(netip.MustParseAddr("93.184.215.14")) || !isPublic(netip.MustParseAddr("2606:4700::1111")) {
+		t.Fatal("expected public addresses to be public")
+	}
+
+	// Host names are checked after they are resolved.
+	hook := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("expected the push notification to not reach a loopback address")
+	}))
+	defer hook.Close()
+
+	client := &http.Client{Transport: pushTransport()}
+	resp, err := client.Get(strings.Replace(hook.URL, "127.0.0.1", "localhost", 1))
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the push to a loopback address to fail")
+	}
+}
@@ -0,0 +1,235 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// taskTTL is how long finished tasks are kept so clients can still get them.
+const taskTTL = time.Hour
+
+// task is a message sent to an agent, run as a call of the chat tool of the agent in the thread of
+// the context of the task.
+type task struct {
+	ctx       context.Context
+	stop      context.CancelFunc
+	accountID string
+	agent     string
+
+	lock       sync.Mutex
+	task       Task
+	pushConfig *PushNotificationConfig
+	// events are the status and artifact updates of the task in order, changed is closed and
+	// replaced when one is added.
+	events  []any
+	changed chan struct{}
+	// elicit is the elicitation the task waits on while it requires input, answer receives the
+	// result.
+	elicit    *mcp.ElicitRequest
+	answer    chan mcp.ElicitResult
+	streamed  map[string]bool
+	toolCalls map[string]bool
+	finished  time.Time
+
+	pending []Task
+	pushing bool
+}
+
+func newTask(ctx context.Context, stop context.CancelFunc, accountID, agent string) *task {
+	return &task{
+		ctx:       ctx,
+		stop:      stop,
+		accountID: accountID,
+		agent:     agent,
+		changed:   make(chan struct{}),
+		streamed:  map[string]bool{},
+		toolCalls: map[string]bool{},
+	}
+}
+
+// snapshot returns a copy of the task with at most historyLength messages of history, and the number
+// of events of the task so far.
+func (t *task) snapshot(historyLength *int) (Task, int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	result := t.task
+	result.Artifacts = append([]Artifact(nil), t.task.Artifacts...)
+	result.History = append([]Message(nil), t.task.History...)
+	if historyLength != nil && *historyLength >= 0 && len(result.History) > *historyLength {
+		result.History = result.History[len(result.History)-*historyLength:]
+	}
+	return result, len(t.events)
+}
+
+// addEvent adds an event and wakes up the streams and waiters of the task. The lock must be held.
+func (t *task) addEvent(event any) {
+	t.events = append(t.events, event)
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// setStatus changes the status of the task unless it is finished already. The updated task is
+// returned to be pushed to the client if the state changed.
+func (t *task) setStatus(state string, msg *Message) (Task, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if IsFinalState(t.task.Status.State) {
+		return Task{}, false
+	}
+
+	changed := t.task.Status.State != state
+	t.task.Status = TaskStatus{
+		State:     state,
+		Message:   msg,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if msg != nil {
+		t.task.History = append(t.task.History, *msg)
+	}
+	if IsFinalState(state) {
+		t.finished = time.Now()
+	}
+
+	t.addEvent(TaskStatusUpdateEvent{
+		Kind:      "status-update",
+		TaskID:    t.task.ID,
+		ContextID: t.task.ContextID,
+		Status:    t.task.Status,
+		// The stream of a task ends when it is finished or waits for input.
+		Final: IsFinalState(state) || state == TaskStateInputRequired,
+	})
+	return t.task, changed
+}
+
+func (t *task) addArtifact(artifact Artifact) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.task.Artifacts = append(t.task.Artifacts, artifact)
+	t.addEvent(TaskArtifactUpdateEvent{
+		Kind:      "artifact-update",
+		TaskID:    t.task.ID,
+		ContextID: t.task.ContextID,
+		Artifact:  artifact,
+		LastChunk: true,
+	})
+}
+
+// wait returns when the task is finished or requires input.
+func (t *task) wait(ctx context.Context) {
+	for {
+		t.lock.Lock()
+		state, changed := t.task.Status.State, t.changed
+		t.lock.Unlock()
+
+		if IsFinalState(state) || state == TaskStateInputRequired {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// progress converts the completion progress of the chat call to events of the task. The text of the
+// agent is streamed as artifacts and tool calls as status messages.
+func (t *task) progress(msg mcp.Message) {
+	if msg.Method != "notifications/progress" {
+		return
+	}
+
+	var payload struct {
+		Meta struct {
+			Progress *types.CompletionProgress `json:"ai.nanobot.progress/completion"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(msg.Params, &payload); err != nil || payload.Meta.Progress == nil {
+		return
+	}
+
+	item := payload.Meta.Progress.Item
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	switch {
+	case item.ToolCall != nil && item.ToolCallResult == nil:
+		if item.ToolCall.CallID == "" || item.ToolCall.Name == "" || t.toolCalls[item.ToolCall.CallID] {
+			return
+		}
+		t.toolCalls[item.ToolCall.CallID] = true
+		t.addEvent(TaskStatusUpdateEvent{
+			Kind:      "status-update",
+			TaskID:    t.task.ID,
+			ContextID: t.task.ContextID,
+			Status: TaskStatus{
+				State:     TaskStateWorking,
+				Message:   textMessage("agent", t.task.ContextID, t.task.ID, fmt.Sprintf("Calling tool %s", item.ToolCall.Name)),
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			},
+		})
+	case item.Content != nil && item.Content.Type == "text" && item.Content.Text != "" && payload.Meta.Progress.Role != "user":
+		appendChunk := item.Partial && t.streamed[item.ID]
+		t.streamed[item.ID] = true
+		t.addEvent(TaskArtifactUpdateEvent{
+			Kind:      "artifact-update",
+			TaskID:    t.task.ID,
+			ContextID: t.task.ContextID,
+			Artifact: Artifact{
+				ArtifactID: item.ID,
+				Name:       "response",
+				Parts: []Part{
+					{Kind: "text", Text: item.Content.Text},
+				},
+			},
+			Append: appendChunk,
+		})
+	}
+}
+
+type taskRegistry struct {
+	lock  sync.Mutex
+	tasks map[string]*task
+}
+
+func (r *taskRegistry) add(t *task) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for id, existing := range r.tasks {
+		existing.lock.Lock()
+		expired := !existing.finished.IsZero() && time.Since(existing.finished) > taskTTL
+		existing.lock.Unlock()
+		if expired {
+			delete(r.tasks, id)
+		}
+	}
+
+	if r.tasks == nil {
+		r.tasks = map[string]*task{}
+	}
+	r.tasks[t.task.ID] = t
+}
+
+// get returns a task of the account, tasks of other accounts are not found.
+func (r *taskRegistry) get(id, accountID string) (*task, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	t, ok := r.tasks[id]
+	if !ok || t.accountID != accountID {
+		return nil, ErrTaskNotFound.WithMessage("%s", id)
+	}
+	return t, nil
+}
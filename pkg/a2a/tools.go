@@ -0,0 +1,552 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/envvar"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"github.com/nanobot-ai/nanobot/pkg/version"
+)
+
+const (
+	// pollInterval is how often a task is fetched while the agent works on it without streaming.
+	pollInterval = time.Second
+	// responseProperty is the property of the elicitation for input that has no requested schema.
+	responseProperty = "response"
+)
+
+var invalidToolChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// ToolServer publishes the skills of a remote A2A agent, configured with the a2a field of an MCP
+// server, as tools with the input of a chat tool. A call is sent to the agent as a message, in the
+// same context for the life of the session so the agent keeps the conversation. Updates of the task
+// are sent as progress, and input the agent requires is asked for with an elicitation.
+type ToolServer struct {
+	name string
+
+	lock      sync.Mutex
+	card      *AgentCard
+	contextID string
+}
+
+func NewToolServer(name string) *ToolServer {
+	return &ToolServer{
+		name: name,
+	}
+}
+
+func (s *ToolServer) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, s.initialize)
+	case "notifications/initialized":
+		// nothing to do
+	case "notifications/cancelled":
+		mcp.HandleCancelled(ctx, msg)
+	case "tools/list":
+		mcp.Invoke(ctx, msg, s.toolsList)
+	case "tools/call":
+		mcp.Invoke(ctx, msg, s.toolsCall)
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+func (s *ToolServer) initialize(_ context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+	return &mcp.InitializeResult{
+		ProtocolVersion: params.ProtocolVersion,
+		Capabilities: mcp.ServerCapabilities{
+			Tools: &mcp.ToolsServerCapability{},
+		},
+		ServerInfo: mcp.ServerInfo{
+			Name:    version.Name,
+			Version: version.Get().String(),
+		},
+	}, nil
+}
+
+// client returns the card of the agent, fetched once per session, and a client for it.
+func (s *ToolServer) client(ctx context.Context) (*AgentCard, *Client, error) {
+	config, ok := types.ConfigFromContext(ctx).MCPServers[s.name]
+	if !ok || config.A2A == "" {
+		return nil, nil, fmt.Errorf("A2A agent %s not found in config", s.name)
+	}
+
+	env := mcp.SessionFromContext(ctx).GetEnvMap()
+	headers := envvar.ReplaceMap(env, config.Headers)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.card == nil {
+		card, err := FetchCard(ctx, envvar.ReplaceString(env, config.A2A), headers)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.card = card
+	}
+	return s.card, NewClient(s.card.URL, headers), nil
+}
+
+// tools returns the tools of the skills of the agent by name, with the ID of their skill. An agent
+// without skills has a single chat tool.
+func (s *ToolServer) tools(card *AgentCard) ([]mcp.Tool, map[string]string) {
+	var (
+		tools  []mcp.Tool
+		skills = map[string]string{}
+	)
+	for _, skill := range card.Skills {
+		name := strings.Trim(invalidToolChars.ReplaceAllString(skill.ID, "_"), "_")
+		if name == "" || skills[name] != "" {
+			continue
+		}
+		skills[name] = skill.ID
+
+		description := skill.Description
+		if len(skill.Examples) > 0 {
+			description += "\n\nExamples:\n- " + strings.Join(skill.Examples, "\n- ")
+		}
+		tools = append(tools, mcp.Tool{
+			Name:        name,
+			Title:       skill.Name,
+			Description: strings.TrimSpace(description),
+			InputSchema: types.ChatInputSchema,
+		})
+	}
+
+	if len(tools) == 0 {
+		name := types.AgentTool + s.name
+		skills[name] = ""
+		tools = append(tools, mcp.Tool{
+			Name:        name,
+			Title:       card.Name,
+			Description: card.Description,
+			InputSchema: types.ChatInputSchema,
+		})
+	}
+	return tools, skills
+}
+
+func (s *ToolServer) toolsList(ctx context.Context, _ mcp.Message, _ mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	card, _, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tools, _ := s.tools(card)
+	return &mcp.ListToolsResult{
+		Tools: tools,
+	}, nil
+}
+
+func (s *ToolServer) toolsCall(ctx context.Context, msg mcp.Message, payload mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	card, client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, skills := s.tools(card)
+	skill, ok := skills[payload.Name]
+	if !ok {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("tool %s not found", payload.Name)
+	}
+
+	var request types.SampleCallRequest
+	if err := mcp.JSONCoerce(payload.Arguments, &request); err != nil {
+		return nil, mcp.ErrRPCInvalidParams.WithError(err)
+	}
+
+	s.lock.Lock()
+	contextID := s.contextID
+	s.lock.Unlock()
+
+	message := fromChatRequest(request, contextID)
+	if skill != "" {
+		message.Metadata = map[string]any{"skill": skill}
+	}
+
+	c := &toolCall{
+		server:    s,
+		msg:       msg,
+		card:      card,
+		client:    client,
+		streaming: card.Capabilities.Streaming,
+	}
+	return c.run(ctx, message)
+}
+
+// toolCall is a call of a tool of the agent, it sends messages until the task of the agent is
+// finished.
+type toolCall struct {
+	server    *ToolServer
+	msg       mcp.Message
+	card      *AgentCard
+	client    *Client
+	streaming bool
+	progress  int
+	// task is the last state of the task, a stream that continues it starts from it.
+	task *Task
+}
+
+func (c *toolCall) run(ctx context.Context, message Message) (*mcp.CallToolResult, error) {
+	for {
+		task, err := c.send(ctx, message)
+		if err != nil {
+			return nil, err
+		}
+		c.task = task
+
+		if task.ContextID != "" {
+			c.server.lock.Lock()
+			c.server.contextID = task.ContextID
+			c.server.lock.Unlock()
+		}
+
+		if ctx.Err() != nil && task.ID != "" && !IsFinalState(task.Status.State) {
+			cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pollInterval*5)
+			_, _ = c.client.Cancel(cancelCtx, task.ID)
+			cancel()
+			return nil, ctx.Err()
+		}
+
+		switch task.Status.State {
+		case TaskStateCompleted:
+			return taskResult(task), nil
+		case TaskStateFailed, TaskStateRejected, TaskStateCanceled:
+			text := messageText(task.Status.Message)
+			if text == "" {
+				text = fmt.Sprintf("the task of agent %s was %s", c.card.Name, task.Status.State)
+			}
+			return &mcp.CallToolResult{
+				IsError: true,
+				Content: []mcp.Content{{Type: "text", Text: text}},
+			}, nil
+		case TaskStateInputRequired:
+			answer, result, err := c.elicit(ctx, task)
+			if err != nil || result != nil {
+				return result, err
+			}
+			message = *answer
+		case TaskStateAuthRequired:
+			return nil, fmt.Errorf("agent %s requires authentication: %s", c.card.Name, messageText(task.Status.Message))
+		default:
+			return nil, fmt.Errorf("task %s of agent %s ended in state %q", task.ID, c.card.Name, task.Status.State)
+		}
+	}
+}
+
+// send sends a message and returns the task once it is finished or requires input.
+func (c *toolCall) send(ctx context.Context, message Message) (*Task, error) {
+	params := MessageSendParams{
+		Message: message,
+		Configuration: &MessageSendConfiguration{
+			AcceptedOutputModes: defaultModes,
+		},
+	}
+
+	var (
+		task *Task
+		err  error
+	)
+	if c.streaming {
+		task, err = c.stream(ctx, params)
+	} else {
+		task, err = c.client.Send(ctx, params)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message to agent %s: %w", c.card.Name, err)
+	}
+
+	// The agent did not wait for the task to finish, or the stream ended early.
+	for task.ID != "" && !IsFinalState(task.Status.State) && !waiting(task.Status.State) {
+		select {
+		case <-ctx.Done():
+			return task, nil
+		case <-time.After(pollInterval):
+		}
+		task, err = c.client.Get(ctx, task.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task of agent %s: %w", c.card.Name, err)
+		}
+	}
+	return task, nil
+}
+
+func waiting(state string) bool {
+	return state == TaskStateInputRequired || state == TaskStateAuthRequired
+}
+
+// stream sends a message with message/stream, the task is built from the events and the updates are
+// sent as progress.
+func (c *toolCall) stream(ctx context.Context, params MessageSendParams) (*Task, error) {
+	task := &Task{
+		Kind:      "task",
+		ContextID: params.Message.ContextID,
+		Status:    TaskStatus{State: TaskStateSubmitted},
+	}
+	if c.task != nil && c.task.ID == params.Message.TaskID {
+		continued := *c.task
+		continued.Artifacts = slices.Clone(c.task.Artifacts)
+		task = &continued
+	}
+	err := c.client.Stream(ctx, params, func(event Event) error {
+		switch {
+		case event.Task != nil:
+			task = event.Task
+		case event.Message != nil:
+			task = messageTask(*event.Message)
+		case event.StatusUpdate != nil:
+			task.ID = event.StatusUpdate.TaskID
+			task.ContextID = event.StatusUpdate.ContextID
+			task.Status = event.StatusUpdate.Status
+			if task.Status.State == TaskStateWorking {
+				c.sendProgress(ctx, messageText(task.Status.Message))
+			}
+		case event.ArtifactUpdate != nil:
+			task.ID = event.ArtifactUpdate.TaskID
+			addArtifact(task, event.ArtifactUpdate)
+			c.sendProgress(ctx, partsText(event.ArtifactUpdate.Artifact.Parts))
+		}
+		return nil
+	})
+	if err != nil && task.ID == "" {
+		return nil, err
+	}
+	return task, nil
+}
+
+// addArtifact adds the artifact of an update to the task, or appends its parts to the artifact with
+// the same ID.
+func addArtifact(task *Task, update *TaskArtifactUpdateEvent) {
+	if update.Append {
+		for i, artifact := range task.Artifacts {
+			if artifact.ArtifactID == update.Artifact.ArtifactID {
+				task.Artifacts[i].Parts = append(task.Artifacts[i].Parts, update.Artifact.Parts...)
+				return
+			}
+		}
+	}
+	for i, artifact := range task.Artifacts {
+		if artifact.ArtifactID == update.Artifact.ArtifactID {
+			task.Artifacts[i] = update.Artifact
+			return
+		}
+	}
+	task.Artifacts = append(task.Artifacts, update.Artifact)
+}
+
+func (c *toolCall) sendProgress(ctx context.Context, text string) {
+	token := c.msg.ProgressToken()
+	if token == nil || text == "" {
+		return
+	}
+	c.progress++
+	_ = c.msg.Session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
+		ProgressToken: token,
+		Progress:      json.Number(fmt.Sprint(c.progress)),
+		Message:       text,
+	})
+}
+
+// elicit asks the user for the input the task requires and returns the message with the answer. If
+// the user does not accept, the task is canceled and the result of the call is returned instead.
+func (c *toolCall) elicit(ctx context.Context, task *Task) (*Message, *mcp.CallToolResult, error) {
+	req, freeText := elicitationRequest(c.card.Name, task.Status.Message)
+
+	// Requests to the client do not go through in-process sessions, the user is asked through the
+	// root session like agents do.
+	var result mcp.ElicitResult
+	if err := c.msg.Session.Root().Exchange(ctx, "elicitation/create", req, &result); err != nil {
+		return nil, nil, fmt.Errorf("failed to ask for the input of agent %s: %w", c.card.Name, err)
+	}
+
+	if result.Action != "accept" {
+		if _, err := c.client.Cancel(ctx, task.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to cancel task of agent %s: %w", c.card.Name, err)
+		}
+		return nil, &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{{
+				Type: "text",
+				Text: fmt.Sprintf("The user did not provide the input agent %s asked for (%s), the task was canceled.", c.card.Name, result.Action),
+			}},
+		}, nil
+	}
+
+	answer := Message{
+		Kind:      "message",
+		MessageID: uuid.String(),
+		Role:      "user",
+		ContextID: task.ContextID,
+		TaskID:    task.ID,
+	}
+	if text, ok := result.Content[responseProperty].(string); ok && freeText {
+		answer.Parts = []Part{{Kind: "text", Text: text}}
+	} else {
+		answer.Parts = []Part{{Kind: "data", Data: result.Content}}
+	}
+	return &answer, nil, nil
+}
+
+// elicitationRequest converts the message of a task that requires input to an elicitation. A data
+// part with a requestedSchema, as sent by nanobot agents, is the schema of the elicitation, otherwise
+// a free text response is requested.
+func elicitationRequest(agent string, msg *Message) (mcp.ElicitRequest, bool) {
+	req := mcp.ElicitRequest{
+		Message: messageText(msg),
+	}
+	if req.Message == "" {
+		req.Message = fmt.Sprintf("Agent %s requires more input", agent)
+	}
+
+	if msg != nil {
+		for _, part := range msg.Parts {
+			if part.Kind != "data" || part.Data["requestedSchema"] == nil {
+				continue
+			}
+			var schema mcp.PrimitiveSchema
+			if err := mcp.JSONCoerce(part.Data["requestedSchema"], &schema); err == nil && len(schema.Properties) > 0 {
+				req.RequestedSchema = schema
+				return req, false
+			}
+		}
+	}
+
+	req.RequestedSchema = mcp.PrimitiveSchema{
+		Type: "object",
+		Properties: map[string]mcp.PrimitiveProperty{
+			responseProperty: {
+				Type:        "string",
+				Description: "The input for the agent",
+			},
+		},
+		Required: []string{responseProperty},
+	}
+	return req, true
+}
+
+// fromChatRequest converts the arguments of a chat tool to a message, attachments become file parts.
+func fromChatRequest(request types.SampleCallRequest, contextID string) Message {
+	msg := Message{
+		Kind:      "message",
+		MessageID: uuid.String(),
+		Role:      "user",
+		ContextID: contextID,
+	}
+	if request.Prompt != "" {
+		msg.Parts = append(msg.Parts, Part{Kind: "text", Text: request.Prompt})
+	}
+	for _, attachment := range request.Attachments {
+		file := &FileContent{
+			Name:     attachment.Name,
+			MimeType: attachment.MimeType,
+			URI:      attachment.URL,
+		}
+		if data, ok := strings.CutPrefix(attachment.URL, "data:"); ok {
+			if header, content, ok := strings.Cut(data, ","); ok && strings.HasSuffix(header, ";base64") {
+				file.URI = ""
+				file.Bytes = content
+				if file.MimeType == "" {
+					file.MimeType = strings.TrimSuffix(header, ";base64")
+				}
+			}
+		}
+		msg.Parts = append(msg.Parts, Part{Kind: "file", File: file})
+	}
+	return msg
+}
+
+// taskResult converts the artifacts of a completed task, or the message of its status if it has no
+// artifacts, to the result of a tool call.
+func taskResult(task *Task) *mcp.CallToolResult {
+	var parts [][]Part
+	for _, artifact := range task.Artifacts {
+		parts = append(parts, artifact.Parts)
+	}
+	if len(parts) == 0 && task.Status.Message != nil {
+		parts = append(parts, task.Status.Message.Parts)
+	}
+
+	result := &mcp.CallToolResult{}
+	for _, artifactParts := range parts {
+		// The text of an artifact can be streamed in chunks, they are joined.
+		text := -1
+		for _, part := range artifactParts {
+			switch part.Kind {
+			case "text":
+				if text >= 0 {
+					result.Content[text].Text += part.Text
+					continue
+				}
+				text = len(result.Content)
+				result.Content = append(result.Content, mcp.Content{Type: "text", Text: part.Text})
+			case "data":
+				if result.StructuredContent == nil {
+					result.StructuredContent = part.Data
+				}
+				data, _ := json.Marshal(part.Data)
+				result.Content = append(result.Content, mcp.Content{Type: "text", Text: string(data)})
+			case "file":
+				if part.File != nil {
+					result.Content = append(result.Content, fileContent(*part.File))
+				}
+			}
+		}
+	}
+	if len(result.Content) == 0 {
+		result.Content = []mcp.Content{{Type: "text", Text: ""}}
+	}
+	return result
+}
+
+func fileContent(file FileContent) mcp.Content {
+	if file.Bytes == "" {
+		return mcp.Content{
+			Type:     "resource_link",
+			Name:     file.Name,
+			URI:      file.URI,
+			MIMEType: file.MimeType,
+		}
+	}
+	if strings.HasPrefix(file.MimeType, "image/") || strings.HasPrefix(file.MimeType, "audio/") {
+		return mcp.Content{
+			Type:     strings.Split(file.MimeType, "/")[0],
+			Data:     file.Bytes,
+			MIMEType: file.MimeType,
+		}
+	}
+	return mcp.Content{
+		Type: "resource",
+		Resource: &mcp.EmbeddedResource{
+			URI:      "file:///" + file.Name,
+			Name:     file.Name,
+			MIMEType: file.MimeType,
+			Blob:     file.Bytes,
+		},
+	}
+}
+
+func messageText(msg *Message) string {
+	if msg == nil {
+		return ""
+	}
+	return partsText(msg.Parts)
+}
+
+func partsText(parts []Part) string {
+	var texts []string
+	for _, part := range parts {
+		if part.Kind == "text" && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
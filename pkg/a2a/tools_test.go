@@ -0,0 +1,185 @@
+package a2a_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/a2a"
+	"github.com/nanobot-ai/nanobot/pkg/a2a/a2atest"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// greeter streams a greeting for the name in the message, or fails if the message is "fail".
+func greeter(msg a2a.Message, reply *a2atest.Reply) {
+	text := msg.Parts[0].Text
+	if text == "fail" {
+		reply.Fail("I can not do that")
+		return
+	}
+	reply.Working("Thinking")
+	reply.Stream("Hello ")
+	reply.Stream(text)
+}
+
+func testClient(t *testing.T, server mcp.Server, opts mcp.ClientOption) *mcp.Client {
+	t.Helper()
+
+	ctx := t.Context()
+	config := types.Config{
+		MCPServers: map[string]mcp.Server{"remote": server},
+	}
+	toolServer := a2a.NewToolServer("remote")
+	handler := mcp.MessageHandlerFunc(func(ctx context.Context, msg mcp.Message) {
+		toolServer.OnMessage(types.WithConfig(ctx, config), msg)
+	})
+	env := func() (map[string]string, error) {
+		return map[string]string{"TOKEN": "secret"}, nil
+	}
+
+	httpServer, err := mcp.NewHTTPServer(ctx, env, handler, mcp.HTTPServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(httpServer)
+	t.Cleanup(ts.Close)
+
+	client, err := mcp.NewClient(ctx, "remote", mcp.Server{BaseURL: ts.URL}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close(false) })
+	return client
+}
+
+func TestToolServerTools(t *testing.T) {
+	agent := a2atest.NewServer(t, a2a.AgentCard{
+		Name: "Greeter",
+		Skills: []a2a.AgentSkill{
+			{ID: "greet", Name: "Greet", Description: "Greets someone", Examples: []string{"Greet Ada"}},
+			{ID: "say hello", Name: "Say hello", Description: "Says hello"},
+		},
+	}, greeter)
+
+	client := testClient(t, mcp.Server{
+		A2A:     agent.URL,
+		Headers: map[string]string{"Authorization": "Bearer ${TOKEN}"},
+	}, mcp.ClientOption{})
+
+	tools, err := client.ListTools(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools.Tools) != 2 || tools.Tools[0].Name != "greet" || tools.Tools[1].Name != "say_hello" {
+		t.Fatalf("expected a tool for each skill, got %+v", tools.Tools)
+	}
+	if !strings.Contains(tools.Tools[0].Description, "Greet Ada") || !strings.Contains(string(tools.Tools[0].InputSchema), `"prompt"`) {
+		t.Fatalf("expected a chat tool with the examples of the skill, got %+v", tools.Tools[0])
+	}
+	if got := agent.Headers().Get("Authorization"); got != "Bearer secret" {
+		t.Fatalf("expected the headers to be sent with env vars replaced, got %q", got)
+	}
+}
+
+func TestToolServerCall(t *testing.T) {
+	for _, streaming := range []bool{true, false} {
+		t.Run(map[bool]string{true: "stream", false: "send"}[streaming], func(t *testing.T) {
+			agent := a2atest.NewServer(t, a2a.AgentCard{
+				Name:         "Greeter",
+				Capabilities: a2a.AgentCapabilities{Streaming: streaming},
+			}, greeter)
+
+			var (
+				lock     sync.Mutex
+				progress []string
+			)
+			client := testClient(t, mcp.Server{A2A: agent.URL}, mcp.ClientOption{
+				OnNotify: func(_ context.Context, msg mcp.Message) error {
+					var payload mcp.NotificationProgressRequest
+					if msg.Method == "notifications/progress" && json.Unmarshal(msg.Params, &payload) == nil {
+						lock.Lock()
+						progress = append(progress, payload.Message)
+						lock.Unlock()
+					}
+					return nil
+				},
+			})
+
+			result, err := client.Call(t.Context(), types.AgentTool+"remote", map[string]any{"prompt": "Ada"}, mcp.CallOption{ProgressToken: "token"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.IsError || len(result.Content) != 1 || result.Content[0].Text != "Hello Ada" {
+				t.Fatalf("expected the response of the agent, got %+v", result)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			if streaming && strings.Join(progress, "|") != "Thinking|Hello |Ada" {
+				t.Fatalf("expected the updates of the task as progress, got %q", progress)
+			}
+
+			result, err = client.Call(t.Context(), types.AgentTool+"remote", map[string]any{"prompt": "fail"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.IsError || result.Content[0].Text != "I can not do that" {
+				t.Fatalf("expected a failed task to be an error, got %+v", result)
+			}
+
+			messages := agent.Messages()
+			if messages[0].ContextID != "" || messages[1].ContextID == "" {
+				t.Fatalf("expected the calls of a session to share a context, got %+v", messages)
+			}
+		})
+	}
+}
+
+func TestToolServerInputRequired(t *testing.T) {
+	agent := a2atest.NewServer(t, a2a.AgentCard{
+		Name:         "Greeter",
+		Capabilities: a2a.AgentCapabilities{Streaming: true},
+	}, func(msg a2a.Message, reply *a2atest.Reply) {
+		if len(reply.Task().History) == 1 {
+			reply.InputRequired(a2a.Part{Kind: "text", Text: "What is your name?"})
+			return
+		}
+		reply.Stream("Hello " + msg.Parts[0].Text)
+	})
+
+	var elicitations []mcp.ElicitRequest
+	answer := mcp.ElicitResult{Action: "accept", Content: map[string]any{"response": "Ada"}}
+	client := testClient(t, mcp.Server{A2A: agent.URL}, mcp.ClientOption{
+		OnElicit: func(_ context.Context, _ mcp.Message, req mcp.ElicitRequest) (mcp.ElicitResult, error) {
+			elicitations = append(elicitations, req)
+			return answer, nil
+		},
+	})
+
+	result, err := client.Call(t.Context(), types.AgentTool+"remote", map[string]any{"prompt": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError || result.Content[0].Text != "Hello Ada" {
+		t.Fatalf("expected the answer to continue the task, got %+v", result)
+	}
+	if len(elicitations) != 1 || elicitations[0].Message != "What is your name?" {
+		t.Fatalf("expected the user to be asked for input, got %+v", elicitations)
+	}
+	if messages := agent.Messages(); len(messages) != 2 || messages[1].TaskID == "" || messages[1].Parts[0].Text != "Ada" {
+		t.Fatalf("expected the answer to be sent as text to the task, got %+v", messages)
+	}
+
+	answer = mcp.ElicitResult{Action: "decline"}
+	result, err = client.Call(t.Context(), types.AgentTool+"remote", map[string]any{"prompt": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "canceled") {
+		t.Fatalf("expected a declined elicitation to cancel the task, got %+v", result)
+	}
+}
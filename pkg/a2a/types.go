@@ -0,0 +1,202 @@
+// Package a2a implements the Agent-to-Agent (A2A) protocol, so agents of other platforms can call
+// nanobot agents and nanobot agents can call remote A2A agents.
+package a2a
+
+import (
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+const (
+	ProtocolVersion = "0.3.0"
+
+	// WellKnownPath is where the agent card of a server is published.
+	WellKnownPath = "/.well-known/agent.json"
+	// WellKnownCardPath is the newer location of the agent card, it serves the same card.
+	WellKnownCardPath = "/.well-known/agent-card.json"
+
+	MethodMessageSend      = "message/send"
+	MethodMessageStream    = "message/stream"
+	MethodTasksGet         = "tasks/get"
+	MethodTasksCancel      = "tasks/cancel"
+	MethodTasksResubscribe = "tasks/resubscribe"
+	MethodPushConfigSet    = "tasks/pushNotificationConfig/set"
+	MethodPushConfigGet    = "tasks/pushNotificationConfig/get"
+
+	// MethodTasksSend and MethodTasksSendSubscribe are the names of message/send and message/stream
+	// in earlier versions of the protocol.
+	MethodTasksSend          = "tasks/send"
+	MethodTasksSendSubscribe = "tasks/sendSubscribe"
+
+	NotificationTokenHeader = "X-A2A-Notification-Token"
+)
+
+const (
+	TaskStateSubmitted     = "submitted"
+	TaskStateWorking       = "working"
+	TaskStateInputRequired = "input-required"
+	TaskStateCompleted     = "completed"
+	TaskStateCanceled      = "canceled"
+	TaskStateFailed        = "failed"
+	TaskStateRejected      = "rejected"
+	TaskStateAuthRequired  = "auth-required"
+	TaskStateUnknown       = "unknown"
+)
+
+// IsFinalState returns true if a task in the state will not change anymore.
+func IsFinalState(state string) bool {
+	switch state {
+	case TaskStateCompleted, TaskStateCanceled, TaskStateFailed, TaskStateRejected:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrTaskNotFound                 = mcp.NewRPCError(-32001, "Task not found")
+	ErrTaskNotCancelable            = mcp.NewRPCError(-32002, "Task cannot be canceled")
+	ErrPushNotificationNotSupported = mcp.NewRPCError(-32003, "Push Notification is not supported")
+	ErrUnsupportedOperation         = mcp.NewRPCError(-32004, "This operation is not supported")
+	ErrContentTypeNotSupported      = mcp.NewRPCError(-32005, "Incompatible content types")
+)
+
+type AgentCard struct {
+	ProtocolVersion    string                    `json:"protocolVersion,omitempty"`
+	Name               string                    `json:"name"`
+	Description        string                    `json:"description"`
+	URL                string                    `json:"url"`
+	PreferredTransport string                    `json:"preferredTransport,omitempty"`
+	IconURL            string                    `json:"iconUrl,omitempty"`
+	Version            string                    `json:"version"`
+	Capabilities       AgentCapabilities         `json:"capabilities"`
+	SecuritySchemes    map[string]map[string]any `json:"securitySchemes,omitempty"`
+	Security           []map[string][]string     `json:"security,omitempty"`
+	DefaultInputModes  []string                  `json:"defaultInputModes"`
+	DefaultOutputModes []string                  `json:"defaultOutputModes"`
+	Skills             []AgentSkill              `json:"skills"`
+}
+
+type AgentCapabilities struct {
+	Streaming              bool `json:"streaming,omitempty"`
+	PushNotifications      bool `json:"pushNotifications,omitempty"`
+	StateTransitionHistory bool `json:"stateTransitionHistory,omitempty"`
+}
+
+type AgentSkill struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Examples    []string `json:"examples,omitempty"`
+	InputModes  []string `json:"inputModes,omitempty"`
+	OutputModes []string `json:"outputModes,omitempty"`
+}
+
+type Message struct {
+	Kind      string         `json:"kind"`
+	MessageID string         `json:"messageId"`
+	Role      string         `json:"role"`
+	Parts     []Part         `json:"parts"`
+	ContextID string         `json:"contextId,omitempty"`
+	TaskID    string         `json:"taskId,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// Part is a text, file or data part of a message or an artifact, Kind selects the fields that are
+// set.
+type Part struct {
+	Kind     string         `json:"kind"`
+	Text     string         `json:"text,omitempty"`
+	File     *FileContent   `json:"file,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+type FileContent struct {
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Bytes    string `json:"bytes,omitempty"`
+	URI      string `json:"uri,omitempty"`
+}
+
+type Artifact struct {
+	ArtifactID  string         `json:"artifactId"`
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Parts       []Part         `json:"parts"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+}
+
+type TaskStatus struct {
+	State     string   `json:"state"`
+	Message   *Message `json:"message,omitempty"`
+	Timestamp string   `json:"timestamp,omitempty"`
+}
+
+type Task struct {
+	Kind      string         `json:"kind"`
+	ID        string         `json:"id"`
+	ContextID string         `json:"contextId"`
+	Status    TaskStatus     `json:"status"`
+	Artifacts []Artifact     `json:"artifacts,omitempty"`
+	History   []Message      `json:"history,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+type TaskStatusUpdateEvent struct {
+	Kind      string         `json:"kind"`
+	TaskID    string         `json:"taskId"`
+	ContextID string         `json:"contextId"`
+	Status    TaskStatus     `json:"status"`
+	Final     bool           `json:"final"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+type TaskArtifactUpdateEvent struct {
+	Kind      string         `json:"kind"`
+	TaskID    string         `json:"taskId"`
+	ContextID string         `json:"contextId"`
+	Artifact  Artifact       `json:"artifact"`
+	Append    bool           `json:"append,omitempty"`
+	LastChunk bool           `json:"lastChunk,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+type MessageSendParams struct {
+	Message       Message                   `json:"message"`
+	Configuration *MessageSendConfiguration `json:"configuration,omitempty"`
+	Metadata      map[string]any            `json:"metadata,omitempty"`
+}
+
+type MessageSendConfiguration struct {
+	AcceptedOutputModes    []string                `json:"acceptedOutputModes,omitempty"`
+	HistoryLength          *int                    `json:"historyLength,omitempty"`
+	PushNotificationConfig *PushNotificationConfig `json:"pushNotificationConfig,omitempty"`
+	// Blocking waits for the task to finish or require input before replying, it defaults to true.
+	Blocking *bool `json:"blocking,omitempty"`
+}
+
+type PushNotificationConfig struct {
+	ID             string                              `json:"id,omitempty"`
+	URL            string                              `json:"url"`
+	Token          string                              `json:"token,omitempty"`
+	Authentication *PushNotificationAuthenticationInfo `json:"authentication,omitempty"`
+}
+
+type PushNotificationAuthenticationInfo struct {
+	Schemes     []string `json:"schemes"`
+	Credentials string   `json:"credentials,omitempty"`
+}
+
+type TaskPushNotificationConfig struct {
+	TaskID                 string                 `json:"taskId"`
+	PushNotificationConfig PushNotificationConfig `json:"pushNotificationConfig"`
+}
+
+type TaskQueryParams struct {
+	ID            string `json:"id"`
+	HistoryLength *int   `json:"historyLength,omitempty"`
+}
+
+type TaskIDParams struct {
+	ID string `json:"id"`
+}
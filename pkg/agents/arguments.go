@@ -0,0 +1,82 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/schema"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// checkArguments validates the arguments of a call against the input schema of the tool. In lenient
+// mode, values that obviously mismatch the schema are converted first and the changes returned. If
+// the arguments don't match, the result to return to the model instead of calling the tool is
+// returned.
+func checkArguments(mode string, target types.TargetMapping[types.TargetTool], args map[string]any) (map[string]any, []string, *types.CallResult) {
+	if mode == types.ToolArgumentsOff {
+		return args, nil, nil
+	}
+
+	inputSchema := schema.ValidateAndFixToolSchema(target.Target.InputSchema)
+
+	var changes []string
+	if mode == types.ToolArgumentsLenient && args != nil {
+		coerced, coerceChanges := schema.Coerce(inputSchema, args)
+		if coercedArgs, ok := coerced.(map[string]any); ok {
+			args, changes = coercedArgs, coerceChanges
+		}
+	}
+
+	value := args
+	if value == nil {
+		value = map[string]any{}
+	}
+	err := mcp.ValidateSchema(inputSchema, value)
+	if err == nil {
+		return args, changes, nil
+	}
+
+	validationErr, ok := errors.AsType[*mcp.SchemaValidationError](err)
+	if !ok {
+		// A schema that can't be compiled is the problem of the server, let it check the arguments.
+		slog.Debug("failed to validate tool call arguments", "server", target.MCPServer, "tool", target.TargetName, "error", err)
+		return args, changes, nil
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "The arguments for %s do not match its input schema, the tool was not called:\n", target.TargetName)
+	for _, problem := range validationErr.Problems {
+		text.WriteString("- " + problem + "\n")
+	}
+	text.WriteString("\nCall the tool again with corrected arguments.")
+
+	return args, changes, &types.CallResult{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: text.String(),
+			},
+		},
+		IsError: true,
+	}
+}
+
+// withCoercedArguments records the changes made to the arguments of the call in the result, so that
+// the model can correct its calls.
+func withCoercedArguments(result *types.CallResult, changes []string) *types.CallResult {
+	copied := *result
+	copied.Meta = maps.Clone(result.Meta)
+	if copied.Meta == nil {
+		copied.Meta = map[string]any{}
+	}
+	copied.Meta[types.CoercedArgumentsMetaKey] = changes
+	copied.Content = append(copied.Content[:len(copied.Content):len(copied.Content)], mcp.Content{
+		Type: "text",
+		Text: "The arguments were converted to match the input schema of the tool: " + strings.Join(changes, "; "),
+	})
+	return &copied
+}
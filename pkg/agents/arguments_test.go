@@ -0,0 +1,104 @@
+package agents
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func argumentsTarget() types.TargetMapping[types.TargetTool] {
+	return types.TargetMapping[types.TargetTool]{
+		MCPServer:  "server",
+		TargetName: "search",
+		Target: types.TargetTool{
+			Tool: mcp.Tool{
+				Name:        "search",
+				InputSchema: json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"},"limit":{"type":"integer"},"exact":{"type":"boolean"},"tags":{"type":"array","items":{"type":"string"}}},"required":["query"]}`),
+			},
+		},
+	}
+}
+
+func TestCheckArguments_Valid(t *testing.T) {
+	args := map[string]any{"query": "nanobot", "limit": float64(5)}
+
+	got, changes, invalid := checkArguments("", argumentsTarget(), args)
+	if invalid != nil || len(changes) != 0 || got["limit"] != float64(5) {
+		t.Errorf("expected valid arguments to be unchanged, got %v %v %+v", got, changes, invalid)
+	}
+}
+
+func TestCheckArguments_Invalid(t *testing.T) {
+	_, _, invalid := checkArguments(types.ToolArgumentsStrict, argumentsTarget(), map[string]any{"limit": "5"})
+	if invalid == nil || !invalid.IsError {
+		t.Fatalf("expected an error result, got %+v", invalid)
+	}
+	text := invalid.Content[0].Text
+	if !strings.Contains(text, "/: missing property 'query'") || !strings.Contains(text, "/limit: got string, want integer") {
+		t.Errorf("expected the validation errors in the result, got %q", text)
+	}
+}
+
+func TestCheckArguments_NoArguments(t *testing.T) {
+	_, _, invalid := checkArguments("", argumentsTarget(), nil)
+	if invalid == nil || !strings.Contains(invalid.Content[0].Text, "missing property 'query'") {
+		t.Errorf("expected missing arguments to be an error, got %+v", invalid)
+	}
+}
+
+func TestCheckArguments_Lenient(t *testing.T) {
+	args := map[string]any{"query": float64(42), "limit": "5", "exact": "TRUE", "tags": `["a", 1]`}
+
+	got, changes, invalid := checkArguments(types.ToolArgumentsLenient, argumentsTarget(), args)
+	if invalid != nil {
+		t.Fatalf("expected the arguments to be converted, got %+v", invalid)
+	}
+	if got["query"] != "42" || got["limit"] != float64(5) || got["exact"] != true {
+		t.Errorf("unexpected arguments: %v", got)
+	}
+	if tags, _ := got["tags"].([]any); len(tags) != 2 || tags[1] != "1" {
+		t.Errorf("unexpected tags: %v", got["tags"])
+	}
+	expected := []string{
+		`/exact: converted "TRUE" to true`,
+		`/limit: converted "5" to 5`,
+		`/query: converted 42 to "42"`,
+		`/tags: converted "[\"a\", 1]" to ["a",1]`,
+		`/tags/1: converted 1 to "1"`,
+	}
+	if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected changes:\n%s", strings.Join(changes, "\n"))
+	}
+}
+
+func TestCheckArguments_LenientInvalid(t *testing.T) {
+	_, _, invalid := checkArguments(types.ToolArgumentsLenient, argumentsTarget(), map[string]any{"query": "q", "limit": "five"})
+	if invalid == nil || !strings.Contains(invalid.Content[0].Text, "/limit: got string, want integer") {
+		t.Errorf("expected values that can't be converted to be an error, got %+v", invalid)
+	}
+}
+
+func TestCheckArguments_Off(t *testing.T) {
+	_, _, invalid := checkArguments(types.ToolArgumentsOff, argumentsTarget(), map[string]any{"limit": "5"})
+	if invalid != nil {
+		t.Errorf("expected no validation, got %+v", invalid)
+	}
+}
+
+func TestWithCoercedArguments(t *testing.T) {
+	result := &types.CallResult{Content: []mcp.Content{{Type: "text", Text: "ok"}}}
+
+	got := withCoercedArguments(result, []string{`/limit: converted "5" to 5`})
+	if len(result.Content) != 1 || result.Meta != nil {
+		t.Errorf("expected the result to be copied, got %+v", result)
+	}
+	if len(got.Content) != 2 || !strings.Contains(got.Content[1].Text, `/limit: converted "5" to 5`) {
+		t.Errorf("expected the changes in the content, got %+v", got.Content)
+	}
+	if changes, _ := got.Meta[types.CoercedArgumentsMetaKey].([]string); len(changes) != 1 {
+		t.Errorf("expected the changes in the meta, got %+v", got.Meta)
+	}
+}
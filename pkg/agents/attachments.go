@@ -0,0 +1,115 @@
+package agents
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// supportedMimeType returns a function reporting whether binary content of a MIME type can be sent to the
+// model. The agent's configured mimeTypes take precedence; otherwise the model registry is used.
+func supportedMimeType(mimeTypes []string, model types.ModelInfo) func(string) bool {
+	if len(mimeTypes) > 0 {
+		return func(mimeType string) bool {
+			return slices.ContainsFunc(mimeTypes, func(pattern string) bool {
+				ok, _ := path.Match(pattern, mimeType)
+				return ok
+			})
+		}
+	}
+	return model.SupportsMimeType
+}
+
+// filterUnsupportedContent replaces image, audio and binary resource content that the model can not
+// accept with a text note. The input messages are not modified, so the original content remains in
+// the thread history in case a later turn uses a model that supports it.
+func filterUnsupportedContent(messages []types.Message, supported func(string) bool) []types.Message {
+	var result []types.Message
+	for i, msg := range messages {
+		var items []types.CompletionItem
+		for j, item := range msg.Items {
+			newItem, changed := filterItem(item, supported)
+			if !changed {
+				continue
+			}
+			if items == nil {
+				items = slices.Clone(msg.Items)
+			}
+			items[j] = newItem
+		}
+		if items == nil {
+			continue
+		}
+		if result == nil {
+			result = slices.Clone(messages)
+		}
+		result[i].Items = items
+	}
+	if result == nil {
+		return messages
+	}
+	return result
+}
+
+func filterItem(item types.CompletionItem, supported func(string) bool) (types.CompletionItem, bool) {
+	if item.Content != nil {
+		if content, changed := filterContent(*item.Content, supported); changed {
+			item.Content = &content
+			return item, true
+		}
+	}
+
+	if item.ToolCallResult != nil {
+		var (
+			contents []mcp.Content
+			changed  bool
+		)
+		for i, c := range item.ToolCallResult.Output.Content {
+			content, ok := filterContent(c, supported)
+			if !ok {
+				continue
+			}
+			if contents == nil {
+				contents = slices.Clone(item.ToolCallResult.Output.Content)
+			}
+			contents[i] = content
+			changed = true
+		}
+		if changed {
+			result := *item.ToolCallResult
+			result.Output.Content = contents
+			item.ToolCallResult = &result
+			return item, true
+		}
+	}
+
+	return item, false
+}
+
+func filterContent(content mcp.Content, supported func(string) bool) (mcp.Content, bool) {
+	var mimeType string
+	switch content.Type {
+	case "image", "audio":
+		mimeType = content.MIMEType
+	case "resource":
+		if content.Resource == nil || content.Resource.Blob == "" {
+			return content, false
+		}
+		mimeType = content.Resource.MIMEType
+	default:
+		return content, false
+	}
+
+	if mimeType == "" || supported(mimeType) {
+		return content, false
+	}
+
+	return mcp.Content{
+		Type: "text",
+		Text: fmt.Sprintf("[Content of type %s was omitted because the model does not support it]", mimeType),
+		Meta: content.Meta,
+	}, true
+}
@@ -0,0 +1,93 @@
+package agents
+
+import (
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestFilterUnsupportedContent(t *testing.T) {
+	messages := []types.Message{
+		{
+			Role: "user",
+			Items: []types.CompletionItem{
+				{Content: &mcp.Content{Type: "text", Text: "what is this?"}},
+				{Content: &mcp.Content{Type: "image", MIMEType: "image/png", Data: "aGVsbG8="}},
+			},
+		},
+		{
+			Role: "user",
+			Items: []types.CompletionItem{
+				{
+					ToolCallResult: &types.ToolCallResult{
+						CallID: "call_1",
+						Output: types.CallResult{
+							Content: []mcp.Content{
+								{Type: "text", Text: "file contents"},
+								{Type: "resource", Resource: &mcp.EmbeddedResource{MIMEType: "image/jpeg", Blob: "aGVsbG8="}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	result := filterUnsupportedContent(messages, supportedMimeType(nil, types.ModelInfo{Vision: new(false)}))
+
+	if got := result[0].Items[1].Content; got.Type != "text" || got.Data != "" {
+		t.Errorf("expected image to be replaced with text, got %+v", got)
+	}
+	if got := result[1].Items[0].ToolCallResult.Output.Content[1]; got.Type != "text" || got.Resource != nil {
+		t.Errorf("expected image resource in tool result to be replaced with text, got %+v", got)
+	}
+	if got := result[1].Items[0].ToolCallResult.Output.Content[0].Text; got != "file contents" {
+		t.Errorf("expected text tool result to be kept, got %q", got)
+	}
+
+	// The original messages must not be modified.
+	if messages[0].Items[1].Content.Type != "image" {
+		t.Errorf("expected original image content to be unchanged, got %q", messages[0].Items[1].Content.Type)
+	}
+	if messages[1].Items[0].ToolCallResult.Output.Content[1].Type != "resource" {
+		t.Errorf("expected original tool result to be unchanged")
+	}
+}
+
+func TestFilterUnsupportedContent_AgentMimeTypes(t *testing.T) {
+	messages := []types.Message{
+		{
+			Role: "user",
+			Items: []types.CompletionItem{
+				{Content: &mcp.Content{Type: "image", MIMEType: "image/png", Data: "aGVsbG8="}},
+				{Content: &mcp.Content{Type: "audio", MIMEType: "audio/wav", Data: "aGVsbG8="}},
+			},
+		},
+	}
+
+	result := filterUnsupportedContent(messages, supportedMimeType([]string{"image/*"}, types.ModelInfo{}))
+
+	if got := result[0].Items[0].Content.Type; got != "image" {
+		t.Errorf("expected image allowed by image/* to be kept, got %q", got)
+	}
+	if got := result[0].Items[1].Content.Type; got != "text" {
+		t.Errorf("expected audio to be replaced with text, got %q", got)
+	}
+}
+
+func TestFilterUnsupportedContent_NothingFiltered(t *testing.T) {
+	messages := []types.Message{
+		{
+			Role: "user",
+			Items: []types.CompletionItem{
+				{Content: &mcp.Content{Type: "image", MIMEType: "image/png", Data: "aGVsbG8="}},
+			},
+		},
+	}
+
+	result := filterUnsupportedContent(messages, supportedMimeType(nil, types.ModelInfo{}))
+	if &result[0] != &messages[0] {
+		t.Errorf("expected messages to be returned as is when nothing is filtered")
+	}
+}
@@ -18,11 +18,15 @@ const (
 )
 
 // getContextWindowSize returns the context window size for the given model.
-// If configOverride is > 0, it is used directly. Otherwise, defaults to 200k.
-func getContextWindowSize(configOverride int) int {
+// If configOverride is > 0, it is used directly. Otherwise, the context window from the
+// model registry is used, and defaults to 200k if the model is unknown.
+func getContextWindowSize(configOverride int, model types.ModelInfo) int {
 	if configOverride > 0 {
 		return configOverride
 	}
+	if model.ContextWindow > 0 {
+		return model.ContextWindow
+	}
 	return defaultContextWindow
 }
 
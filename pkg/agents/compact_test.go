@@ -8,14 +8,21 @@ import (
 )
 
 func TestGetContextWindowSize_ConfigOverride(t *testing.T) {
-	size := getContextWindowSize(50000)
+	size := getContextWindowSize(50000, types.ModelInfo{ContextWindow: 400_000})
 	if size != 50000 {
 		t.Errorf("expected config override 50000, got %d", size)
 	}
 }
 
+func TestGetContextWindowSize_Registry(t *testing.T) {
+	size := getContextWindowSize(0, types.ModelInfo{ContextWindow: 400_000})
+	if size != 400_000 {
+		t.Errorf("expected registry context window 400000, got %d", size)
+	}
+}
+
 func TestGetContextWindowSize_Default(t *testing.T) {
-	size := getContextWindowSize(0)
+	size := getContextWindowSize(0, types.ModelInfo{})
 	if size != defaultContextWindow {
 		t.Errorf("expected default %d, got %d", defaultContextWindow, size)
 	}
@@ -0,0 +1,464 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+	"github.com/nanobot-ai/nanobot/pkg/llm/progress"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/sampling"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+const (
+	delegateTaskPending   = "pending"
+	delegateTaskRunning   = "running"
+	delegateTaskCompleted = "completed"
+	delegateTaskFailed    = "failed"
+
+	// delegateProgressInterval is how often the progress of the tasks is sent while they stream.
+	delegateProgressInterval = 250 * time.Millisecond
+	// delegateOutputPreview is how much of the output of a running task is sent as progress.
+	delegateOutputPreview = 500
+)
+
+type delegateTask struct {
+	Agent  string `json:"agent"`
+	Prompt string `json:"prompt"`
+}
+
+type delegateRequest struct {
+	Tasks []delegateTask `json:"tasks"`
+}
+
+// addDelegateTool adds the delegate tool to the tools of an agent with sub-agents, unless the
+// agent is as many delegations deep as it allows.
+func addDelegateTool(ctx context.Context, toolMappings types.ToolMappings, agent *types.Agent) {
+	if len(agent.Agents) == 0 || (agent.Delegation != nil && agent.Delegation.Disabled) ||
+		types.DelegationDepth(ctx) >= agent.Delegation.GetMaxDepth() {
+		return
+	}
+	if _, exists := toolMappings[types.DelegateTool]; exists {
+		return
+	}
+
+	schema, _ := json.Marshal(map[string]any{
+		"type":     "object",
+		"required": []string{"tasks"},
+		"properties": map[string]any{
+			"tasks": map[string]any{
+				"type":        "array",
+				"description": "The tasks to run, all the tasks run at the same time",
+				"minItems":    1,
+				"items": map[string]any{
+					"type":     "object",
+					"required": []string{"agent", "prompt"},
+					"properties": map[string]any{
+						"agent": map[string]any{
+							"type":        "string",
+							"description": "The agent to run the task",
+							"enum":        slices.Clone(agent.Agents),
+						},
+						"prompt": map[string]any{
+							"type":        "string",
+							"description": "The task for the agent, with all the context it needs",
+						},
+					},
+				},
+			},
+		},
+	})
+
+	toolMappings[types.DelegateTool] = types.TargetMapping[types.TargetTool]{
+		MCPServer:  types.DelegateServer,
+		TargetName: types.DelegateTool,
+		Target: types.TargetTool{
+			Tool: mcp.Tool{
+				Name: types.DelegateTool,
+				Description: "Run tasks on other agents at the same time and get all their results. " +
+					"Each task runs in a new thread without the history of this conversation, so the " +
+					"prompt of a task must have all the context the agent needs.",
+				InputSchema: schema,
+			},
+		},
+	}
+}
+
+// delegate runs the tasks of a delegate tool call concurrently, each on its agent in its own
+// thread, and returns the results of all the tasks. The progress of the tasks is sent as the
+// progress of the delegate tool call. The arguments and policies are checked like for the calls of
+// other tools, for the delegate call and for each task on its agent.
+func (a *Agents) delegate(ctx context.Context, agentName string, target types.TargetMapping[types.TargetTool], funcCall tools.ToolCallInvocation, opts []types.CompletionOptions) (*types.Message, error) {
+	var (
+		config  = types.ConfigFromContext(ctx)
+		agent   = config.Agents[agentName]
+		depth   = types.DelegationDepth(ctx)
+		data    map[string]any
+		request delegateRequest
+	)
+
+	if err := json.Unmarshal([]byte(funcCall.ToolCall.Arguments), &data); err != nil {
+		return delegateError(funcCall, fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+	data, _, invalid := checkArguments(agent.ToolArguments, target, data)
+	if invalid != nil {
+		return toolResult(funcCall.ToolCall.CallID, invalid), nil
+	}
+	args, denied := a.registry.CheckPolicies(ctx, types.DelegateServer, types.DelegateTool, data)
+	if denied != nil {
+		return toolResult(funcCall.ToolCall.CallID, denied), nil
+	}
+	if err := mcp.JSONCoerce(args, &request); err != nil {
+		return delegateError(funcCall, fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+	if len(request.Tasks) == 0 {
+		return delegateError(funcCall, "at least one task is required"), nil
+	}
+	for _, task := range request.Tasks {
+		if !slices.Contains(agent.Agents, task.Agent) {
+			return delegateError(funcCall, fmt.Sprintf("agent %q is not one of the agents of %s: %s",
+				task.Agent, agentName, strings.Join(agent.Agents, ", "))), nil
+		}
+		if strings.TrimSpace(task.Prompt) == "" {
+			return delegateError(funcCall, fmt.Sprintf("the task for agent %s has no prompt", task.Agent)), nil
+		}
+	}
+	if maxDepth := agent.Delegation.GetMaxDepth(); depth >= maxDepth {
+		return delegateError(funcCall, fmt.Sprintf("the maximum delegation depth of %d is reached, "+
+			"do the tasks without delegating them", maxDepth)), nil
+	}
+
+	status := newDelegateStatus(ctx, funcCall, complete.Complete(opts...).ProgressToken, request.Tasks)
+	childCtx := types.WithDelegationDepth(ctx, depth+1)
+
+	var (
+		wg      sync.WaitGroup
+		running = make(chan struct{}, agent.Delegation.GetMaxConcurrency())
+	)
+	for i, task := range request.Tasks {
+		wg.Go(func() {
+			select {
+			case running <- struct{}{}:
+				defer func() { <-running }()
+			case <-ctx.Done():
+				status.finish(i, nil, context.Cause(ctx))
+				return
+			}
+
+			status.start(i)
+			result, err := a.runDelegateTask(childCtx, funcCall, i, task, status)
+			status.finish(i, result, err)
+		})
+	}
+	wg.Wait()
+
+	result := status.result()
+	status.send(&result, false)
+
+	return &types.Message{
+		Role: "user",
+		Items: []types.CompletionItem{
+			{
+				ID: funcCall.ItemID,
+				ToolCallResult: &types.ToolCallResult{
+					CallID: funcCall.ToolCall.CallID,
+					Output: result,
+				},
+			},
+		},
+	}, nil
+}
+
+// runDelegateTask runs a task in a new thread of its agent. The progress of the agent is
+// captured for the status of the task instead of being sent to the client.
+func (a *Agents) runDelegateTask(ctx context.Context, funcCall tools.ToolCallInvocation, i int, task delegateTask, status *delegateStatus) (*types.CallResult, error) {
+	progressToken := uuid.String()
+	defer mcp.SessionFromContext(ctx).AddFilter(func(_ context.Context, msg *mcp.Message) (*mcp.Message, error) {
+		return status.capture(i, progressToken, msg), nil
+	})()
+
+	if remote, ok := types.ConfigFromContext(ctx).MCPServers[task.Agent]; ok && remote.A2A != "" {
+		return a.runRemoteDelegateTask(ctx, task, progressToken)
+	}
+
+	// The policies of the agent apply to a task as if the agent was called as a tool.
+	args, denied := a.registry.CheckPolicies(ctx, task.Agent, types.AgentTool+task.Agent, types.SampleCallRequest{
+		Prompt: task.Prompt,
+	})
+	if denied != nil {
+		return denied, nil
+	}
+	var request types.SampleCallRequest
+	if err := mcp.JSONCoerce(args, &request); err != nil {
+		return nil, fmt.Errorf("failed to read arguments of task for agent %s: %w", task.Agent, err)
+	}
+
+	now := time.Now()
+	resp, err := a.Complete(ctx, types.CompletionRequest{
+		Model:      task.Agent,
+		ThreadName: fmt.Sprintf("delegate/%s/%d", funcCall.ToolCall.CallID, i),
+		Input: []types.Message{
+			{
+				ID:      uuid.String(),
+				Created: &now,
+				Role:    "user",
+				Items: []types.CompletionItem{
+					{
+						ID: uuid.String(),
+						Content: &mcp.Content{
+							Type: "text",
+							Text: request.Prompt,
+						},
+					},
+				},
+			},
+		},
+	}, types.CompletionOptions{
+		ProgressToken: progressToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sampling.CompletionResponseToCallResult(resp, false, nil)
+}
+
+// runRemoteDelegateTask sends a task to a remote A2A agent. The tools of the skills of an agent all
+// send the prompt to the agent, so the first one is called.
+func (a *Agents) runRemoteDelegateTask(ctx context.Context, task delegateTask, progressToken string) (*types.CallResult, error) {
+	list, err := a.registry.ListTools(ctx, tools.ListToolsOptions{
+		Servers: []string{task.Agent},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools of agent %s: %w", task.Agent, err)
+	}
+	if len(list) == 0 || len(list[0].Tools) == 0 {
+		return nil, fmt.Errorf("agent %s has no tools", task.Agent)
+	}
+	return a.registry.Call(ctx, task.Agent, list[0].Tools[0].Name, types.SampleCallRequest{
+		Prompt: task.Prompt,
+	}, tools.CallOptions{
+		ProgressToken: progressToken,
+	})
+}
+
+func delegateError(funcCall tools.ToolCallInvocation, message string) *types.Message {
+	return &types.Message{
+		Role: "user",
+		Items: []types.CompletionItem{
+			{
+				ID: funcCall.ItemID,
+				ToolCallResult: &types.ToolCallResult{
+					CallID: funcCall.ToolCall.CallID,
+					Output: types.CallResult{
+						IsError: true,
+						Content: []mcp.Content{
+							{
+								Type: "text",
+								Text: message,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// delegateTaskStatus is the status of a task in the progress and the result of a delegate call.
+type delegateTaskStatus struct {
+	Agent             string         `json:"agent"`
+	Status            string         `json:"status"`
+	Output            string         `json:"output,omitempty"`
+	StructuredContent map[string]any `json:"structuredContent,omitempty"`
+	Error             string         `json:"error,omitempty"`
+
+	// items are the texts streamed by the agent of the task, in order.
+	items     []string
+	itemIndex map[string]int
+}
+
+type delegateStatus struct {
+	ctx           context.Context
+	funcCall      tools.ToolCallInvocation
+	progressToken any
+
+	lock     sync.Mutex
+	tasks    []delegateTaskStatus
+	lastSent time.Time
+}
+
+func newDelegateStatus(ctx context.Context, funcCall tools.ToolCallInvocation, progressToken any, tasks []delegateTask) *delegateStatus {
+	s := &delegateStatus{
+		ctx:           ctx,
+		funcCall:      funcCall,
+		progressToken: progressToken,
+	}
+	for _, task := range tasks {
+		s.tasks = append(s.tasks, delegateTaskStatus{
+			Agent:     task.Agent,
+			Status:    delegateTaskPending,
+			itemIndex: map[string]int{},
+		})
+	}
+	return s
+}
+
+func (s *delegateStatus) start(i int) {
+	s.update(true, func() {
+		s.tasks[i].Status = delegateTaskRunning
+	})
+}
+
+func (s *delegateStatus) finish(i int, result *types.CallResult, err error) {
+	s.update(true, func() {
+		task := &s.tasks[i]
+		switch {
+		case err != nil:
+			task.Status = delegateTaskFailed
+			task.Error = err.Error()
+			return
+		case result.IsError:
+			task.Status = delegateTaskFailed
+		default:
+			task.Status = delegateTaskCompleted
+		}
+
+		var texts []string
+		for _, content := range result.Content {
+			if content.Type == "text" && content.Text != "" {
+				texts = append(texts, content.Text)
+			}
+		}
+		task.Output = strings.Join(texts, "\n")
+		task.StructuredContent = result.StructuredContent
+		if task.StructuredContent == nil {
+			var obj map[string]any
+			if err := json.Unmarshal([]byte(task.Output), &obj); err == nil {
+				task.StructuredContent = obj
+			}
+		}
+		if task.Status == delegateTaskFailed {
+			task.Error, task.Output = task.Output, ""
+		}
+	})
+}
+
+// capture records the progress of the agent of a task and drops it, other messages are returned
+// to be sent.
+func (s *delegateStatus) capture(i int, progressToken string, msg *mcp.Message) *mcp.Message {
+	if msg.Method != "notifications/progress" {
+		return msg
+	}
+
+	var event struct {
+		ProgressToken any `json:"progressToken"`
+		Meta          struct {
+			Progress *types.CompletionProgress `json:"ai.nanobot.progress/completion"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(msg.Params, &event); err != nil || fmt.Sprint(event.ProgressToken) != progressToken {
+		return msg
+	}
+
+	if event.Meta.Progress == nil {
+		return nil
+	}
+	item := event.Meta.Progress.Item
+	if item.Content == nil || item.Content.Type != "text" || event.Meta.Progress.Role == "user" {
+		return nil
+	}
+
+	s.update(false, func() {
+		task := &s.tasks[i]
+		index, ok := task.itemIndex[item.ID]
+		if !ok {
+			index = len(task.items)
+			task.itemIndex[item.ID] = index
+			task.items = append(task.items, "")
+		}
+		if item.Partial {
+			task.items[index] += item.Content.Text
+		} else {
+			task.items[index] = item.Content.Text
+		}
+
+		output := strings.Join(task.items, "\n")
+		if len(output) > delegateOutputPreview {
+			output = "..." + output[len(output)-delegateOutputPreview:]
+		}
+		task.Output = output
+	})
+	return nil
+}
+
+// update changes the status and sends it as progress, at most every delegateProgressInterval
+// unless force is set.
+func (s *delegateStatus) update(force bool, f func()) {
+	s.lock.Lock()
+	f()
+	if !force && time.Since(s.lastSent) < delegateProgressInterval {
+		s.lock.Unlock()
+		return
+	}
+	s.lastSent = time.Now()
+	result := s.resultLocked()
+	s.lock.Unlock()
+
+	s.send(&result, true)
+}
+
+func (s *delegateStatus) send(result *types.CallResult, hasMore bool) {
+	progress.Send(s.ctx, &types.CompletionProgress{
+		MessageID: s.funcCall.MessageID,
+		Item: types.CompletionItem{
+			ID:       s.funcCall.ItemID,
+			HasMore:  hasMore,
+			ToolCall: &s.funcCall.ToolCall,
+			ToolCallResult: &types.ToolCallResult{
+				CallID: s.funcCall.ToolCall.CallID,
+				Output: *result,
+			},
+		},
+	}, s.progressToken)
+}
+
+func (s *delegateStatus) result() types.CallResult {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.resultLocked()
+}
+
+func (s *delegateStatus) resultLocked() types.CallResult {
+	var (
+		tasks  = slices.Clone(s.tasks)
+		failed int
+	)
+	for _, task := range tasks {
+		if task.Status == delegateTaskFailed {
+			failed++
+		}
+	}
+
+	structured := map[string]any{
+		"tasks": tasks,
+	}
+	data, _ := json.Marshal(structured)
+	return types.CallResult{
+		IsError: failed == len(tasks),
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: string(data),
+			},
+		},
+		StructuredContent: structured,
+	}
+}
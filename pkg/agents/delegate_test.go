@@ -0,0 +1,141 @@
+package agents
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestAddDelegateTool(t *testing.T) {
+	agent := &types.Agent{HookAgent: types.HookAgent{Agents: []string{"researcher", "writer"}}}
+
+	toolMappings := types.ToolMappings{}
+	addDelegateTool(t.Context(), toolMappings, agent)
+	mapping, ok := toolMappings[types.DelegateTool]
+	if !ok || mapping.MCPServer != types.DelegateServer {
+		t.Fatalf("expected the delegate tool, got %+v", toolMappings)
+	}
+	if !strings.Contains(string(mapping.Target.InputSchema), `"enum":["researcher","writer"]`) {
+		t.Fatalf("expected the agents in the input schema, got %s", mapping.Target.InputSchema)
+	}
+
+	toolMappings = types.ToolMappings{}
+	addDelegateTool(types.WithDelegationDepth(t.Context(), types.DefaultDelegateMaxDepth), toolMappings, agent)
+	if len(toolMappings) != 0 {
+		t.Fatal("expected no delegate tool at the maximum depth")
+	}
+
+	agent.Delegation = &types.AgentDelegation{Disabled: true}
+	addDelegateTool(t.Context(), toolMappings, agent)
+	if len(toolMappings) != 0 {
+		t.Fatal("expected no delegate tool when delegation is disabled")
+	}
+}
+
+func TestDelegateValidation(t *testing.T) {
+	ctx := types.WithConfig(t.Context(), types.Config{
+		Agents: map[string]types.Agent{
+			"lead": {HookAgent: types.HookAgent{Agents: []string{"researcher"}}},
+		},
+	})
+
+	for name, args := range map[string]string{
+		"no tasks":      `{"tasks":[]}`,
+		"unknown agent": `{"tasks":[{"agent":"writer","prompt":"write"}]}`,
+		"no prompt":     `{"tasks":[{"agent":"researcher","prompt":" "}]}`,
+	} {
+		msg, err := (&Agents{}).delegate(ctx, "lead", types.TargetMapping[types.TargetTool]{}, tools.ToolCallInvocation{
+			ItemID:   "item",
+			ToolCall: types.ToolCall{CallID: "call", Name: types.DelegateTool, Arguments: args},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := msg.Items[0].ToolCallResult; result.CallID != "call" || !result.Output.IsError {
+			t.Errorf("%s: expected an error result, got %+v", name, result)
+		}
+	}
+}
+
+func TestDelegatePolicies(t *testing.T) {
+	config := types.Config{
+		Agents: map[string]types.Agent{
+			"lead": {HookAgent: types.HookAgent{Agents: []string{"researcher"}}},
+		},
+		Policies: []types.ToolPolicy{
+			{Tools: []string{"researcher"}, Action: types.PolicyActionDeny, Reason: "no research"},
+		},
+	}
+	invocation := tools.ToolCallInvocation{
+		ItemID:   "item",
+		ToolCall: types.ToolCall{CallID: "call", Name: types.DelegateTool, Arguments: `{"tasks":[{"agent":"researcher","prompt":"find it"}]}`},
+	}
+	a := &Agents{registry: tools.NewToolsService()}
+
+	// A denied task fails without running the agent.
+	msg, err := a.delegate(types.WithConfig(t.Context(), config), "lead", types.TargetMapping[types.TargetTool]{}, invocation, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := msg.Items[0].ToolCallResult.Output.StructuredContent["tasks"].([]delegateTaskStatus)
+	if tasks[0].Status != delegateTaskFailed || !strings.Contains(tasks[0].Error, "no research") {
+		t.Fatalf("expected the task to be denied, got %+v", tasks[0])
+	}
+
+	// The delegate call itself is denied like the call of any other tool.
+	config.Policies[0].Tools = []string{types.DelegateServer}
+	msg, err = a.delegate(types.WithConfig(t.Context(), config), "lead", types.TargetMapping[types.TargetTool]{}, invocation, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := msg.Items[0].ToolCallResult; !result.Output.IsError || result.Output.StructuredContent["error"] != "policy_denied" {
+		t.Fatalf("expected the delegate call to be denied, got %+v", result)
+	}
+}
+
+func TestDelegateStatus(t *testing.T) {
+	status := newDelegateStatus(t.Context(), tools.ToolCallInvocation{}, nil, []delegateTask{
+		{Agent: "researcher"},
+		{Agent: "writer"},
+		{Agent: "reviewer"},
+	})
+
+	for _, text := range []string{"Hello", " world"} {
+		params, _ := json.Marshal(map[string]any{
+			"progressToken": "child",
+			"_meta": map[string]any{
+				types.CompletionProgressMetaKey: types.CompletionProgress{
+					Item: types.CompletionItem{ID: "1", Partial: true, Content: &mcp.Content{Type: "text", Text: text}},
+				},
+			},
+		})
+		if msg := status.capture(0, "child", &mcp.Message{Method: "notifications/progress", Params: params}); msg != nil {
+			t.Fatal("expected the progress of the task to be dropped")
+		}
+	}
+	if status.tasks[0].Output != "Hello world" {
+		t.Fatalf("expected the streamed output, got %q", status.tasks[0].Output)
+	}
+
+	status.finish(0, &types.CallResult{Content: []mcp.Content{{Type: "text", Text: `{"answer":42}`}}}, nil)
+	status.finish(1, &types.CallResult{IsError: true, Content: []mcp.Content{{Type: "text", Text: "no access"}}}, nil)
+
+	result := status.result()
+	if result.IsError {
+		t.Fatal("expected the result to not be an error when some tasks completed")
+	}
+	tasks := result.StructuredContent["tasks"].([]delegateTaskStatus)
+	if tasks[0].Status != delegateTaskCompleted || tasks[0].StructuredContent["answer"] != float64(42) {
+		t.Errorf("expected the first task to be completed with structured content, got %+v", tasks[0])
+	}
+	if tasks[1].Status != delegateTaskFailed || tasks[1].Error != "no access" {
+		t.Errorf("expected the second task to fail, got %+v", tasks[1])
+	}
+	if tasks[2].Status != delegateTaskPending {
+		t.Errorf("expected the third task to be pending, got %+v", tasks[2])
+	}
+}
@@ -0,0 +1,134 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+// durableRun checkpoints a chat run to the session store after every response of the model and
+// every tool output, so the run can be resumed or closed if the process stops before it
+// completes. A nil durableRun does nothing, runs are only checkpointed when there is a store.
+type durableRun struct {
+	manager *session.Manager
+	session *mcp.Session
+	run     session.AgentRun
+}
+
+// newDurableRun starts the record of a run. The run is recorded as the run ID of the context if
+// there is one, which is how background runs are recorded under the ID returned to the caller.
+func newDurableRun(ctx context.Context, chatSession *mcp.Session, executionKey, inputID string, req types.CompletionRequest) *durableRun {
+	var manager session.Manager
+	if chatSession == nil || !chatSession.Get(session.ManagerSessionKey, &manager) || manager.DB == nil {
+		return nil
+	}
+
+	d := &durableRun{
+		manager: &manager,
+		session: chatSession,
+		run: session.AgentRun{
+			RunID:     uuid.String(),
+			SessionID: chatSession.ID(),
+		},
+	}
+	if runID := types.RunID(ctx); runID != "" {
+		if run, err := manager.DB.GetAgentRun(ctx, chatSession.ID(), runID); err == nil {
+			d.run = *run
+		} else {
+			d.run.RunID = runID
+		}
+	}
+
+	d.run.Agent = req.GetAgent()
+	d.run.Status = session.AgentRunRunning
+	d.run.ReplicaID = manager.ReplicaID()
+	d.run.Owner = manager.RunOwner()
+	d.run.ExecutionKey = executionKey
+	d.run.InputID = inputID
+	return d
+}
+
+// resume loads the checkpoint of a run and continues it as this run. The run must have been claimed
+// with session.Store.ClaimAgentRun, which the chat call does before it starts.
+func (d *durableRun) resume(ctx context.Context, runID string) (*types.Execution, error) {
+	if d == nil {
+		return nil, fmt.Errorf("runs can't be resumed without a session store")
+	}
+
+	run, err := d.manager.DB.GetAgentRun(ctx, d.run.SessionID, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find run %s: %w", runID, err)
+	}
+	if run.Status != session.AgentRunRunning {
+		return nil, fmt.Errorf("run %s is %s, it was not claimed to be resumed", runID, run.Status)
+	}
+	d.run = *run
+
+	execution := types.Execution(run.Execution)
+	return &execution, nil
+}
+
+// track makes the run stoppable. Stopping the run cancels the user context of the returned
+// context, the same as the client canceling the request.
+func (d *durableRun) track(ctx context.Context) (context.Context, func()) {
+	if d == nil {
+		return ctx, func() {}
+	}
+	userCtx, cancel := context.WithCancelCause(mcp.UserContext(ctx))
+	untrack := d.manager.TrackAgentRun(d.run.RunID, cancel)
+	return mcp.WithUserContext(ctx, userCtx), func() {
+		untrack()
+		cancel(nil)
+	}
+}
+
+func (d *durableRun) checkpoint(ctx context.Context, execution *types.Execution) {
+	if d == nil {
+		return
+	}
+	d.run.Execution = session.RunExecution(*execution)
+	d.save(ctx)
+}
+
+// finish records the end of the run. A run is stopped if it was stopped with the manager or the
+// client canceled it. The tool calls of a stopped run that never completed get an output, so the
+// checkpoint stays a valid conversation.
+func (d *durableRun) finish(ctx context.Context, execution *types.Execution, err error) {
+	if d == nil {
+		return
+	}
+
+	_, stopped := errors.AsType[*mcp.RequestCancelledError](context.Cause(mcp.UserContext(ctx)))
+	switch {
+	case stopped:
+		d.run.Status = session.AgentRunStopped
+		execution.CloseToolCalls("The tool call was stopped before it completed.")
+	case err != nil:
+		d.run.Status = session.AgentRunFailed
+		d.run.Error = err.Error()
+	default:
+		d.run.Status = session.AgentRunCompleted
+	}
+	if execution.Response != nil {
+		d.run.Execution = session.RunExecution(*execution)
+	}
+
+	// Save even if the run was canceled.
+	d.save(context.WithoutCancel(ctx))
+}
+
+func (d *durableRun) save(ctx context.Context) {
+	if err := d.manager.DB.SaveAgentRun(ctx, &d.run); err != nil {
+		slog.Error("failed to checkpoint agent run", "run_id", d.run.RunID, "session_id", d.run.SessionID, "error", err)
+		return
+	}
+	_ = d.session.SendPayload(ctx, "notifications/resources/updated", map[string]any{
+		"uri": fmt.Sprintf(types.RunURI, d.run.RunID),
+	})
+}
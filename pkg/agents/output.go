@@ -0,0 +1,122 @@
+package agents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+// checkOutput validates the final response of a run against the output schema of its request. A
+// response that matches gets the decoded object as its structured content. If it doesn't match, the
+// message asking the model to fix it is returned, or the response fails once the repairs are used up.
+func checkOutput(run *types.Execution, repairs int) *types.Message {
+	if run.PopulatedRequest == nil || run.PopulatedRequest.OutputSchema == nil || run.Response == nil ||
+		run.Response.Error != "" || hasToolCalls(run.Response.Output) {
+		return nil
+	}
+
+	outputSchema := run.PopulatedRequest.OutputSchema
+	value, err := decodeOutput(outputSchema.ToSchema(), run.Response.Output)
+	if err == nil {
+		if obj, ok := value.(map[string]any); ok {
+			run.Response.StructuredContent = obj
+		}
+		return nil
+	}
+
+	var problems []string
+	if validationErr, ok := errors.AsType[*mcp.SchemaValidationError](err); ok {
+		problems = validationErr.Problems
+	} else if _, ok := errors.AsType[*json.SyntaxError](err); ok || errors.Is(err, errNoOutput) {
+		problems = []string{err.Error()}
+	} else {
+		// The schema itself is broken, that is not something the model can fix.
+		slog.Warn("failed to validate response against output schema", "schema", outputSchema.Name, "error", err)
+		return nil
+	}
+
+	if repairs < outputSchema.GetMaxRepairs() {
+		msg := repairMessage(problems)
+		return &msg
+	}
+
+	run.Response.Error = fmt.Sprintf("the response does not match the output schema %s after %d repairs: %s",
+		outputSchema.Name, repairs, strings.Join(problems, "; "))
+	return nil
+}
+
+var errNoOutput = errors.New("the response has no text")
+
+// decodeOutput decodes the text of the message as JSON, ignoring a markdown code fence around it, and
+// validates it against the schema.
+func decodeOutput(outputSchema json.RawMessage, msg types.Message) (any, error) {
+	var text strings.Builder
+	for _, item := range msg.Items {
+		if item.Content != nil && item.Content.Type == "text" {
+			text.WriteString(item.Content.Text)
+		}
+	}
+
+	data := strings.TrimSpace(text.String())
+	if fenced, ok := strings.CutPrefix(data, "```"); ok {
+		fenced = strings.TrimPrefix(fenced, "json")
+		data = strings.TrimSpace(strings.TrimSuffix(fenced, "```"))
+	}
+	if data == "" {
+		return nil, errNoOutput
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return nil, fmt.Errorf("the response is not valid JSON: %w", err)
+	}
+	if len(outputSchema) == 0 {
+		return value, nil
+	}
+	if err := mcp.ValidateSchema(outputSchema, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func hasToolCalls(msg types.Message) bool {
+	for _, item := range msg.Items {
+		if item.ToolCall != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// repairMessage asks the model to respond again with the problems of its last response fixed.
+func repairMessage(problems []string) types.Message {
+	var text strings.Builder
+	text.WriteString("Your response does not match the required output schema:\n")
+	for _, problem := range problems {
+		text.WriteString("- " + problem + "\n")
+	}
+	text.WriteString("\nRespond again with only the corrected JSON, without any other text.")
+
+	now := time.Now()
+	return types.Message{
+		ID:      uuid.String(),
+		Created: &now,
+		Role:    "user",
+		Items: []types.CompletionItem{
+			{
+				ID: uuid.String(),
+				Content: &mcp.Content{
+					Type: "text",
+					Text: text.String(),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package agents
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func outputRun(text string, maxRepairs int) *types.Execution {
+	return &types.Execution{
+		PopulatedRequest: &types.CompletionRequest{
+			OutputSchema: &types.OutputSchema{
+				Name:       "person",
+				Schema:     json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name"]}`),
+				MaxRepairs: &maxRepairs,
+			},
+		},
+		Response: &types.CompletionResponse{
+			Output: types.Message{
+				Role: "assistant",
+				Items: []types.CompletionItem{
+					{Content: &mcp.Content{Type: "text", Text: text}},
+				},
+			},
+		},
+	}
+}
+
+func TestCheckOutput_Valid(t *testing.T) {
+	run := outputRun("```json\n{\"name\": \"Ada\", \"age\": 36}\n```", 2)
+
+	if repair := checkOutput(run, 0); repair != nil {
+		t.Fatalf("expected no repair, got %+v", repair)
+	}
+	if run.Response.Error != "" || run.Response.StructuredContent["name"] != "Ada" {
+		t.Errorf("expected the decoded object as structured content, got %+v", run.Response)
+	}
+}
+
+func TestCheckOutput_Repair(t *testing.T) {
+	run := outputRun(`{"age": "36"}`, 2)
+
+	repair := checkOutput(run, 0)
+	if repair == nil || repair.Role != "user" {
+		t.Fatalf("expected a repair message, got %+v", repair)
+	}
+	text := repair.Items[0].Content.Text
+	if !strings.Contains(text, "/: missing property 'name'") || !strings.Contains(text, "/age: got string, want integer") {
+		t.Errorf("expected the problems in the repair message, got %q", text)
+	}
+	if run.Response.StructuredContent != nil {
+		t.Errorf("expected no structured content, got %+v", run.Response.StructuredContent)
+	}
+}
+
+func TestCheckOutput_InvalidJSON(t *testing.T) {
+	run := outputRun("Sure, here is the person.", 1)
+
+	repair := checkOutput(run, 0)
+	if repair == nil || !strings.Contains(repair.Items[0].Content.Text, "not valid JSON") {
+		t.Fatalf("expected a repair message for invalid JSON, got %+v", repair)
+	}
+}
+
+func TestCheckOutput_RepairsUsedUp(t *testing.T) {
+	run := outputRun(`{"age": 36}`, 2)
+
+	if repair := checkOutput(run, 2); repair != nil {
+		t.Fatalf("expected no repair, got %+v", repair)
+	}
+	if !strings.Contains(run.Response.Error, "does not match the output schema person after 2 repairs") {
+		t.Errorf("expected the response to fail, got %q", run.Response.Error)
+	}
+}
+
+func TestCheckOutput_NoSchema(t *testing.T) {
+	run := outputRun("not json", 2)
+	run.PopulatedRequest.OutputSchema = nil
+
+	if repair := checkOutput(run, 0); repair != nil || run.Response.Error != "" {
+		t.Errorf("expected a response without an output schema to be left alone, got %+v", run.Response)
+	}
+}
+
+func TestCheckOutput_ToolCalls(t *testing.T) {
+	run := outputRun("", 2)
+	run.Response.Output.Items = append(run.Response.Output.Items, types.CompletionItem{
+		ToolCall: &types.ToolCall{Name: "external"},
+	})
+
+	if repair := checkOutput(run, 0); repair != nil || run.Response.Error != "" {
+		t.Errorf("expected a response with tool calls to be left alone, got %+v", run.Response)
+	}
+}
@@ -57,6 +57,8 @@ func (a *Agents) addTools(ctx context.Context, req *types.CompletionRequest, age
 		return nil, fmt.Errorf("failed to build tool mappings: %w", err)
 	}
 
+	addDelegateTool(ctx, toolMappings, agent)
+
 	switch opt.ToolIncludeContext {
 	case "none":
 		toolMappings = types.ToolMappings{}
@@ -196,6 +198,7 @@ func (a *Agents) populateRequest(ctx context.Context, config types.Config, run *
 			Description: agent.Output.Description,
 			Schema:      agent.Output.ToSchema(),
 			Strict:      agent.Output.Strict,
+			MaxRepairs:  agent.Output.MaxRepairs,
 		}
 	}
 
@@ -209,6 +212,13 @@ func (a *Agents) populateRequest(ctx context.Context, config types.Config, run *
 
 	req.Model = agent.Model
 
+	// Default the max tokens to, and never exceed, what the model can generate.
+	if modelInfo, ok := config.LookupModel(req.Model); ok && modelInfo.MaxOutputTokens > 0 {
+		if req.MaxTokens == 0 || req.MaxTokens > modelInfo.MaxOutputTokens {
+			req.MaxTokens = modelInfo.MaxOutputTokens
+		}
+	}
+
 	toolMapping, err := a.addTools(ctx, &req, &agent, opts)
 	if err != nil {
 		return req, nil, fmt.Errorf("failed to add tools: %w", err)
@@ -390,6 +400,7 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 		currentRun           = &types.Execution{}
 		baseConfig           = types.ConfigFromContext(ctx)
 		startID              = ""
+		repairs              = 0
 	)
 
 	if len(req.Input) > 0 {
@@ -412,8 +423,43 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 		isChat = *ch
 	}
 
+	var durable *durableRun
+	if isChat {
+		durable = newDurableRun(ctx, session, previousExecutionKey, startID, req)
+	}
+	if types.RunID(ctx) != "" {
+		// The agents called by this run start new runs.
+		ctx = types.WithRunID(ctx, "")
+	}
+
+	// A resumed run continues from its checkpoint. If the model had responded, the run continues
+	// with the tool calls that didn't complete.
+	resumed := false
+	if runID := types.ResumeRun(ctx); runID != "" {
+		// The agents called by this run start new runs.
+		ctx = types.WithResumeRun(ctx, "")
+		execution, err := durable.resume(ctx, runID)
+		if err != nil {
+			return nil, err
+		}
+		req = execution.Request
+		previousExecutionKey = durable.run.ExecutionKey
+		if len(req.Input) > 0 {
+			startID = req.Input[0].ID
+		}
+		currentRun = execution
+		resumed = execution.Response != nil
+	}
+
+	ctx, untrack := durable.track(ctx)
+	defer untrack()
+	defer func() {
+		durable.finish(ctx, currentRun, err)
+	}()
+
 	// Save the original request to the Execution status
 	currentRun.Request = req
+	durable.checkpoint(ctx, currentRun)
 
 	if isChat {
 		var fallBack *types.Execution
@@ -443,7 +489,9 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 		// Use a new context so that we don't leak values.
 		runCtx := types.WithConfig(ctx, config)
 
-		if err := a.run(runCtx, config, currentRun, previousRun, opts); err != nil {
+		if resumed {
+			resumed = false
+		} else if err := a.run(runCtx, config, currentRun, previousRun, opts); err != nil {
 			return nil, err
 		}
 
@@ -456,11 +504,25 @@ func (a *Agents) Complete(ctx context.Context, req types.CompletionRequest, opts
 		if isChat {
 			session.Set(previousExecutionKey, currentRun)
 		}
+		durable.checkpoint(runCtx, currentRun)
 
 		// This doesn't return an error because any issues we run into should be returned to the LLM for further processing.
-		a.toolCalls(runCtx, currentRun, opts)
+		a.toolCalls(runCtx, currentRun, opts, func() {
+			durable.checkpoint(runCtx, currentRun)
+		})
 
 		if currentRun.Done {
+			// A response that doesn't match the output schema is sent back to the model to fix.
+			if repair := checkOutput(currentRun, repairs); repair != nil {
+				repairs++
+				previousRun = currentRun
+				currentRun = &types.Execution{
+					Request: req.Reset(),
+				}
+				currentRun.Request.Input = []types.Message{*repair}
+				continue
+			}
+
 			if isChat {
 				session.Set(previousExecutionKey, currentRun)
 			}
@@ -609,8 +671,9 @@ func (a *Agents) run(ctx context.Context, config types.Config, run *types.Execut
 
 	// Check if compaction is needed
 	agent, agentExists := config.Agents[completionRequest.GetAgent()]
+	modelInfo, _ := config.LookupModel(completionRequest.Model)
 	if agentExists {
-		ctxWindowSize := getContextWindowSize(agent.ContextWindow)
+		ctxWindowSize := getContextWindowSize(agent.ContextWindow, modelInfo)
 		if shouldCompact(completionRequest, ctxWindowSize) {
 			var prevCompacted []types.Message
 			if prev != nil {
@@ -660,6 +723,8 @@ func (a *Agents) run(ctx context.Context, config types.Config, run *types.Execut
 		return nil
 	}
 
+	modifiedRequest.Input = filterUnsupportedContent(modifiedRequest.Input, supportedMimeType(agent.MimeTypes, modelInfo))
+
 	resp, err = a.completer.Complete(ctx, modifiedRequest, opts...)
 	if err != nil {
 		return err
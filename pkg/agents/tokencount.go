@@ -24,6 +24,12 @@ func init() {
 	image.RegisterFormat("webp", "RIFF????WEBP", webp.Decode, webp.DecodeConfig)
 }
 
+// EstimateTokens estimates the token count of a conversation, excluding the system prompt and tools.
+// It is meant for clients that only see the thread history, such as the terminal chat.
+func EstimateTokens(model string, messages []types.Message) int {
+	return estimateTokens(model, messages, "", nil)
+}
+
 // estimateTokens estimates the total token count for a set of messages, a system prompt, and tool definitions.
 // It uses the cl100k_base encoding (reasonable for both OpenAI and Anthropic models).
 // Falls back to len(text)/4 heuristic if tiktoken encoding fails.
@@ -174,9 +180,16 @@ func countTokens(model, text string) int {
 }
 
 // encodingForModel returns the tiktoken name of the encoding for a given model name.
-// It will try to find the appropriate encoding for the specified model,
+// The model registry is consulted first, then tiktoken's own model tables,
 // and will default to cl100k_base if the model is not recognized.
 func encodingForModel(model string) string {
+	if info, ok := types.LookupModel(nil, model); ok && info.Encoding != "" {
+		return info.Encoding
+	}
+
+	if _, name, ok := strings.Cut(model, "/"); ok {
+		model = name
+	}
 	encoding := tiktoken.MODEL_TO_ENCODING[model]
 	if encoding == "" {
 		for prefix, enc := range tiktoken.MODEL_PREFIX_TO_ENCODING {
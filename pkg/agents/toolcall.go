@@ -12,7 +12,9 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/types"
 )
 
-func (a *Agents) toolCalls(ctx context.Context, run *types.Execution, opts []types.CompletionOptions) {
+// toolCalls invokes the tool calls of the response of the run, checkpoint is called after every
+// tool output.
+func (a *Agents) toolCalls(ctx context.Context, run *types.Execution, opts []types.CompletionOptions, checkpoint func()) {
 	// If the proxy blocked tool calls due to a policy violation, return error
 	// tool_results for each call instead of executing them. This keeps the
 	// conversation history valid (every tool_use gets a tool_result).
@@ -142,11 +144,21 @@ func (a *Agents) toolCalls(ctx context.Context, run *types.Execution, opts []typ
 			continue
 		}
 
-		callOutput, err := a.invoke(ctx, targetServer, tools.ToolCallInvocation{
+		invocation := tools.ToolCallInvocation{
 			MessageID: run.Response.Output.ID,
 			ItemID:    output.ID,
 			ToolCall:  *functionCall,
-		}, opts)
+		}
+
+		var (
+			callOutput *types.Message
+			err        error
+		)
+		if targetServer.MCPServer == types.DelegateServer {
+			callOutput, err = a.delegate(ctx, run.Request.GetAgent(), targetServer, invocation, opts)
+		} else {
+			callOutput, err = a.invoke(ctx, run.Request.GetAgent(), targetServer, invocation, opts)
+		}
 		cancelCause := context.Cause(mcp.UserContext(ctx))
 		if err != nil || cancelCause != nil {
 			// Check if this was a client-initiated cancellation
@@ -195,6 +207,7 @@ func (a *Agents) toolCalls(ctx context.Context, run *types.Execution, opts []typ
 			Output: *callOutput,
 			Done:   true,
 		}
+		checkpoint()
 	}
 
 	if len(run.ToolOutputs) == 0 {
@@ -202,7 +215,7 @@ func (a *Agents) toolCalls(ctx context.Context, run *types.Execution, opts []typ
 	}
 }
 
-func (a *Agents) invoke(ctx context.Context, target types.TargetMapping[types.TargetTool], funcCall tools.ToolCallInvocation, opts []types.CompletionOptions) (*types.Message, error) {
+func (a *Agents) invoke(ctx context.Context, agentName string, target types.TargetMapping[types.TargetTool], funcCall tools.ToolCallInvocation, opts []types.CompletionOptions) (*types.Message, error) {
 	var data map[string]any
 
 	if funcCall.ToolCall.Arguments != "" {
@@ -212,10 +225,19 @@ func (a *Agents) invoke(ctx context.Context, target types.TargetMapping[types.Ta
 		}
 	}
 
+	mode := types.ConfigFromContext(ctx).Agents[agentName].ToolArguments
+	data, changes, invalid := checkArguments(mode, target, data)
+	if invalid != nil {
+		return toolResult(funcCall.ToolCall.CallID, invalid), nil
+	}
+
 	response, err := a.registry.Call(ctx, target.MCPServer, target.TargetName, data, tools.CallOptions{
 		ProgressToken:      complete.Complete(opts...).ProgressToken,
 		ToolCallInvocation: &funcCall,
 	})
+	if err == nil && len(changes) > 0 {
+		response = withCoercedArguments(response, changes)
+	}
 	if err != nil {
 		response = &types.CallResult{
 			Content: []mcp.Content{
@@ -227,15 +249,19 @@ func (a *Agents) invoke(ctx context.Context, target types.TargetMapping[types.Ta
 			IsError: true,
 		}
 	}
+	return toolResult(funcCall.ToolCall.CallID, response), nil
+}
+
+func toolResult(callID string, result *types.CallResult) *types.Message {
 	return &types.Message{
 		Role: "user",
 		Items: []types.CompletionItem{
 			{
 				ToolCallResult: &types.ToolCallResult{
-					CallID: funcCall.ToolCall.CallID,
-					Output: *response,
+					CallID: callID,
+					Output: *result,
 				},
 			},
 		},
-	}, nil
+	}
 }
@@ -57,12 +57,7 @@ func (s *server) setupContext(_ http.ResponseWriter, req *http.Request) (Context
 		err        error
 	)
 
-	currentServer := s.server
-	currentServer.Headers = map[string]string{
-		"User-Agent":    req.Header.Get("User-Agent"),
-		"Authorization": req.Header.Get("Authorization"),
-		"Cookie":        req.Header.Get("Cookie"),
-	}
+	currentServer := s.serverForRequest(req)
 
 	if threadID != "" {
 		chatClient, err = mcp.NewClient(req.Context(), "nanobot.ui", currentServer, mcp.ClientOption{
@@ -100,6 +95,18 @@ func (s *server) setupContext(_ http.ResponseWriter, req *http.Request) (Context
 	}, nil
 }
 
+// serverForRequest returns the UI MCP server with the caller's credentials so that calls are made
+// on behalf of the caller.
+func (s *server) serverForRequest(req *http.Request) mcp.Server {
+	currentServer := s.server
+	currentServer.Headers = map[string]string{
+		"User-Agent":    req.Header.Get("User-Agent"),
+		"Authorization": req.Header.Get("Authorization"),
+		"Cookie":        req.Header.Get("Cookie"),
+	}
+	return currentServer
+}
+
 func (s *server) withContext(f func(rw http.ResponseWriter, req *http.Request) error) http.Handler {
 	return s.api(func(rw http.ResponseWriter, req *http.Request) error {
 		ctx, err := s.setupContext(rw, req)
@@ -4,5 +4,10 @@ import "net/http"
 
 func routes(s *server, mux *http.ServeMux) {
 	mux.Handle("GET /api/events/{thread_id}", s.withContext(Events))
+	mux.Handle("GET /api/search", s.api(s.Search))
+	mux.Handle("GET /api/threads/{thread_id}/runs", s.api(s.ListRuns))
+	mux.Handle("GET /api/threads/{thread_id}/runs/{run_id}", s.api(s.GetRun))
+	mux.Handle("POST /api/threads/{thread_id}/runs/{run_id}/resume", s.withContext(s.ResumeRun))
+	mux.Handle("POST /api/threads/{thread_id}/runs/{run_id}/stop", s.api(s.StopRun))
 	mux.Handle("GET /api/version", s.api(Version))
 }
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"gorm.io/gorm"
+)
+
+// checkThread writes a not found response and returns false if the thread of the request is not a
+// thread of the caller.
+func (s *server) checkThread(rw http.ResponseWriter, req *http.Request) bool {
+	accountID := types.NanobotContext(req.Context()).User.ID
+	_, err := s.sessionManager.DB.GetByIDByAccountID(req.Context(), req.PathValue("thread_id"), accountID)
+	if err != nil {
+		http.Error(rw, "thread not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func (s *server) getRun(rw http.ResponseWriter, req *http.Request) (*session.AgentRun, bool, error) {
+	if !s.checkThread(rw, req) {
+		return nil, false, nil
+	}
+	run, err := s.sessionManager.DB.GetAgentRun(req.Context(), req.PathValue("thread_id"), req.PathValue("run_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(rw, "run not found", http.StatusNotFound)
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return run, true, nil
+}
+
+func writeJSON(rw http.ResponseWriter, status int, obj any) error {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	return json.NewEncoder(rw).Encode(obj)
+}
+
+// ListRuns returns the agent runs of a thread, newest first.
+func (s *server) ListRuns(rw http.ResponseWriter, req *http.Request) error {
+	if !s.checkThread(rw, req) {
+		return nil
+	}
+	runs, err := s.sessionManager.DB.ListAgentRuns(req.Context(), req.PathValue("thread_id"))
+	if err != nil {
+		return err
+	}
+	if runs == nil {
+		runs = []session.AgentRun{}
+	}
+	return writeJSON(rw, http.StatusOK, map[string]any{"runs": runs})
+}
+
+// GetRun returns an agent run of a thread with its output, so callers can poll background runs.
+func (s *server) GetRun(rw http.ResponseWriter, req *http.Request) error {
+	run, ok, err := s.getRun(rw, req)
+	if !ok || err != nil {
+		return err
+	}
+	return writeJSON(rw, http.StatusOK, run.Result())
+}
+
+// ResumeRun continues an interrupted or failed run from its last checkpoint. The run continues in
+// the background, its progress is streamed to the events of the thread.
+func (s *server) ResumeRun(rw http.ResponseWriter, req *http.Request) error {
+	run, ok, err := s.getRun(rw, req)
+	if !ok || err != nil {
+		return err
+	}
+	switch run.Status {
+	case session.AgentRunInterrupted, session.AgentRunFailed:
+	default:
+		http.Error(rw, fmt.Sprintf("run is %s, only interrupted and failed runs can be resumed", run.Status), http.StatusConflict)
+		return nil
+	}
+
+	c := getContext(req.Context())
+	if _, err := c.ChatClient.Call(req.Context(), types.AgentTool+run.Agent, map[string]any{
+		"prompt": "",
+	}, mcp.CallOption{
+		ProgressToken: uuid.String(),
+		Meta: map[string]any{
+			types.AsyncMetaKey:     true,
+			types.ResumeRunMetaKey: run.RunID,
+		},
+	}); err != nil {
+		// The run is claimed before it is resumed, which fails if it was resumed concurrently.
+		if rpcErr, ok := errors.AsType[*mcp.RPCError](err); ok && rpcErr.Code == mcp.ErrRPCInvalidRequest.Code {
+			http.Error(rw, err.Error(), http.StatusConflict)
+			return nil
+		}
+		return fmt.Errorf("failed to resume run %s: %w", run.RunID, err)
+	}
+
+	run.Status = session.AgentRunRunning
+	run.Error = ""
+	return writeJSON(rw, http.StatusAccepted, run)
+}
+
+// StopRun stops a run in progress, or closes an interrupted or failed run so it is not resumed.
+func (s *server) StopRun(rw http.ResponseWriter, req *http.Request) error {
+	if !s.checkThread(rw, req) {
+		return nil
+	}
+	run, err := s.sessionManager.StopAgentRun(req.Context(), req.PathValue("thread_id"), req.PathValue("run_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(rw, "run not found", http.StatusNotFound)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return writeJSON(rw, http.StatusAccepted, run)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+// Search finds messages in the caller's chats matching the q query parameter. It calls the
+// search_chats tool so results are scoped to the caller's account the same way as in the UI.
+func (s *server) Search(rw http.ResponseWriter, req *http.Request) error {
+	query := req.URL.Query().Get("q")
+	if query == "" {
+		http.Error(rw, "missing q query parameter", http.StatusBadRequest)
+		return nil
+	}
+
+	args := map[string]any{
+		"query": query,
+	}
+	if limit := req.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("invalid limit %q", limit), http.StatusBadRequest)
+			return nil
+		}
+		args["limit"] = n
+	}
+
+	client, err := mcp.NewClient(req.Context(), "nanobot.ui", s.serverForRequest(req))
+	if err != nil {
+		return err
+	}
+	defer client.Close(false)
+
+	result, err := client.Call(req.Context(), "search_chats", args)
+	if err != nil {
+		return err
+	}
+	if result.IsError {
+		var msg string
+		if len(result.Content) > 0 {
+			msg = result.Content[0].Text
+		}
+		return fmt.Errorf("search failed: %s", msg)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if result.StructuredContent != nil {
+		return json.NewEncoder(rw).Encode(result.StructuredContent)
+	}
+	if len(result.Content) > 0 {
+		_, err = rw.Write([]byte(result.Content[0].Text))
+		return err
+	}
+	return json.NewEncoder(rw).Encode(map[string]any{"results": []any{}})
+}
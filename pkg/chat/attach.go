@@ -0,0 +1,47 @@
+package chat
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// maxAttachmentSize limits attachments read from disk since they are sent inline as data URIs.
+const maxAttachmentSize = 20 * 1024 * 1024
+
+// Attach reads a local file into an attachment with a data URI.
+func Attach(path string) (types.Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return types.Attachment{}, err
+	}
+	if info.IsDir() {
+		return types.Attachment{}, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxAttachmentSize {
+		return types.Attachment{}, fmt.Errorf("%s is larger than the %d MB attachment limit", path, maxAttachmentSize/1024/1024)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return types.Attachment{}, err
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(path))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+
+	return types.Attachment{
+		Name:     filepath.Base(path),
+		URL:      "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data),
+		MimeType: mimeType,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestPrompterQuestions(t *testing.T) {
+	meta, _ := json.Marshal(map[string]any{
+		types.MetaPrefix + "question": []map[string]any{
+			{
+				"question": "Which color?",
+				"header":   "Color",
+				"options":  []map[string]any{{"label": "Red"}, {"label": "Blue"}},
+			},
+			{
+				"question": "Which sizes?",
+				"header":   "Sizes",
+				"multiple": true,
+				"options":  []map[string]any{{"label": "S"}, {"label": "M"}, {"label": "L"}},
+			},
+			{
+				"question": "Anything else?",
+				"header":   "Notes",
+				"options":  []map[string]any{{"label": "No"}},
+			},
+		},
+	})
+
+	p := Prompter{
+		In:  bufio.NewReader(strings.NewReader("2\n1, 3\nship it fast\n")),
+		Out: io.Discard,
+	}
+	result, err := p.Elicit(mcp.ElicitRequest{Meta: meta})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Action != "accept" {
+		t.Fatalf("expected accept, got %q", result.Action)
+	}
+	expected := map[string]any{
+		"q0": "Blue",
+		"q1": `["S","L"]`,
+		"q2": "ship it fast",
+	}
+	for key, value := range expected {
+		if result.Content[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, result.Content[key])
+		}
+	}
+}
+
+func TestPrompterOAuth(t *testing.T) {
+	meta, _ := json.Marshal(map[string]any{
+		types.MetaPrefix + "oauth-url":   "https://example.com/authorize",
+		types.MetaPrefix + "server-name": "github",
+	})
+
+	var out strings.Builder
+	p := Prompter{
+		In:  bufio.NewReader(strings.NewReader("\n")),
+		Out: &out,
+	}
+	result, err := p.Elicit(mcp.ElicitRequest{Meta: meta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != "accept" {
+		t.Fatalf("expected accept, got %q", result.Action)
+	}
+	if !strings.Contains(out.String(), "https://example.com/authorize") {
+		t.Fatalf("expected the authorization URL to be printed, got %q", out.String())
+	}
+}
+
+func TestPrinterStreamsDeltas(t *testing.T) {
+	var out strings.Builder
+	p := NewPrinter(&out)
+
+	for _, progress := range []types.CompletionProgress{
+		{Model: "gpt-4.1", Role: "assistant", Item: types.CompletionItem{ID: "1", Partial: true, Content: &mcp.Content{Text: "Hello"}}},
+		{Role: "assistant", Item: types.CompletionItem{ID: "1", Partial: true, Content: &mcp.Content{Text: " world"}}},
+		{Role: "assistant", Item: types.CompletionItem{ID: "1", Content: &mcp.Content{Text: "Hello world"}}},
+		{Role: "assistant", Item: types.CompletionItem{ID: "2", Partial: true, ToolCall: &types.ToolCall{CallID: "c1", Name: "search"}}},
+		{Role: "assistant", Item: types.CompletionItem{ID: "2", Partial: true, ToolCall: &types.ToolCall{Arguments: "{}"}}},
+		{Item: types.CompletionItem{ToolCallResult: &types.ToolCallResult{CallID: "c1"}}},
+	} {
+		p.Progress(progress)
+	}
+
+	if !p.Finish() {
+		t.Fatal("expected text to have been streamed")
+	}
+	if expected := "Hello world\n  → search\n  ← search done\n"; out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+	if p.Model() != "gpt-4.1" {
+		t.Fatalf("expected model gpt-4.1, got %q", p.Model())
+	}
+}
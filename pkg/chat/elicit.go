@@ -0,0 +1,254 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+type question struct {
+	Question string `json:"question"`
+	Header   string `json:"header"`
+	Multiple bool   `json:"multiple,omitempty"`
+	Options  []struct {
+		Label       string `json:"label"`
+		Description string `json:"description,omitempty"`
+	} `json:"options"`
+}
+
+// Prompter answers elicitation requests by asking the user on a terminal.
+type Prompter struct {
+	In  *bufio.Reader
+	Out io.Writer
+}
+
+// Elicit renders the request and reads the user's answer. Questions from askUserQuestion are shown
+// with their options, OAuth requests print the authorization URL and any other request prompts for
+// each property of the requested schema.
+func (p Prompter) Elicit(req mcp.ElicitRequest) (mcp.ElicitResult, error) {
+	var meta map[string]json.RawMessage
+	if len(req.Meta) > 0 {
+		_ = json.Unmarshal(req.Meta, &meta)
+	}
+
+	if raw, ok := meta[types.MetaPrefix+"oauth-url"]; ok {
+		var url, server string
+		_ = json.Unmarshal(raw, &url)
+		_ = json.Unmarshal(meta[types.MetaPrefix+"server-name"], &server)
+		return p.oauth(server, url)
+	}
+
+	if raw, ok := meta[types.MetaPrefix+"question"]; ok {
+		var questions []question
+		if err := unmarshalMetaValue(raw, &questions); err == nil && len(questions) > 0 {
+			return p.questions(questions)
+		}
+	}
+
+	return p.generic(req)
+}
+
+func (p Prompter) oauth(server, url string) (mcp.ElicitResult, error) {
+	_, _ = fmt.Fprintf(p.Out, "\nMCP server %s requires authorization. Open this URL in your browser:\n\n  %s\n\n", server, url)
+	answer, err := p.ask("Press Enter once authorization is complete, or type n to cancel: ")
+	if err != nil {
+		return mcp.ElicitResult{}, err
+	}
+	if isNo(answer) {
+		return mcp.ElicitResult{Action: "cancel"}, nil
+	}
+	return mcp.ElicitResult{Action: "accept"}, nil
+}
+
+func (p Prompter) questions(questions []question) (mcp.ElicitResult, error) {
+	content := map[string]any{}
+	for i, q := range questions {
+		_, _ = fmt.Fprintf(p.Out, "\n%s: %s\n", q.Header, q.Question)
+		for j, opt := range q.Options {
+			if opt.Description != "" {
+				_, _ = fmt.Fprintf(p.Out, "  %d) %s - %s\n", j+1, opt.Label, opt.Description)
+			} else {
+				_, _ = fmt.Fprintf(p.Out, "  %d) %s\n", j+1, opt.Label)
+			}
+		}
+
+		prompt := "Choose an option or type an answer (Enter to skip): "
+		if q.Multiple {
+			prompt = "Choose options separated by commas or type an answer (Enter to skip): "
+		}
+		answer, err := p.ask(prompt)
+		if err != nil {
+			return mcp.ElicitResult{}, err
+		}
+		if answer == "" {
+			continue
+		}
+		content[fmt.Sprintf("q%d", i)] = answerQuestion(q, answer)
+	}
+
+	if len(content) == 0 {
+		return mcp.ElicitResult{Action: "decline"}, nil
+	}
+	return mcp.ElicitResult{
+		Action:  "accept",
+		Content: content,
+	}, nil
+}
+
+// answerQuestion turns the user's input into the answer format expected by askUserQuestion: the label
+// of the chosen option, a JSON array of labels for multiple choice questions, or the free text typed.
+func answerQuestion(q question, answer string) string {
+	var labels []string
+	for _, choice := range strings.Split(answer, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(choice))
+		if err != nil || n < 1 || n > len(q.Options) {
+			labels = nil
+			break
+		}
+		labels = append(labels, q.Options[n-1].Label)
+	}
+
+	switch {
+	case len(labels) == 0:
+		labels = []string{answer}
+	case !q.Multiple && len(labels) > 1:
+		labels = []string{answer}
+	}
+
+	if !q.Multiple {
+		return labels[0]
+	}
+	data, _ := json.Marshal(labels)
+	return string(data)
+}
+
+func (p Prompter) generic(req mcp.ElicitRequest) (mcp.ElicitResult, error) {
+	_, _ = fmt.Fprintf(p.Out, "\n%s\n", strings.TrimSpace(req.Message))
+
+	if len(req.RequestedSchema.Properties) == 0 {
+		answer, err := p.ask("Continue? [Y/n]: ")
+		if err != nil {
+			return mcp.ElicitResult{}, err
+		}
+		if isNo(answer) {
+			return mcp.ElicitResult{Action: "decline"}, nil
+		}
+		return mcp.ElicitResult{Action: "accept"}, nil
+	}
+
+	content := map[string]any{}
+	for _, name := range slices.Sorted(maps.Keys(req.RequestedSchema.Properties)) {
+		prop := req.RequestedSchema.Properties[name]
+		for {
+			value, err := p.property(name, prop, slices.Contains(req.RequestedSchema.Required, name))
+			if err != nil {
+				if _, ok := err.(invalidInputError); ok {
+					_, _ = fmt.Fprintln(p.Out, err.Error())
+					continue
+				}
+				return mcp.ElicitResult{}, err
+			}
+			if value != nil {
+				content[name] = value
+			}
+			break
+		}
+	}
+
+	return mcp.ElicitResult{
+		Action:  "accept",
+		Content: content,
+	}, nil
+}
+
+type invalidInputError string
+
+func (e invalidInputError) Error() string {
+	return string(e)
+}
+
+func (p Prompter) property(name string, prop mcp.PrimitiveProperty, required bool) (any, error) {
+	label := name
+	if prop.Title != "" {
+		label = prop.Title
+	}
+	if prop.Description != "" {
+		label += " (" + prop.Description + ")"
+	}
+	if len(prop.Enum) > 0 {
+		label += " [" + strings.Join(prop.Enum, ", ") + "]"
+	} else if prop.Type == "boolean" {
+		label += " [y/n]"
+	}
+
+	answer, err := p.ask(label + ": ")
+	if err != nil {
+		return nil, err
+	}
+	if answer == "" {
+		if prop.Default != nil {
+			return prop.Default, nil
+		}
+		if required {
+			return nil, invalidInputError(name + " is required")
+		}
+		return nil, nil
+	}
+
+	switch {
+	case len(prop.Enum) > 0:
+		if !slices.Contains(prop.Enum, answer) {
+			return nil, invalidInputError("must be one of: " + strings.Join(prop.Enum, ", "))
+		}
+		return answer, nil
+	case prop.Type == "boolean":
+		return !isNo(answer), nil
+	case prop.Type == "integer":
+		n, err := strconv.Atoi(answer)
+		if err != nil {
+			return nil, invalidInputError("must be an integer")
+		}
+		return n, nil
+	case prop.Type == "number":
+		n, err := strconv.ParseFloat(answer, 64)
+		if err != nil {
+			return nil, invalidInputError("must be a number")
+		}
+		return n, nil
+	}
+	return answer, nil
+}
+
+func (p Prompter) ask(prompt string) (string, error) {
+	_, _ = io.WriteString(p.Out, prompt)
+	line, err := p.In.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func isNo(answer string) bool {
+	switch strings.ToLower(answer) {
+	case "n", "no":
+		return true
+	}
+	return false
+}
+
+// unmarshalMetaValue decodes a _meta value that is either inline JSON or a string containing JSON.
+func unmarshalMetaValue(raw json.RawMessage, out any) error {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		raw = json.RawMessage(str)
+	}
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,147 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// Printer renders the completion progress of an agent call to a terminal as it streams in: assistant
+// text is written as it arrives and tool calls are summarized on their own lines.
+type Printer struct {
+	out       io.Writer
+	lock      sync.Mutex
+	model     string
+	lastItem  string
+	midLine   bool
+	wroteText bool
+	streamed  map[string]struct{}
+	toolCalls map[string]string
+}
+
+func NewPrinter(out io.Writer) *Printer {
+	return &Printer{
+		out:       out,
+		streamed:  map[string]struct{}{},
+		toolCalls: map[string]string{},
+	}
+}
+
+// Notification handles an MCP notification, rendering it if it is completion progress.
+func (p *Printer) Notification(msg mcp.Message) {
+	if msg.Method != "notifications/progress" {
+		return
+	}
+
+	var payload struct {
+		Meta struct {
+			Progress *types.CompletionProgress `json:"ai.nanobot.progress/completion"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(msg.Params, &payload); err != nil || payload.Meta.Progress == nil {
+		return
+	}
+	p.Progress(*payload.Meta.Progress)
+}
+
+// Progress renders a single completion progress event.
+func (p *Printer) Progress(progress types.CompletionProgress) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if progress.Model != "" {
+		p.model = progress.Model
+	}
+
+	item := progress.Item
+	switch {
+	case item.ToolCallResult != nil:
+		name := p.toolCalls[item.ToolCallResult.CallID]
+		if name == "" {
+			return
+		}
+		status := "done"
+		if item.ToolCallResult.Output.IsError {
+			status = "failed"
+		}
+		p.line("  ← %s %s", name, status)
+	case item.ToolCall != nil:
+		if item.ToolCall.CallID == "" || item.ToolCall.Name == "" {
+			return
+		}
+		if _, seen := p.toolCalls[item.ToolCall.CallID]; seen {
+			return
+		}
+		p.toolCalls[item.ToolCall.CallID] = item.ToolCall.Name
+		p.line("  → %s", item.ToolCall.Name)
+	case item.Content != nil && item.Content.Text != "":
+		if progress.Role != "" && progress.Role != "assistant" {
+			return
+		}
+		if !item.Partial {
+			// The complete item is sent after its deltas, only print it if it was not streamed.
+			if _, streamed := p.streamed[item.ID]; streamed {
+				return
+			}
+		}
+		p.streamed[item.ID] = struct{}{}
+		if item.ID != p.lastItem && p.midLine {
+			_, _ = fmt.Fprintln(p.out)
+			p.midLine = false
+		}
+		p.lastItem = item.ID
+		_, _ = io.WriteString(p.out, item.Content.Text)
+		p.midLine = !strings.HasSuffix(item.Content.Text, "\n")
+		p.wroteText = true
+	}
+}
+
+// Model returns the last model reported by the progress events.
+func (p *Printer) Model() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.model
+}
+
+// Finish ends the current line and resets the printer for the next call. It returns whether any
+// assistant text was printed since the last call to Finish.
+func (p *Printer) Finish() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.midLine {
+		_, _ = fmt.Fprintln(p.out)
+	}
+	wroteText := p.wroteText
+	p.lastItem, p.midLine, p.wroteText = "", false, false
+	clear(p.streamed)
+	clear(p.toolCalls)
+	return wroteText
+}
+
+// Locked runs f while no progress is being printed, so that prompts are not interleaved with output.
+func (p *Printer) Locked(f func()) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.midLine {
+		_, _ = fmt.Fprintln(p.out)
+		p.midLine = false
+		p.lastItem = ""
+	}
+	f()
+}
+
+func (p *Printer) line(format string, args ...any) {
+	if p.midLine {
+		_, _ = fmt.Fprintln(p.out)
+		p.midLine = false
+		p.lastItem = ""
+	}
+	_, _ = fmt.Fprintf(p.out, format+"\n", args...)
+}
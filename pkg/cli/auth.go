@@ -0,0 +1,405 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/envvar"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// AuthCommand manages the OAuth tokens of remote MCP servers, stored in the state database or in the
+// local token file.
+type AuthCommand struct {
+	Nanobot *Nanobot
+	Account string `usage:"Account of the tokens in the state database (default: all accounts)"`
+	Local   bool   `usage:"Use the local token file instead of the state database"`
+}
+
+func NewAuthCommand(n *Nanobot) *AuthCommand {
+	return &AuthCommand{
+		Nanobot: n,
+	}
+}
+
+func (o *AuthCommand) Customize(cmd *cobra.Command) {
+	cmd.Use = "auth"
+	cmd.Short = "Manage the stored OAuth tokens of remote MCP servers"
+	cmd.Args = cobra.NoArgs
+}
+
+// ParentEnv keeps the NANOBOT_ env prefix for the flags shared by the auth subcommands.
+func (o *AuthCommand) ParentEnv() string {
+	return "NANOBOT_"
+}
+
+func (o *AuthCommand) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+// storage returns the token storage and a context for the selected account.
+func (o *AuthCommand) storage(ctx context.Context) (context.Context, mcp.TokenStorage, error) {
+	if o.Local {
+		return ctx, mcp.NewDefaultLocalStorage(), nil
+	}
+
+	store, err := session.NewStoreFromDSN(o.Nanobot.DSN())
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.Account != "" {
+		ctx = withAccount(ctx, o.Account)
+	}
+	return ctx, store, nil
+}
+
+// tokens returns the stored tokens, only the ones of a server URL if it is set.
+func (o *AuthCommand) tokens(ctx context.Context, url string) (mcp.TokenStorage, []mcp.StoredToken, error) {
+	ctx, storage, err := o.storage(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lister, ok := storage.(mcp.TokenLister)
+	if !ok {
+		return nil, nil, fmt.Errorf("token storage %T cannot list tokens", storage)
+	}
+	tokens, err := lister.ListTokenConfigs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if url == "" {
+		return storage, tokens, nil
+	}
+	var matched []mcp.StoredToken
+	for _, token := range tokens {
+		if token.URL == url {
+			matched = append(matched, token)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil, fmt.Errorf("no token stored for %s", url)
+	}
+	return storage, matched, nil
+}
+
+// server resolves an MCP server URL, or the name of an MCP server in the configuration.
+func (o *AuthCommand) server(ctx context.Context, nameOrURL string) (string, mcp.Server, error) {
+	if strings.Contains(nameOrURL, "://") {
+		return nameOrURL, mcp.Server{BaseURL: nameOrURL}, nil
+	}
+
+	cfg, err := o.Nanobot.ReadConfig(ctx, o.Nanobot.ConfigPaths(), false)
+	if err != nil {
+		return "", mcp.Server{}, err
+	}
+	server, ok := cfg.MCPServers[nameOrURL]
+	if !ok {
+		return "", mcp.Server{}, fmt.Errorf("MCP server %s not found in the configuration", nameOrURL)
+	}
+	if server.BaseURL == "" {
+		return "", mcp.Server{}, fmt.Errorf("MCP server %s is not a remote server", nameOrURL)
+	}
+	return nameOrURL, server, nil
+}
+
+func withAccount(ctx context.Context, accountID string) context.Context {
+	s := mcp.NewEmptySession(ctx)
+	s.Set(types.AccountIDSessionKey, accountID)
+	return mcp.WithSession(ctx, s)
+}
+
+type storedToken struct {
+	URL       string    `json:"url"`
+	AccountID string    `json:"accountID,omitempty"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	Expiry    time.Time `json:"expiry,omitzero"`
+	Refresh   bool      `json:"refresh"`
+}
+
+type List struct {
+	auth   *AuthCommand
+	Output string `usage:"Output format (json, yaml, table)" short:"o" default:"table"`
+}
+
+func NewList(o *AuthCommand) *List {
+	return &List{
+		auth: o,
+	}
+}
+
+func (l *List) Customize(cmd *cobra.Command) {
+	cmd.Use = "list [flags]"
+	cmd.Short = "List the stored OAuth tokens"
+	cmd.Aliases = []string{"ls"}
+	cmd.Args = cobra.NoArgs
+}
+
+func (l *List) Run(cmd *cobra.Command, _ []string) error {
+	_, tokens, err := l.auth.tokens(cmd.Context(), "")
+	if err != nil {
+		return err
+	}
+
+	result := make([]storedToken, 0, len(tokens))
+	for _, token := range tokens {
+		t := storedToken{
+			URL:       token.URL,
+			AccountID: token.AccountID,
+		}
+		if token.Config != nil {
+			t.Scopes = token.Config.Scopes
+		}
+		if token.Token != nil {
+			t.Expiry = token.Token.Expiry
+			t.Refresh = token.Token.RefreshToken != ""
+		}
+		result = append(result, t)
+	}
+
+	if display(result, l.Output) {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, err = tw.Write([]byte("URL\tACCT\tSCOPES\tEXPIRES\tREFRESH\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, t := range result {
+		expires := "never"
+		if !t.Expiry.IsZero() {
+			expires = t.Expiry.Format(time.RFC3339)
+			if t.Expiry.Before(time.Now()) {
+				expires += " (expired)"
+			}
+		}
+		refresh := "no"
+		if t.Refresh {
+			refresh = "yes"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", t.URL, trim(t.AccountID), trim(strings.Join(t.Scopes, " ")), expires, refresh)
+	}
+
+	return tw.Flush()
+}
+
+type Login struct {
+	auth            *AuthCommand
+	DeviceCode      bool   `usage:"Use the device authorization flow instead of redirecting the browser to a local callback"`
+	CallbackAddress string `usage:"Address of the local OAuth callback server" default:"localhost:0"`
+}
+
+func NewLogin(o *AuthCommand) *Login {
+	return &Login{
+		auth: o,
+	}
+}
+
+func (l *Login) Customize(cmd *cobra.Command) {
+	cmd.Use = "login [flags] SERVER"
+	cmd.Short = "Log in to a remote MCP server and store its OAuth token"
+	cmd.Long = `Log in to a remote MCP server and store its OAuth token. SERVER is the URL of the server or the
+name of an MCP server in the configuration.
+
+The authorization URL is printed to open in a browser, which is redirected to a local callback
+server. With --device-code a code is printed to enter on any device instead.`
+	cmd.Example = `
+  # Log in to a server from nanobot.yaml, storing the token for an account in the state database
+  nanobot auth login --account me github
+
+  # Log in on a machine without a browser, storing the token in the local token file
+  nanobot auth login --local --device-code https://mcp.example.com/mcp
+`
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (l *Login) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	if !l.auth.Local && l.auth.Account == "" {
+		return fmt.Errorf("an account is required to store a token in the state database, use --account or --local")
+	}
+
+	name, server, err := l.auth.server(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx, storage, err := l.auth.storage(ctx)
+	if err != nil {
+		return err
+	}
+
+	env, err := l.auth.Nanobot.loadEnv()
+	if err != nil {
+		return fmt.Errorf("failed to load environment: %w", err)
+	}
+
+	opts := mcp.HTTPClientOptions{
+		TokenStorage: storage,
+	}
+	if l.DeviceCode {
+		opts.DeviceCodeHandler = printDeviceCode{}
+	} else {
+		listener, err := net.Listen("tcp", l.CallbackAddress)
+		if err != nil {
+			return fmt.Errorf("failed to start OAuth callback server: %w", err)
+		}
+		defer listener.Close()
+
+		callbackServer := mcp.NewCallbackServer(printAuthURL{})
+		mux := http.NewServeMux()
+		mux.Handle("/oauth/callback", callbackServer)
+		go func() {
+			if err := http.Serve(listener, mux); err != nil && !errors.Is(err, net.ErrClosed) {
+				fmt.Fprintf(os.Stderr, "OAuth callback server failed: %v\n", err)
+			}
+		}()
+
+		opts.CallbackHandler = callbackServer
+		opts.OAuthRedirectURL = fmt.Sprintf("http://localhost:%d/oauth/callback", listener.Addr().(*net.TCPAddr).Port)
+	}
+
+	if err := mcp.Login(ctx, name, server, opts, envvar.ReplaceMap(env, server.Headers)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Logged in to %s\n", mcp.TokenURL(server.BaseURL))
+	return nil
+}
+
+type printAuthURL struct{}
+
+func (printAuthURL) HandleAuthURL(_ context.Context, serverName, authURL string) (bool, error) {
+	_, _ = fmt.Fprintf(os.Stderr, "Open this URL in a browser to authorize %s:\n\n  %s\n\n", serverName, authURL)
+	return true, nil
+}
+
+type printDeviceCode struct{}
+
+func (printDeviceCode) HandleDeviceCode(_ context.Context, serverName string, resp *oauth2.DeviceAuthResponse) error {
+	if resp.VerificationURIComplete != "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Open this URL on any device to authorize %s:\n\n  %s\n\nand confirm the code %s\n\n", serverName, resp.VerificationURIComplete, resp.UserCode)
+		return nil
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Open this URL on any device to authorize %s:\n\n  %s\n\nand enter the code %s\n\n", serverName, resp.VerificationURI, resp.UserCode)
+	return nil
+}
+
+type Logout struct {
+	auth *AuthCommand
+}
+
+func NewLogout(o *AuthCommand) *Logout {
+	return &Logout{
+		auth: o,
+	}
+}
+
+func (l *Logout) Customize(cmd *cobra.Command) {
+	cmd.Use = "logout [flags] SERVER"
+	cmd.Short = "Delete the stored OAuth token of a remote MCP server"
+	cmd.Long = `Delete the stored OAuth token of a remote MCP server. SERVER is the URL of the server or the name
+of an MCP server in the configuration. Without --account the token of every account is deleted.`
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (l *Logout) Run(cmd *cobra.Command, args []string) error {
+	_, server, err := l.auth.server(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	url := mcp.TokenURL(server.BaseURL)
+	storage, tokens, err := l.auth.tokens(cmd.Context(), url)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if err := storage.DeleteTokenConfig(tokenContext(cmd.Context(), token), token.URL); err != nil {
+			return fmt.Errorf("failed to delete token for %s: %w", token.URL, err)
+		}
+	}
+
+	fmt.Printf("Logged out of %s\n", url)
+	return nil
+}
+
+type Refresh struct {
+	auth *AuthCommand
+}
+
+func NewRefresh(o *AuthCommand) *Refresh {
+	return &Refresh{
+		auth: o,
+	}
+}
+
+func (r *Refresh) Customize(cmd *cobra.Command) {
+	cmd.Use = "refresh [flags] [SERVER]"
+	cmd.Short = "Refresh the stored OAuth tokens with their refresh tokens"
+	cmd.Long = `Refresh the stored OAuth tokens with their refresh tokens. SERVER is the URL of the server or the
+name of an MCP server in the configuration, all tokens that can be refreshed are refreshed without it.`
+	cmd.Args = cobra.MaximumNArgs(1)
+}
+
+func (r *Refresh) Run(cmd *cobra.Command, args []string) error {
+	var url string
+	if len(args) > 0 {
+		_, server, err := r.auth.server(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		url = mcp.TokenURL(server.BaseURL)
+	}
+
+	storage, tokens, err := r.auth.tokens(cmd.Context(), url)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, token := range tokens {
+		if url == "" && (token.Token == nil || token.Token.RefreshToken == "") {
+			continue
+		}
+
+		tok, err := mcp.RefreshToken(tokenContext(cmd.Context(), token), storage, token.URL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		fmt.Printf("Refreshed %s", token.URL)
+		if token.AccountID != "" {
+			fmt.Printf(" for %s", token.AccountID)
+		}
+		if !tok.Expiry.IsZero() {
+			fmt.Printf(", expires %s", tok.Expiry.Format(time.RFC3339))
+		}
+		fmt.Println()
+	}
+	return errors.Join(errs...)
+}
+
+// tokenContext returns a context for the account of a stored token.
+func tokenContext(ctx context.Context, token mcp.StoredToken) context.Context {
+	if token.AccountID == "" {
+		return ctx
+	}
+	return withAccount(ctx, token.AccountID)
+}
@@ -0,0 +1,593 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/agents"
+	"github.com/nanobot-ai/nanobot/pkg/chat"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"github.com/spf13/cobra"
+)
+
+type Chat struct {
+	EntrypointAgent string `usage:"ID of the agent to chat with (default: the first entrypoint agent)" name:"agent"`
+	Thread          string `usage:"ID, ID prefix, or \"last\" to resume an existing thread instead of starting a new one" short:"t"`
+	n               *Nanobot
+}
+
+func NewChat(n *Nanobot) *Chat {
+	return &Chat{
+		n: n,
+	}
+}
+
+func (c *Chat) Customize(cmd *cobra.Command) {
+	cmd.Args = cobra.NoArgs
+	cmd.Use = "chat [flags]"
+	cmd.Short = "Chat with an agent in the terminal"
+	cmd.Long = `Chat with an agent in the terminal.
+
+The nanobot is started in the background using the same configuration rules as "nanobot run" and threads
+are kept in the state database, so they can be resumed later from the terminal or the UI.
+
+Type a message and press Enter to send it. Lines starting with / are commands, type /help to list them.
+Press Ctrl+C to interrupt a response and Ctrl+D to exit.
+`
+	cmd.Example = `
+  # Chat with the default agent of the configuration in .nanobot/
+  nanobot chat
+
+  # Chat with a specific agent, merging multiple configs
+  nanobot chat -c .nanobot/ -c ./local.yaml --agent researcher
+
+  # Resume the most recent thread
+  nanobot chat -t last
+`
+}
+
+func (c *Chat) Run(cmd *cobra.Command, _ []string) error {
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	if !c.n.Debug && !c.n.Trace {
+		// Server logs would be interleaved with the conversation, only show problems.
+		slog.SetDefault(slog.New(secrets.NewHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: slog.LevelWarn,
+		}))))
+	}
+
+	cfg, err := c.n.ReadConfig(ctx, c.n.ConfigPaths(), !c.n.ExcludeBuiltInAgents)
+	if err != nil {
+		return fmt.Errorf("failed to read config from %q: %w", strings.Join(c.n.ConfigPaths(), ", "), err)
+	}
+
+	address, err := localAddress()
+	if err != nil {
+		return err
+	}
+
+	run := NewRun(c.n)
+	run.ListenAddress = address
+	run.EntrypointAgent = c.EntrypointAgent
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- run.serve(ctx)
+	}()
+
+	if err := waitForServer(ctx, "http://"+address, serverErr); err != nil {
+		return err
+	}
+
+	r := &chatREPL{
+		n:       c.n,
+		url:     "http://" + address + "/mcp/ui",
+		config:  *cfg,
+		in:      bufio.NewReader(os.Stdin),
+		out:     os.Stdout,
+		printer: chat.NewPrinter(os.Stdout),
+	}
+	defer r.close()
+
+	if err := r.open(ctx, c.Thread); err != nil {
+		return err
+	}
+
+	return r.loop(ctx)
+}
+
+// localAddress picks a free loopback address for the background server.
+func localAddress() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+func waitForServer(ctx context.Context, url string, serverErr <-chan error) error {
+	deadline := time.After(time.Minute)
+	for {
+		resp, err := http.Get(url)
+		if err == nil {
+			_ = resp.Body.Close()
+			return nil
+		}
+
+		select {
+		case err := <-serverErr:
+			if err == nil {
+				err = errors.New("server exited")
+			}
+			return fmt.Errorf("failed to start nanobot: %w", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("timed out waiting for nanobot to start at %s", url)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+type chatREPL struct {
+	n           *Nanobot
+	url         string
+	config      types.Config
+	in          *bufio.Reader
+	out         io.Writer
+	printer     *chat.Printer
+	client      *mcp.Client
+	agent       string
+	attachments []types.Attachment
+}
+
+func (r *chatREPL) close() {
+	if r.client != nil {
+		r.client.Close(false)
+		r.client = nil
+	}
+}
+
+// open connects to a new thread, or to an existing one if threadRef is set.
+func (r *chatREPL) open(ctx context.Context, threadRef string) error {
+	var state *mcp.SessionState
+	if threadRef != "" {
+		id, err := r.resolveThread(ctx, threadRef)
+		if err != nil {
+			return err
+		}
+		state = &mcp.SessionState{
+			ID: id,
+			InitializeRequest: mcp.InitializeRequest{
+				Capabilities: mcp.ClientCapabilities{
+					Elicitation: &struct{}{},
+				},
+			},
+		}
+	}
+
+	client, err := mcp.NewClient(ctx, "nanobot.chat", mcp.Server{
+		BaseURL: r.url,
+	}, mcp.ClientOption{
+		ClientName:   "nanobot-chat",
+		SessionState: state,
+		OnNotify: func(_ context.Context, msg mcp.Message) error {
+			r.printer.Notification(msg)
+			return nil
+		},
+		OnElicit: func(_ context.Context, _ mcp.Message, req mcp.ElicitRequest) (result mcp.ElicitResult, err error) {
+			r.printer.Locked(func() {
+				result, err = chat.Prompter{In: r.in, Out: r.out}.Elicit(req)
+			})
+			return result, err
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to nanobot: %w", err)
+	}
+
+	r.close()
+	r.client = client
+	r.attachments = nil
+
+	if r.agent == "" {
+		agents, err := r.listAgents(ctx)
+		if err != nil {
+			return err
+		}
+		for _, agent := range agents {
+			if agent.Current || r.agent == "" {
+				r.agent = agent.ID
+			}
+		}
+		if r.agent == "" {
+			return errors.New("no agents found in the configuration")
+		}
+	}
+
+	if state == nil {
+		_, _ = fmt.Fprintf(r.out, "Started a new thread %s with %s. Type /help for commands.\n", client.Session.ID(), r.agent)
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(r.out, "Resumed thread %s with %s.\n", client.Session.ID(), r.agent)
+	messages, err := r.history(ctx)
+	if err != nil {
+		return err
+	}
+	printMessages(r.out, messages)
+	return nil
+}
+
+func (r *chatREPL) loop(ctx context.Context) error {
+	for {
+		_, _ = fmt.Fprintf(r.out, "\n%s> ", r.agent)
+		line, err := r.in.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			_, _ = fmt.Fprintln(r.out)
+			return nil
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			quit, err := r.command(ctx, line)
+			if err != nil {
+				_, _ = fmt.Fprintf(r.out, "Error: %v\n", err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+
+		if err := r.send(ctx, line); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			_, _ = fmt.Fprintf(r.out, "Error: %v\n", err)
+		}
+	}
+}
+
+func (r *chatREPL) send(ctx context.Context, prompt string) error {
+	callCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	result, err := r.client.Call(callCtx, types.AgentTool+r.agent, types.SampleCallRequest{
+		Prompt:      prompt,
+		Attachments: r.attachments,
+	}, mcp.CallOption{
+		ProgressToken: uuid.String(),
+	})
+	streamed := r.printer.Finish()
+	if err != nil {
+		if callCtx.Err() != nil && ctx.Err() == nil {
+			_, _ = fmt.Fprintln(r.out, "Interrupted.")
+			return nil
+		}
+		return err
+	}
+
+	r.attachments = nil
+	if streamed && !result.IsError {
+		return nil
+	}
+	return chat.PrintResult(r.out, result)
+}
+
+func (r *chatREPL) command(ctx context.Context, line string) (quit bool, _ error) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "quit", "exit":
+		return true, nil
+	case "help":
+		_, _ = fmt.Fprint(r.out, chatHelp)
+	case "new":
+		return false, r.open(ctx, "")
+	case "resume":
+		if arg == "" {
+			return false, errors.New("usage: /resume <thread ID, ID prefix, or last>")
+		}
+		return false, r.open(ctx, arg)
+	case "threads":
+		return false, r.threads(ctx)
+	case "agent", "agents":
+		return false, r.switchAgent(ctx, arg)
+	case "attach":
+		return false, r.attach(arg)
+	case "usage":
+		return false, r.usage(ctx)
+	default:
+		return false, fmt.Errorf("unknown command /%s, type /help for the list of commands", name)
+	}
+	return false, nil
+}
+
+const chatHelp = `Commands:
+  /agent [ID]            List agents, or switch to the agent with the given ID
+  /new                   Start a new thread
+  /threads               List threads
+  /resume <ID|last>      Resume a thread by ID, ID prefix, or the most recent one
+  /attach [path]         Attach a file to the next message, or list pending attachments
+  /usage                 Show the estimated token usage of the current thread
+  /help                  Show this help
+  /quit                  Exit
+`
+
+func (r *chatREPL) switchAgent(ctx context.Context, id string) error {
+	agents, err := r.listAgents(ctx)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		for _, agent := range agents {
+			marker := " "
+			if agent.ID == r.agent {
+				marker = "*"
+			}
+			_, _ = fmt.Fprintf(r.out, "%s %s\t%s\n", marker, agent.ID, firstLine(agent.Description))
+		}
+		return nil
+	}
+
+	for _, agent := range agents {
+		if agent.ID == id {
+			r.agent = id
+			_, _ = fmt.Fprintf(r.out, "Now chatting with %s.\n", id)
+			return nil
+		}
+	}
+	return fmt.Errorf("agent %q not found, type /agent to list agents", id)
+}
+
+func (r *chatREPL) threads(ctx context.Context) error {
+	chats, err := r.listChats(ctx)
+	if err != nil {
+		return err
+	}
+	if len(chats) == 0 {
+		_, _ = fmt.Fprintln(r.out, "No threads.")
+		return nil
+	}
+
+	for _, thread := range chats {
+		marker := " "
+		if thread.ID == r.client.Session.ID() {
+			marker = "*"
+		}
+		title := thread.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		if thread.Starred {
+			title = "★ " + title
+		}
+		_, _ = fmt.Fprintf(r.out, "%s %s  %s  %s\n", marker, thread.ID, thread.Created.Local().Format(time.DateTime), title)
+	}
+	return nil
+}
+
+func (r *chatREPL) resolveThread(ctx context.Context, ref string) (string, error) {
+	chats, err := r.listChats(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if ref == "last" {
+		// Threads are listed newest first, skip the current one so that /resume last goes back.
+		for _, thread := range chats {
+			if r.client == nil || thread.ID != r.client.Session.ID() {
+				return thread.ID, nil
+			}
+		}
+		return "", errors.New("there are no threads to resume")
+	}
+
+	var matches []string
+	for _, thread := range chats {
+		if thread.ID == ref {
+			return thread.ID, nil
+		}
+		if strings.HasPrefix(thread.ID, ref) {
+			matches = append(matches, thread.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("thread %q not found", ref)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("thread prefix %q is ambiguous, it matches %d threads", ref, len(matches))
+}
+
+func (r *chatREPL) attach(path string) error {
+	if path == "" {
+		if len(r.attachments) == 0 {
+			_, _ = fmt.Fprintln(r.out, "No pending attachments.")
+		}
+		for _, attachment := range r.attachments {
+			_, _ = fmt.Fprintf(r.out, "  %s (%s)\n", attachment.Name, attachment.MimeType)
+		}
+		return nil
+	}
+
+	attachment, err := chat.Attach(path)
+	if err != nil {
+		return err
+	}
+	r.attachments = append(r.attachments, attachment)
+	_, _ = fmt.Fprintf(r.out, "Attached %s (%s), it will be sent with the next message.\n", attachment.Name, attachment.MimeType)
+	return nil
+}
+
+func (r *chatREPL) usage(ctx context.Context) error {
+	messages, err := r.history(ctx)
+	if err != nil {
+		return err
+	}
+
+	model := r.printer.Model()
+	if model == "" {
+		model = r.config.Agents[r.agent].Model
+	}
+	switch model {
+	case "", "default":
+		model = r.n.DefaultModel
+	case "mini":
+		model = r.n.DefaultMiniModel
+	}
+
+	tokens := agents.EstimateTokens(model, messages)
+	_, _ = fmt.Fprintf(r.out, "Model:    %s\n", model)
+	_, _ = fmt.Fprintf(r.out, "Messages: %d\n", len(messages))
+
+	info, _ := r.config.LookupModel(model)
+	if info.ContextWindow > 0 {
+		_, _ = fmt.Fprintf(r.out, "Context:  ~%d of %d tokens (%.1f%%)\n", tokens, info.ContextWindow,
+			float64(tokens)*100/float64(info.ContextWindow))
+	} else {
+		_, _ = fmt.Fprintf(r.out, "Context:  ~%d tokens\n", tokens)
+	}
+	if info.Pricing != nil && info.Pricing.Input > 0 {
+		_, _ = fmt.Fprintf(r.out, "Cost:     ~$%.4f of input per request at the current context size\n",
+			float64(tokens)*info.Pricing.Input/1_000_000)
+	}
+	return nil
+}
+
+func (r *chatREPL) history(ctx context.Context) ([]types.Message, error) {
+	resources, err := r.client.ListResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resources: %w", err)
+	}
+
+	var messages []types.Message
+	for _, resource := range resources.Resources {
+		if resource.MimeType != types.HistoryMimeType {
+			continue
+		}
+		contents, err := r.client.ReadResource(ctx, resource.URI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		for _, content := range contents.Contents {
+			if content.MIMEType != types.MessageMimeType || content.Text == nil {
+				continue
+			}
+			var message types.Message
+			if err := json.Unmarshal([]byte(*content.Text), &message); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal message: %w", err)
+			}
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (r *chatREPL) listAgents(ctx context.Context) ([]types.AgentDisplay, error) {
+	var agents types.AgentList
+	if err := callJSON(ctx, r.client, "list_agents", &agents); err != nil {
+		return nil, err
+	}
+	return agents.Agents, nil
+}
+
+func (r *chatREPL) listChats(ctx context.Context) ([]types.Chat, error) {
+	client := r.client
+	if client == nil {
+		// Resolving the thread to resume happens before the REPL is connected to a thread.
+		var err error
+		client, err = mcp.NewClient(ctx, "nanobot.chat", mcp.Server{
+			BaseURL: r.url,
+		}, mcp.ClientOption{
+			ClientName: "nanobot-chat",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to nanobot: %w", err)
+		}
+		defer client.Close(true)
+	}
+
+	var chats types.ChatList
+	if err := callJSON(ctx, client, "list_chats", &chats); err != nil {
+		return nil, err
+	}
+	if client != r.client {
+		// Don't list the session that was only created to do the listing.
+		chats.Chats = slices.DeleteFunc(chats.Chats, func(chat types.Chat) bool {
+			return chat.ID == client.Session.ID()
+		})
+	}
+	return chats.Chats, nil
+}
+
+func callJSON[T any](ctx context.Context, client *mcp.Client, tool string, out *T) error {
+	result, err := client.Call(ctx, tool, map[string]any{})
+	if err != nil {
+		return err
+	}
+	if result.IsError {
+		var msg string
+		if len(result.Content) > 0 {
+			msg = result.Content[0].Text
+		}
+		return fmt.Errorf("%s failed: %s", tool, msg)
+	}
+	if result.StructuredContent != nil {
+		return mcp.JSONCoerce(result.StructuredContent, out)
+	}
+	if len(result.Content) > 0 {
+		return json.Unmarshal([]byte(result.Content[0].Text), out)
+	}
+	return nil
+}
+
+// printMessages prints the text of previous messages in a thread and the names of the tools called.
+func printMessages(out io.Writer, messages []types.Message) {
+	for _, msg := range messages {
+		for _, item := range msg.Items {
+			switch {
+			case item.Content != nil && item.Content.Text != "":
+				if msg.Role == "user" {
+					_, _ = fmt.Fprintf(out, "\n> %s\n", strings.TrimSpace(item.Content.Text))
+				} else {
+					_, _ = fmt.Fprintf(out, "%s\n", strings.TrimSpace(item.Content.Text))
+				}
+			case item.ToolCall != nil && item.ToolCall.Name != "":
+				_, _ = fmt.Fprintf(out, "  → %s\n", item.ToolCall.Name)
+			}
+		}
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
@@ -13,16 +13,19 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nanobot-ai/nanobot/pkg/a2a"
 	"github.com/nanobot-ai/nanobot/pkg/api"
 	"github.com/nanobot-ai/nanobot/pkg/auth"
 	"github.com/nanobot-ai/nanobot/pkg/cmd"
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/config"
+	"github.com/nanobot-ai/nanobot/pkg/envvar"
 	"github.com/nanobot-ai/nanobot/pkg/llm"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
 	"github.com/nanobot-ai/nanobot/pkg/runtime"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
 	"github.com/nanobot-ai/nanobot/pkg/server"
 	"github.com/nanobot-ai/nanobot/pkg/session"
 	"github.com/nanobot-ai/nanobot/pkg/telemetry"
@@ -35,11 +38,15 @@ import (
 
 func New() *cobra.Command {
 	n := &Nanobot{}
+	auth := NewAuthCommand(n)
 
 	root := cmd.Command(n,
 		NewCall(n),
+		NewChat(n),
 		NewTargets(n),
-		NewSessions(n),
+		cmd.Command(NewSessions(n), NewPrune(n), NewReencrypt(n)),
+		cmd.Command(auth, NewList(auth), NewLogin(auth), NewLogout(auth), NewRefresh(auth)),
+		cmd.Command(NewSecretsCommand(), NewEncrypt()),
 		NewRun(n))
 	return root
 }
@@ -55,8 +62,11 @@ type Nanobot struct {
 	MaxConcurrency       int      `usage:"The maximum number of concurrent tasks in a parallel loop" default:"10" hidden:"true"`
 	Chdir                string   `usage:"Change directory to this path before running the nanobot" default:"." short:"C"`
 	State                string   `usage:"Path to the state file" default:"./nanobot.db"`
+	StateEncryptionKey   string   `usage:"Key to encrypt the session state and stored tokens in the state database" env:"NANOBOT_STATE_ENCRYPTION_KEY"`
+	StateKeyFile         string   `usage:"File with the keys to encrypt the state, one per line. The first key encrypts and the others only decrypt, see nanobot sessions reencrypt" env:"NANOBOT_STATE_KEY_FILE"`
 	ConfigPath           []string `usage:"Configuration file, directory, URL, or repo ref. Repeat to merge multiple configs; later entries override earlier ones" name:"config" short:"c"`
 	ExcludeBuiltInAgents bool     `usage:"Exclude built-in agents from the configuration"`
+	SandboxBackend       string   `usage:"Default backend for sandboxed MCP servers (docker, podman, namespace)" default:"docker" env:"NANOBOT_SANDBOX_BACKEND"`
 
 	otel *telemetry.Otel
 }
@@ -121,6 +131,10 @@ func (n *Nanobot) PersistentPre(cmd *cobra.Command, _ []string) error {
 	log.ConfigureSlog(n.Debug, n.Trace)
 	log.EnableMessages = log.EnableMessages || n.Debug || n.Trace
 
+	if err := n.configureStateEncryption(); err != nil {
+		return err
+	}
+
 	if n.otel == nil {
 		otel, err := telemetry.New(cmd.Context())
 		if err != nil {
@@ -143,6 +157,36 @@ func (n *Nanobot) PersistentPre(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
+// configureStateEncryption sets the keyring of the session stores from --state-encryption-key and
+// --state-key-file. The flag key comes first, so it encrypts when both are set.
+func (n *Nanobot) configureStateEncryption() error {
+	var keys []string
+	if n.StateEncryptionKey != "" {
+		keys = append(keys, n.StateEncryptionKey)
+	}
+	if n.StateKeyFile != "" {
+		fileKeys, err := session.ReadKeyFile(n.StateKeyFile)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	if len(keys) == 0 {
+		session.SetKeyring(nil)
+		return nil
+	}
+
+	keyring, err := session.NewKeyring(keys...)
+	if err != nil {
+		return fmt.Errorf("failed to configure state encryption: %w", err)
+	}
+	for _, key := range keys {
+		secrets.Register(key)
+	}
+	session.SetKeyring(keyring)
+	return nil
+}
+
 func display(obj any, format string) bool {
 	switch format {
 	case "json":
@@ -225,8 +269,25 @@ func (n *Nanobot) loadEnv() (map[string]string, error) {
 }
 
 func (n *Nanobot) ReadConfig(ctx context.Context, cfgPaths []string, includeDefaultAgents bool, opts ...runtime.Options) (*types.Config, error) {
-	cfg, _, err := config.LoadMany(ctx, cfgPaths, includeDefaultAgents, complete.Complete(opts...).Profiles...)
-	return cfg, err
+	opt := complete.Complete(opts...)
+	cfg, _, err := config.LoadMany(ctx, cfgPaths, includeDefaultAgents, opt.Profiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	key := opt.EncryptionKey
+	if key == "" && cfg.Auth != nil && cfg.Auth.EncryptionKey != "" {
+		// The key in the configuration is usually a reference like ${file:/run/secrets/key}.
+		env, err := n.loadEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load environment: %w", err)
+		}
+		key = envvar.ReplaceString(env, cfg.Auth.EncryptionKey)
+	}
+	if err := config.Decrypt(cfg, key); err != nil {
+		return nil, fmt.Errorf("failed to decrypt config: %w", err)
+	}
+	return cfg, nil
 }
 
 func (n *Nanobot) ConfigPaths() []string {
@@ -260,7 +321,9 @@ func runtimeConfigDir(configPaths []string) string {
 }
 
 func (n *Nanobot) GetRuntime(ctx context.Context, opts ...runtime.Options) (*runtime.Runtime, error) {
-	return runtime.NewRuntime(ctx, n.llmConfig(), opts...)
+	return runtime.NewRuntime(ctx, n.llmConfig(), append([]runtime.Options{{
+		SandboxBackend: n.SandboxBackend,
+	}}, opts...)...)
 }
 
 func (n *Nanobot) Run(cmd *cobra.Command, _ []string) error {
@@ -273,6 +336,11 @@ type mcpOpts struct {
 	HealthzPath        string
 	ForceFetchToolList bool
 	StartUI            bool
+	SessionManager     session.ManagerOptions
+	WebSocket          bool
+	// CloseInterruptedRuns closes the agent runs interrupted by a restart instead of leaving them
+	// to be resumed.
+	CloseInterruptedRuns bool
 }
 
 func (n *Nanobot) runMCP(ctx context.Context, baseConfig types.ConfigFactory, runt *runtime.Runtime, oauthCallbackHandler mcp.CallbackServer, auditLogCollector *auditlogs.Collector, store *session.Store, opts mcpOpts) error {
@@ -305,7 +373,12 @@ func (n *Nanobot) runMCP(ctx context.Context, baseConfig types.ConfigFactory, ru
 		return fmt.Errorf("https:// is not supported, use http:// instead")
 	}
 
-	sessionManager := session.NewManager(store)
+	sessionManager := session.NewManager(store, opts.SessionManager)
+	if store != nil {
+		if _, err := sessionManager.RecoverAgentRuns(ctx, opts.CloseInterruptedRuns); err != nil {
+			return fmt.Errorf("failed to recover interrupted agent runs: %w", err)
+		}
+	}
 
 	var mcpServer mcp.MessageHandler = server.NewServer(runt, config, sessionManager, server.Options{
 		ForceFetchToolList: opts.ForceFetchToolList,
@@ -326,6 +399,7 @@ func (n *Nanobot) runMCP(ctx context.Context, baseConfig types.ConfigFactory, ru
 		RunHealthChecker:  opts.HealthzPath != "" && os.Getenv("NANOBOT_DISABLE_HEALTH_CHECKER") != "true",
 		SessionStore:      sessionManager,
 		AuditLogCollector: auditLogCollector,
+		WebSocket:         opts.WebSocket,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP server: %w", err)
@@ -335,10 +409,18 @@ func (n *Nanobot) runMCP(ctx context.Context, baseConfig types.ConfigFactory, ru
 	if oauthCallbackHandler != nil {
 		mux.Handle("/oauth/callback", oauthCallbackHandler)
 	}
+	a2aHandler := a2a.Handler(config, sessionManager, address)
+	mux.Handle("/a2a/", a2aHandler)
+	mux.Handle(a2a.WellKnownPath, a2aHandler)
+	mux.Handle(a2a.WellKnownCardPath, a2aHandler)
+	apiHandler := api.Handler(sessionManager, address)
+	// The runs of threads are served to every client, not only browsers, so scripts can poll the
+	// background runs they start.
+	mux.Handle("/api/threads/", sessionManager.Forward(apiHandler))
 	if opts.StartUI {
-		mux.Handle("/", session.UISession(httpServer, sessionManager, api.Handler(sessionManager, address)))
+		mux.Handle("/", sessionManager.Forward(session.UISession(httpServer, sessionManager, apiHandler)))
 	} else {
-		mux.Handle("/", httpServer)
+		mux.Handle("/", sessionManager.Forward(httpServer))
 	}
 
 	handler, err := auth.Wrap(ctx, env, opts.Auth, n.DSN(), opts.HealthzPath, mux)
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+type SecretsCommand struct{}
+
+func NewSecretsCommand() *SecretsCommand {
+	return &SecretsCommand{}
+}
+
+func (s *SecretsCommand) Customize(cmd *cobra.Command) {
+	cmd.Use = "secrets"
+	cmd.Short = "Manage the secrets of the configuration"
+	cmd.Args = cobra.NoArgs
+}
+
+// ParentEnv keeps the NANOBOT_ env prefix for the secrets subcommands.
+func (s *SecretsCommand) ParentEnv() string {
+	return "NANOBOT_"
+}
+
+func (s *SecretsCommand) Run(cmd *cobra.Command, _ []string) error {
+	return cmd.Help()
+}
+
+type Encrypt struct {
+	EncryptionKey string `usage:"Encryption key, the same key must be passed to nanobot run with --encryption-key or set as auth.encryptionKey" env:"NANOBOT_RUN_ENCRYPTION_KEY"`
+}
+
+func NewEncrypt() *Encrypt {
+	return &Encrypt{}
+}
+
+func (e *Encrypt) Customize(cmd *cobra.Command) {
+	cmd.Use = "encrypt [flags] [VALUE]"
+	cmd.Short = "Encrypt a value to use in the configuration"
+	cmd.Long = `Encrypt a value to use in the configuration. The value is read from stdin if it is not
+given as an argument, which keeps it out of the shell history. The result starts with enc: and is
+decrypted with the encryption key when the configuration is loaded.`
+	cmd.Example = `
+  # Encrypt the value typed on stdin
+  NANOBOT_RUN_ENCRYPTION_KEY=... nanobot secrets encrypt
+
+  # Encrypt the content of a file
+  nanobot secrets encrypt --encryption-key ... < api-key.txt
+`
+	cmd.Args = cobra.MaximumNArgs(1)
+}
+
+func (e *Encrypt) Run(cmd *cobra.Command, args []string) error {
+	if e.EncryptionKey == "" {
+		return fmt.Errorf("an encryption key is required, use --encryption-key or NANOBOT_RUN_ENCRYPTION_KEY")
+	}
+
+	var value string
+	if len(args) > 0 && args[0] != "-" {
+		value = args[0]
+	} else {
+		data, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return fmt.Errorf("failed to read value: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+	if value == "" {
+		return fmt.Errorf("the value to encrypt is empty")
+	}
+
+	encrypted, err := secrets.Encrypt(e.EncryptionKey, value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, encrypted)
+	return err
+}
@@ -22,6 +22,7 @@ import (
 
 type Run struct {
 	Auth
+	Retention
 	ListenAddress                string            `usage:"Address to listen on" default:"localhost:8080" short:"a"`
 	DisableUI                    bool              `usage:"Disable the UI"`
 	ForceFetchToolList           bool              `usage:"Always fetch tools when listing instead of using session cache"`
@@ -33,6 +34,11 @@ type Run struct {
 	AuditLogFlushIntervalSeconds int               `usage:"Interval for flushing audit logs" default:"5"`
 	Roots                        []string          `usage:"Roots to expose the MCP server in the form of name:directory" short:"r"`
 	EntrypointAgent              string            `usage:"ID of the agent to use for chat" name:"agent"`
+	Distributed                  bool              `usage:"Coordinate session ownership and scheduled tasks with other replicas sharing the same Postgres or MySQL state database"`
+	ReplicaID                    string            `usage:"Unique ID of this replica in distributed mode (default: hostname)" env:"NANOBOT_REPLICA_ID"`
+	AdvertiseURL                 string            `usage:"URL other replicas use to reach this replica in distributed mode (default: http://<listen-address>)" env:"NANOBOT_ADVERTISE_URL"`
+	WebSocket                    bool              `usage:"Also accept MCP sessions over WebSocket on the MCP endpoint"`
+	CloseInterruptedRuns         bool              `usage:"Close agent runs interrupted by a restart with tool results explaining the interruption instead of leaving them to be resumed"`
 	n                            *Nanobot
 }
 
@@ -117,7 +123,12 @@ func (r *Run) getRoots() ([]mcp.Root, error) {
 	return roots, nil
 }
 
-func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
+func (r *Run) Run(cmd *cobra.Command, _ []string) error {
+	return r.serve(cmd.Context())
+}
+
+// serve runs the nanobot server until the context is canceled.
+func (r *Run) serve(ctx context.Context) error {
 	if (r.TrustedIssuer != "") != (len(r.TrustedAudiences) != 0) {
 		return fmt.Errorf("trusted issuer and audience must be set together")
 	}
@@ -127,6 +138,11 @@ func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
+	managerOpts, err := r.managerOptions()
+	if err != nil {
+		return err
+	}
+
 	callbackHandler := mcp.NewCallbackServer(confirm.New())
 	configPaths := r.n.ConfigPaths()
 	runtimeOpt := runtime.Options{
@@ -139,14 +155,16 @@ func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
 		DefaultModel:              r.n.DefaultModel,
 		ConfigDir:                 r.n.RuntimeConfigDir(),
 		LoopbackURL:               "http://" + r.ListenAddress + "/mcp/chat",
+		ReplicaID:                 managerOpts.ReplicaID,
+		EncryptionKey:             r.Auth.EncryptionKey,
 	}
 
-	cfgFactory := types.ConfigFactory(func(ctx context.Context, profiles string) (types.Config, error) {
+	cfgFactory := types.ConfigFactory(func(_ context.Context, profiles string) (types.Config, error) {
 		optCopy := runtimeOpt
 		if profiles != "" {
 			optCopy.Profiles = append(optCopy.Profiles, strings.Split(profiles, ",")...)
 		}
-		cfg, err := r.n.ReadConfig(cmd.Context(), configPaths, !r.n.ExcludeBuiltInAgents, optCopy)
+		cfg, err := r.n.ReadConfig(ctx, configPaths, !r.n.ExcludeBuiltInAgents, optCopy)
 		if err != nil {
 			return types.Config{}, err
 		}
@@ -168,7 +186,7 @@ func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
 		return *cfg, nil
 	})
 
-	once, err := cfgFactory(cmd.Context(), "")
+	once, err := cfgFactory(ctx, "")
 	if err != nil {
 		return fmt.Errorf("failed to read config from %q: %w", strings.Join(configPaths, ", "), err)
 	}
@@ -186,7 +204,7 @@ func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
 		return fmt.Errorf("failed to create session store: %w", err)
 	}
 
-	runtime, err := r.n.GetRuntime(cmd.Context(), runtimeOpt, runtime.Options{
+	runtime, err := r.n.GetRuntime(ctx, runtimeOpt, runtime.Options{
 		OAuthRedirectURL:  "http://" + strings.Replace(r.ListenAddress, "127.0.0.1", "localhost", 1) + "/oauth/callback",
 		Store:             store,
 		AuditLogCollector: auditLogCollector,
@@ -195,11 +213,53 @@ func (r *Run) Run(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
-	return r.n.runMCP(cmd.Context(), cfgFactory, runtime, callbackHandler, auditLogCollector, store, mcpOpts{
-		Auth:               auth.Auth(r.Auth),
-		ListenAddress:      r.ListenAddress,
-		HealthzPath:        r.HealthzPath,
-		ForceFetchToolList: r.ForceFetchToolList,
-		StartUI:            !r.DisableUI,
+	return r.n.runMCP(ctx, cfgFactory, runtime, callbackHandler, auditLogCollector, store, mcpOpts{
+		Auth:                 auth.Auth(r.Auth),
+		ListenAddress:        r.ListenAddress,
+		HealthzPath:          r.HealthzPath,
+		ForceFetchToolList:   r.ForceFetchToolList,
+		StartUI:              !r.DisableUI,
+		SessionManager:       managerOpts,
+		WebSocket:            r.WebSocket,
+		CloseInterruptedRuns: r.CloseInterruptedRuns,
 	})
 }
+
+func (r *Run) managerOptions() (session.ManagerOptions, error) {
+	retention, err := r.policy()
+	if err != nil {
+		return session.ManagerOptions{}, err
+	}
+
+	if !r.Distributed {
+		return session.ManagerOptions{
+			Retention: retention,
+		}, nil
+	}
+
+	dsn := r.n.DSN()
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") &&
+		!strings.HasPrefix(dsn, "mysql://") && !strings.Contains(dsn, "@tcp(") {
+		return session.ManagerOptions{}, fmt.Errorf("distributed mode requires a Postgres or MySQL state database, got %q", dsn)
+	}
+
+	replicaID := r.ReplicaID
+	if replicaID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return session.ManagerOptions{}, fmt.Errorf("failed to determine replica ID from hostname: %w", err)
+		}
+		replicaID = hostname
+	}
+
+	advertiseURL := r.AdvertiseURL
+	if advertiseURL == "" {
+		advertiseURL = "http://" + r.ListenAddress
+	}
+
+	return session.ManagerOptions{
+		ReplicaID: replicaID,
+		Address:   advertiseURL,
+		Retention: retention,
+	}, nil
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 	"text/tabwriter"
 	"time"
@@ -28,6 +29,12 @@ func (t *Sessions) Customize(cmd *cobra.Command) {
 	cmd.Hidden = true
 }
 
+// ParentEnv keeps the NANOBOT_ env prefix now that sessions is built on its own to nest
+// subcommands under it.
+func (t *Sessions) ParentEnv() string {
+	return "NANOBOT_"
+}
+
 func (t *Sessions) Run(cmd *cobra.Command, args []string) error {
 	store, err := session.NewStoreFromDSN(t.Nanobot.DSN())
 	if err != nil {
@@ -53,3 +60,173 @@ func (t *Sessions) Run(cmd *cobra.Command, args []string) error {
 
 	return tw.Flush()
 }
+
+// Retention holds the session retention flags shared by "run" and "sessions prune".
+type Retention struct {
+	SessionMaxIdleAge    string `usage:"Delete sessions that have not been updated for this long (e.g. 720h)"`
+	SessionMaxPerAccount int    `usage:"Keep at most this many of the most recently updated sessions per account"`
+	SessionKeepStarred   bool   `usage:"Never delete starred sessions" default:"true"`
+	SessionPurgeDeleted  string `usage:"Purge sessions that were deleted this long ago (e.g. 168h)"`
+}
+
+func (r Retention) policy() (session.RetentionPolicy, error) {
+	var maxIdleAge time.Duration
+	if r.SessionMaxIdleAge != "" {
+		var err error
+		maxIdleAge, err = time.ParseDuration(r.SessionMaxIdleAge)
+		if err != nil {
+			return session.RetentionPolicy{}, fmt.Errorf("invalid session max idle age %q: %w", r.SessionMaxIdleAge, err)
+		}
+	}
+	var deletedGracePeriod time.Duration
+	if r.SessionPurgeDeleted != "" {
+		var err error
+		deletedGracePeriod, err = time.ParseDuration(r.SessionPurgeDeleted)
+		if err != nil {
+			return session.RetentionPolicy{}, fmt.Errorf("invalid session purge deleted duration %q: %w", r.SessionPurgeDeleted, err)
+		}
+	}
+	return session.RetentionPolicy{
+		MaxIdleAge:            maxIdleAge,
+		MaxSessionsPerAccount: r.SessionMaxPerAccount,
+		KeepStarred:           r.SessionKeepStarred,
+		DeletedGracePeriod:    deletedGracePeriod,
+	}, nil
+}
+
+type Prune struct {
+	Retention
+	Nanobot *Nanobot
+	DryRun  bool   `usage:"Only print the sessions that would be deleted"`
+	Output  string `usage:"Output format (json, yaml, table)" short:"o" default:"table"`
+}
+
+func NewPrune(n *Nanobot) *Prune {
+	return &Prune{
+		Nanobot: n,
+	}
+}
+
+func (p *Prune) Customize(cmd *cobra.Command) {
+	cmd.Use = "prune [flags]"
+	cmd.Short = "Delete sessions according to the retention settings"
+	cmd.Long = `Delete sessions according to the retention settings, along with their workflow runs and
+session directories. Sessions that were deleted are only purged with --session-purge-deleted.
+
+This uses the same logic as the background sweeper of "nanobot run".`
+	cmd.Example = `
+  # Show sessions that have been idle for more than 30 days
+  nanobot sessions prune --session-max-idle-age 720h --dry-run
+
+  # Keep only the 100 most recent sessions per account
+  nanobot sessions prune --session-max-per-account 100
+
+  # Purge sessions that were deleted more than a week ago
+  nanobot sessions prune --session-purge-deleted 168h
+`
+	cmd.Args = cobra.NoArgs
+}
+
+func (p *Prune) Run(cmd *cobra.Command, _ []string) error {
+	policy, err := p.policy()
+	if err != nil {
+		return err
+	}
+	if !policy.Enabled() {
+		return fmt.Errorf("no retention limits set, use --session-max-idle-age, --session-max-per-account or --session-purge-deleted")
+	}
+
+	store, err := session.NewStoreFromDSN(p.Nanobot.DSN())
+	if err != nil {
+		return err
+	}
+
+	pruned, err := store.Prune(cmd.Context(), policy, session.PruneOptions{
+		DryRun: p.DryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	if display(pruned, p.Output) {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, err = tw.Write([]byte("ID\tDATE\tACCT\tREASON\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, session := range pruned {
+		_, _ = tw.Write([]byte(session.SessionID + "\t" + session.UpdatedAt.Format(time.RFC3339) +
+			"\t" + trim(session.AccountID) +
+			"\t" + session.Reason + "\n"))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if p.DryRun {
+		fmt.Printf("\n%d session(s) would be deleted\n", len(pruned))
+	} else {
+		fmt.Printf("\n%d session(s) deleted\n", len(pruned))
+	}
+	return nil
+}
+
+type Reencrypt struct {
+	Nanobot *Nanobot
+	Output  string `usage:"Output format (json, yaml, table)" short:"o" default:"table"`
+}
+
+func NewReencrypt(n *Nanobot) *Reencrypt {
+	return &Reencrypt{
+		Nanobot: n,
+	}
+}
+
+func (r *Reencrypt) Customize(cmd *cobra.Command) {
+	cmd.Use = "reencrypt [flags]"
+	cmd.Short = "Re-encrypt the session state and stored tokens with the current key"
+	cmd.Long = `Re-encrypt the session state and stored OAuth tokens with the first key of
+--state-encryption-key and --state-key-file. Values in plain text are encrypted and values
+encrypted with one of the other keys are encrypted again, after which those keys can be removed.
+The message search index is deleted, it is not kept when the state is encrypted.
+
+Without a key, the values are decrypted to plain text.`
+	cmd.Example = `
+  # Encrypt an existing state database
+  nanobot sessions reencrypt --state-encryption-key ...
+
+  # Rotate keys: put the new key first and the old key second in the key file, re-encrypt, then
+  # remove the old key from the file
+  nanobot sessions reencrypt --state-key-file ./nanobot.keys
+`
+	cmd.Args = cobra.NoArgs
+}
+
+func (r *Reencrypt) Run(cmd *cobra.Command, _ []string) error {
+	store, err := session.NewStoreFromDSN(r.Nanobot.DSN())
+	if err != nil {
+		return err
+	}
+
+	result, err := store.Reencrypt(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if display(result, r.Output) {
+		return nil
+	}
+
+	action := "re-encrypted"
+	if !session.Encrypted() {
+		action = "decrypted"
+	}
+	fmt.Printf("%d session(s), %d workflow checkpoint(s), %d agent run(s) and %d token(s) %s, %d search entries deleted\n",
+		result.Sessions, result.WorkflowCheckpoints, result.AgentRuns, result.Tokens, action, result.SearchEntries)
+	return nil
+}
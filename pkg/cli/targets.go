@@ -1,10 +1,12 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 	"text/tabwriter"
 
 	"github.com/nanobot-ai/nanobot/pkg/log"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/tools"
 	"github.com/spf13/cobra"
 )
@@ -49,6 +51,8 @@ func (t *Targets) Run(cmd *cobra.Command, args []string) error {
 		Servers: t.MCPServer,
 	})
 	if err != nil {
+		// Show which servers are unhealthy to explain the failure.
+		_ = printHealth(r.ServerHealth())
 		return err
 	}
 
@@ -75,6 +79,29 @@ func (t *Targets) Run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	return printHealth(r.ServerHealth())
+}
+
+// printHealth prints the state of the stdio MCP servers that were started.
+func printHealth(health []mcp.ServerHealth) error {
+	if len(health) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, err := tw.Write([]byte("\nSERVER\tSTATE\tRESTARTS\tLAST ERROR\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, h := range health {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", h.Server, h.State, h.Restarts, trim(h.LastError))
+	}
+
 	return tw.Flush()
 }
 
@@ -152,6 +152,14 @@ func loadResource(ctx context.Context, configResource *resource, profiles ...str
 		return nil, "", fmt.Errorf("error rewriting source references: %w", err)
 	}
 
+	if _, ok := last.MCPServers[types.ScriptsServer]; len(last.Scripts) > 0 && !ok {
+		// The scripts are published by a built-in server, so agents can reference them as tools.
+		if last.MCPServers == nil {
+			last.MCPServers = map[string]mcp.Server{}
+		}
+		last.MCPServers[types.ScriptsServer] = mcp.Server{}
+	}
+
 	if len(last.Agents) == 1 && len(last.Publish.Entrypoint) == 0 {
 		for agentName := range last.Agents {
 			last.Publish.Entrypoint = append(last.Publish.Entrypoint, agentName)
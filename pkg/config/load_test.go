@@ -177,6 +177,17 @@ func TestSchema(t *testing.T) {
 				}
 			}
 		}
+	},
+	"scripts": {
+		"script1": {
+			"description": "a description",
+			"input": {
+				"fields": {
+					"field1": "description1"
+				}
+			},
+			"script": "return call('server1/tool1', {value: args.field1})"
+		}
 	}
 }`), &obj)
 	if err != nil {
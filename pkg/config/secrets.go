@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// Decrypt replaces the enc: values of the configuration with their decrypted values. The key is
+// only required if the configuration has encrypted values.
+func Decrypt(cfg *types.Config, key string) error {
+	data, err := toMap(*cfg)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	decrypted, err := decryptValue(data, key, &count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	decryptedData, err := json.Marshal(decrypted)
+	if err != nil {
+		return err
+	}
+
+	var result types.Config
+	if err := json.Unmarshal(decryptedData, &result); err != nil {
+		return err
+	}
+	*cfg = result
+	return nil
+}
+
+func decryptValue(value any, key string, count *int) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for k, item := range v {
+			decrypted, err := decryptValue(item, key, count)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			result[k] = decrypted
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			decrypted, err := decryptValue(item, key, count)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			result[i] = decrypted
+		}
+		return result, nil
+	case string:
+		if !secrets.IsEncrypted(v) {
+			return v, nil
+		}
+		if key == "" {
+			return nil, fmt.Errorf("the value is encrypted but no encryption key is set, use --encryption-key or auth.encryptionKey")
+		}
+		*count++
+		return secrets.Decrypt(key, v)
+	}
+	return value, nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestDecrypt(t *testing.T) {
+	encrypted, err := secrets.Encrypt("key", "decrypted-header")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := types.Config{
+		MCPServers: map[string]mcp.Server{
+			"server": {
+				BaseURL: "https://example.com/mcp",
+				Headers: map[string]string{
+					"Authorization": encrypted,
+					"X-Plain":       "plain",
+				},
+			},
+		},
+	}
+
+	if err := Decrypt(&cfg, ""); err == nil {
+		t.Fatal("expected an error without an encryption key")
+	}
+	if err := Decrypt(&cfg, "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := cfg.MCPServers["server"].Headers
+	if headers["Authorization"] != "decrypted-header" || headers["X-Plain"] != "plain" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+	if redacted := cfg.Redacted().MCPServers["server"].Headers["Authorization"]; redacted != secrets.Mask {
+		t.Fatalf("expected the decrypted header to be redacted, got %q", redacted)
+	}
+	if cfg.MCPServers["server"].Headers["Authorization"] != "decrypted-header" {
+		t.Fatal("expected redacting to leave the config unchanged")
+	}
+}
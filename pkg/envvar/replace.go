@@ -10,10 +10,11 @@ import (
 	"log/slog"
 
 	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
 )
 
 func ReplaceString(envs map[string]string, str string) string {
-	r, err := expr.EvalString(context.TODO(), envs, nil, str)
+	r, err := expr.EvalString(context.TODO(), withSecrets(envs, str), nil, str)
 	if err != nil {
 		slog.Error("failed to evaluate expression", "expression", str, "error", err)
 		return str
@@ -55,3 +56,33 @@ func ReplaceEnv(envs map[string]string, command string, args []string, env map[s
 	}
 	return ReplaceString(envs, command), newArgs, newEnv
 }
+
+// withSecrets returns envs with the values of the secret references in str, like
+// ${file:/run/secrets/token}, added by their reference. Secrets are only resolved for configuration
+// values replaced here, never for templates like instructions and prompts.
+func withSecrets(envs map[string]string, str string) map[string]string {
+	var refs []string
+	expr.Expand(str, func(name string) string {
+		if secrets.IsReference(name) {
+			refs = append(refs, name)
+		}
+		return ""
+	})
+	if len(refs) == 0 {
+		return envs
+	}
+
+	result := maps.Clone(envs)
+	if result == nil {
+		result = map[string]string{}
+	}
+	for _, ref := range refs {
+		value, err := secrets.Resolve(context.TODO(), ref)
+		if err != nil {
+			slog.Error("failed to resolve secret reference", "error", err)
+			continue
+		}
+		result[ref] = value
+	}
+	return result
+}
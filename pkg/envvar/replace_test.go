@@ -0,0 +1,27 @@
+package envvar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+)
+
+func TestReplaceStringSecretReferences(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got := ReplaceString(map[string]string{"USER": "ada"}, "${USER} ${file:"+path+"} token")
+	if got != "ada s3cret token" {
+		t.Fatalf("expected the secret reference to be resolved, got %q", got)
+	}
+
+	// Templates evaluated outside of configuration values don't resolve secret references.
+	if _, err := expr.EvalString(context.Background(), nil, nil, "${file:"+path+"}"); err == nil {
+		t.Fatal("expected expressions to not read files")
+	}
+}
@@ -79,6 +79,7 @@ func translate(ctx context.Context, env map[string]string, data map[string]any,
 			}
 			result[key] = res
 		}
+		return result, nil
 	case string:
 		return evalString(ctx, env, data, expr)
 	}
@@ -167,3 +168,26 @@ func EvalList(ctx context.Context, env map[string]string, data map[string]any, e
 		return nil, fmt.Errorf("expected a list, got %T", val)
 	}
 }
+
+// RunScript runs a script as the body of a function and returns the exported value it returns.
+// The script is interrupted when the context is done.
+func RunScript(ctx context.Context, data map[string]any, script string) (any, error) {
+	runtime, err := newRuntime(data)
+	if err != nil {
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		runtime.Interrupt(context.Cause(ctx))
+	})
+	defer stop()
+
+	val, err := runtime.RunString("(function() {\n" + script + "\n})()")
+	if err != nil {
+		return nil, err
+	}
+	if goja.IsUndefined(val) || goja.IsNull(val) {
+		return nil, nil
+	}
+	return val.Export(), nil
+}
@@ -0,0 +1,28 @@
+package expr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestEvalObjectMap(t *testing.T) {
+	got, err := EvalObject(context.Background(), map[string]string{"NAME": "Ada"}, map[string]any{"count": 2},
+		map[string]any{
+			"greeting": "Hello ${NAME}",
+			"nested":   map[string]any{"count": "${count}"},
+			"list":     []any{"${NAME}", 1},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"greeting": "Hello Ada",
+		"nested":   map[string]any{"count": int64(2)},
+		"list":     []any{"Ada", 1},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected the values of the map to be evaluated, got %#v", got)
+	}
+}
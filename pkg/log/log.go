@@ -9,6 +9,8 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
 )
 
 var (
@@ -47,9 +49,9 @@ func ConfigureSlog(debug, trace bool) {
 		level = slog.LevelDebug
 	}
 
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	slog.SetDefault(slog.New(secrets.NewHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: level,
-	})))
+	}))))
 }
 
 func Messages(_ context.Context, server string, out bool, data []byte) {
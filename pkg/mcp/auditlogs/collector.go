@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"log/slog"
+
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
 )
 
 type Collector struct {
@@ -55,6 +57,11 @@ func (c *Collector) CollectMCPAuditEntry(entry MCPAuditLog) {
 	}
 
 	entry.Metadata = c.auditLogMetadata
+	// Resolved secrets, like the ones passed as tool arguments, are never sent.
+	entry.RequestBody = secrets.RedactBytes(entry.RequestBody)
+	entry.MutatedRequestBody = secrets.RedactBytes(entry.MutatedRequestBody)
+	entry.ResponseBody = secrets.RedactBytes(entry.ResponseBody)
+	entry.OriginalResponseBody = secrets.RedactBytes(entry.OriginalResponseBody)
 
 	c.auditLock.Lock()
 	defer c.auditLock.Unlock()
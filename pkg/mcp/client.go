@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"log/slog"
@@ -18,10 +19,14 @@ import (
 )
 
 type Client struct {
-	Session       *Session
-	serverName    string
-	toolOverrides ToolOverrides
-	toolPrefix    string
+	Session          *Session
+	serverName       string
+	toolOverrides    ToolOverrides
+	toolPrefix       string
+	outputValidation string
+
+	lock          sync.Mutex
+	outputSchemas map[string]json.RawMessage
 }
 
 func (c *Client) Close(deleteSession bool) {
@@ -111,6 +116,7 @@ func (c ClientOption) Merge(other ClientOption) (result ClientOption) {
 		result.OnElicit = other.OnElicit
 	}
 	result.CallbackHandler = complete.Last(c.CallbackHandler, other.CallbackHandler)
+	result.DeviceCodeHandler = complete.Last(c.DeviceCodeHandler, other.DeviceCodeHandler)
 	result.ClientCredLookup = complete.Last(c.ClientCredLookup, other.ClientCredLookup)
 	result.TokenStorage = complete.Last(c.TokenStorage, other.TokenStorage)
 	result.ClientName = complete.Last(c.ClientName, other.ClientName)
@@ -135,19 +141,29 @@ type Server struct {
 	ShortName   string `json:"shortName,omitempty"`
 	Description string `json:"description,omitempty"`
 
-	Image        string            `json:"image,omitempty"`
-	Dockerfile   string            `json:"dockerfile,omitempty"`
-	Source       ServerSource      `json:"source,omitzero"`
-	Sandboxed    bool              `json:"sandboxed,omitempty"`
-	Env          map[string]string `json:"env,omitempty"`
-	Command      string            `json:"command,omitempty"`
-	Args         []string          `json:"args,omitempty"`
-	BaseURL      string            `json:"url,omitempty"`
-	Ports        []string          `json:"ports,omitempty"`
-	ReversePorts []int             `json:"reversePorts,omitempty"`
-	Cwd          string            `json:"cwd,omitempty"`
-	Workdir      string            `json:"workdir,omitempty"`
-	Headers      map[string]string `json:"headers,omitempty"`
+	Image          string            `json:"image,omitempty"`
+	Dockerfile     string            `json:"dockerfile,omitempty"`
+	Source         ServerSource      `json:"source,omitzero"`
+	Sandboxed      bool              `json:"sandboxed,omitempty"`
+	SandboxBackend string            `json:"sandboxBackend,omitempty"`
+	SandboxNetwork string            `json:"sandboxNetwork,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	Command        string            `json:"command,omitempty"`
+	Args           []string          `json:"args,omitempty"`
+	BaseURL        string            `json:"url,omitempty"`
+	Ports          []string          `json:"ports,omitempty"`
+	ReversePorts   []int             `json:"reversePorts,omitempty"`
+	Limits         Limits            `json:"limits,omitzero"`
+	Restart        RestartPolicy     `json:"restart,omitzero"`
+	Lifecycle      Lifecycle         `json:"lifecycle,omitzero"`
+	Cwd            string            `json:"cwd,omitempty"`
+	Workdir        string            `json:"workdir,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Auth           ServerAuth        `json:"auth,omitzero"`
+
+	// A2A is the URL of the agent card of a remote A2A agent. The skills of the agent are published
+	// as tools instead of connecting to an MCP server, Headers are sent with its requests.
+	A2A string `json:"a2a,omitempty"`
 
 	// If providing tool overrides, any tools not included will be implicitly disabled.
 	// If providing no tool overrides, all tools will be enabled.
@@ -158,6 +174,10 @@ type Server struct {
 	// prefix before being dispatched upstream. Empty disables prefixing.
 	ToolPrefix string `json:"toolPrefix,omitempty"`
 
+	// OutputValidation is how the structured content of tool results is checked against the output
+	// schemas of the tools, warn, strict or off. Defaults to warn.
+	OutputValidation string `json:"outputValidation,omitempty"`
+
 	Hooks Hooks `json:"hooks,omitzero"`
 }
 
@@ -324,18 +344,23 @@ func NewSession(ctx context.Context, serverName string, config Server, opts ...C
 			if err != nil {
 				return nil, err
 			}
-			if err := waitForURL(ctx, serverName, config.BaseURL); err != nil {
+			if err := waitForURL(ctx, serverName, httpURL(config.BaseURL)); err != nil {
 				return nil, err
 			}
 		}
 		headers := envvar.ReplaceMap(opt.Env, config.Headers)
+		config.Auth = config.Auth.replaceEnv(opt.Env)
 		if opt.SessionState != nil && opt.SessionState.ID != "" {
 			if headers == nil {
 				headers = make(map[string]string)
 			}
 			headers["Mcp-Session-Id"] = opt.SessionState.ID
 		}
-		wire, err = newHTTPClient(serverName, config, opt.HTTPClientOptions, opt.SessionState, headers, !opt.ignoreEvents)
+		if isWebSocketURL(config.BaseURL) {
+			wire, err = newWebSocketClient(serverName, config, opt.HTTPClientOptions, opt.SessionState, headers, !opt.ignoreEvents)
+		} else {
+			wire, err = newHTTPClient(serverName, config, opt.HTTPClientOptions, opt.SessionState, headers, !opt.ignoreEvents)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -373,10 +398,11 @@ func NewClient(ctx context.Context, serverName string, config Server, opts ...Cl
 	}()
 
 	c := &Client{
-		Session:       session,
-		serverName:    serverName,
-		toolOverrides: config.ToolOverrides,
-		toolPrefix:    config.ToolPrefix,
+		Session:          session,
+		serverName:       serverName,
+		toolOverrides:    config.ToolOverrides,
+		toolPrefix:       config.ToolPrefix,
+		outputValidation: config.OutputValidation,
 	}
 
 	var (
@@ -540,6 +566,9 @@ func (c *Client) ListTools(ctx context.Context) (*ListToolsResult, error) {
 
 	var tools ListToolsResult
 	err := c.Session.Exchange(ctx, "tools/list", struct{}{}, &tools)
+	if err == nil {
+		c.setOutputSchemas(tools.Tools)
+	}
 	if err == nil && len(c.toolOverrides) > 0 {
 		filtered := tools.Tools[:0] // reuse the backing array
 		for _, tool := range tools.Tools {
@@ -628,6 +657,9 @@ func (c *Client) Call(ctx context.Context, tool string, args any, opts ...CallOp
 	}, result, ExchangeOption{
 		ProgressToken: opt.ProgressToken,
 	})
+	if err == nil {
+		err = c.checkOutput(tool, result)
+	}
 
 	return
 }
@@ -85,7 +85,8 @@ func RequestIDFromContext(ctx context.Context) any {
 
 type userCtxKey struct{}
 
-func withUserCtx(ctx, userCtx context.Context) context.Context {
+// WithUserContext sets the context that is canceled when the user cancels the request of ctx.
+func WithUserContext(ctx, userCtx context.Context) context.Context {
 	return context.WithValue(ctx, userCtxKey{}, userCtx)
 }
 
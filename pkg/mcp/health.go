@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stderrLines is how many of the last stderr lines of a server are kept for its health.
+const stderrLines = 20
+
+type ServerState string
+
+const (
+	ServerRunning    ServerState = "running"
+	ServerRestarting ServerState = "restarting"
+	ServerIdle       ServerState = "idle"
+	ServerFailed     ServerState = "failed"
+	ServerStopped    ServerState = "stopped"
+)
+
+// ServerHealth is the state of the processes of a stdio server started by the Runner.
+type ServerHealth struct {
+	Server    string      `json:"server"`
+	State     ServerState `json:"state"`
+	Processes int         `json:"processes"`
+	Idle      int         `json:"idle,omitempty"`
+	Restarts  int         `json:"restarts,omitempty"`
+	LastError string      `json:"lastError,omitempty"`
+	Since     time.Time   `json:"since,omitzero"`
+	Stderr    []string    `json:"stderr,omitempty"`
+}
+
+type serverHealth struct {
+	lock       sync.Mutex
+	processes  int
+	restarting int
+	idle       int
+	failed     bool
+	restarts   int
+	lastError  string
+	state      ServerState
+	since      time.Time
+	stderr     []string
+	partial    []byte
+}
+
+// update recomputes the state after a change, a server is failed only until a new process starts.
+func (h *serverHealth) update() {
+	state := ServerStopped
+	switch {
+	case h.restarting > 0:
+		state = ServerRestarting
+	case h.processes > 0:
+		state = ServerRunning
+	case h.idle > 0:
+		state = ServerIdle
+	case h.failed:
+		state = ServerFailed
+	}
+	if state != h.state {
+		h.state = state
+		h.since = time.Now()
+	}
+}
+
+func (h *serverHealth) change(f func(h *serverHealth)) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	f(h)
+	h.update()
+}
+
+// Write keeps the last stderr lines of the server.
+func (h *serverHealth) Write(data []byte) (int, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.partial = append(h.partial, data...)
+	for {
+		i := bytes.IndexByte(h.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(h.partial[:i])); line != "" {
+			h.stderr = append(h.stderr, line)
+		}
+		h.partial = h.partial[i+1:]
+	}
+	if len(h.stderr) > stderrLines {
+		h.stderr = slices.Clone(h.stderr[len(h.stderr)-stderrLines:])
+	}
+	if len(h.partial) > 4096 {
+		h.partial = h.partial[:0]
+	}
+	return len(data), nil
+}
+
+func (h *serverHealth) get(server string) ServerHealth {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return ServerHealth{
+		Server:    server,
+		State:     h.state,
+		Processes: h.processes,
+		Idle:      h.idle,
+		Restarts:  h.restarts,
+		LastError: h.lastError,
+		Since:     h.since,
+		Stderr:    slices.Clone(h.stderr),
+	}
+}
+
+func (r *Runner) serverHealth(serverName string) *serverHealth {
+	r.healthLock.Lock()
+	defer r.healthLock.Unlock()
+	if r.health == nil {
+		r.health = make(map[string]*serverHealth)
+	}
+	h, ok := r.health[serverName]
+	if !ok {
+		h = &serverHealth{}
+		r.health[serverName] = h
+	}
+	return h
+}
+
+// Health returns the state of every stdio server that was started, sorted by name.
+func (r *Runner) Health() []ServerHealth {
+	r.healthLock.Lock()
+	health := maps.Clone(r.health)
+	r.healthLock.Unlock()
+
+	result := make([]ServerHealth, 0, len(health))
+	for _, name := range slices.Sorted(maps.Keys(health)) {
+		result = append(result, health[name].get(name))
+	}
+	return result
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/log"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/oauth2"
 )
 
 const (
@@ -46,6 +47,8 @@ type HTTPClient struct {
 	httpClient   *http.Client
 	handler      WireHandler
 	oauthHandler *oauth
+	authConfig   ServerAuth
+	auth         *serverAuth
 	baseURL      string
 	messageURL   string
 	serverName   string
@@ -70,6 +73,7 @@ type HTTPClientOptions struct {
 	OAuthClientName           string
 	OAuthRedirectURL          string
 	CallbackHandler           CallbackHandler
+	DeviceCodeHandler         DeviceCodeHandler
 	ClientCredLookup          ClientCredLookup
 	TokenStorage              TokenStorage
 	TokenExchangeEndpoint     string
@@ -96,7 +100,8 @@ func newHTTPClient(serverName string, config Server, opts HTTPClientOptions, ses
 
 	return &HTTPClient{
 		httpClient:        instrumentHTTPClient(http.DefaultClient),
-		oauthHandler:      newOAuth(opts.CallbackHandler, opts.ClientCredLookup, opts.TokenStorage, opts.OAuthClientName, opts.OAuthRedirectURL),
+		oauthHandler:      newOAuth(opts.CallbackHandler, opts.DeviceCodeHandler, opts.ClientCredLookup, opts.TokenStorage, opts.OAuthClientName, opts.OAuthRedirectURL),
+		authConfig:        config.Auth,
 		baseURL:           config.BaseURL,
 		messageURL:        config.BaseURL,
 		serverName:        serverName,
@@ -463,7 +468,13 @@ func (s *HTTPClient) Start(ctx context.Context, handler WireHandler) error {
 	s.ctx, s.cancel = context.WithCancelCause(ctx)
 	s.handler = handler
 
-	if httpClient := s.oauthHandler.loadFromStorage(s.ctx, s.baseURL); httpClient != nil {
+	if s.authConfig.Type != "" {
+		s.auth = newServerAuth(s.ctx, s.authConfig, s.oauthHandler, s.baseURL)
+		// The transport is used instead of oauth2.NewClient, which would keep a token without an
+		// expiry, like the one of a token file, forever.
+		s.httpClient = instrumentHTTPClient(&http.Client{Transport: &oauth2.Transport{Source: s.auth}})
+		slog.Info("mcp client using non-interactive auth", "server", s.serverName, "type", s.authConfig.Type)
+	} else if httpClient := s.oauthHandler.loadFromStorage(s.ctx, s.baseURL); httpClient != nil {
 		s.httpClient = instrumentHTTPClient(httpClient)
 		slog.Info("mcp client loaded oauth token from storage", "server", s.serverName)
 	}
@@ -539,7 +550,7 @@ func (s *HTTPClient) initialize(ctx context.Context, msg Message) error {
 		"status_code", resp.StatusCode)
 
 	go func() {
-		if err = s.ensureSSE(ctx, nil, ""); err != nil {
+		if err := s.ensureSSE(ctx, nil, ""); err != nil {
 			slog.Error("failed to initialize SSE", "error", err)
 		}
 	}()
@@ -555,11 +566,29 @@ func (s *HTTPClient) initialize(ctx context.Context, msg Message) error {
 }
 
 func (s *HTTPClient) Send(ctx context.Context, msg Message) error {
-	err := s.send(ctx, msg)
+	return s.retrySend(ctx, msg, s.send)
+}
+
+// retrySend sends a message with send and recovers from the errors that require authenticating or
+// a new session. The WebSocket client sends its messages through it too.
+func (s *HTTPClient) retrySend(ctx context.Context, msg Message, send func(context.Context, Message) error) error {
+	err := send(ctx, msg)
 	if err == nil {
 		return nil
 	}
 
+	// Servers with non-interactive auth get a new token once instead of going through the OAuth process.
+	if s.auth != nil {
+		if _, ok := errors.AsType[AuthRequiredErr](err); ok {
+			slog.Info("mcp client authentication required, getting a new token",
+				"server", s.serverName,
+				"method", msg.Method,
+				"request_id", MessageIDString(msg.ID))
+			s.auth.reset()
+			return send(ctx, msg)
+		}
+	}
+
 	// We need to check for various errors and handle them according the spec.
 
 	// Check for an authentication-required error and put the user through the OAuth process.
@@ -585,7 +614,7 @@ func (s *HTTPClient) Send(ctx context.Context, msg Message) error {
 		s.clientLock.Unlock()
 
 		// Make the call to send instead of Send so we don't get stuck in an authentication loop.
-		return s.send(ctx, msg)
+		return send(ctx, msg)
 	}
 
 	// Check for a session-not-found error and re-initialize.
@@ -602,13 +631,14 @@ func (s *HTTPClient) Send(ctx context.Context, msg Message) error {
 		s.initializeLock.Unlock()
 
 		// Make the call to send instead of Send so we don't get stuck in a reinitialize loop.
-		return s.send(ctx, msg)
+		return send(ctx, msg)
 	}
 
 	// This loop checks for errors from the oauth2 package we use for the HTTP client after authentication.
 	// This is meant to catch errors such as failing to refresh the OAuth token.
+	// Errors getting a non-interactive token are returned as they are.
 	unwrappedErr := err
-	for unwrappedErr != nil {
+	for unwrappedErr != nil && s.auth == nil {
 		// Continually unwrap the errors until we find one that starts with oauth2:
 		if strings.HasPrefix(unwrappedErr.Error(), "oauth2:") {
 			slog.Warn("mcp client oauth2 transport error, retrying unauthenticated",
@@ -625,8 +655,8 @@ func (s *HTTPClient) Send(ctx context.Context, msg Message) error {
 			s.httpClient = instrumentHTTPClient(http.DefaultClient)
 			s.clientLock.Unlock()
 
-			// Use the exported Send method here so that we catch the AuthRequiredErr above on the recursed call.
-			return s.Send(ctx, msg)
+			// Recurse here so that we catch the AuthRequiredErr above on the recursed call.
+			return s.retrySend(ctx, msg, send)
 		}
 		unwrappedErr = errors.Unwrap(unwrappedErr)
 	}
@@ -106,6 +106,7 @@ type HTTPServer struct {
 	sessions                  SessionStore
 	ctx                       context.Context
 	healthzPath               string
+	webSocket                 bool
 
 	// internal health check state
 	internalSession *ServerSession
@@ -122,6 +123,8 @@ type HTTPServerOptions struct {
 	ResourceName      string
 	RunHealthChecker  bool
 	AuditLogCollector *auditlogs.Collector
+	// WebSocket accepts sessions over a WebSocket on the MCP endpoint, next to streamable HTTP.
+	WebSocket bool
 }
 
 func (h HTTPServerOptions) Complete() HTTPServerOptions {
@@ -145,6 +148,7 @@ func (h HTTPServerOptions) Merge(other HTTPServerOptions) (result HTTPServerOpti
 	h.HealthCheckPath = complete.Last(h.HealthCheckPath, other.HealthCheckPath)
 	h.ResourceName = complete.Last(h.ResourceName, other.ResourceName)
 	h.AuditLogCollector = complete.Last(h.AuditLogCollector, other.AuditLogCollector)
+	h.WebSocket = complete.Last(h.WebSocket, other.WebSocket)
 	return h
 }
 
@@ -157,6 +161,7 @@ func NewHTTPServer(ctx context.Context, envProvider func() (map[string]string, e
 		sessions:          o.SessionStore,
 		ctx:               o.BaseContext,
 		auditLogCollector: o.AuditLogCollector,
+		webSocket:         o.WebSocket,
 	}
 
 	if o.HealthCheckPath != "" {
@@ -284,6 +289,9 @@ func (h *HTTPServer) serveHTTP(rw http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
 		auditMethod = "sse/stream"
+		if h.webSocket && isWebSocketUpgrade(req) {
+			auditMethod = "websocket"
+		}
 	case http.MethodDelete:
 		auditMethod = "session/delete"
 	case http.MethodPost:
@@ -323,6 +331,10 @@ func (h *HTTPServer) serveHTTP(rw http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	auditLog.Subject = UserFromContext(ctx).Sub
 	if req.Method == http.MethodGet {
+		if h.webSocket && isWebSocketUpgrade(req) {
+			h.serveWebSocket(rw, req, auditLog)
+			return
+		}
 		h.streamEvents(rw, req, auditLog)
 		return
 	}
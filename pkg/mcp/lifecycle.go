@@ -0,0 +1,50 @@
+package mcp
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// LifecyclePerSession starts a server process for every session, this is the default.
+	LifecyclePerSession = "perSession"
+	// LifecycleShared starts one server process that is used by all sessions with the same
+	// environment.
+	LifecycleShared = "shared"
+)
+
+// Lifecycle configures when the process of a stdio server is started and stopped.
+type Lifecycle struct {
+	// Mode is perSession or shared, defaults to perSession.
+	Mode string `json:"mode,omitempty"`
+	// IdleTimeout stops the process once it was not used for this long, like 10m. It is started
+	// again on the next request.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+}
+
+// Shared returns if one process is used by all sessions.
+func (l Lifecycle) Shared() bool {
+	return l.Mode == LifecycleShared
+}
+
+// Validate checks the mode and the idle timeout.
+func (l Lifecycle) Validate() error {
+	_, err := l.idleTimeout()
+	return err
+}
+
+func (l Lifecycle) idleTimeout() (time.Duration, error) {
+	switch l.Mode {
+	case "", LifecyclePerSession, LifecycleShared:
+	default:
+		return 0, fmt.Errorf("invalid lifecycle mode %q, must be %s or %s", l.Mode, LifecyclePerSession, LifecycleShared)
+	}
+	if l.IdleTimeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(l.IdleTimeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid lifecycle idleTimeout %q, must be a positive duration like 10m", l.IdleTimeout)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,84 @@
+package mcp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/supervise"
+)
+
+// Limits restricts the resources of an MCP server that is run locally.
+type Limits struct {
+	// Memory is the maximum memory, like 512Mi or 2G. Units are powers of 1024.
+	Memory string `json:"memory,omitempty"`
+	// CPUShares is the relative CPU weight of the server, 1024 being the default of other processes.
+	CPUShares int `json:"cpuShares,omitempty"`
+	// Pids is the maximum number of processes and threads.
+	Pids int `json:"pids,omitempty"`
+	// OpenFiles is the maximum number of open files per process.
+	OpenFiles int `json:"openFiles,omitempty"`
+	// WallClock is how long the server may run before it is stopped, like 30m.
+	WallClock string `json:"wallClock,omitempty"`
+	// Egress is the allowlist of hosts, *.domains, IPs or CIDRs, each with an optional :port, the
+	// server can connect to. No restriction is applied if empty. It is only enforced by the namespace
+	// sandbox, see sandbox.EgressProxy.
+	Egress []string `json:"egress,omitempty"`
+}
+
+// Parse returns the limits to enforce on the server process and its wall-clock limit.
+func (l Limits) Parse() (supervise.Limits, time.Duration, error) {
+	var (
+		result    supervise.Limits
+		wallClock time.Duration
+		err       error
+	)
+	if l.Memory != "" {
+		if result.Memory, err = parseMemory(l.Memory); err != nil {
+			return result, 0, err
+		}
+	}
+	if l.WallClock != "" {
+		if wallClock, err = time.ParseDuration(l.WallClock); err != nil || wallClock <= 0 {
+			return result, 0, fmt.Errorf("invalid wallClock limit %q, must be a duration like 30m", l.WallClock)
+		}
+	}
+	for name, value := range map[string]int{
+		"cpuShares": l.CPUShares,
+		"pids":      l.Pids,
+		"openFiles": l.OpenFiles,
+	} {
+		if value < 0 {
+			return result, 0, fmt.Errorf("invalid %s limit %d, must not be negative", name, value)
+		}
+	}
+	result.CPUShares = l.CPUShares
+	result.Pids = l.Pids
+	result.OpenFiles = l.OpenFiles
+	return result, wallClock, nil
+}
+
+var memoryUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+}
+
+// parseMemory parses a memory size in bytes with an optional k, m, g or t unit, followed by an
+// optional i or b, so 512m, 512Mi and 512MB are all 512 MiB.
+func parseMemory(value string) (int64, error) {
+	lower := strings.ToLower(strings.TrimSpace(value))
+	number := strings.TrimRight(lower, "kmgtib")
+	unit := strings.TrimSuffix(strings.TrimSuffix(lower[len(number):], "b"), "i")
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	size, ok := memoryUnits[unit]
+	if err != nil || !ok || n <= 0 {
+		return 0, fmt.Errorf("invalid memory limit %q, must be a size like 512Mi or 2G", value)
+	}
+	return n * size, nil
+}
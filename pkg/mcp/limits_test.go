@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMemory(t *testing.T) {
+	for value, expected := range map[string]int64{
+		"1024":  1024,
+		"512m":  512 << 20,
+		"512Mi": 512 << 20,
+		"512MB": 512 << 20,
+		"2G":    2 << 30,
+		"64k":   64 << 10,
+	} {
+		n, err := parseMemory(value)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", value, err)
+		} else if n != expected {
+			t.Errorf("expected %s to be %d bytes, got %d", value, expected, n)
+		}
+	}
+
+	for _, value := range []string{"", "lots", "-1G", "12x", "1.5G"} {
+		if _, err := parseMemory(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestLimitsParse(t *testing.T) {
+	limits, wallClock, err := Limits{Memory: "256Mi", Pids: 64, WallClock: "90s"}.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.Memory != 256<<20 || limits.Pids != 64 || wallClock != 90*time.Second {
+		t.Errorf("unexpected limits %+v, wall-clock %s", limits, wallClock)
+	}
+
+	if _, _, err := (Limits{WallClock: "forever"}).Parse(); err == nil {
+		t.Error("expected an error for an invalid wall-clock limit")
+	}
+	if _, _, err := (Limits{OpenFiles: -1}).Parse(); err == nil {
+		t.Error("expected an error for a negative limit")
+	}
+}
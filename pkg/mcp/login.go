@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// StoredToken is an OAuth token of an MCP server in a TokenStorage.
+type StoredToken struct {
+	URL       string         `json:"url"`
+	AccountID string         `json:"accountID,omitempty"`
+	Config    *oauth2.Config `json:"-"`
+	Token     *oauth2.Token  `json:"-"`
+}
+
+// TokenLister is implemented by the token storages that can list the tokens they store.
+type TokenLister interface {
+	ListTokenConfigs(context.Context) ([]StoredToken, error)
+}
+
+// TokenURL returns the URL the token of an MCP server is stored under.
+func TokenURL(serverURL string) string {
+	return httpURL(serverURL)
+}
+
+// Login runs the OAuth flow of a remote MCP server and stores the new token, replacing the stored
+// one. The authorization URL goes to the callback handler, or the device code to the device code
+// handler if one is set.
+func Login(ctx context.Context, serverName string, config Server, opts HTTPClientOptions, headers map[string]string) error {
+	if config.BaseURL == "" {
+		return fmt.Errorf("MCP server %s has no URL", serverName)
+	}
+	if config.Auth.Type != "" {
+		return fmt.Errorf("MCP server %s uses %s auth, which does not need a login", serverName, config.Auth.Type)
+	}
+	if opts.TokenStorage == nil {
+		return fmt.Errorf("no token storage to store the token of %s in", serverName)
+	}
+	if opts.ClientCredLookup == nil {
+		opts.ClientCredLookup = NewClientLookupFromEnv()
+	}
+	if opts.OAuthClientName == "" {
+		opts.OAuthClientName = "Nanobot MCP Client"
+	}
+
+	config.BaseURL = TokenURL(config.BaseURL)
+	c, err := newHTTPClient(serverName, config, opts, nil, headers, false)
+	if err != nil {
+		return err
+	}
+
+	if err := opts.TokenStorage.DeleteTokenConfig(ctx, c.baseURL); err != nil {
+		return fmt.Errorf("failed to delete token config: %w", err)
+	}
+
+	authenticateHeader, err := c.authenticateHeader(ctx)
+	if err != nil {
+		return err
+	}
+
+	httpClient, err := c.oauthHandler.oauthClient(ctx, c, c.baseURL, authenticateHeader)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate to %s: %w", serverName, err)
+	} else if httpClient == nil {
+		return fmt.Errorf("failed to authenticate to %s: the authorization URL was not handled", serverName)
+	}
+	return nil
+}
+
+// authenticateHeader returns the WWW-Authenticate header the server sends to unauthenticated
+// requests, it may point to the protected resource metadata and the required scopes.
+func (s *HTTPClient) authenticateHeader(ctx context.Context) (string, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to %s: %w", s.baseURL, err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return "", nil
+	}
+	return resp.Header.Get("WWW-Authenticate"), nil
+}
+
+// RefreshToken gets a new token for the stored token of an MCP server URL with its refresh token.
+func RefreshToken(ctx context.Context, tokenStorage TokenStorage, url string) (*oauth2.Token, error) {
+	conf, tok, err := tokenStorage.GetTokenConfig(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token config: %w", err)
+	}
+	if conf == nil || tok == nil {
+		return nil, fmt.Errorf("no token stored for %s", url)
+	}
+	if tok.RefreshToken == "" {
+		return nil, fmt.Errorf("token for %s has no refresh token, log in again", url)
+	}
+
+	// Expire the token so that the token source refreshes it.
+	expired := *tok
+	expired.Expiry = time.Now().Add(-time.Minute)
+	newToken, err := conf.TokenSource(ctx, &expired).Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token for %s: %w", url, err)
+	}
+
+	if err := tokenStorage.SetTokenConfig(ctx, url, conf, newToken); err != nil {
+		return nil, fmt.Errorf("failed to store token: %w", err)
+	}
+	return newToken, nil
+}
@@ -0,0 +1,67 @@
+package mcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshToken(t *testing.T) {
+	ctx := t.Context()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil || req.PostForm.Get("refresh_token") != "refresh-1" {
+			http.Error(rw, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"access_token":"access-2","refresh_token":"refresh-2","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer ts.Close()
+
+	storage := NewLocalTokenStorage(t.TempDir())
+	conf := &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{TokenURL: ts.URL},
+	}
+	for url, token := range map[string]*oauth2.Token{
+		"https://b.example.com/mcp": {AccessToken: "access-1", RefreshToken: "refresh-1", Expiry: time.Now().Add(time.Hour)},
+		"https://a.example.com/mcp": {AccessToken: "access"},
+	} {
+		if err := storage.SetTokenConfig(ctx, url, conf, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokens, err := storage.(TokenLister).ListTokenConfigs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0].URL != "https://a.example.com/mcp" || tokens[1].Token.RefreshToken != "refresh-1" {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+
+	// The token is refreshed even though it has not expired.
+	tok, err := RefreshToken(ctx, storage, "https://b.example.com/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "access-2" {
+		t.Fatalf("expected a new access token, got %s", tok.AccessToken)
+	}
+
+	_, stored, err := storage.GetTokenConfig(ctx, "https://b.example.com/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AccessToken != "access-2" || stored.RefreshToken != "refresh-2" {
+		t.Fatalf("expected the new token to be stored, got %+v", stored)
+	}
+
+	if _, err := RefreshToken(ctx, storage, "https://a.example.com/mcp"); err == nil {
+		t.Fatal("expected an error for a token without a refresh token")
+	}
+}
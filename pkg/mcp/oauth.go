@@ -24,20 +24,28 @@ var (
 	scopeRegex            = regexp.MustCompile(`scope="([^"]*)"`)
 )
 
+// DeviceCodeHandler shows the user where to enter the code of an OAuth device authorization
+// (RFC 8628). It is used instead of the callback handler when it is set.
+type DeviceCodeHandler interface {
+	HandleDeviceCode(ctx context.Context, serverName string, resp *oauth2.DeviceAuthResponse) error
+}
+
 type oauth struct {
 	redirectURL, clientName string
 	currentToken            oauth2.Token
 	metadataClient          *http.Client
 	callbackHandler         CallbackHandler
+	deviceCodeHandler       DeviceCodeHandler
 	clientLookup            ClientCredLookup
 	tokenStorage            TokenStorage
 }
 
-func newOAuth(callbackHandler CallbackHandler, clientLookup ClientCredLookup, tokenStorage TokenStorage, clientName, redirectURL string) *oauth {
+func newOAuth(callbackHandler CallbackHandler, deviceCodeHandler DeviceCodeHandler, clientLookup ClientCredLookup, tokenStorage TokenStorage, clientName, redirectURL string) *oauth {
 	return &oauth{
-		clientName:      clientName,
-		redirectURL:     redirectURL,
-		callbackHandler: callbackHandler,
+		clientName:        clientName,
+		redirectURL:       redirectURL,
+		callbackHandler:   callbackHandler,
+		deviceCodeHandler: deviceCodeHandler,
 		metadataClient: instrumentHTTPClient(&http.Client{
 			Timeout: 5 * time.Second,
 		}),
@@ -81,7 +89,7 @@ func (o *oauth) oauthClient(ctx context.Context, c *HTTPClient, connectURL, auth
 		return httpClient, nil
 	}
 
-	if o.callbackHandler == nil || o.redirectURL == "" {
+	if o.deviceCodeHandler == nil && (o.callbackHandler == nil || o.redirectURL == "") {
 		return nil, fmt.Errorf("oauth callback server is not configured")
 	}
 
@@ -141,7 +149,9 @@ func (o *oauth) oauthClient(ctx context.Context, c *HTTPClient, connectURL, auth
 	}
 
 	clientMetadata := authServerMetadataToClientRegistration(authorizationServerMetadata, scope)
-	clientMetadata.RedirectURIs = []string{o.redirectURL}
+	if o.redirectURL != "" {
+		clientMetadata.RedirectURIs = []string{o.redirectURL}
+	}
 	clientMetadata.ClientName = o.clientName
 
 	b, err := json.Marshal(clientMetadata)
@@ -195,7 +205,7 @@ func (o *oauth) oauthClient(ctx context.Context, c *HTTPClient, connectURL, auth
 	conf := &oauth2.Config{
 		ClientID:     clientInfo.ClientID,
 		ClientSecret: clientInfo.ClientSecret,
-		RedirectURL:  clientMetadata.RedirectURIs[0],
+		RedirectURL:  o.redirectURL,
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authorizationServerMetadata.AuthorizationEndpoint,
 			TokenURL: authorizationServerMetadata.TokenEndpoint,
@@ -213,6 +223,37 @@ func (o *oauth) oauthClient(ctx context.Context, c *HTTPClient, connectURL, auth
 		conf.Endpoint.AuthStyle = oauth2.AuthStyleAutoDetect
 	}
 
+	var tok *oauth2.Token
+	if o.deviceCodeHandler != nil {
+		tok, err = o.deviceCode(ctx, c, conf, authorizationServerMetadata.DeviceAuthorizationEndpoint, connectURL)
+	} else {
+		tok, err = o.authorizationCode(ctx, c, conf, authorizationServerMetadata.AuthorizationEndpoint, connectURL)
+	}
+	if err != nil || tok == nil {
+		return nil, err
+	}
+
+	o.currentToken = *tok
+
+	if o.tokenStorage != nil {
+		if err = o.tokenStorage.SetTokenConfig(ctx, connectURL, conf, tok); err != nil {
+			slog.Info("failed to save token config", "error", err)
+		} else {
+			slog.Info("saved oauth token config", "server", c.serverName, "connect_url", connectURL)
+		}
+	}
+
+	return oauth2.NewClient(ctx, newTokenSource(ctx, o.tokenStorage, connectURL, conf, tok)), nil
+}
+
+// authorizationCode sends the user to the authorization endpoint and exchanges the code sent to
+// the callback handler for a token. It returns no token if the callback handler did not handle
+// the authorization URL.
+func (o *oauth) authorizationCode(ctx context.Context, c *HTTPClient, conf *oauth2.Config, authorizationEndpoint, connectURL string) (*oauth2.Token, error) {
+	if o.callbackHandler == nil || o.redirectURL == "" {
+		return nil, fmt.Errorf("oauth callback server is not configured")
+	}
+
 	// use PKCE to protect against CSRF attacks
 	// https://www.ietf.org/archive/id/draft-ietf-oauth-security-topics-22.html#name-countermeasures-6
 	verifier := oauth2.GenerateVerifier()
@@ -222,12 +263,12 @@ func (o *oauth) oauthClient(ctx context.Context, c *HTTPClient, connectURL, auth
 		return nil, fmt.Errorf("failed to create state: %w", err)
 	}
 
-	authURL, err := authCodeURL(conf, authorizationServerMetadata.AuthorizationEndpoint, connectURL, state, verifier)
+	authURL, err := authCodeURL(conf, authorizationEndpoint, connectURL, state, verifier)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate auth code URL: %w", err)
 	}
 
-	slog.Info("handing oauth authorization url to callback handler", "server", c.serverName, "auth_url", authorizationServerMetadata.AuthorizationEndpoint)
+	slog.Info("handing oauth authorization url to callback handler", "server", c.serverName, "auth_url", authorizationEndpoint)
 	handled, err := o.callbackHandler.HandleAuthURL(ctx, c.displayName, authURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to handle auth url %s: %w", authURL, err)
@@ -257,18 +298,69 @@ func (o *oauth) oauthClient(ctx context.Context, c *HTTPClient, connectURL, auth
 		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
 	slog.Info("oauth code exchange succeeded", "server", c.serverName)
+	return tok, nil
+}
 
-	o.currentToken = *tok
+// deviceCode runs the device authorization flow, the user enters the code shown by the device code
+// handler on another device while the token endpoint is polled.
+func (o *oauth) deviceCode(ctx context.Context, c *HTTPClient, conf *oauth2.Config, deviceAuthorizationEndpoint, connectURL string) (*oauth2.Token, error) {
+	if deviceAuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("authorization server of %s does not support the device authorization flow", c.displayName)
+	}
+	conf.Endpoint.DeviceAuthURL = deviceAuthorizationEndpoint
 
-	if o.tokenStorage != nil {
-		if err = o.tokenStorage.SetTokenConfig(ctx, connectURL, conf, tok); err != nil {
-			slog.Info("failed to save token config", "error", err)
-		} else {
-			slog.Info("saved oauth token config", "server", c.serverName, "connect_url", connectURL)
+	resp, err := conf.DeviceAuth(ctx, oauth2.SetAuthURLParam("resource", connectURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start device authorization: %w", err)
+	}
+
+	slog.Info("handing device code to device code handler", "server", c.serverName, "verification_uri", resp.VerificationURI)
+	if err := o.deviceCodeHandler.HandleDeviceCode(ctx, c.displayName, resp); err != nil {
+		return nil, fmt.Errorf("failed to handle device code: %w", err)
+	}
+
+	tok, err := conf.DeviceAccessToken(ctx, resp, oauth2.SetAuthURLParam("resource", connectURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token for device code: %w", err)
+	}
+	slog.Info("oauth device authorization succeeded", "server", c.serverName)
+	return tok, nil
+}
+
+// discoverTokenEndpoint finds the token endpoint of the authorization server of an MCP server in
+// its protected resource metadata, for the auth modes that have no configured token URL.
+func (o *oauth) discoverTokenEndpoint(connectURL string) (string, error) {
+	u, err := url.Parse(connectURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse MCP URL: %w", err)
+	}
+	u.Path = "/.well-known/oauth-protected-resource"
+
+	resp, err := o.metadataClient.Get(u.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to get protected resource metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var metadata protectedResourceMetadata
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status getting protected resource metadata (%d): %s", resp.StatusCode, string(body))
+	} else if resp.StatusCode == http.StatusOK {
+		metadata, err = parseProtectedResourceMetadata(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse protected resource metadata: %w", err)
 		}
 	}
+	if len(metadata.AuthorizationServers) == 0 {
+		metadata.AuthorizationServers = []string{fmt.Sprintf("%s://%s", u.Scheme, u.Host)}
+	}
 
-	return oauth2.NewClient(ctx, newTokenSource(ctx, o.tokenStorage, connectURL, conf, tok)), nil
+	authServerMetadata, err := o.getAuthServerMetadata(metadata.AuthorizationServers[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to get authorization server metadata: %w", err)
+	}
+	return authServerMetadata.TokenEndpoint, nil
 }
 
 func (o *oauth) getAuthServerMetadata(authURL string) (authorizationServerMetadata, error) {
@@ -511,6 +603,9 @@ type authorizationServerMetadata struct {
 	// OPTIONAL. URL of the authorization server's JWK Set document
 	JwksURI string `json:"jwks_uri,omitempty"`
 
+	// OPTIONAL. URL of the authorization server's OAuth 2.0 device authorization endpoint
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint,omitempty"`
+
 	// OPTIONAL. URL of the authorization server's OAuth 2.0 Dynamic Client Registration endpoint
 	RegistrationEndpoint string `json:"registration_endpoint,omitempty"`
 
@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+)
+
+const (
+	// OutputValidationWarn adds a warning to tool results with structured content that doesn't match
+	// the output schema of the tool, this is the default.
+	OutputValidationWarn = "warn"
+	// OutputValidationStrict fails tool calls with structured content that doesn't match the output
+	// schema of the tool.
+	OutputValidationStrict = "strict"
+	// OutputValidationOff doesn't check the structured content of tool results.
+	OutputValidationOff = "off"
+)
+
+// OutputSchemaViolationsMetaKey lists the problems of the structured content of a tool result that
+// doesn't match the output schema of the tool.
+const OutputSchemaViolationsMetaKey = "ai.nanobot.output-schema/violations"
+
+// OutputSchemaError is returned for a tool result with structured content that doesn't match the
+// output schema of the tool.
+type OutputSchemaError struct {
+	Server   string
+	Tool     string
+	Problems []string
+}
+
+func (e *OutputSchemaError) Error() string {
+	return fmt.Sprintf("tool %s of MCP server %s returned structured content that does not match its output schema: %s",
+		e.Tool, e.Server, strings.Join(e.Problems, "; "))
+}
+
+// setOutputSchemas remembers the output schemas of the tools by their name on the server.
+func (c *Client) setOutputSchemas(tools []Tool) {
+	outputSchemas := map[string]json.RawMessage{}
+	for _, tool := range tools {
+		if len(tool.OutputSchema) > 0 {
+			outputSchemas[tool.Name] = tool.OutputSchema
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.outputSchemas = outputSchemas
+}
+
+// checkOutput validates the structured content of the result of the tool against the output schema
+// the tool was listed with. In strict mode a violation is returned as an error, otherwise it is added
+// to the meta of the result.
+func (c *Client) checkOutput(tool string, result *CallToolResult) error {
+	if c.outputValidation == OutputValidationOff || result.IsError {
+		return nil
+	}
+
+	c.lock.Lock()
+	outputSchema := c.outputSchemas[tool]
+	c.lock.Unlock()
+	if len(outputSchema) == 0 {
+		return nil
+	}
+
+	var problems []string
+	if result.StructuredContent == nil {
+		problems = []string{"the result has no structured content"}
+	} else if err := ValidateSchema(outputSchema, result.StructuredContent); err != nil {
+		validationErr, ok := errors.AsType[*SchemaValidationError](err)
+		if !ok {
+			slog.Debug("failed to validate tool output", "server", c.serverName, "tool", tool, "error", err)
+			return nil
+		}
+		problems = validationErr.Problems
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	violation := &OutputSchemaError{
+		Server:   c.serverName,
+		Tool:     tool,
+		Problems: problems,
+	}
+	if c.outputValidation == OutputValidationStrict {
+		return violation
+	}
+
+	slog.Warn("tool output does not match output schema", "server", c.serverName, "tool", tool, "problems", problems)
+	result.Meta = maps.Clone(result.Meta)
+	if result.Meta == nil {
+		result.Meta = map[string]any{}
+	}
+	result.Meta[OutputSchemaViolationsMetaKey] = problems
+	return nil
+}
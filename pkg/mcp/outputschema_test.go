@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPerson struct {
+	Name string            `json:"name"`
+	Age  int               `json:"age,omitempty"`
+	Tags map[string]string `json:"tags"`
+}
+
+// brokenTool declares an output schema that its results don't match.
+type brokenTool struct {
+	ServerTool
+}
+
+func (b brokenTool) Definition() Tool {
+	tool := b.ServerTool.Definition()
+	tool.Name = "broken"
+	return tool
+}
+
+func (b brokenTool) Invoke(context.Context, Message, CallToolRequest) (*CallToolResult, error) {
+	return &CallToolResult{
+		Content:           []Content{{Type: "text", Text: `{"name":5}`}},
+		StructuredContent: map[string]any{"name": 5},
+	}, nil
+}
+
+func TestNewServerToolOutputSchema(t *testing.T) {
+	tool := NewServerTool("person", "", func(context.Context, struct{}) (*testPerson, error) {
+		return &testPerson{Name: "Ada"}, nil
+	}).Definition()
+
+	var outputSchema map[string]any
+	if err := json.Unmarshal(tool.OutputSchema, &outputSchema); err != nil {
+		t.Fatal(err)
+	}
+	if outputSchema["type"] != "object" || outputSchema["required"].([]any)[0] != "name" {
+		t.Fatalf("expected the schema of the result type, got %s", tool.OutputSchema)
+	}
+	if err := ValidateSchema(tool.OutputSchema, map[string]any{"name": "Ada", "tags": nil}); err != nil {
+		t.Fatalf("expected a nil map to match, got %v", err)
+	}
+
+	text := NewServerTool("text", "", func(context.Context, struct{}) (string, error) {
+		return "", nil
+	}).Definition()
+	if len(text.OutputSchema) != 0 {
+		t.Fatalf("expected no output schema for a text result, got %s", text.OutputSchema)
+	}
+}
+
+func TestClientCallOutputValidation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	person := NewServerTool("person", "", func(context.Context, struct{}) (*testPerson, error) {
+		return &testPerson{Name: "Ada"}, nil
+	})
+	tools := NewServerTools(person, brokenTool{ServerTool: person})
+
+	handler := MessageHandlerFunc(func(ctx context.Context, msg Message) {
+		switch msg.Method {
+		case "initialize":
+			_ = msg.Reply(ctx, InitializeResult{
+				ProtocolVersion: "2025-06-18",
+				ServerInfo:      ServerInfo{Name: "test"},
+				Capabilities:    ServerCapabilities{Tools: &ToolsServerCapability{}},
+			})
+		case "tools/list":
+			Invoke(ctx, msg, tools.List)
+		case "tools/call":
+			Invoke(ctx, msg, tools.Call)
+		}
+	})
+
+	server, err := NewHTTPServer(ctx, nil, handler, HTTPServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	for _, mode := range []string{OutputValidationWarn, OutputValidationStrict, OutputValidationOff} {
+		t.Run(mode, func(t *testing.T) {
+			client, err := NewClient(ctx, "test", Server{BaseURL: ts.URL, OutputValidation: mode}, ClientOption{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close(true)
+
+			if _, err := client.ListTools(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := client.Call(ctx, "person", nil)
+			if err != nil || result.Meta[OutputSchemaViolationsMetaKey] != nil {
+				t.Fatalf("expected a valid result, got %+v, %v", result, err)
+			}
+
+			result, err = client.Call(ctx, "broken", nil)
+			switch mode {
+			case OutputValidationStrict:
+				if violation, ok := errors.AsType[*OutputSchemaError](err); !ok || violation.Tool != "broken" {
+					t.Fatalf("expected an output schema error, got %v", err)
+				}
+			case OutputValidationOff:
+				if err != nil || result.Meta[OutputSchemaViolationsMetaKey] != nil {
+					t.Fatalf("expected the result to not be checked, got %+v, %v", result, err)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				problems, _ := result.Meta[OutputSchemaViolationsMetaKey].([]string)
+				if strings.Join(problems, "; ") != "/: missing property 'tags'; /name: got number, want string" {
+					t.Fatalf("expected the problems in the meta of the result, got %+v", result.Meta)
+				}
+			}
+		})
+	}
+}
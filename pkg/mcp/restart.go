@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultMaxRestarts    = 5
+	defaultRestartWindow  = 10 * time.Minute
+	defaultRestartBackoff = time.Second
+	maxRestartBackoff     = 30 * time.Second
+)
+
+// RestartPolicy configures how a stdio server that exits unexpectedly is restarted.
+type RestartPolicy struct {
+	// Disabled turns off restarts, calls fail once the server exits.
+	Disabled bool `json:"disabled,omitempty"`
+	// MaxRestarts is how many restarts are allowed within Window before the server is considered
+	// to be crash looping and is marked as failed. Defaults to 5.
+	MaxRestarts int `json:"maxRestarts,omitempty"`
+	// Window is the period restarts are counted in, like 10m. Defaults to 10m.
+	Window string `json:"window,omitempty"`
+	// Backoff is the delay before the first restart, like 1s. It doubles with every restart within
+	// Window, up to 30s or the initial backoff if it is longer. Defaults to 1s.
+	Backoff string `json:"backoff,omitempty"`
+}
+
+type restartPolicy struct {
+	disabled    bool
+	maxRestarts int
+	window      time.Duration
+	backoff     time.Duration
+}
+
+// Validate checks the durations and counts of the policy.
+func (r RestartPolicy) Validate() error {
+	_, err := r.parse()
+	return err
+}
+
+// parse returns the policy with its defaults applied.
+func (r RestartPolicy) parse() (result restartPolicy, err error) {
+	result = restartPolicy{
+		disabled:    r.Disabled,
+		maxRestarts: defaultMaxRestarts,
+		window:      defaultRestartWindow,
+		backoff:     defaultRestartBackoff,
+	}
+	if r.MaxRestarts < 0 {
+		return result, fmt.Errorf("invalid restart maxRestarts %d, must not be negative", r.MaxRestarts)
+	} else if r.MaxRestarts > 0 {
+		result.maxRestarts = r.MaxRestarts
+	}
+	if r.Window != "" {
+		if result.window, err = time.ParseDuration(r.Window); err != nil || result.window <= 0 {
+			return result, fmt.Errorf("invalid restart window %q, must be a positive duration like 10m", r.Window)
+		}
+	}
+	if r.Backoff != "" {
+		if result.backoff, err = time.ParseDuration(r.Backoff); err != nil || result.backoff <= 0 {
+			return result, fmt.Errorf("invalid restart backoff %q, must be a positive duration like 1s", r.Backoff)
+		}
+	}
+	return result, nil
+}
+
+// next records a restart at now and returns how long to wait before it. It returns false if the
+// server restarted too often within the window.
+func (r restartPolicy) next(restarts []time.Time, now time.Time) ([]time.Time, time.Duration, bool) {
+	recent := restarts[:0]
+	for _, restart := range restarts {
+		if now.Sub(restart) < r.window {
+			recent = append(recent, restart)
+		}
+	}
+	if len(recent) >= r.maxRestarts {
+		return recent, 0, false
+	}
+
+	backoff, limit := r.backoff, max(r.backoff, maxRestartBackoff)
+	for range recent {
+		if backoff *= 2; backoff >= limit {
+			backoff = limit
+			break
+		}
+	}
+	return append(recent, now), backoff, true
+}
@@ -11,6 +11,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"log/slog"
 
@@ -21,20 +22,68 @@ import (
 )
 
 type Runner struct {
+	// SandboxBackend is used for sandboxed servers that do not select a backend.
+	SandboxBackend string
+	// OnLimitViolation is called when a server exceeds one of its limits.
+	OnLimitViolation func(server string, violation *sandbox.LimitError)
+
 	lock    sync.Mutex
 	running map[string]Server
+
+	healthLock sync.Mutex
+	health     map[string]*serverHealth
+
+	sharedLock sync.Mutex
+	shared     map[string]*sharedStdio
 }
 
 type streamResult struct {
-	cmd    *exec.Cmd
-	Stdout io.Reader
-	Stdin  io.Writer
-	Close  func()
+	cmd     *exec.Cmd
+	Stdout  io.Reader
+	Stdin   io.Writer
+	Close   func()
+	exitErr func() error
+}
+
+func (r *Runner) newCommand(ctx context.Context, serverName string, currentEnv map[string]string, root func(context.Context) ([]Root, error), config Server) (Server, *sandbox.Cmd, error) {
+	limits, wallClock, err := config.Limits.Parse()
+	if err != nil {
+		return config, nil, err
+	}
+
+	var proxy *sandbox.EgressProxy
+	if len(config.Limits.Egress) > 0 {
+		proxy, err = sandbox.NewEgressProxy(config.Limits.Egress, func(target string) {
+			r.limitViolation(serverName, &sandbox.LimitError{
+				Limit:   sandbox.LimitEgress,
+				Message: fmt.Sprintf("egress to %s is not allowed", target),
+			})
+		})
+		if err != nil {
+			return config, nil, fmt.Errorf("failed to start egress proxy: %w", err)
+		}
+	}
+
+	config, cmd, err := r.buildCommand(ctx, serverName, currentEnv, root, config, limits, proxy)
+	if err != nil {
+		if proxy != nil {
+			proxy.Close()
+		}
+		return config, nil, err
+	}
+
+	if proxy != nil {
+		cmd.OnExit(proxy.Close)
+	}
+	cmd.SetWallClock(wallClock)
+	return config, cmd, nil
 }
 
-func (r *Runner) newCommand(ctx context.Context, currentEnv map[string]string, root func(context.Context) ([]Root, error), config Server) (Server, *sandbox.Cmd, error) {
+func (r *Runner) buildCommand(ctx context.Context, serverName string, currentEnv map[string]string, root func(context.Context) ([]Root, error), config Server, limits supervise.Limits, proxy *sandbox.EgressProxy) (Server, *sandbox.Cmd, error) {
 	var publishPorts []string
 	ports := config.Ports
+	// Only servers that are reached over their ports need them published.
+	publish := len(ports) > 0 || config.BaseURL != ""
 	if len(ports) == 0 {
 		// If no ports are specified, use the default port
 		ports = []string{"mcp"}
@@ -58,7 +107,9 @@ func (r *Runner) newCommand(ctx context.Context, currentEnv map[string]string, r
 		if err := l.Close(); err != nil {
 			return config, nil, fmt.Errorf("failed to close listener for %s, addr %s: %w", port, addrString, err)
 		}
-		publishPorts = append(publishPorts, portStr)
+		if publish {
+			publishPorts = append(publishPorts, portStr)
+		}
 		currentEnv["port:"+port] = portStr
 		currentEnv["nanobot:port:"+port] = portStr
 	}
@@ -66,7 +117,19 @@ func (r *Runner) newCommand(ctx context.Context, currentEnv map[string]string, r
 	config.BaseURL = envvar.ReplaceString(currentEnv, config.BaseURL)
 
 	command, args, env := envvar.ReplaceEnv(currentEnv, config.Command, config.Args, config.Env)
+	envKeys := slices.Collect(maps.Keys(config.Env))
+	if proxy != nil {
+		env = append(env, proxy.Env()...)
+		for _, e := range proxy.Env() {
+			key, _, _ := strings.Cut(e, "=")
+			envKeys = append(envKeys, key)
+		}
+	}
+
 	if !config.Sandboxed || command == "nanobot" {
+		if proxy != nil {
+			return config, nil, fmt.Errorf("an egress allowlist can only be enforced for servers in the %s sandbox", sandbox.BackendNamespace)
+		}
 		if command == "nanobot" {
 			command = system.Bin()
 		}
@@ -74,7 +137,9 @@ func (r *Runner) newCommand(ctx context.Context, currentEnv map[string]string, r
 		cmd := supervise.Cmd(internalCtx, command, args...)
 		cmd.Dir = envvar.ReplaceString(currentEnv, config.Cwd)
 		cmd.Env = append(cleanOSEnv(), env...)
-		return config, sandbox.WrapCmd(ctx, cmd, forceCancel, nil), nil
+		wrapped := sandbox.WrapCmd(ctx, cmd, forceCancel, nil)
+		wrapped.Limit(serverName, limits)
+		return config, wrapped, nil
 	}
 
 	var (
@@ -99,17 +164,27 @@ func (r *Runner) newCommand(ctx context.Context, currentEnv map[string]string, r
 		}
 	}
 
+	backend := config.SandboxBackend
+	if backend == "" {
+		backend = r.SandboxBackend
+	}
+
 	cmd, err := sandbox.NewCmd(ctx, sandbox.Command{
+		Backend:      backend,
+		Network:      config.SandboxNetwork,
 		PublishPorts: publishPorts,
 		ReversePorts: config.ReversePorts,
 		Roots:        rootPaths,
 		Command:      command,
 		Workdir:      envvar.ReplaceString(config.Env, config.Workdir),
 		Args:         args,
-		Env:          slices.Collect(maps.Keys(config.Env)),
+		Env:          envKeys,
 		BaseImage:    config.Image,
 		Dockerfile:   config.Dockerfile,
 		Source:       sandbox.Source(config.Source),
+		Name:         serverName,
+		Limits:       limits,
+		Egress:       proxy,
 	})
 	if err != nil {
 		return config, nil, fmt.Errorf("failed to create sandbox command: %w", err)
@@ -182,6 +257,9 @@ func (r *Runner) doRun(ctx context.Context, serverName string, config Server, cm
 		if err != nil {
 			slog.Error("command exited with error", "server", serverName, "error", err)
 		}
+		if violation := cmd.Violation(); violation != nil {
+			r.limitViolation(serverName, violation)
+		}
 		r.lock.Lock()
 		delete(r.running, serverName)
 		r.lock.Unlock()
@@ -208,20 +286,60 @@ func (r *Runner) doStream(ctx context.Context, serverName string, cmd *sandbox.C
 		return nil, fmt.Errorf("failed to start command: %w", err)
 	}
 
+	health := r.serverHealth(serverName)
+	health.change(func(h *serverHealth) {
+		h.processes++
+		h.failed = false
+	})
+
+	exited := make(chan struct{})
 	go func() {
-		sandbox.PipeOut(ctx, stderrPipe, serverName)
-		if err := cmd.Wait(); err != nil {
+		defer close(exited)
+		sandbox.PipeOut(ctx, io.TeeReader(stderrPipe, health), serverName)
+		err := cmd.Wait()
+		if err != nil {
 			slog.Error("command exited with error", "server", serverName, "error", err)
 		}
+		violation := cmd.Violation()
+		if violation != nil {
+			r.limitViolation(serverName, violation)
+		}
+		health.change(func(h *serverHealth) {
+			h.processes--
+			if violation != nil {
+				h.lastError = violation.Error()
+			} else if err != nil && ctx.Err() == nil {
+				h.lastError = err.Error()
+			}
+		})
 	}()
 
 	return &streamResult{
 		cmd:    cmd.Cmd,
 		Stdout: stdoutPipe,
 		Stdin:  stdinPipe,
+		exitErr: func() error {
+			// stdout closes right before the process exits, give it a moment to be reaped.
+			select {
+			case <-exited:
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+			if violation := cmd.Violation(); violation != nil {
+				return fmt.Errorf("MCP server %s was stopped: %w", serverName, violation)
+			}
+			return nil
+		},
 	}, nil
 }
 
+func (r *Runner) limitViolation(serverName string, violation *sandbox.LimitError) {
+	slog.Error("MCP server exceeded its limits", "server", serverName, "limit", violation.Limit, "error", violation.Message)
+	if r.OnLimitViolation != nil {
+		r.OnLimitViolation(serverName, violation)
+	}
+}
+
 func (r *Runner) Run(ctx context.Context, roots func(ctx context.Context) ([]Root, error), env map[string]string, serverName string, config Server) (Server, error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -230,7 +348,7 @@ func (r *Runner) Run(ctx context.Context, roots func(ctx context.Context) ([]Roo
 		return c, nil
 	}
 
-	newConfig, cmd, err := r.newCommand(ctx, env, roots, config)
+	newConfig, cmd, err := r.newCommand(ctx, serverName, env, roots, config)
 	if err != nil {
 		return config, err
 	}
@@ -240,7 +358,7 @@ func (r *Runner) Run(ctx context.Context, roots func(ctx context.Context) ([]Roo
 
 func (r *Runner) Stream(ctx context.Context, roots func(context.Context) ([]Root, error), env map[string]string, serverName string, config Server) (*streamResult, error) {
 	ctx, cancel := context.WithCancel(ctx)
-	_, cmd, err := r.newCommand(ctx, env, roots, config)
+	_, cmd, err := r.newCommand(ctx, serverName, env, roots, config)
 	if err != nil {
 		cancel()
 		return nil, err
@@ -0,0 +1,268 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"log/slog"
+
+	"github.com/nanobot-ai/nanobot/pkg/supervise"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"github.com/nanobot-ai/nanobot/pkg/version"
+)
+
+var (
+	validChars = regexp.MustCompile(`^[a-zA-Z0-9@:/._-]+$`)
+	// Must start with git@ or https:// or ssh:// or http://
+	gitRepoPrefix = regexp.MustCompile(`^(git@|https://|ssh://|http://)`)
+)
+
+// containerBackend runs commands in a container using the docker CLI or a compatible one.
+type containerBackend struct {
+	bin string
+	// rootless maps the host user into the container's user namespace instead of running as its ID,
+	// when the runtime runs rootless because it isn't started as root.
+	rootless bool
+}
+
+// publishPorts returns the ports to publish on the host, there are none when sharing the host network.
+func publishPorts(sandbox Command) []string {
+	if sandbox.Network == NetworkHost || sandbox.Network == NetworkNone {
+		return nil
+	}
+	return sandbox.PublishPorts
+}
+
+func (b containerBackend) getBaseImage(ctx context.Context, config Command) (string, error) {
+	baseImage := config.BaseImage
+	if baseImage == "" {
+		baseImage = version.BaseImage
+	}
+	if config.Dockerfile != "" {
+		var err error
+		baseImage, err = b.buildBaseImage(ctx, config)
+		if err != nil {
+			return "", fmt.Errorf("failed to build base image: %w", err)
+		}
+	}
+	if config.Source.Repo != "" {
+		return b.buildImage(ctx, baseImage, config)
+	}
+	if !validChars.MatchString(baseImage) {
+		return "", fmt.Errorf("invalid base image: %s", baseImage)
+	}
+	return baseImage, nil
+}
+
+func (b containerBackend) NewCmd(ctx context.Context, sandbox Command) (*Cmd, error) {
+	if sandbox.Egress != nil {
+		return nil, fmt.Errorf("the %s sandbox backend can not enforce an egress allowlist, use %s", b.bin, BackendNamespace)
+	}
+
+	baseImage, err := b.getBaseImage(ctx, sandbox)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheDir, err := sharedCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	containerName := fmt.Sprintf("nanobot-%s", strings.Split(uuid.String(), "-")[0])
+	dockerArgs := []string{"run",
+		"-i", "--name", containerName}
+
+	for _, dir := range cacheDirs {
+		dockerArgs = append(dockerArgs, "-v", fmt.Sprintf("%s/%s:%s/%s", cacheDir, dir, sandboxHome, dir))
+	}
+
+	if b.rootless && os.Geteuid() != 0 {
+		// Map the host user to the same ID in the container so that mounted files keep their owner.
+		dockerArgs = append(dockerArgs, "--userns=keep-id")
+	} else {
+		dockerArgs = append(dockerArgs, "-u", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	if sandbox.Network != "" {
+		dockerArgs = append(dockerArgs, "--network", sandbox.Network)
+	}
+	dockerArgs = append(dockerArgs, containerLimitArgs(sandbox.Limits)...)
+	for _, k := range sandbox.Env {
+		dockerArgs = append(dockerArgs, "-e", k)
+	}
+
+	for _, root := range sandbox.Roots {
+		dockerArgs = append(dockerArgs, "-v", root.Path+":"+root.Path)
+	}
+	if workdir := sandbox.workdir(); workdir != "" {
+		dockerArgs = append(dockerArgs, "-w", workdir)
+	}
+	for _, port := range publishPorts(sandbox) {
+		dockerArgs = append(dockerArgs, "-p", "127.0.0.1:"+port+":"+port)
+	}
+	dockerArgs = append(dockerArgs, "--", baseImage)
+	if sandbox.Command != "" {
+		dockerArgs = append(dockerArgs, sandbox.Command)
+	}
+	dockerArgs = append(dockerArgs, sandbox.Args...)
+
+	internalCtx, forceCancel := context.WithCancel(context.Background())
+	cmd := supervise.Cmd(internalCtx, b.bin, dockerArgs...)
+	wrapped := WrapCmd(ctx, cmd, forceCancel, func() error {
+		for _, port := range sandbox.ReversePorts {
+			if err := b.startReversePort(internalCtx, containerName, port, forceCancel); err != nil {
+				return err
+			}
+		}
+		return err
+	})
+	if sandbox.Limits.Memory > 0 {
+		wrapped.exitCheck = func() *LimitError {
+			return b.oomKilled(containerName, sandbox.Limits.Memory)
+		}
+	}
+	return wrapped, nil
+}
+
+// oomKilled returns a LimitError if the container was killed for using more than its memory limit.
+func (b containerBackend) oomKilled(containerName string, memory int64) *LimitError {
+	out, err := exec.Command(b.bin, "inspect", "--format", "{{.State.OOMKilled}}", containerName).Output()
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		return nil
+	}
+	return &LimitError{
+		Limit:   LimitMemory,
+		Message: fmt.Sprintf("memory limit of %s exceeded", supervise.FormatBytes(memory)),
+	}
+}
+
+func (b containerBackend) buildImage(ctx context.Context, baseImage string, config Command) (string, error) {
+	var (
+		source   = config.Source.Repo
+		fragment string
+		isGit    = gitRepoPrefix.MatchString(source)
+	)
+
+	if !validChars.MatchString(source) {
+		return "", fmt.Errorf("invalid source repo: %s", source)
+	}
+
+	if config.Source.Commit != "" {
+		fragment = config.Source.Commit
+	} else if config.Source.Tag != "" {
+		fragment = config.Source.Tag
+	} else if config.Source.Branch != "" {
+		fragment = config.Source.Branch
+	}
+	if config.Source.SubPath != "" {
+		fragment += ":" + config.Source.SubPath
+	}
+
+	if fragment != "" && !validChars.MatchString(fragment) {
+		return "", fmt.Errorf("invalid source reference: %s", fragment)
+	}
+
+	if fragment != "" {
+		source = source + "#" + fragment
+	}
+
+	uid := os.Getuid()
+	gid := os.Getgid()
+
+	var cmd *exec.Cmd
+	if isGit {
+		slog.Info("downloading source", "source", source)
+		cmd = exec.CommandContext(ctx, b.bin, "build", "-q", "-")
+		cmd.Stdin = dockerFileToTar(fmt.Sprintf(`FROM %s
+USER %d:%d
+WORKDIR /mcp
+ADD %s /mcp`, baseImage, uid, gid, source))
+	} else {
+		slog.Info("copying source", "source", filepath.Join(config.Source.Repo, config.Source.SubPath))
+		srcPath := config.Source.SubPath
+		if srcPath == "" {
+			srcPath = "."
+		}
+		cmd = exec.CommandContext(ctx, b.bin, "build", "-q", "-f", "-", config.Source.Repo)
+		cmd.Stdin = bytes.NewBufferString(fmt.Sprintf(`FROM %s
+USER %d:%d
+WORKDIR /mcp
+COPY %s /mcp`, baseImage, uid, gid, srcPath))
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get source %s: %w, output: %s", source, err, string(out))
+	}
+
+	id := strings.TrimSpace(string(out))
+	slog.Info("built image", "image", id)
+	return id, nil
+}
+
+func dockerFileToTar(dockerfile string) io.Reader {
+	dockerfile = strings.ReplaceAll(dockerfile, "${NANOBOT_IMAGE}", version.BaseImage)
+	var buf bytes.Buffer
+	t := tar.NewWriter(&buf)
+	if err := t.WriteHeader(&tar.Header{
+		Name: "Dockerfile",
+		Size: int64(len([]byte(dockerfile))),
+	}); err != nil {
+		panic(fmt.Errorf("failed to write tar header: %w", err))
+	}
+	if _, err := t.Write([]byte(dockerfile)); err != nil {
+		panic(fmt.Errorf("failed to write Dockerfile to tar: %w", err))
+	}
+	if err := t.Close(); err != nil {
+		panic(fmt.Errorf("failed to close tar writer: %w", err))
+	}
+	return &buf
+}
+
+func (b containerBackend) buildBaseImage(ctx context.Context, config Command) (string, error) {
+	slog.Info("building base image")
+	f, err := os.CreateTemp("", "nanobot-dockerfile-*.id")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for dockerfile: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	outBuf := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, b.bin, "build", "--iidfile", f.Name(), "-")
+	cmd.Stdin = dockerFileToTar(config.Dockerfile)
+	cmd.Stdout = outBuf
+	stdErr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to get stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start %s build: %w", b.bin, err)
+	}
+
+	lines := bufio.NewScanner(stdErr)
+	for lines.Scan() {
+		_, _ = fmt.Fprintln(os.Stderr, lines.Text())
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return "", fmt.Errorf("failed to build base image: %w, output: %s", err, outBuf.String())
+	}
+
+	idBytes, err := os.ReadFile(f.Name())
+	return strings.TrimSpace(string(idBytes)), err
+}
@@ -0,0 +1,263 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// egressRule allows connections to a host, to the subdomains of a domain or to a network, optionally
+// only on one port.
+type egressRule struct {
+	host     string
+	wildcard bool
+	network  *net.IPNet
+	port     string
+}
+
+func parseEgressRule(rule string) (egressRule, error) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	if rule == "" {
+		return egressRule{}, fmt.Errorf("empty egress rule")
+	}
+
+	if _, network, err := net.ParseCIDR(rule); err == nil {
+		return egressRule{network: network}, nil
+	}
+
+	var result egressRule
+	if host, port, err := net.SplitHostPort(rule); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return egressRule{}, fmt.Errorf("invalid port in egress rule %q", rule)
+		}
+		rule, result.port = host, port
+	}
+
+	if ip := net.ParseIP(rule); ip != nil {
+		bits := 8 * len(ip.To16())
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		result.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		return result, nil
+	}
+
+	if domain, ok := strings.CutPrefix(rule, "*."); ok {
+		result.wildcard = true
+		rule = domain
+	}
+	if rule == "" || strings.ContainsAny(rule, "*/ ") {
+		return egressRule{}, fmt.Errorf("invalid egress rule %q, must be a host, *.domain, IP or CIDR with an optional :port", rule)
+	}
+	result.host = rule
+	return result, nil
+}
+
+func (r egressRule) allows(host, port string) bool {
+	if r.port != "" && r.port != port {
+		return false
+	}
+	if r.network != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && r.network.Contains(ip)
+	}
+	if r.wildcard {
+		return strings.HasSuffix(host, "."+r.host)
+	}
+	return host == r.host
+}
+
+// ValidateEgress checks the rules of an egress allowlist.
+func ValidateEgress(rules []string) error {
+	for _, rule := range rules {
+		if _, err := parseEgressRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// egressPort is the port the egress proxy is reachable on in the private network of a sandbox.
+const egressPort = 3128
+
+// EgressProxy is an HTTP proxy that only connects to the hosts of an allowlist. It listens on a unix
+// socket that the namespace backend forwards egressPort of the sandbox's private network to, so the
+// proxy is the only way out of the sandbox. Commands use it through the standard proxy environment
+// variables, see Env.
+type EgressProxy struct {
+	rules     []egressRule
+	onDeny    func(target string)
+	dir       string
+	listener  net.Listener
+	server    *http.Server
+	dialer    *net.Dialer
+	transport *http.Transport
+}
+
+// NewEgressProxy starts a proxy allowing the rules on a unix socket in a new temporary directory.
+// onDeny is called for every connection that is not allowed.
+func NewEgressProxy(rules []string, onDeny func(target string)) (*EgressProxy, error) {
+	p := &EgressProxy{
+		onDeny: onDeny,
+		dialer: &net.Dialer{Timeout: 30 * time.Second},
+	}
+	p.transport = &http.Transport{
+		DialContext: p.dialer.DialContext,
+	}
+	for _, rule := range rules {
+		parsed, err := parseEgressRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		p.rules = append(p.rules, parsed)
+	}
+
+	var err error
+	p.dir, err = os.MkdirTemp("", "nanobot-egress-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create egress proxy directory: %w", err)
+	}
+	p.listener, err = net.Listen("unix", filepath.Join(p.dir, "proxy.sock"))
+	if err != nil {
+		_ = os.RemoveAll(p.dir)
+		return nil, fmt.Errorf("failed to listen for egress proxy: %w", err)
+	}
+	p.server = &http.Server{
+		Handler:           p,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go func() {
+		if err := p.server.Serve(p.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("egress proxy failed", "error", err)
+		}
+	}()
+	return p, nil
+}
+
+// Socket returns the path of the unix socket the proxy listens on.
+func (p *EgressProxy) Socket() string {
+	return p.listener.Addr().String()
+}
+
+// Env returns the environment variables that make commands in a sandbox use the proxy. Node.js only
+// uses them with NODE_USE_ENV_PROXY set.
+func (p *EgressProxy) Env() []string {
+	proxyURL := fmt.Sprintf("http://127.0.0.1:%d", egressPort)
+	return []string{
+		"HTTP_PROXY=" + proxyURL,
+		"HTTPS_PROXY=" + proxyURL,
+		"ALL_PROXY=" + proxyURL,
+		"http_proxy=" + proxyURL,
+		"https_proxy=" + proxyURL,
+		"all_proxy=" + proxyURL,
+		"NO_PROXY=",
+		"no_proxy=",
+		"NODE_USE_ENV_PROXY=1",
+	}
+}
+
+func (p *EgressProxy) Close() {
+	_ = p.server.Close()
+	p.transport.CloseIdleConnections()
+	_ = os.RemoveAll(p.dir)
+}
+
+func (p *EgressProxy) allowed(target string) bool {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return false
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, rule := range p.rules {
+		if rule.allows(host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *EgressProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	target := req.Host
+	if req.Method != http.MethodConnect {
+		target = req.URL.Host
+		if req.URL.Scheme != "http" || target == "" {
+			http.Error(rw, "nanobot egress proxy only supports http URLs and CONNECT", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, "80")
+	}
+
+	if !p.allowed(target) {
+		if p.onDeny != nil {
+			p.onDeny(target)
+		}
+		http.Error(rw, fmt.Sprintf("nanobot: egress to %s is not in the MCP server's egress allowlist", target), http.StatusForbidden)
+		return
+	}
+
+	if req.Method == http.MethodConnect {
+		p.connect(rw, req, target)
+		return
+	}
+	p.forward(rw, req)
+}
+
+func (p *EgressProxy) connect(rw http.ResponseWriter, req *http.Request, target string) {
+	upstream, err := p.dialer.DialContext(req.Context(), "tcp", target)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	conn, buf, err := http.NewResponseController(rw).Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		_ = conn.Close()
+		_ = upstream.Close()
+		return
+	}
+
+	go func() {
+		_, _ = io.Copy(upstream, buf)
+		_ = upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+	_ = conn.Close()
+}
+
+func (p *EgressProxy) forward(rw http.ResponseWriter, req *http.Request) {
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+
+	// Redirects are returned to the client, which sends them through the proxy again.
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			rw.Header().Add(key, value)
+		}
+	}
+	rw.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(rw, resp.Body)
+}
@@ -0,0 +1,125 @@
+package sandbox
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/supervise"
+)
+
+const (
+	LimitMemory    = "memory"
+	LimitPids      = "pids"
+	LimitWallClock = "wallClock"
+	LimitEgress    = "egress"
+)
+
+// LimitError reports that a command was stopped or restricted because it exceeded one of its limits.
+type LimitError struct {
+	// Limit is LimitMemory, LimitPids, LimitWallClock or LimitEgress.
+	Limit   string
+	Message string
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// containerLimitArgs returns the docker run flags for the limits.
+func containerLimitArgs(limits supervise.Limits) (args []string) {
+	if limits.Memory > 0 {
+		memory := strconv.FormatInt(limits.Memory, 10)
+		// The same swap limit as memory limit disables swap.
+		args = append(args, "--memory", memory, "--memory-swap", memory)
+	}
+	if limits.CPUShares > 0 {
+		args = append(args, "--cpu-shares", strconv.Itoa(limits.CPUShares))
+	}
+	if limits.Pids > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(limits.Pids))
+	}
+	if limits.OpenFiles > 0 {
+		args = append(args, "--ulimit", fmt.Sprintf("nofile=%d:%d", limits.OpenFiles, limits.OpenFiles))
+	}
+	return args
+}
+
+// Limit enforces the limits on the host when the command is started, see supervise.Limit. It is
+// used for commands that are not run in a container.
+func (c *Cmd) Limit(name string, limits supervise.Limits) {
+	c.name = name
+	c.hostLimits = limits
+}
+
+// SetWallClock stops the command once it has run for d.
+func (c *Cmd) SetWallClock(d time.Duration) {
+	c.wallClock = d
+}
+
+// OnExit registers f to be called after the command exited.
+func (c *Cmd) OnExit(f func()) {
+	c.onExit = append(c.onExit, f)
+}
+
+// Violation returns the limit that the command exceeded. Limits that stop the command are only
+// known once it exited.
+func (c *Cmd) Violation() *LimitError {
+	c.violationLock.Lock()
+	defer c.violationLock.Unlock()
+	return c.violation
+}
+
+// SetViolation records a limit the command exceeded, the first one is kept.
+func (c *Cmd) SetViolation(err *LimitError) {
+	c.violationLock.Lock()
+	defer c.violationLock.Unlock()
+	if c.violation == nil {
+		c.violation = err
+	}
+}
+
+func (c *Cmd) startLimits() error {
+	if c.hostLimits.IsZero() {
+		return nil
+	}
+	limiter, err := supervise.Limit(c.Cmd, c.name, c.hostLimits)
+	if err != nil {
+		return fmt.Errorf("failed to enforce limits of MCP server %s: %w", c.name, err)
+	}
+	c.limiter = limiter
+	return nil
+}
+
+func (c *Cmd) startWallClock() {
+	if c.wallClock <= 0 {
+		return
+	}
+	c.wallClockTimer = time.AfterFunc(c.wallClock, func() {
+		c.SetViolation(&LimitError{
+			Limit:   LimitWallClock,
+			Message: fmt.Sprintf("wall-clock limit of %s exceeded", c.wallClock),
+		})
+		c.cancel()
+	})
+}
+
+func (c *Cmd) stopLimits() {
+	if c.wallClockTimer != nil {
+		c.wallClockTimer.Stop()
+	}
+	if c.limiter != nil {
+		if limit, message := c.limiter.Violation(); limit != "" {
+			c.SetViolation(&LimitError{Limit: limit, Message: message})
+		}
+		c.limiter.Close()
+	}
+	if c.exitCheck != nil {
+		if violation := c.exitCheck(); violation != nil {
+			c.SetViolation(violation)
+		}
+	}
+	for _, f := range c.onExit {
+		f()
+	}
+}
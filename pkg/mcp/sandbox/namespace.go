@@ -0,0 +1,64 @@
+package sandbox
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// namespaceBackend runs commands with Linux user, mount, PID, IPC and UTS namespaces. The host
+// filesystem is visible read-only, the roots are mounted read-write and the home directory is a
+// private directory with the shared caches. No container runtime is needed.
+type namespaceBackend struct{}
+
+// namespaceSpec is passed from the sandboxed command to the _sandbox entrypoint that sets up the
+// namespaces.
+type namespaceSpec struct {
+	Roots    []Root   `json:"roots,omitempty"`
+	Workdir  string   `json:"workdir,omitempty"`
+	Command  string   `json:"command"`
+	Args     []string `json:"args,omitempty"`
+	CacheDir string   `json:"cacheDir,omitempty"`
+	Network  string   `json:"network,omitempty"`
+	UID      int      `json:"uid"`
+	GID      int      `json:"gid"`
+	// EgressSocket is the unix socket of the egress proxy. The sandbox gets a private network that
+	// only reaches the proxy if it is set.
+	EgressSocket string `json:"egressSocket,omitempty"`
+}
+
+func (s namespaceSpec) encode() (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal sandbox spec: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func decodeSpec(arg string) (spec namespaceSpec, _ error) {
+	data, err := base64.StdEncoding.DecodeString(arg)
+	if err != nil {
+		return spec, fmt.Errorf("failed to decode sandbox spec: %w", err)
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("failed to unmarshal sandbox spec: %w", err)
+	}
+	return spec, nil
+}
+
+// checkNamespaceCommand returns an error for the options that need a container image.
+func checkNamespaceCommand(sandbox Command) error {
+	if sandbox.Dockerfile != "" || sandbox.Source.Repo != "" {
+		return fmt.Errorf("the %s sandbox backend does not support dockerfile or source, use %s or %s", BackendNamespace, BackendDocker, BackendPodman)
+	}
+	if len(sandbox.ReversePorts) > 0 && sandbox.Network == NetworkNone {
+		return fmt.Errorf("reversePorts can not be used with the %s sandbox network", NetworkNone)
+	}
+	if len(sandbox.PublishPorts) > 0 && sandbox.Network == NetworkNone {
+		return fmt.Errorf("publishPorts can not be used with the %s sandbox network", NetworkNone)
+	}
+	if sandbox.Egress != nil && (sandbox.Network != "" || len(sandbox.PublishPorts) > 0 || len(sandbox.ReversePorts) > 0) {
+		return fmt.Errorf("an egress allowlist can not be used with a sandbox network or ports, the sandbox gets a private network")
+	}
+	return nil
+}
@@ -0,0 +1,488 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/nanobot-ai/nanobot/pkg/supervise"
+	"github.com/nanobot-ai/nanobot/pkg/system"
+	"golang.org/x/sys/unix"
+)
+
+// hiddenDirs are the top level host directories that are not visible in the sandbox.
+var hiddenDirs = []string{"boot", "dev", "home", "lost+found", "media", "mnt", "proc", "root", "run", "sys", "tmp"}
+
+// devices are bound from the host into the sandbox's /dev.
+var devices = []string{"full", "null", "random", "tty", "urandom", "zero"}
+
+func (namespaceBackend) NewCmd(ctx context.Context, sandbox Command) (*Cmd, error) {
+	if err := checkNamespaceCommand(sandbox); err != nil {
+		return nil, err
+	}
+
+	cacheDir, err := sharedCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := namespaceSpec{
+		Roots:        sandbox.Roots,
+		Workdir:      sandbox.workdir(),
+		Command:      sandbox.Command,
+		Args:         sandbox.Args,
+		CacheDir:     cacheDir,
+		Network:      sandbox.Network,
+		UID:          os.Getuid(),
+		GID:          os.Getgid(),
+		EgressSocket: egressSocket(sandbox.Egress),
+	}.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	internalCtx, forceCancel := context.WithCancel(context.Background())
+	cmd := supervise.Cmd(internalCtx, system.Bin(), "_sandbox", spec)
+	wrapped := WrapCmd(ctx, cmd, forceCancel, nil)
+	wrapped.Limit(sandbox.Name, sandbox.Limits)
+	return wrapped, nil
+}
+
+// Main is the entrypoint of the _sandbox command used by the namespace backend. The first stage
+// creates the namespaces and the second stage, running as init of the new PID namespace, builds
+// the filesystem and runs the command.
+func Main(args []string) error {
+	if len(args) == 3 && args[0] == "init" {
+		spec, err := decodeSpec(args[2])
+		if err != nil {
+			return err
+		}
+		return initSandbox(args[1], spec)
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("invalid sandbox arguments")
+	}
+	spec, err := decodeSpec(args[0])
+	if err != nil {
+		return err
+	}
+	return startSandbox(args[0], spec)
+}
+
+func startSandbox(arg string, spec namespaceSpec) error {
+	newRoot, err := os.MkdirTemp("", "nanobot-sandbox-")
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox root: %w", err)
+	}
+	defer os.Remove(newRoot)
+
+	cloneFlags := uintptr(unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWPID | unix.CLONE_NEWIPC | unix.CLONE_NEWUTS)
+	if spec.Network == NetworkNone || spec.EgressSocket != "" {
+		cloneFlags |= unix.CLONE_NEWNET
+	}
+
+	cmd := exec.Command("/proc/self/exe", "_sandbox", "init", newRoot, arg)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: cloneFlags,
+		// Setting up the sandbox needs to be root in the user namespace, the command itself runs
+		// as the original user in a nested user namespace.
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: spec.UID, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: spec.GID, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to create sandbox namespaces: %w", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	return exitWith(cmd.Wait())
+}
+
+func initSandbox(newRoot string, spec namespaceSpec) error {
+	runtime.LockOSThread()
+
+	if err := buildRoot(newRoot, spec); err != nil {
+		return err
+	}
+	if err := pivotRoot(newRoot); err != nil {
+		return err
+	}
+	_ = unix.Sethostname([]byte("nanobot-sandbox"))
+
+	if spec.EgressSocket != "" {
+		if err := forwardEgress(spec.EgressSocket); err != nil {
+			return err
+		}
+	}
+
+	workdir := spec.Workdir
+	if workdir == "" {
+		workdir = sandboxHome
+	}
+	if err := os.Chdir(workdir); err != nil {
+		return fmt.Errorf("failed to change to sandbox workdir %s: %w", workdir, err)
+	}
+
+	if err := restrictSyscalls(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(spec.Command, spec.Args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(slices.DeleteFunc(os.Environ(), func(e string) bool {
+		return strings.HasPrefix(e, "HOME=")
+	}), "HOME="+sandboxHome)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		// Map the root user of the sandbox back to the original user so that the command runs
+		// without any capabilities and sees its own files as owned by itself.
+		Cloneflags:                 unix.CLONE_NEWUSER,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: spec.UID, HostID: 0, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: spec.GID, HostID: 0, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s in sandbox: %w", spec.Command, err)
+	}
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	// As init of the PID namespace, reap every orphaned process until the command exits. Exiting
+	// kills whatever is left in the namespace.
+	for {
+		var status unix.WaitStatus
+		pid, err := unix.Wait4(-1, &status, 0, nil)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to wait for sandboxed command: %w", err)
+		}
+		if pid != cmd.Process.Pid {
+			continue
+		}
+		if status.Signaled() {
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(status.ExitStatus())
+	}
+}
+
+// buildRoot populates newRoot with the filesystem of the sandbox.
+func buildRoot(newRoot string, spec namespaceSpec) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	if err := unix.Mount("tmpfs", newRoot, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("failed to mount sandbox root: %w", err)
+	}
+
+	entries, err := os.ReadDir("/")
+	if err != nil {
+		return fmt.Errorf("failed to read host root: %w", err)
+	}
+	for _, entry := range entries {
+		if slices.Contains(hiddenDirs, entry.Name()) {
+			continue
+		}
+		source := filepath.Join("/", entry.Name())
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			target, err := os.Readlink(source)
+			if err != nil {
+				return fmt.Errorf("failed to read link %s: %w", source, err)
+			}
+			if err := os.Symlink(target, filepath.Join(newRoot, entry.Name())); err != nil {
+				return fmt.Errorf("failed to create link %s: %w", source, err)
+			}
+		case entry.IsDir():
+			if err := bind(source, newRoot, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := buildDev(newRoot); err != nil {
+		return err
+	}
+
+	if err := os.Mkdir(filepath.Join(newRoot, "proc"), 0755); err != nil {
+		return fmt.Errorf("failed to create /proc: %w", err)
+	}
+	if err := unix.Mount("proc", filepath.Join(newRoot, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		// Mounting a new proc fails when the host's proc has parts masked, as in most containers.
+		if err := unix.Mount("/proc", filepath.Join(newRoot, "proc"), "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to mount /proc: %w", err)
+		}
+	}
+
+	for _, dir := range []string{"tmp", strings.TrimPrefix(sandboxHome, "/")} {
+		if err := os.Mkdir(filepath.Join(newRoot, dir), 0755); err != nil {
+			return fmt.Errorf("failed to create /%s: %w", dir, err)
+		}
+		if err := unix.Mount("tmpfs", filepath.Join(newRoot, dir), "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("failed to mount /%s: %w", dir, err)
+		}
+	}
+	if spec.CacheDir != "" {
+		for _, dir := range cacheDirs {
+			if err := bindTo(filepath.Join(spec.CacheDir, dir), filepath.Join(newRoot, sandboxHome, dir), false); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Tools installed under the home directory are usually on the PATH, keep them visible.
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if !filepath.IsAbs(dir) || !isHidden(dir) {
+			continue
+		}
+		if filepath.Base(dir) == "bin" {
+			// Binaries in bin directories usually link to files next to it, like node_modules in lib.
+			dir = filepath.Dir(dir)
+		}
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if err := bind(dir, newRoot, true); err != nil {
+			return err
+		}
+	}
+
+	if spec.EgressSocket != "" {
+		if err := bind(filepath.Dir(spec.EgressSocket), newRoot, true); err != nil {
+			return err
+		}
+	} else if spec.Network != NetworkNone {
+		// resolv.conf is commonly a link into /run which is not visible in the sandbox.
+		if target, err := filepath.EvalSymlinks("/etc/resolv.conf"); err == nil && isHidden(target) {
+			if err := bind(filepath.Dir(target), newRoot, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, root := range spec.Roots {
+		if err := bind(root.Path, newRoot, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func egressSocket(proxy *EgressProxy) string {
+	if proxy == nil {
+		return ""
+	}
+	return proxy.Socket()
+}
+
+// forwardEgress brings up the loopback interface of the sandbox's private network and forwards
+// egressPort on it to the egress proxy, which is the only connection out of the sandbox. The command
+// can't change the network as it has no capabilities in it.
+func forwardEgress(socket string) error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open socket to configure loopback: %w", err)
+	}
+	defer unix.Close(fd)
+
+	ifreq, err := unix.NewIfreq("lo")
+	if err != nil {
+		return fmt.Errorf("failed to configure loopback: %w", err)
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifreq); err != nil {
+		return fmt.Errorf("failed to get loopback flags: %w", err)
+	}
+	ifreq.SetUint16(ifreq.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifreq); err != nil {
+		return fmt.Errorf("failed to bring up loopback: %w", err)
+	}
+
+	l, err := net.Listen("tcp4", fmt.Sprintf("127.0.0.1:%d", egressPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen for egress: %w", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				upstream, err := net.Dial("unix", socket)
+				if err != nil {
+					return
+				}
+				go func() {
+					_, _ = io.Copy(upstream, conn)
+					_ = upstream.Close()
+				}()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return nil
+}
+
+// isHidden returns whether the host path is not visible in the sandbox by default.
+func isHidden(path string) bool {
+	top, _, _ := strings.Cut(strings.TrimPrefix(filepath.Clean(path), "/"), "/")
+	return slices.Contains(hiddenDirs, top)
+}
+
+func buildDev(newRoot string) error {
+	dev := filepath.Join(newRoot, "dev")
+	if err := os.Mkdir(dev, 0755); err != nil {
+		return fmt.Errorf("failed to create /dev: %w", err)
+	}
+	if err := unix.Mount("tmpfs", dev, "tmpfs", unix.MS_NOSUID|unix.MS_NOEXEC, "mode=0755"); err != nil {
+		return fmt.Errorf("failed to mount /dev: %w", err)
+	}
+	for _, device := range devices {
+		if err := bindTo(filepath.Join("/dev", device), filepath.Join(dev, device), false); err != nil {
+			return err
+		}
+	}
+	for name, target := range map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	} {
+		if err := os.Symlink(target, filepath.Join(dev, name)); err != nil {
+			return fmt.Errorf("failed to create /dev/%s: %w", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dev, "shm"), 01777); err != nil {
+		return fmt.Errorf("failed to create /dev/shm: %w", err)
+	}
+	if err := unix.Mount("tmpfs", filepath.Join(dev, "shm"), "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("failed to mount /dev/shm: %w", err)
+	}
+	return nil
+}
+
+// bind mounts the host path at the same path under newRoot.
+func bind(source, newRoot string, readOnly bool) error {
+	return bindTo(source, filepath.Join(newRoot, source), readOnly)
+}
+
+func bindTo(source, target string, readOnly bool) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("failed to bind %s into sandbox: %w", source, err)
+	}
+	if info.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("failed to create mount point %s: %w", target, err)
+	}
+
+	if err := unix.Mount(source, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to bind %s into sandbox: %w", source, err)
+	}
+	if !readOnly {
+		return nil
+	}
+
+	err = unix.MountSetattr(-1, target, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+	if err == nil {
+		return nil
+	}
+
+	// Kernels before 5.12 can only make the top mount read-only. The flags the mount already has
+	// are locked in a user namespace and have to be kept.
+	var stat unix.Statfs_t
+	if err := unix.Statfs(target, &stat); err != nil {
+		return fmt.Errorf("failed to stat %s: %w", target, err)
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for st, ms := range map[int64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if int64(stat.Flags)&st != 0 {
+			flags |= ms
+		}
+	}
+	if err := unix.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("failed to make %s read-only: %w", target, err)
+	}
+	return nil
+}
+
+func pivotRoot(newRoot string) error {
+	if err := os.Chdir(newRoot); err != nil {
+		return fmt.Errorf("failed to change to sandbox root: %w", err)
+	}
+	if err := os.Mkdir(".oldroot", 0700); err != nil {
+		return fmt.Errorf("failed to create old root: %w", err)
+	}
+	if err := unix.PivotRoot(".", ".oldroot"); err != nil {
+		return fmt.Errorf("failed to pivot to sandbox root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return fmt.Errorf("failed to change to sandbox root: %w", err)
+	}
+	if err := unix.Unmount("/.oldroot", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to unmount old root: %w", err)
+	}
+	if err := os.Remove("/.oldroot"); err != nil {
+		return fmt.Errorf("failed to remove old root: %w", err)
+	}
+	// Nothing else needs to be created at the top of the sandbox.
+	_ = unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, "")
+	return nil
+}
+
+// exitWith exits with the exit code of the command that ended with err.
+func exitWith(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(exitErr.ExitCode())
+	}
+	return err
+}
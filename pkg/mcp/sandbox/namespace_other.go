@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+func (namespaceBackend) NewCmd(context.Context, Command) (*Cmd, error) {
+	return nil, fmt.Errorf("the %s sandbox backend is not supported on %s", BackendNamespace, runtime.GOOS)
+}
+
+// Main is the entrypoint of the _sandbox command used by the namespace backend, which is only
+// supported on Linux.
+func Main([]string) error {
+	return fmt.Errorf("the %s sandbox backend is not supported on %s", BackendNamespace, runtime.GOOS)
+}
@@ -14,9 +14,9 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/version"
 )
 
-func startReversePort(ctx context.Context, targetContainerName string, port int, cancel func()) error {
+func (b containerBackend) startReversePort(ctx context.Context, targetContainerName string, port int, cancel func()) error {
 	for range 10 {
-		if err := exec.Command("docker", "start", targetContainerName).Run(); err == nil {
+		if err := exec.Command(b.bin, "start", targetContainerName).Run(); err == nil {
 			break
 		}
 	}
@@ -42,7 +42,7 @@ func startReversePort(ctx context.Context, targetContainerName string, port int,
 	}
 
 	containerName := fmt.Sprintf("%s-%d", targetContainerName, port)
-	cmd := supervise.Cmd(ctx, "docker", "run", "--rm",
+	cmd := supervise.Cmd(ctx, b.bin, "run", "--rm",
 		"--network", "container:"+targetContainerName,
 		"--name", containerName,
 		"-e", "LISTEN_PORT",
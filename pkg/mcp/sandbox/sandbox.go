@@ -1,34 +1,71 @@
 package sandbox
 
 import (
-	"archive/tar"
-	"bufio"
-	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strings"
 	"sync"
 	"time"
 
-	"log/slog"
-
 	"github.com/nanobot-ai/nanobot/pkg/supervise"
-	"github.com/nanobot-ai/nanobot/pkg/uuid"
-	"github.com/nanobot-ai/nanobot/pkg/version"
 )
 
-var (
-	validChars = regexp.MustCompile(`^[a-zA-Z0-9@:/._-]+$`)
-	// Must start with git@ or https:// or ssh:// or http://
-	gitRepoPrefix = regexp.MustCompile(`^(git@|https://|ssh://|http://)`)
+const (
+	BackendDocker    = "docker"
+	BackendPodman    = "podman"
+	BackendNamespace = "namespace"
+
+	// NetworkHost shares the host network with the sandbox.
+	NetworkHost = "host"
+	// NetworkNone gives the sandbox no network access at all.
+	NetworkNone = "none"
 )
 
+// Backend starts commands in a sandbox.
+type Backend interface {
+	NewCmd(ctx context.Context, command Command) (*Cmd, error)
+}
+
+var backends = map[string]Backend{
+	BackendDocker:    containerBackend{bin: "docker"},
+	BackendPodman:    containerBackend{bin: "podman", rootless: true},
+	BackendNamespace: namespaceBackend{},
+}
+
+// GetBackend returns the backend with the given name. An empty name selects Docker.
+func GetBackend(name string) (Backend, error) {
+	if name == "" {
+		name = BackendDocker
+	}
+	backend, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sandbox backend %q, must be one of %s, %s or %s", name, BackendDocker, BackendPodman, BackendNamespace)
+	}
+	return backend, nil
+}
+
+// Validate checks that the sandbox backend and network of a server are known. Empty values select
+// the defaults.
+func Validate(backend, network string) error {
+	if _, err := GetBackend(backend); err != nil {
+		return err
+	}
+	switch network {
+	case "", NetworkHost, NetworkNone:
+		return nil
+	}
+	return fmt.Errorf("unknown sandbox network %q, must be %s or %s", network, NetworkHost, NetworkNone)
+}
+
 type Command struct {
+	// Backend is the name of the sandbox backend, see GetBackend.
+	Backend string
+	// Network is empty for the backend's default network, NetworkHost or NetworkNone. The container
+	// backends default to their bridge network and the namespace backend to the host network.
+	Network      string
 	PublishPorts []string
 	ReversePorts []int
 	Roots        []Root
@@ -39,6 +76,13 @@ type Command struct {
 	BaseImage    string
 	Dockerfile   string
 	Source       Source
+	// Name identifies the command in logs and resource names.
+	Name string
+	// Egress, if set, is the only way the command can connect out of the sandbox. Only the namespace
+	// backend can enforce it.
+	Egress *EgressProxy
+	// Limits are the resource limits of the command.
+	Limits supervise.Limits
 }
 
 type Root struct {
@@ -60,6 +104,17 @@ type Cmd struct {
 	cancel    func()
 	postStart func() error
 
+	name           string
+	hostLimits     supervise.Limits
+	limiter        *supervise.Limiter
+	wallClock      time.Duration
+	wallClockTimer *time.Timer
+	exitCheck      func() *LimitError
+	onExit         []func()
+	exitOnce       sync.Once
+	violationLock  sync.Mutex
+	violation      *LimitError
+
 	stdinMu   sync.Mutex
 	stdinPipe io.WriteCloser
 	done      chan struct{}
@@ -69,6 +124,7 @@ type Cmd struct {
 
 func (c *Cmd) Wait() error {
 	err := c.Cmd.Wait()
+	c.exitOnce.Do(c.stopLimits)
 	if c.done != nil {
 		c.doneOnce.Do(func() {
 			close(c.done)
@@ -95,9 +151,15 @@ func (c *Cmd) StdinPipe() (io.WriteCloser, error) {
 }
 
 func (c *Cmd) Start() error {
+	if err := c.startLimits(); err != nil {
+		c.exitOnce.Do(c.stopLimits)
+		return err
+	}
 	if err := c.Cmd.Start(); err != nil {
+		c.exitOnce.Do(c.stopLimits)
 		return err
 	}
+	c.startWallClock()
 	if c.postStart == nil {
 		return nil
 	}
@@ -135,224 +197,68 @@ func (c *Cmd) requestStop(forceCancel func()) {
 	})
 }
 
-func WrapCmd(ctx context.Context, cmd *exec.Cmd, forceCancel func(), postStart func() error) *Cmd {
-	wrapped := &Cmd{
-		Cmd:  cmd,
-		done: make(chan struct{}),
-	}
-	wrapped.cancel = func() {
-		wrapped.requestStop(forceCancel)
-	}
-	go func() {
-		select {
-		case <-ctx.Done():
-			wrapped.requestStop(forceCancel)
-		case <-wrapped.done:
-			forceCancel()
-		}
-	}()
-	wrapped.postStart = postStart
-	return wrapped
-}
-
-func getBaseImage(ctx context.Context, config Command) (string, error) {
-	baseImage := config.BaseImage
-	if baseImage == "" {
-		baseImage = version.BaseImage
-	}
-	if config.Dockerfile != "" {
-		var err error
-		baseImage, err = buildBaseImage(ctx, config)
-		if err != nil {
-			return "", fmt.Errorf("failed to build base image: %w", err)
-		}
-	}
-	if config.Source.Repo != "" {
-		return buildImage(ctx, baseImage, config)
-	}
-	if !validChars.MatchString(baseImage) {
-		return "", fmt.Errorf("invalid base image: %s", baseImage)
-	}
-	return baseImage, nil
-}
+// sandboxHome is the home directory inside a sandbox, where the shared cache directories are mounted.
+const sandboxHome = "/mcp"
 
-func NewCmd(ctx context.Context, sandbox Command) (*Cmd, error) {
-	baseImage, err := getBaseImage(ctx, sandbox)
-	if err != nil {
-		return nil, err
-	}
+// cacheDirs are the directories in the sandbox home that are shared by all sandboxes so that
+// downloaded packages are reused.
+var cacheDirs = []string{".cache", ".npm", "go/pkg"}
 
+// sharedCacheDir returns the host directory holding the shared cache directories, creating them if needed.
+func sharedCacheDir() (string, error) {
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user cache directory: %w", err)
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
 	}
 
-	containerName := fmt.Sprintf("nanobot-%s", strings.Split(uuid.String(), "-")[0])
-	dockerArgs := []string{"run",
-		"-i", "--name", containerName}
-
 	cacheDir = filepath.Join(cacheDir, "nanobot")
-	for _, dir := range []string{".cache", ".npm", "go/pkg"} {
+	for _, dir := range cacheDirs {
 		if err := os.MkdirAll(filepath.Join(cacheDir, dir), 0755); err != nil {
-			return nil, fmt.Errorf("failed to create cache directory: %w", err)
-		}
-		dockerArgs = append(dockerArgs, "-v", fmt.Sprintf("%s/%s:/mcp/%s", cacheDir, dir, dir))
-	}
-
-	dockerArgs = append(dockerArgs, "-u", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
-	for _, k := range sandbox.Env {
-		dockerArgs = append(dockerArgs, "-e", k)
-	}
-
-	workdir := sandbox.Workdir
-	for _, root := range sandbox.Roots {
-		if root.Name == "cwd" && sandbox.Source.Repo == "" && sandbox.Source.SubPath == "" && workdir == "" {
-			workdir = root.Path
+			return "", fmt.Errorf("failed to create cache directory: %w", err)
 		}
-		dockerArgs = append(dockerArgs, "-v", root.Path+":"+root.Path)
-	}
-	if workdir != "" {
-		dockerArgs = append(dockerArgs, "-w", workdir)
-	}
-	for _, port := range sandbox.PublishPorts {
-		dockerArgs = append(dockerArgs, "-p", "127.0.0.1:"+port+":"+port)
 	}
-	dockerArgs = append(dockerArgs, "--", baseImage)
-	if sandbox.Command != "" {
-		dockerArgs = append(dockerArgs, sandbox.Command)
-	}
-	dockerArgs = append(dockerArgs, sandbox.Args...)
-
-	internalCtx, forceCancel := context.WithCancel(context.Background())
-	cmd := supervise.Cmd(internalCtx, "docker", dockerArgs...)
-	return WrapCmd(ctx, cmd, forceCancel, func() error {
-		for _, port := range sandbox.ReversePorts {
-			if err := startReversePort(internalCtx, containerName, port, forceCancel); err != nil {
-				return err
-			}
-		}
-		return err
-	}), nil
+	return cacheDir, nil
 }
 
-func buildImage(ctx context.Context, baseImage string, config Command) (string, error) {
-	var (
-		source   = config.Source.Repo
-		fragment string
-		isGit    = gitRepoPrefix.MatchString(source)
-	)
-
-	if !validChars.MatchString(source) {
-		return "", fmt.Errorf("invalid source repo: %s", source)
+// workdir returns the working directory of the command, which defaults to the cwd root unless the
+// command runs from a source checkout.
+func (c Command) workdir() string {
+	if c.Workdir != "" || c.Source.Repo != "" || c.Source.SubPath != "" {
+		return c.Workdir
 	}
-
-	if config.Source.Commit != "" {
-		fragment = config.Source.Commit
-	} else if config.Source.Tag != "" {
-		fragment = config.Source.Tag
-	} else if config.Source.Branch != "" {
-		fragment = config.Source.Branch
-	}
-	if config.Source.SubPath != "" {
-		fragment += ":" + config.Source.SubPath
-	}
-
-	if fragment != "" && !validChars.MatchString(fragment) {
-		return "", fmt.Errorf("invalid source reference: %s", fragment)
-	}
-
-	if fragment != "" {
-		source = source + "#" + fragment
-	}
-
-	uid := os.Getuid()
-	gid := os.Getgid()
-
-	var cmd *exec.Cmd
-	if isGit {
-		slog.Info("downloading source", "source", source)
-		cmd = exec.CommandContext(ctx, "docker", "build", "-q", "-")
-		cmd.Stdin = dockerFileToTar(fmt.Sprintf(`FROM %s
-USER %d:%d
-WORKDIR /mcp
-ADD %s /mcp`, baseImage, uid, gid, source))
-	} else {
-		slog.Info("copying source", "source", filepath.Join(config.Source.Repo, config.Source.SubPath))
-		srcPath := config.Source.SubPath
-		if srcPath == "" {
-			srcPath = "."
+	for _, root := range c.Roots {
+		if root.Name == "cwd" {
+			return root.Path
 		}
-		cmd = exec.CommandContext(ctx, "docker", "build", "-q", "-f", "-", config.Source.Repo)
-		cmd.Stdin = bytes.NewBufferString(fmt.Sprintf(`FROM %s
-USER %d:%d
-WORKDIR /mcp
-COPY %s /mcp`, baseImage, uid, gid, srcPath))
-	}
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("failed to get source %s: %w, output: %s", source, err, string(out))
 	}
-
-	id := strings.TrimSpace(string(out))
-	slog.Info("built image", "image", id)
-	return id, nil
+	return ""
 }
 
-func dockerFileToTar(dockerfile string) io.Reader {
-	dockerfile = strings.ReplaceAll(dockerfile, "${NANOBOT_IMAGE}", version.BaseImage)
-	var buf bytes.Buffer
-	t := tar.NewWriter(&buf)
-	if err := t.WriteHeader(&tar.Header{
-		Name: "Dockerfile",
-		Size: int64(len([]byte(dockerfile))),
-	}); err != nil {
-		panic(fmt.Errorf("failed to write tar header: %w", err))
-	}
-	if _, err := t.Write([]byte(dockerfile)); err != nil {
-		panic(fmt.Errorf("failed to write Dockerfile to tar: %w", err))
-	}
-	if err := t.Close(); err != nil {
-		panic(fmt.Errorf("failed to close tar writer: %w", err))
+// NewCmd creates a command that runs in the sandbox backend selected by the command.
+func NewCmd(ctx context.Context, command Command) (*Cmd, error) {
+	backend, err := GetBackend(command.Backend)
+	if err != nil {
+		return nil, err
 	}
-	return &buf
+	return backend.NewCmd(ctx, command)
 }
 
-func buildBaseImage(ctx context.Context, config Command) (string, error) {
-	slog.Info("building base image")
-	f, err := os.CreateTemp("", "nanobot-dockerfile-*.id")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file for dockerfile: %w", err)
+func WrapCmd(ctx context.Context, cmd *exec.Cmd, forceCancel func(), postStart func() error) *Cmd {
+	wrapped := &Cmd{
+		Cmd:  cmd,
+		done: make(chan struct{}),
 	}
-	if err := f.Close(); err != nil {
-		return "", fmt.Errorf("failed to close temp file: %w", err)
+	wrapped.cancel = func() {
+		wrapped.requestStop(forceCancel)
 	}
-
-	defer func() {
-		_ = os.Remove(f.Name())
+	go func() {
+		select {
+		case <-ctx.Done():
+			wrapped.requestStop(forceCancel)
+		case <-wrapped.done:
+			forceCancel()
+		}
 	}()
-
-	outBuf := &bytes.Buffer{}
-	cmd := exec.CommandContext(ctx, "docker", "build", "--iidfile", f.Name(), "-")
-	cmd.Stdin = dockerFileToTar(config.Dockerfile)
-	cmd.Stdout = outBuf
-	stdErr, err := cmd.StderrPipe()
-	if err != nil {
-		return "", fmt.Errorf("failed to get stderr pipe: %w", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start docker build: %w", err)
-	}
-
-	lines := bufio.NewScanner(stdErr)
-	for lines.Scan() {
-		_, _ = fmt.Fprintln(os.Stderr, lines.Text())
-	}
-
-	if err := cmd.Wait(); err != nil {
-		return "", fmt.Errorf("failed to build base image: %w, output: %s", err, outBuf.String())
-	}
-
-	idBytes, err := os.ReadFile(f.Name())
-	return strings.TrimSpace(string(idBytes)), err
+	wrapped.postStart = postStart
+	return wrapped
 }
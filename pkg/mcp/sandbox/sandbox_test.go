@@ -0,0 +1,140 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/supervise"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		backend, network string
+		valid            bool
+	}{
+		{"", "", true},
+		{BackendDocker, NetworkNone, true},
+		{BackendPodman, NetworkHost, true},
+		{BackendNamespace, "", true},
+		{"firecracker", "", false},
+		{BackendDocker, "bridge", false},
+	} {
+		if err := Validate(tt.backend, tt.network); (err == nil) != tt.valid {
+			t.Errorf("Validate(%q, %q) = %v, expected valid %v", tt.backend, tt.network, err, tt.valid)
+		}
+	}
+}
+
+func TestWorkdir(t *testing.T) {
+	roots := []Root{{Name: "docs", Path: "/docs"}, {Name: "cwd", Path: "/project"}}
+
+	if workdir := (Command{Roots: roots}).workdir(); workdir != "/project" {
+		t.Errorf("expected the cwd root to be the workdir, got %q", workdir)
+	}
+	if workdir := (Command{Roots: roots, Workdir: "/src"}).workdir(); workdir != "/src" {
+		t.Errorf("expected the configured workdir, got %q", workdir)
+	}
+	if workdir := (Command{Roots: roots, Source: Source{Repo: "https://github.com/example/mcp"}}).workdir(); workdir != "" {
+		t.Errorf("expected no workdir for a source checkout, got %q", workdir)
+	}
+}
+
+func TestNamespaceRejectsImages(t *testing.T) {
+	if err := checkNamespaceCommand(Command{Dockerfile: "Dockerfile"}); err == nil {
+		t.Error("expected an error for a dockerfile")
+	}
+	if err := checkNamespaceCommand(Command{ReversePorts: []int{8080}, Network: NetworkNone}); err == nil {
+		t.Error("expected an error for reverse ports without a network")
+	}
+	if err := checkNamespaceCommand(Command{PublishPorts: []string{"8080"}, Network: NetworkNone}); err == nil {
+		t.Error("expected an error for published ports without a network")
+	}
+	if err := checkNamespaceCommand(Command{Egress: &EgressProxy{}, Network: NetworkHost}); err == nil {
+		t.Error("expected an error for an egress allowlist with the host network")
+	}
+	if err := checkNamespaceCommand(Command{Command: "npx"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestContainerRejectsEgress(t *testing.T) {
+	if _, err := (containerBackend{bin: BackendDocker}).NewCmd(t.Context(), Command{Egress: &EgressProxy{}}); err == nil {
+		t.Error("expected an error for an egress allowlist")
+	}
+}
+
+func TestEgressRules(t *testing.T) {
+	proxy := EgressProxy{}
+	for _, rule := range []string{"api.github.com", "*.example.com", "pypi.org:443", "10.0.0.0/8", "192.168.1.5"} {
+		parsed, err := parseEgressRule(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxy.rules = append(proxy.rules, parsed)
+	}
+
+	for target, allowed := range map[string]bool{
+		"api.github.com:443":  true,
+		"API.GitHub.com.:443": true,
+		"github.com:443":      false,
+		"docs.example.com:80": true,
+		"example.com:443":     false,
+		"pypi.org:443":        true,
+		"pypi.org:80":         false,
+		"10.1.2.3:8080":       true,
+		"192.168.1.5:22":      true,
+		"192.168.1.6:22":      false,
+	} {
+		if proxy.allowed(target) != allowed {
+			t.Errorf("expected %s allowed to be %v", target, allowed)
+		}
+	}
+
+	if err := ValidateEgress([]string{"*"}); err == nil {
+		t.Error("expected an error for a bare wildcard")
+	}
+}
+
+func TestEgressProxyDenies(t *testing.T) {
+	var denied []string
+	proxy, err := NewEgressProxy([]string{"allowed.test"}, func(target string) {
+		denied = append(denied, target)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", egressPort))
+	client := http.Client{Transport: &http.Transport{
+		Proxy: http.ProxyURL(proxyURL),
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", proxy.Socket())
+		},
+	}}
+	resp, err := client.Get("http://denied.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", resp.StatusCode)
+	}
+	if len(denied) != 1 || denied[0] != "denied.test:80" {
+		t.Errorf("expected denied.test:80 to be reported, got %v", denied)
+	}
+}
+
+func TestContainerLimitArgs(t *testing.T) {
+	args := containerLimitArgs(supervise.Limits{Memory: 512 << 20, Pids: 100, OpenFiles: 1024})
+	expected := []string{"--memory", "536870912", "--memory-swap", "536870912", "--pids-limit", "100", "--ulimit", "nofile=1024:1024"}
+	if !slices.Equal(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+}
@@ -0,0 +1,101 @@
+//go:build linux && (amd64 || arm64)
+
+package sandbox
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// deniedSyscalls fail with EPERM in the sandbox. They change mounts, namespaces or the kernel, or
+// inspect other processes.
+var deniedSyscalls = []uint32{
+	unix.SYS_ADD_KEY,
+	unix.SYS_BPF,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_FSCONFIG,
+	unix.SYS_FSMOUNT,
+	unix.SYS_FSOPEN,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_MOUNT,
+	unix.SYS_MOUNT_SETATTR,
+	unix.SYS_MOVE_MOUNT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_OPEN_TREE,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_PTRACE,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETNS,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+}
+
+// x32SyscallBit is set in the syscall numbers of the x32 ABI, which shares the amd64 audit arch.
+const x32SyscallBit = 0x40000000
+
+func auditArch() uint32 {
+	if runtime.GOARCH == "arm64" {
+		return unix.AUDIT_ARCH_AARCH64
+	}
+	return unix.AUDIT_ARCH_X86_64
+}
+
+// restrictSyscalls sets no_new_privs and installs a seccomp filter denying deniedSyscalls on all
+// threads. Syscalls of other architectures are denied too so the filter can not be bypassed.
+func restrictSyscalls() error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+
+	deny := bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM))
+	filter := []unix.SockFilter{
+		// seccomp_data.arch
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 4),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, auditArch(), 1, 0),
+		deny,
+		// seccomp_data.nr
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 0),
+	}
+
+	// Each check jumps over the remaining checks and the allow to the deny.
+	checks := len(deniedSyscalls)
+	if runtime.GOARCH == "amd64" {
+		checks++
+		filter = append(filter, bpfJump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, uint8(checks), 0))
+		checks--
+	}
+	for _, nr := range deniedSyscalls {
+		filter = append(filter, bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, uint8(checks), 0))
+		checks--
+	}
+	filter = append(filter, bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW), deny)
+
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER, unix.SECCOMP_FILTER_FLAG_TSYNC, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return fmt.Errorf("failed to install seccomp filter: %w", errno)
+	}
+	return nil
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
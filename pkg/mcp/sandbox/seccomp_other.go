@@ -0,0 +1,17 @@
+//go:build linux && !(amd64 || arm64)
+
+package sandbox
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// restrictSyscalls only sets no_new_privs, the seccomp filter is only built for amd64 and arm64.
+func restrictSyscalls() error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// compiledSchemas caches the compiled schemas by their JSON.
+var compiledSchemas sync.Map
+
+// SchemaValidationError lists why a value does not match a schema, each problem is prefixed with the
+// location of the value it is about, like "/items/0/count: got string, want integer".
+type SchemaValidationError struct {
+	Problems []string
+}
+
+func (e *SchemaValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// ValidateSchema checks that value, decoded from JSON, matches the JSON schema. A value that does not
+// match returns a *SchemaValidationError, a schema that can not be compiled another error. References to
+// other documents are not loaded.
+func ValidateSchema(schema json.RawMessage, value any) error {
+	s, err := compileSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	// Values are validated as the validator decodes JSON, numbers as json.Number.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	err = s.Validate(instance)
+	if validationErr, ok := errors.AsType[*jsonschema.ValidationError](err); ok {
+		return &SchemaValidationError{Problems: schemaProblems(validationErr)}
+	}
+	return err
+}
+
+func compileSchema(schema json.RawMessage) (*jsonschema.Schema, error) {
+	key := string(schema)
+	if s, ok := compiledSchemas.Load(key); ok {
+		return s.(*jsonschema.Schema), nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode schema: %w", err)
+	}
+
+	c := jsonschema.NewCompiler()
+	c.UseLoader(jsonschema.SchemeURLLoader{})
+	if err := c.AddResource("schema.json", doc); err != nil {
+		return nil, fmt.Errorf("failed to add schema: %w", err)
+	}
+	s, err := c.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema: %w", err)
+	}
+
+	compiledSchemas.Store(key, s)
+	return s, nil
+}
+
+// schemaProblems flattens the causes of a validation error to the errors of the values that failed.
+func schemaProblems(err *jsonschema.ValidationError) []string {
+	var (
+		result []string
+		seen   = map[string]bool{}
+	)
+	for _, unit := range err.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		message := unit.Error.String()
+		if strings.HasPrefix(message, "validation failed") || strings.HasPrefix(message, "doesn't validate with") {
+			// The errors of the subschemas are listed too.
+			continue
+		}
+
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		problem := location + ": " + message
+		if !seen[problem] {
+			seen[problem] = true
+			result = append(result, problem)
+		}
+	}
+	if len(result) == 0 {
+		result = append(result, err.Error())
+	}
+	return result
+}
@@ -0,0 +1,266 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nanobot-ai/nanobot/pkg/envvar"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	// AuthClientCredentials gets tokens with the OAuth client credentials grant and a client secret.
+	AuthClientCredentials = "clientCredentials"
+	// AuthPrivateKeyJWT gets tokens with the OAuth client credentials grant, authenticating the
+	// client with a JWT signed by its private key (RFC 7523).
+	AuthPrivateKeyJWT = "privateKeyJWT"
+	// AuthTokenFile sends the bearer token in a file, like a projected service account token.
+	AuthTokenFile = "tokenFile"
+
+	// serverAuthExpiryDelta is how long before it expires a token is replaced.
+	serverAuthExpiryDelta = time.Minute
+)
+
+// ServerAuth configures how a remote server is authenticated without a user, for deployments that
+// cannot complete the interactive OAuth flow. Tokens are not stored, they are requested again
+// when they are about to expire.
+type ServerAuth struct {
+	// Type is clientCredentials, privateKeyJWT or tokenFile.
+	Type string `json:"type,omitempty"`
+	// TokenURL is the token endpoint of the authorization server. It is discovered from the
+	// metadata of the server if it is not set.
+	TokenURL string `json:"tokenURL,omitempty"`
+	// ClientID identifies the client for clientCredentials and privateKeyJWT.
+	ClientID string `json:"clientID,omitempty"`
+	// ClientSecret authenticates the client for clientCredentials.
+	ClientSecret string `json:"clientSecret,omitempty"`
+	// PrivateKeyFile is the PEM encoded RSA, ECDSA or Ed25519 key that signs the assertion of
+	// privateKeyJWT. It is read for every token, so it can be rotated.
+	PrivateKeyFile string `json:"privateKeyFile,omitempty"`
+	// KeyID is set as the kid header of the assertion.
+	KeyID string `json:"keyID,omitempty"`
+	// Scopes are requested with the token.
+	Scopes []string `json:"scopes,omitempty"`
+	// TokenFile holds the bearer token for tokenFile, it is read again when it changes.
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// Validate checks that the fields of the type are set.
+func (a ServerAuth) Validate() error {
+	switch a.Type {
+	case "":
+		if a.TokenURL != "" || a.ClientID != "" || a.ClientSecret != "" || a.PrivateKeyFile != "" || a.TokenFile != "" {
+			return fmt.Errorf("auth type is required, must be %s, %s or %s", AuthClientCredentials, AuthPrivateKeyJWT, AuthTokenFile)
+		}
+	case AuthClientCredentials:
+		if a.ClientID == "" || a.ClientSecret == "" {
+			return fmt.Errorf("auth type %s requires clientID and clientSecret", a.Type)
+		}
+	case AuthPrivateKeyJWT:
+		if a.ClientID == "" || a.PrivateKeyFile == "" {
+			return fmt.Errorf("auth type %s requires clientID and privateKeyFile", a.Type)
+		}
+	case AuthTokenFile:
+		if a.TokenFile == "" {
+			return fmt.Errorf("auth type %s requires tokenFile", a.Type)
+		}
+	default:
+		return fmt.Errorf("invalid auth type %q, must be %s, %s or %s", a.Type, AuthClientCredentials, AuthPrivateKeyJWT, AuthTokenFile)
+	}
+	return nil
+}
+
+func (a ServerAuth) replaceEnv(env map[string]string) ServerAuth {
+	a.TokenURL = envvar.ReplaceString(env, a.TokenURL)
+	a.ClientID = envvar.ReplaceString(env, a.ClientID)
+	a.ClientSecret = envvar.ReplaceString(env, a.ClientSecret)
+	a.PrivateKeyFile = envvar.ReplaceString(env, a.PrivateKeyFile)
+	a.KeyID = envvar.ReplaceString(env, a.KeyID)
+	a.TokenFile = envvar.ReplaceString(env, a.TokenFile)
+	return a
+}
+
+// serverAuth is the token source of a ServerAuth.
+type serverAuth struct {
+	ctx      context.Context
+	config   ServerAuth
+	oauth    *oauth
+	resource string
+
+	lock        sync.Mutex
+	tokenURL    string
+	tokenSource oauth2.TokenSource
+}
+
+func newServerAuth(ctx context.Context, config ServerAuth, oauth *oauth, resource string) *serverAuth {
+	return &serverAuth{
+		ctx:      ctx,
+		config:   config,
+		oauth:    oauth,
+		resource: resource,
+		tokenURL: config.TokenURL,
+	}
+}
+
+func (a *serverAuth) Token() (*oauth2.Token, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.tokenSource == nil {
+		switch a.config.Type {
+		case AuthTokenFile:
+			a.tokenSource = &fileTokenSource{path: a.config.TokenFile}
+		default:
+			a.tokenSource = oauth2.ReuseTokenSourceWithExpiry(nil, tokenSourceFunc(a.clientCredentials), serverAuthExpiryDelta)
+		}
+	}
+	return a.tokenSource.Token()
+}
+
+// reset drops the current token, the next request gets a new one.
+func (a *serverAuth) reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.tokenSource = nil
+}
+
+func (a *serverAuth) clientCredentials() (*oauth2.Token, error) {
+	if a.tokenURL == "" {
+		tokenURL, err := a.oauth.discoverTokenEndpoint(a.resource)
+		if err != nil {
+			return nil, err
+		}
+		a.tokenURL = tokenURL
+	}
+
+	conf := clientcredentials.Config{
+		ClientID:       a.config.ClientID,
+		ClientSecret:   a.config.ClientSecret,
+		TokenURL:       a.tokenURL,
+		Scopes:         a.config.Scopes,
+		EndpointParams: url.Values{"resource": {a.resource}},
+	}
+	if a.config.Type == AuthPrivateKeyJWT {
+		assertion, err := a.assertion()
+		if err != nil {
+			return nil, err
+		}
+		conf.AuthStyle = oauth2.AuthStyleInParams
+		conf.EndpointParams.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		conf.EndpointParams.Set("client_assertion", assertion)
+	}
+
+	tok, err := conf.Token(a.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token from %s: %w", a.tokenURL, err)
+	}
+	return tok, nil
+}
+
+// assertion signs a short-lived JWT that authenticates the client to the token endpoint.
+func (a *serverAuth) assertion() (string, error) {
+	data, err := os.ReadFile(a.config.PrivateKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	key, method, err := parsePrivateKey(data)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Issuer:    a.config.ClientID,
+		Subject:   a.config.ClientID,
+		Audience:  jwt.ClaimStrings{a.tokenURL},
+		ID:        uuid.String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+	})
+	if a.config.KeyID != "" {
+		token.Header["kid"] = a.config.KeyID
+	}
+
+	assertion, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client assertion: %w", err)
+	}
+	return assertion, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.PrivateKey, jwt.SigningMethod, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return key, jwt.SigningMethodRS256, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM(data); err == nil {
+		return key, ecdsaSigningMethod(key), nil
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		return key, jwt.SigningMethodEdDSA, nil
+	}
+	return nil, nil, fmt.Errorf("failed to parse private key: must be a PEM encoded RSA, ECDSA or Ed25519 key")
+}
+
+func ecdsaSigningMethod(key *ecdsa.PrivateKey) jwt.SigningMethod {
+	switch key.Curve.Params().BitSize {
+	case 384:
+		return jwt.SigningMethodES384
+	case 521:
+		return jwt.SigningMethodES512
+	default:
+		return jwt.SigningMethodES256
+	}
+}
+
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) {
+	return f()
+}
+
+// fileTokenSource reads a bearer token from a file, and reads it again when the file changes. The
+// token is not refreshed, whatever writes the file replaces it before it expires.
+type fileTokenSource struct {
+	path    string
+	modTime time.Time
+	size    int64
+	token   *oauth2.Token
+}
+
+func (f *fileTokenSource) Token() (*oauth2.Token, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	if f.token != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.token, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	accessToken := string(bytes.TrimSpace(data))
+	if accessToken == "" {
+		return nil, fmt.Errorf("token file %s is empty", f.path)
+	}
+
+	token := &oauth2.Token{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+	}
+
+	f.token, f.modTime, f.size = token, info.ModTime(), info.Size()
+	return token, nil
+}
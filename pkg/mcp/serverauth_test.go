@@ -0,0 +1,166 @@
+package mcp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestServerAuth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := MessageHandlerFunc(func(ctx context.Context, msg Message) {
+		switch msg.Method {
+		case "initialize":
+			_ = msg.Reply(ctx, InitializeResult{
+				ProtocolVersion: "2025-06-18",
+				ServerInfo:      ServerInfo{Name: "test"},
+			})
+		case "ping":
+			_ = msg.Reply(ctx, PingResult{})
+		}
+	})
+	server, err := NewHTTPServer(ctx, nil, handler, HTTPServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		lock   sync.Mutex
+		tokens []string
+		issued int
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(rw http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil || req.PostForm.Get("grant_type") != "client_credentials" {
+			http.Error(rw, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		if assertion := req.PostForm.Get("client_assertion"); assertion != "" {
+			claims := jwt.RegisteredClaims{}
+			if _, err := jwt.ParseWithClaims(assertion, &claims, func(*jwt.Token) (any, error) { return &key.PublicKey, nil }); err != nil || claims.Subject != "jwt-client" {
+				http.Error(rw, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+		} else if id, secret, ok := req.BasicAuth(); !ok || id != "client" || secret != "secret" {
+			http.Error(rw, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+
+		lock.Lock()
+		issued++
+		lock.Unlock()
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(map[string]any{
+			"access_token": "issued",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("/mcp", func(rw http.ResponseWriter, req *http.Request) {
+		token, ok := req.Header["Authorization"]
+		if !ok {
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		lock.Lock()
+		tokens = append(tokens, token[0])
+		lock.Unlock()
+		server.ServeHTTP(rw, req)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	lastToken := func() string {
+		lock.Lock()
+		defer lock.Unlock()
+		return tokens[len(tokens)-1]
+	}
+
+	for _, auth := range []ServerAuth{
+		{Type: AuthClientCredentials, TokenURL: ts.URL + "/token", ClientID: "client", ClientSecret: "secret"},
+		{Type: AuthPrivateKeyJWT, TokenURL: ts.URL + "/token", ClientID: "jwt-client", PrivateKeyFile: keyFile},
+		{Type: AuthTokenFile, TokenFile: tokenFile},
+	} {
+		t.Run(auth.Type, func(t *testing.T) {
+			if err := auth.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			client, err := NewClient(ctx, "test", Server{
+				BaseURL: ts.URL + "/mcp",
+				Auth:    auth,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close(true)
+
+			if _, err := client.Ping(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if auth.Type != AuthTokenFile {
+				if token := lastToken(); token != "Bearer issued" {
+					t.Fatalf("expected the issued token, got %q", token)
+				}
+				return
+			}
+
+			if token := lastToken(); token != "Bearer file-1" {
+				t.Fatalf("expected the token from the file, got %q", token)
+			}
+			// A rotated token is read again.
+			if err := os.WriteFile(tokenFile, []byte("file-2-rotated\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Ping(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if token := lastToken(); token != "Bearer file-2-rotated" {
+				t.Fatalf("expected the rotated token, got %q", token)
+			}
+		})
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if issued != 2 {
+		t.Fatalf("expected one token per client from the token endpoint, got %d", issued)
+	}
+
+	if err := (ServerAuth{Type: AuthClientCredentials, ClientID: "client"}).Validate(); err == nil {
+		t.Fatal("expected clientCredentials without a secret to be invalid")
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"reflect"
 	"slices"
+	"time"
 
 	"github.com/google/jsonschema-go/jsonschema"
 )
@@ -118,14 +120,108 @@ func NewServerTool[In, Out any](name, description string, handler func(ctx conte
 
 	return &serverTool[In, Out]{
 		tool: Tool{
-			Name:        name,
-			Description: description,
-			InputSchema: inputData,
+			Name:         name,
+			Description:  description,
+			InputSchema:  inputData,
+			OutputSchema: outputSchema(reflect.TypeFor[Out]()),
 		},
 		f: handler,
 	}
 }
 
+// marshalerSchemas allows any value for the types used by t that marshal themselves.
+func marshalerSchemas(t reflect.Type, schemas map[reflect.Type]*jsonschema.Schema, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+
+	if _, ok := schemas[t]; ok || t == reflect.TypeFor[time.Time]() {
+		return
+	}
+	if t.Implements(reflect.TypeFor[json.Marshaler]()) || reflect.PointerTo(t).Implements(reflect.TypeFor[json.Marshaler]()) {
+		schemas[t] = anyValue
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		marshalerSchemas(t.Elem(), schemas, seen)
+	case reflect.Struct:
+		for _, field := range reflect.VisibleFields(t) {
+			if field.IsExported() || field.Anonymous {
+				marshalerSchemas(field.Type, schemas, seen)
+			}
+		}
+	}
+}
+
+// nullableMaps allows null for the maps in the schema, a nil map marshals to null like a nil slice.
+func nullableMaps(s *jsonschema.Schema) {
+	if s == nil {
+		return
+	}
+	if s.Type == "object" && (s.AdditionalProperties == nil || s.AdditionalProperties.Not == nil) && len(s.Properties) == 0 {
+		s.Types = []string{"null", "object"}
+		s.Type = ""
+	}
+	for _, property := range s.Properties {
+		nullableMaps(property)
+	}
+	nullableMaps(s.Items)
+	nullableMaps(s.AdditionalProperties)
+}
+
+// anyValue allows any JSON value. The types are listed, because the schema of a pointer to a type
+// without types would only allow null.
+var anyValue = &jsonschema.Schema{Types: []string{"null", "boolean", "object", "array", "number", "string"}}
+
+// outputSchemaTypes are the schemas of types that don't marshal to what their Go type describes.
+var outputSchemaTypes = map[reflect.Type]*jsonschema.Schema{
+	reflect.TypeFor[json.RawMessage](): anyValue,
+	reflect.TypeFor[[]byte]():          {Type: "string"},
+}
+
+// outputSchema returns the schema of the structured content of the results of a tool returning t.
+// Only structs and maps are returned as structured content, results like text, content or resources
+// have no output schema. Types that marshal themselves can't be described and have none either.
+func outputSchema(t reflect.Type) json.RawMessage {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeFor[Content](), t == reflect.TypeFor[CallToolResult](), t == reflect.TypeFor[Resource]():
+		return nil
+	case t.Kind() == reflect.Map && t.Key().Kind() != reflect.String:
+		return nil
+	case t.Kind() != reflect.Struct && t.Kind() != reflect.Map:
+		return nil
+	case t.Implements(reflect.TypeFor[json.Marshaler]()), reflect.PointerTo(t).Implements(reflect.TypeFor[json.Marshaler]()):
+		return nil
+	}
+
+	typeSchemas := maps.Clone(outputSchemaTypes)
+	marshalerSchemas(t, typeSchemas, map[reflect.Type]bool{})
+
+	s, err := jsonschema.ForType(t, &jsonschema.ForOptions{
+		TypeSchemas: typeSchemas,
+	})
+	if err != nil {
+		return nil
+	}
+	for _, property := range s.Properties {
+		nullableMaps(property)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
 func callResult(object any, err error) (*CallToolResult, error) {
 	if err != nil {
 		return nil, err
@@ -139,7 +139,7 @@ func (s *Session) addWorker(ctx context.Context, id any) context.Context {
 		parentSession.workers[id] = cancel
 		parentSession.workerLock.Unlock()
 
-		ctx = withUserCtx(ctx, userCtx)
+		ctx = WithUserContext(ctx, userCtx)
 	}
 
 	return ctx
@@ -468,6 +468,14 @@ func (s *Session) Close(deleteSession bool) {
 	s.cancel(fmt.Errorf("session closed: %s, delete=%v", s.ID(), deleteSession))
 }
 
+// wireErr returns the reason the wire of the session closed, if it reports one.
+func (s *Session) wireErr() error {
+	if wire, ok := s.wire.(interface{ Err() error }); ok {
+		return wire.Err()
+	}
+	return nil
+}
+
 func (s *Session) Wait() {
 	if s.wire == nil {
 		<-s.ctx.Done()
@@ -812,7 +820,9 @@ func (s *Session) Exchange(ctx context.Context, method string, in, out any, opts
 			err = addHookMutationsMeta(&resp)
 		}
 		if unmarshalErr := s.marshalResponse(resp, out); unmarshalErr != nil && err == nil {
-			err = ErrRPCUnknown.WithMessage("failed to unmarshal response: %v", unmarshalErr)
+			rpcErr := ErrRPCUnknown.WithMessage("failed to unmarshal response: %v", unmarshalErr)
+			rpcErr.err = unmarshalErr
+			err = rpcErr
 		}
 	}()
 
@@ -857,7 +867,14 @@ func (s *Session) Exchange(ctx context.Context, method string, in, out any, opts
 			// If the error is nil, then the send call was successful.
 			// Set the error channel to nil so that this case always blocks.
 			errChan = nil
-		case resp = <-ch:
+		case msg, ok := <-ch:
+			if !ok {
+				// The wire closed before the response arrived, report why if it knows.
+				if err := s.wireErr(); err != nil {
+					return err
+				}
+			}
+			resp = msg
 			if isInit {
 				if err := s.postInit(&resp); err != nil {
 					return fmt.Errorf("failed to post init: %w", err)
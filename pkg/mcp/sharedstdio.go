@@ -0,0 +1,435 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// sharedStdio is one stdio server process used by the sessions with the same server config,
+// environment and roots. Request IDs are unique within nanobot, so responses are routed to the
+// session that sent the request. Progress notifications follow their progress token, requests from
+// the server go to the session they were made for, see requestClient, and other notifications go
+// to every session.
+type sharedStdio struct {
+	key         string
+	runner      *Runner
+	wire        Wire
+	cancel      context.CancelFunc
+	idleTimeout time.Duration
+	initLock    sync.Mutex
+
+	lock       sync.Mutex
+	clients    map[*sharedStdioClient]struct{}
+	requests   map[string]sharedRequest
+	progress   map[string]*sharedStdioClient
+	initID     string
+	initDone   chan Message
+	initResult *Message
+	closeTimer *time.Timer
+	closed     bool
+	// serverRequests are the sessions the requests from the server in progress were sent to.
+	serverRequests map[string]*sharedStdioClient
+}
+
+type sharedRequest struct {
+	client        *sharedStdioClient
+	progressToken string
+}
+
+// sharedStdioKey identifies the servers that can be shared. Sessions with different roots don't
+// share a process, because the roots are what a sandboxed server can access.
+func sharedStdioKey(serverName string, config Server, env map[string]string, roots []Root) (string, error) {
+	digest := sha256.New()
+	if err := json.NewEncoder(digest).Encode([]any{serverName, config, env, roots}); err != nil {
+		return "", fmt.Errorf("failed to hash server config: %w", err)
+	}
+	return fmt.Sprintf("%x", digest.Sum(nil)), nil
+}
+
+// sharedStdio returns a wire to the shared process of a server, starting it with newWire if it
+// is not running.
+func (r *Runner) sharedStdio(ctx context.Context, key string, idleTimeout time.Duration, newWire func(ctx context.Context) (Wire, error)) (Wire, error) {
+	r.sharedLock.Lock()
+	defer r.sharedLock.Unlock()
+
+	shared, ok := r.shared[key]
+	if !ok {
+		// The process outlives the session that started it.
+		wireCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		wire, err := newWire(wireCtx)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		shared = &sharedStdio{
+			key:            key,
+			runner:         r,
+			wire:           wire,
+			cancel:         cancel,
+			idleTimeout:    idleTimeout,
+			clients:        make(map[*sharedStdioClient]struct{}),
+			requests:       make(map[string]sharedRequest),
+			progress:       make(map[string]*sharedStdioClient),
+			serverRequests: make(map[string]*sharedStdioClient),
+		}
+		if err := wire.Start(wireCtx, shared.onMessage); err != nil {
+			cancel()
+			return nil, err
+		}
+		go func() {
+			wire.Wait()
+			shared.close()
+		}()
+
+		if r.shared == nil {
+			r.shared = make(map[string]*sharedStdio)
+		}
+		r.shared[key] = shared
+	}
+
+	return shared.attach(), nil
+}
+
+func (p *sharedStdio) attach() *sharedStdioClient {
+	c := &sharedStdioClient{
+		shared: p,
+		waiter: newWaiter(),
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closeTimer != nil {
+		p.closeTimer.Stop()
+		p.closeTimer = nil
+	}
+	p.clients[c] = struct{}{}
+	return c
+}
+
+// detach removes a session. The process is stopped once no session uses it, after the idle
+// timeout if there is one.
+func (p *sharedStdio) detach(c *sharedStdioClient) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.clients, c)
+	for id, req := range p.requests {
+		if req.client == c {
+			delete(p.requests, id)
+		}
+	}
+	for token, client := range p.progress {
+		if client == c {
+			delete(p.progress, token)
+		}
+	}
+	for id, client := range p.serverRequests {
+		if client == c {
+			delete(p.serverRequests, id)
+		}
+	}
+
+	if len(p.clients) > 0 || p.closed {
+		return
+	}
+	if p.idleTimeout > 0 {
+		if p.closeTimer == nil {
+			p.closeTimer = time.AfterFunc(p.idleTimeout, p.closeUnused)
+		}
+		return
+	}
+	go p.closeUnused()
+}
+
+// closeUnused stops the process if no session attached to it in the meantime.
+func (p *sharedStdio) closeUnused() {
+	p.runner.sharedLock.Lock()
+	p.lock.Lock()
+	p.closeTimer = nil
+	if len(p.clients) > 0 || p.closed {
+		p.lock.Unlock()
+		p.runner.sharedLock.Unlock()
+		return
+	}
+	p.closed = true
+	if p.runner.shared[p.key] == p {
+		delete(p.runner.shared, p.key)
+	}
+	p.lock.Unlock()
+	p.runner.sharedLock.Unlock()
+
+	p.wire.Close(false)
+	p.cancel()
+}
+
+// close stops the process and closes the sessions that still use it.
+func (p *sharedStdio) close() {
+	p.runner.sharedLock.Lock()
+	if p.runner.shared[p.key] == p {
+		delete(p.runner.shared, p.key)
+	}
+	p.runner.sharedLock.Unlock()
+
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return
+	}
+	p.closed = true
+	clients := make([]*sharedStdioClient, 0, len(p.clients))
+	for c := range p.clients {
+		clients = append(clients, c)
+	}
+	p.lock.Unlock()
+
+	p.wire.Close(false)
+	p.cancel()
+	for _, c := range clients {
+		c.waiter.Close()
+	}
+}
+
+func (p *sharedStdio) send(ctx context.Context, c *sharedStdioClient, msg Message) error {
+	if msg.ID != nil && msg.Method != "" {
+		id := MessageIDString(msg.ID)
+		req := sharedRequest{client: c}
+		if token := msg.ProgressToken(); token != nil {
+			req.progressToken = MessageIDString(token)
+		}
+
+		p.lock.Lock()
+		p.requests[id] = req
+		if req.progressToken != "" {
+			p.progress[req.progressToken] = c
+		}
+		p.lock.Unlock()
+
+		if err := p.wire.Send(ctx, msg); err != nil {
+			p.done(id)
+			return err
+		}
+		return nil
+	}
+	if msg.ID != nil {
+		// The response to a request from the server.
+		p.lock.Lock()
+		delete(p.serverRequests, MessageIDString(msg.ID))
+		p.lock.Unlock()
+	}
+	return p.wire.Send(ctx, msg)
+}
+
+// requestClient returns the session a request from the server was made for: the session of the
+// request whose progress token the server request has, or the only session that uses the process.
+// It returns nil if the request can't be attributed to a session, so that a session never gets
+// the sampling, elicitation or roots requests made for another one.
+func (p *sharedStdio) requestClient(msg Message) *sharedStdioClient {
+	if token := msg.ProgressToken(); token != nil {
+		return p.progress[MessageIDString(token)]
+	}
+	if len(p.clients) == 1 {
+		for c := range p.clients {
+			return c
+		}
+	}
+	return nil
+}
+
+func (p *sharedStdio) done(id string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if req, ok := p.requests[id]; ok {
+		delete(p.requests, id)
+		if req.progressToken != "" {
+			delete(p.progress, req.progressToken)
+		}
+	}
+}
+
+// initialize sends the first initialize request to the server, the following sessions get its
+// response without the server being initialized again.
+func (p *sharedStdio) initialize(ctx context.Context, c *sharedStdioClient, msg Message) error {
+	p.initLock.Lock()
+	defer p.initLock.Unlock()
+
+	p.lock.Lock()
+	result := p.initResult
+	p.lock.Unlock()
+
+	if result != nil {
+		resp := *result
+		resp.ID = msg.ID
+		ctx, handler := c.started()
+		go handler(ctx, resp)
+		return nil
+	}
+
+	done := make(chan Message, 1)
+	p.lock.Lock()
+	p.initID, p.initDone = MessageIDString(msg.ID), done
+	p.lock.Unlock()
+
+	if err := p.send(ctx, c, msg); err != nil {
+		return err
+	}
+
+	var resp Message
+	select {
+	case resp = <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.waiter.running:
+		return fmt.Errorf("MCP server was closed during initialize")
+	}
+
+	// The server is told it is initialized before the session sees the response and sends its
+	// next request.
+	if resp.Error == nil {
+		if err := p.wire.Send(ctx, Message{
+			JSONRPC: "2.0",
+			Method:  "notifications/initialized",
+		}); err != nil {
+			return err
+		}
+		p.lock.Lock()
+		p.initResult = &resp
+		p.lock.Unlock()
+	}
+
+	ctx, handler := c.started()
+	handler(ctx, resp)
+	return nil
+}
+
+func (p *sharedStdio) onMessage(ctx context.Context, msg Message) {
+	var (
+		clients []*sharedStdioClient
+		reject  bool
+	)
+
+	p.lock.Lock()
+	switch {
+	case msg.ID != nil && msg.Method == "":
+		id := MessageIDString(msg.ID)
+		if req, ok := p.requests[id]; ok {
+			delete(p.requests, id)
+			if req.progressToken != "" {
+				delete(p.progress, req.progressToken)
+			}
+			if id == p.initID {
+				// Delivered by initialize.
+				p.initDone <- msg
+				p.initID, p.initDone = "", nil
+			} else {
+				clients = append(clients, req.client)
+			}
+		}
+	case msg.Method == "notifications/progress":
+		var progress NotificationProgressRequest
+		if err := json.Unmarshal(msg.Params, &progress); err == nil {
+			if c, ok := p.progress[MessageIDString(progress.ProgressToken)]; ok {
+				clients = append(clients, c)
+			}
+		}
+	case msg.ID != nil:
+		if c := p.requestClient(msg); c != nil {
+			p.serverRequests[MessageIDString(msg.ID)] = c
+			clients = append(clients, c)
+		} else {
+			reject = true
+		}
+	case msg.Method == "notifications/cancelled":
+		// The server cancels its own requests, which went to one session.
+		var cancelled CancelledNotification
+		if err := json.Unmarshal(msg.Params, &cancelled); err == nil {
+			id := MessageIDString(cancelled.RequestID)
+			if c, ok := p.serverRequests[id]; ok {
+				delete(p.serverRequests, id)
+				clients = append(clients, c)
+			}
+		}
+	default:
+		for c := range p.clients {
+			clients = append(clients, c)
+		}
+	}
+	p.lock.Unlock()
+
+	if reject {
+		_ = p.wire.Send(ctx, Message{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: ErrRPCInvalidRequest.WithMessage("%s can't be attributed to a session of the shared server, "+
+				"include the progress token of the request it is made for", msg.Method),
+		})
+		return
+	}
+
+	for _, c := range clients {
+		if ctx, handler := c.started(); handler != nil {
+			handler(ctx, msg)
+		}
+	}
+}
+
+// sharedStdioClient is the wire of one session to a shared server process.
+type sharedStdioClient struct {
+	shared  *sharedStdio
+	waiter  *waiter
+	ctx     context.Context
+	handler WireHandler
+}
+
+func (c *sharedStdioClient) Start(ctx context.Context, handler WireHandler) error {
+	c.shared.lock.Lock()
+	c.ctx, c.handler = ctx, handler
+	c.shared.lock.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		c.Close(false)
+	})
+	return nil
+}
+
+func (c *sharedStdioClient) started() (context.Context, WireHandler) {
+	c.shared.lock.Lock()
+	defer c.shared.lock.Unlock()
+	return c.ctx, c.handler
+}
+
+func (c *sharedStdioClient) Send(ctx context.Context, req Message) error {
+	switch req.Method {
+	case "initialize":
+		return c.shared.initialize(ctx, c, req)
+	case "notifications/initialized":
+		// Sent once by initialize for all sessions.
+		return nil
+	}
+	return c.shared.send(ctx, c, req)
+}
+
+func (c *sharedStdioClient) SessionID() string {
+	return ""
+}
+
+// Err returns why the shared process stopped, if it reports one.
+func (c *sharedStdioClient) Err() error {
+	if wire, ok := c.shared.wire.(interface{ Err() error }); ok {
+		return wire.Err()
+	}
+	return nil
+}
+
+func (c *sharedStdioClient) Wait() {
+	c.waiter.Wait()
+}
+
+func (c *sharedStdioClient) Close(bool) {
+	c.shared.detach(c)
+	c.waiter.Close()
+}
@@ -4,13 +4,16 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	log2 "log"
 	"log/slog"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/log"
 )
@@ -49,6 +52,7 @@ type Stdio struct {
 	pendingRequest PendingRequests
 	waiter         *waiter
 	writeLock      sync.Mutex
+	exitErr        func() error
 }
 
 func (s *Stdio) Send(ctx context.Context, req Message) error {
@@ -61,6 +65,9 @@ func (s *Stdio) Send(ctx context.Context, req Message) error {
 	}
 
 	if s.cmd != nil && (s.cmd.Process == nil || s.cmd.ProcessState != nil) {
+		if err := s.Err(); err != nil {
+			return err
+		}
 		return fmt.Errorf("stdin is closed")
 	}
 
@@ -82,6 +89,14 @@ func (s *Stdio) SessionID() string {
 	return ""
 }
 
+// Err returns why the server process stopped if it was stopped for exceeding one of its limits.
+func (s *Stdio) Err() error {
+	if s.exitErr == nil {
+		return nil
+	}
+	return s.exitErr()
+}
+
 func (s *Stdio) Wait() {
 	s.waiter.Wait()
 }
@@ -126,16 +141,70 @@ func (s *Stdio) start(ctx context.Context, handler WireHandler) error {
 		}
 		go handler(ctx, msg)
 	}
-	return buf.Err()
+	if err := buf.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	// The pipe is closed once the process is reaped, which can happen before EOF is read.
+	return nil
+}
+
+func newStdioClient(ctx context.Context, roots func(context.Context) ([]Root, error), env map[string]string, serverName string, config Server, r *Runner) (Wire, error) {
+	policy, err := config.Restart.parse()
+	if err != nil {
+		return nil, err
+	}
+	idleTimeout, err := config.Lifecycle.idleTimeout()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Lifecycle.Shared() {
+		var resolved []Root
+		if roots != nil {
+			if resolved, err = roots(ctx); err != nil {
+				return nil, fmt.Errorf("failed to get roots of server %s: %w", serverName, err)
+			}
+			roots = func(context.Context) ([]Root, error) {
+				return resolved, nil
+			}
+		}
+		key, err := sharedStdioKey(serverName, config, env, resolved)
+		if err != nil {
+			return nil, err
+		}
+		return r.sharedStdio(ctx, key, idleTimeout, func(ctx context.Context) (Wire, error) {
+			return newSupervisedStdioClient(ctx, roots, env, serverName, config, r, policy, idleTimeout)
+		})
+	}
+
+	if policy.disabled && idleTimeout == 0 {
+		s, err := r.newStdio(ctx, roots, env, serverName, config)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return newSupervisedStdioClient(ctx, roots, env, serverName, config, r, policy, idleTimeout)
+}
+
+func newSupervisedStdioClient(ctx context.Context, roots func(context.Context) ([]Root, error), env map[string]string, serverName string, config Server, r *Runner, policy restartPolicy, idleTimeout time.Duration) (Wire, error) {
+	s, err := newSupervisedStdio(serverName, policy, idleTimeout, r.serverHealth(serverName), func() (*Stdio, error) {
+		return r.newStdio(ctx, roots, env, serverName, config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-func newStdioClient(ctx context.Context, roots func(context.Context) ([]Root, error), env map[string]string, serverName string, config Server, r *Runner) (*Stdio, error) {
+func (r *Runner) newStdio(ctx context.Context, roots func(context.Context) ([]Root, error), env map[string]string, serverName string, config Server) (*Stdio, error) {
 	result, err := r.Stream(ctx, roots, env, serverName, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create stream: %w", err)
 	}
 
 	s := NewStdio(serverName, result.cmd, result.Stdout, result.Stdin, result.Close)
+	s.exitErr = result.exitErr
 	return s, nil
 }
 
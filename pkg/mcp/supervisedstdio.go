@@ -0,0 +1,475 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// reinitializeTimeout is how long a restarted server has to answer the initialize request.
+const reinitializeTimeout = 30 * time.Second
+
+// supervisedStdio is a stdio wire that restarts the server process when it exits unexpectedly.
+// The restarted server is initialized with the original initialize request and the idempotent
+// requests that did not get a response yet are sent again, the others fail. With an
+// idle timeout the process is stopped when it is not used and started again on the next request.
+type supervisedStdio struct {
+	server      string
+	policy      restartPolicy
+	idleTimeout time.Duration
+	health      *serverHealth
+	newStdio    func() (*Stdio, error)
+	waiter      *waiter
+	wakeLock    sync.Mutex
+
+	lock       sync.Mutex
+	ctx        context.Context
+	handler    WireHandler
+	current    *Stdio
+	initialize *Message
+	inflight   map[string]Message
+	reinitID   string
+	reinit     chan Message
+	restarts   []time.Time
+	reinitSeq  int
+	idle       bool
+	lastUsed   time.Time
+	idleTimer  *time.Timer
+	closed     bool
+	err        error
+}
+
+func newSupervisedStdio(serverName string, policy restartPolicy, idleTimeout time.Duration, health *serverHealth, newStdio func() (*Stdio, error)) (*supervisedStdio, error) {
+	current, err := newStdio()
+	if err != nil {
+		return nil, err
+	}
+	return &supervisedStdio{
+		server:      serverName,
+		policy:      policy,
+		idleTimeout: idleTimeout,
+		health:      health,
+		newStdio:    newStdio,
+		waiter:      newWaiter(),
+		current:     current,
+		inflight:    make(map[string]Message),
+	}, nil
+}
+
+func (s *supervisedStdio) Start(ctx context.Context, handler WireHandler) error {
+	s.lock.Lock()
+	s.ctx = ctx
+	s.handler = handler
+	current := s.current
+	s.lock.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		s.Close(false)
+	})
+	if err := current.Start(ctx, s.onMessage); err != nil {
+		return err
+	}
+	go s.supervise(current)
+	return nil
+}
+
+func (s *supervisedStdio) SessionID() string {
+	return ""
+}
+
+// Err returns why the server is no longer restarted.
+func (s *supervisedStdio) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+func (s *supervisedStdio) Wait() {
+	s.waiter.Wait()
+}
+
+func (s *supervisedStdio) Close(deleteSession bool) {
+	s.lock.Lock()
+	wasIdle := s.idle && !s.closed
+	s.closed = true
+	current := s.current
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+	}
+	s.lock.Unlock()
+
+	if wasIdle {
+		s.health.change(func(h *serverHealth) {
+			h.idle--
+		})
+	}
+	if current != nil {
+		current.Close(deleteSession)
+	}
+	s.waiter.Close()
+}
+
+func (s *supervisedStdio) Send(ctx context.Context, req Message) error {
+	if err := s.wake(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		if err := s.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("stdin is closed")
+	}
+
+	tracked := req.ID != nil && req.Method != ""
+	switch {
+	case req.Method == "initialize":
+		initialize := req
+		s.initialize = &initialize
+	case req.Method == "notifications/cancelled":
+		var cancelled CancelledNotification
+		if err := json.Unmarshal(req.Params, &cancelled); err == nil {
+			delete(s.inflight, MessageIDString(cancelled.RequestID))
+		}
+	}
+	if tracked {
+		s.inflight[MessageIDString(req.ID)] = req
+	}
+	s.used()
+	current := s.current
+	s.lock.Unlock()
+
+	if current == nil {
+		if tracked {
+			// Sent once the server is restarted.
+			return nil
+		}
+		return fmt.Errorf("MCP server %s is restarting", s.server)
+	}
+
+	if err := current.Send(ctx, req); err != nil {
+		if tracked && current.Err() == nil && !s.isClosed() {
+			// The server exited, the request is sent again once it is restarted.
+			return nil
+		}
+		s.done(req.ID)
+		return err
+	}
+	return nil
+}
+
+func (s *supervisedStdio) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+func (s *supervisedStdio) done(id any) {
+	if id == nil {
+		return
+	}
+	s.lock.Lock()
+	delete(s.inflight, MessageIDString(id))
+	s.lock.Unlock()
+}
+
+func (s *supervisedStdio) onMessage(ctx context.Context, msg Message) {
+	if msg.ID != nil && msg.Method == "" {
+		id := MessageIDString(msg.ID)
+		s.lock.Lock()
+		if s.reinit != nil && id == s.reinitID {
+			reinit := s.reinit
+			s.lock.Unlock()
+			select {
+			case reinit <- msg:
+			default:
+			}
+			return
+		}
+		delete(s.inflight, id)
+		s.used()
+		s.lock.Unlock()
+	}
+	s.handler(ctx, msg)
+}
+
+// used records that the server is in use and schedules stopping it when it becomes idle. The
+// lock must be held.
+func (s *supervisedStdio) used() {
+	if s.idleTimeout <= 0 {
+		return
+	}
+	s.lastUsed = time.Now()
+	if s.idleTimer == nil {
+		s.idleTimer = time.AfterFunc(s.idleTimeout, s.checkIdle)
+	}
+}
+
+// checkIdle stops the server if it was not used within the idle timeout and has no requests
+// waiting for a response.
+func (s *supervisedStdio) checkIdle() {
+	s.lock.Lock()
+	if s.closed || s.idle || s.current == nil {
+		s.idleTimer = nil
+		s.lock.Unlock()
+		return
+	}
+	if unused := time.Since(s.lastUsed); len(s.inflight) > 0 || unused < s.idleTimeout {
+		s.idleTimer.Reset(max(s.idleTimeout-unused, time.Second))
+		s.lock.Unlock()
+		return
+	}
+	s.idleTimer = nil
+	s.idle = true
+	current := s.current
+	s.current = nil
+	s.lock.Unlock()
+
+	slog.Info("stopping idle MCP server", "server", s.server, "idle_timeout", s.idleTimeout)
+	s.health.change(func(h *serverHealth) {
+		h.idle++
+	})
+	current.Close(false)
+}
+
+// wake starts the server again if it was stopped for being idle.
+func (s *supervisedStdio) wake() error {
+	s.wakeLock.Lock()
+	defer s.wakeLock.Unlock()
+
+	s.lock.Lock()
+	idle := s.idle && !s.closed
+	s.lock.Unlock()
+	if !idle {
+		return nil
+	}
+
+	next, err := s.newStdio()
+	if err != nil {
+		return fmt.Errorf("failed to start idle MCP server %s: %w", s.server, err)
+	}
+	if err := s.resume(next); err != nil {
+		next.Close(false)
+		return fmt.Errorf("failed to start idle MCP server %s: %w", s.server, err)
+	}
+
+	s.lock.Lock()
+	s.idle = false
+	s.lock.Unlock()
+	s.health.change(func(h *serverHealth) {
+		h.idle--
+	})
+	go s.supervise(next)
+	return nil
+}
+
+// supervise waits for the server process to exit and restarts it until the wire is closed or the
+// server crash loops.
+func (s *supervisedStdio) supervise(current *Stdio) {
+	for {
+		current.Wait()
+
+		s.lock.Lock()
+		if s.closed || s.ctx.Err() != nil || s.current != current {
+			// Closed, or stopped for being idle.
+			s.lock.Unlock()
+			return
+		}
+		s.current = nil
+		s.lock.Unlock()
+
+		// A server stopped for exceeding its limits is restarted, but the requests that caused it
+		// are not sent again.
+		if err := current.Err(); err != nil {
+			s.failInflight(err)
+		} else {
+			s.failUnresendable(fmt.Errorf("MCP server %s restarted before it responded", s.server))
+		}
+
+		s.health.change(func(h *serverHealth) {
+			h.restarting++
+		})
+		next, err := s.restart()
+		s.health.change(func(h *serverHealth) {
+			h.restarting--
+			if err != nil {
+				h.failed = true
+				h.lastError = err.Error()
+			} else {
+				h.restarts++
+			}
+		})
+		if err != nil {
+			slog.Error("MCP server failed", "server", s.server, "error", err)
+			s.lock.Lock()
+			s.err = err
+			s.lock.Unlock()
+			s.failInflight(err)
+			s.Close(false)
+			return
+		}
+		current = next
+	}
+}
+
+func (s *supervisedStdio) restart() (*Stdio, error) {
+	if s.policy.disabled {
+		return nil, fmt.Errorf("MCP server %s exited and restarts are disabled", s.server)
+	}
+	for {
+		var (
+			backoff time.Duration
+			ok      bool
+		)
+		s.restarts, backoff, ok = s.policy.next(s.restarts, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("MCP server %s keeps exiting after %d restarts within %s and is no longer restarted", s.server, len(s.restarts), s.policy.window)
+		}
+
+		slog.Warn("MCP server exited, restarting", "server", s.server, "backoff", backoff)
+		select {
+		case <-s.ctx.Done():
+			return nil, context.Cause(s.ctx)
+		case <-s.waiter.running:
+			return nil, fmt.Errorf("MCP server %s was closed", s.server)
+		case <-time.After(backoff):
+		}
+
+		next, err := s.newStdio()
+		if err == nil {
+			if err = s.resume(next); err != nil {
+				next.Close(false)
+			}
+		}
+		if err == nil {
+			return next, nil
+		}
+		slog.Error("failed to restart MCP server", "server", s.server, "error", err)
+	}
+}
+
+// resume starts the restarted server, initializes it like the session did and sends the requests
+// that are waiting for a response again.
+func (s *supervisedStdio) resume(next *Stdio) error {
+	if err := next.Start(s.ctx, s.onMessage); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	initialize := s.initialize
+	s.lock.Unlock()
+
+	if initialize != nil {
+		if err := s.reinitialize(next, *initialize); err != nil {
+			return err
+		}
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return fmt.Errorf("MCP server %s was closed", s.server)
+	}
+	s.current = next
+	pending := make([]Message, 0, len(s.inflight))
+	for _, msg := range s.inflight {
+		pending = append(pending, msg)
+	}
+	s.lock.Unlock()
+
+	slog.Info("MCP server started again", "server", s.server, "pending_requests", len(pending))
+	for _, msg := range pending {
+		if err := next.Send(s.ctx, msg); err != nil {
+			return fmt.Errorf("failed to resend request: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *supervisedStdio) reinitialize(next *Stdio, req Message) error {
+	reinit := make(chan Message, 1)
+	s.lock.Lock()
+	s.reinitSeq++
+	req.ID = fmt.Sprintf("nanobot-restart-%d", s.reinitSeq)
+	s.reinitID, s.reinit = req.ID.(string), reinit
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.reinitID, s.reinit = "", nil
+		s.lock.Unlock()
+	}()
+
+	if err := next.Send(s.ctx, req); err != nil {
+		return fmt.Errorf("failed to send initialize request: %w", err)
+	}
+
+	select {
+	case resp := <-reinit:
+		if resp.Error != nil {
+			return fmt.Errorf("failed to initialize: %w", resp.Error)
+		}
+	case <-next.waiter.running:
+		return errors.New("server exited during initialize")
+	case <-time.After(reinitializeTimeout):
+		return fmt.Errorf("server did not respond to initialize within %s", reinitializeTimeout)
+	case <-s.ctx.Done():
+		return context.Cause(s.ctx)
+	}
+
+	return next.Send(s.ctx, Message{
+		JSONRPC: "2.0",
+		Method:  "notifications/initialized",
+	})
+}
+
+// resendableMethods are the requests that are sent to a restarted server again. Any other request,
+// like tools/call, may have had effects before the server exited and fails instead.
+var resendableMethods = map[string]bool{
+	"ping":                     true,
+	"tools/list":               true,
+	"prompts/list":             true,
+	"prompts/get":              true,
+	"resources/list":           true,
+	"resources/templates/list": true,
+	"resources/read":           true,
+	"completion/complete":      true,
+}
+
+// failInflight answers the requests waiting for a response with an error.
+func (s *supervisedStdio) failInflight(err error) {
+	s.fail(err, func(Message) bool { return true })
+}
+
+// failUnresendable answers the requests waiting for a response that can not be sent again with an
+// error.
+func (s *supervisedStdio) failUnresendable(err error) {
+	s.fail(err, func(msg Message) bool { return !resendableMethods[msg.Method] })
+}
+
+func (s *supervisedStdio) fail(err error, match func(Message) bool) {
+	s.lock.Lock()
+	var failed []Message
+	for id, msg := range s.inflight {
+		if match(msg) {
+			failed = append(failed, msg)
+			delete(s.inflight, id)
+		}
+	}
+	handler := s.handler
+	s.lock.Unlock()
+
+	for _, msg := range failed {
+		handler(s.ctx, Message{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error:   ErrRPCUnknown.WithError(err),
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers initialize, ping and tools/call requests over pipes. The first process exits
+// without answering a ping or tools/call, like a server crashing in the middle of a call.
+type fakeServer struct {
+	lock        sync.Mutex
+	started     int
+	initialized int
+	calls       int
+}
+
+func (f *fakeServer) newStdio() (*Stdio, error) {
+	f.lock.Lock()
+	f.started++
+	crash := f.started == 1
+	f.lock.Unlock()
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	go func() {
+		defer stdoutW.Close()
+		scanner := bufio.NewScanner(stdinR)
+		for scanner.Scan() {
+			var msg Message
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				return
+			}
+			switch msg.Method {
+			case "initialize":
+				f.lock.Lock()
+				f.initialized++
+				f.lock.Unlock()
+			case "ping":
+				if crash {
+					return
+				}
+			case "tools/call":
+				f.lock.Lock()
+				f.calls++
+				f.lock.Unlock()
+				if crash {
+					return
+				}
+			default:
+				continue
+			}
+			data, _ := json.Marshal(Message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)})
+			if _, err := stdoutW.Write(append(data, '\n')); err != nil {
+				return
+			}
+		}
+	}()
+
+	return NewStdio("fake", nil, stdoutR, stdinW, func() {
+		_ = stdinW.Close()
+	}), nil
+}
+
+func TestSupervisedStdioRestarts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := &fakeServer{}
+	health := &serverHealth{}
+	wire, err := newSupervisedStdio("fake", restartPolicy{maxRestarts: 2, window: time.Minute, backoff: time.Millisecond}, 0, health, server.newStdio)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := newSession(ctx, wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close(false)
+
+	var initResult InitializeResult
+	if err := session.Exchange(ctx, "initialize", InitializeRequest{ProtocolVersion: "2025-06-18"}, &initResult); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first server exits on the ping, which is answered by the restarted server.
+	var pingResult PingResult
+	if err := session.Exchange(ctx, "ping", struct{}{}, &pingResult); err != nil {
+		t.Fatal(err)
+	}
+
+	server.lock.Lock()
+	started, initialized := server.started, server.initialized
+	server.lock.Unlock()
+	if started != 2 {
+		t.Fatalf("expected the server to be started twice, got %d", started)
+	}
+	if initialized != 2 {
+		t.Fatalf("expected the restarted server to be initialized again, got %d initialize requests", initialized)
+	}
+	if got := health.get("fake"); got.Restarts != 1 {
+		t.Fatalf("expected 1 restart, got %d", got.Restarts)
+	}
+}
+
+func TestSupervisedStdioFailsCallsOnRestart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := &fakeServer{}
+	wire, err := newSupervisedStdio("fake", restartPolicy{maxRestarts: 2, window: time.Minute, backoff: time.Millisecond}, 0, &serverHealth{}, server.newStdio)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := newSession(ctx, wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close(false)
+
+	var initResult InitializeResult
+	if err := session.Exchange(ctx, "initialize", InitializeRequest{ProtocolVersion: "2025-06-18"}, &initResult); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first server exits on the call, which must not be sent to the restarted server again.
+	var callResult CallToolResult
+	err = session.Exchange(ctx, "tools/call", CallToolRequest{Name: "send-email"}, &callResult)
+	if err == nil || !strings.Contains(err.Error(), "restarted") {
+		t.Fatalf("expected the call to fail with a restart error, got %v", err)
+	}
+
+	var pingResult PingResult
+	if err := session.Exchange(ctx, "ping", struct{}{}, &pingResult); err != nil {
+		t.Fatal(err)
+	}
+
+	server.lock.Lock()
+	started, calls := server.started, server.calls
+	server.lock.Unlock()
+	if started != 2 {
+		t.Fatalf("expected the server to be started twice, got %d", started)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the call to be sent once, got %d", calls)
+	}
+}
+
+func TestRestartPolicyCrashLoop(t *testing.T) {
+	policy := restartPolicy{maxRestarts: 3, window: time.Minute, backoff: time.Second}
+	now := time.Now()
+
+	var (
+		restarts []time.Time
+		backoffs []time.Duration
+	)
+	for range 3 {
+		var (
+			backoff time.Duration
+			ok      bool
+		)
+		restarts, backoff, ok = policy.next(restarts, now)
+		if !ok {
+			t.Fatal("expected restart to be allowed")
+		}
+		backoffs = append(backoffs, backoff)
+	}
+	if backoffs[0] != time.Second || backoffs[1] != 2*time.Second || backoffs[2] != 4*time.Second {
+		t.Fatalf("expected exponential backoff, got %v", backoffs)
+	}
+
+	if _, _, ok := policy.next(restarts, now); ok {
+		t.Fatal("expected the fourth restart within the window to be refused")
+	}
+	if _, backoff, ok := policy.next(restarts, now.Add(2*time.Minute)); !ok || backoff != time.Second {
+		t.Fatalf("expected restarts outside of the window to be forgotten, got %v %v", backoff, ok)
+	}
+}
+
+func TestRestartPolicyValidate(t *testing.T) {
+	for _, policy := range []RestartPolicy{
+		{MaxRestarts: -1},
+		{Window: "soon"},
+		{Backoff: "-1s"},
+	} {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", policy)
+		}
+	}
+	if err := (RestartPolicy{MaxRestarts: 3, Window: "1m", Backoff: "500ms"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisedStdioIdleTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The first server does not crash on ping once it was started again.
+	server := &fakeServer{started: 1}
+	health := &serverHealth{}
+	wire, err := newSupervisedStdio("fake", restartPolicy{maxRestarts: 1, window: time.Minute, backoff: time.Millisecond}, 50*time.Millisecond, health, server.newStdio)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := newSession(ctx, wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close(false)
+
+	var initResult InitializeResult
+	if err := session.Exchange(ctx, "initialize", InitializeRequest{ProtocolVersion: "2025-06-18"}, &initResult); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && health.get("fake").State != ServerIdle; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state := health.get("fake").State; state != ServerIdle {
+		t.Fatalf("expected the server to be idle, got %s", state)
+	}
+
+	var pingResult PingResult
+	if err := session.Exchange(ctx, "ping", struct{}{}, &pingResult); err != nil {
+		t.Fatal(err)
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if server.started != 3 || server.initialized != 2 {
+		t.Fatalf("expected the server to be started and initialized again, got %d starts and %d initialize requests", server.started-1, server.initialized)
+	}
+}
+
+func TestSharedStdio(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		r      Runner
+		server = &fakeServer{started: 1}
+		wires  int
+	)
+	newWire := func(context.Context) (Wire, error) {
+		wires++
+		s, err := server.newStdio()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	var sessions []*Session
+	for range 2 {
+		wire, err := r.sharedStdio(ctx, "key", 0, newWire)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := newSession(ctx, wire, MessageHandlerFunc(func(context.Context, Message) {}), nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+
+		var initResult InitializeResult
+		if err := session.Exchange(ctx, "initialize", InitializeRequest{ProtocolVersion: "2025-06-18"}, &initResult); err != nil {
+			t.Fatal(err)
+		}
+		var pingResult PingResult
+		if err := session.Exchange(ctx, "ping", struct{}{}, &pingResult); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if wires != 1 {
+		t.Fatalf("expected one shared process, got %d", wires)
+	}
+	server.lock.Lock()
+	initialized := server.initialized
+	server.lock.Unlock()
+	if initialized != 1 {
+		t.Fatalf("expected the shared process to be initialized once, got %d", initialized)
+	}
+
+	sessions[0].Close(false)
+	r.sharedLock.Lock()
+	running := len(r.shared)
+	r.sharedLock.Unlock()
+	if running != 1 {
+		t.Fatal("expected the shared process to keep running while a session uses it")
+	}
+
+	sessions[1].Close(false)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		r.sharedLock.Lock()
+		running = len(r.shared)
+		r.sharedLock.Unlock()
+		if running == 0 {
+			return
+		}
+	}
+	t.Fatal("expected the shared process to be stopped after the last session closed")
+}
+
+// sentWire records the messages sent to a server.
+type sentWire struct {
+	lock sync.Mutex
+	sent []Message
+}
+
+func (w *sentWire) Close(bool)                               {}
+func (w *sentWire) Wait()                                    {}
+func (w *sentWire) Start(context.Context, WireHandler) error { return nil }
+func (w *sentWire) SessionID() string                        { return "" }
+
+func (w *sentWire) Send(_ context.Context, msg Message) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.sent = append(w.sent, msg)
+	return nil
+}
+
+func TestSharedStdioServerRequests(t *testing.T) {
+	ctx := t.Context()
+	wire := &sentWire{}
+	shared := &sharedStdio{
+		runner:         &Runner{},
+		wire:           wire,
+		cancel:         func() {},
+		clients:        map[*sharedStdioClient]struct{}{},
+		requests:       map[string]sharedRequest{},
+		progress:       map[string]*sharedStdioClient{},
+		serverRequests: map[string]*sharedStdioClient{},
+	}
+
+	received := map[string][]string{}
+	var clients []*sharedStdioClient
+	for _, name := range []string{"first", "second"} {
+		c := shared.attach()
+		if err := c.Start(ctx, func(_ context.Context, msg Message) {
+			received[name] = append(received[name], msg.Method)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, c)
+	}
+
+	call := Message{JSONRPC: "2.0", ID: "call-1", Method: "tools/call", Params: json.RawMessage(`{"name":"ask","_meta":{"progressToken":"token-1"}}`)}
+	if err := clients[0].Send(ctx, call); err != nil {
+		t.Fatal(err)
+	}
+	// The second session used the process last.
+	if err := clients[1].Send(ctx, Message{JSONRPC: "2.0", ID: "ping-1", Method: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A request with the progress token of a call goes to the session of the call, and so does its
+	// cancellation.
+	shared.onMessage(ctx, Message{JSONRPC: "2.0", ID: 1, Method: "sampling/createMessage", Params: json.RawMessage(`{"_meta":{"progressToken":"token-1"}}`)})
+	shared.onMessage(ctx, Message{JSONRPC: "2.0", Method: "notifications/cancelled", Params: json.RawMessage(`{"requestId":1}`)})
+	if !slices.Equal(received["first"], []string{"sampling/createMessage", "notifications/cancelled"}) || len(received["second"]) != 0 {
+		t.Fatalf("expected the request to go to the first session only, got %v", received)
+	}
+
+	// A request that can't be attributed to a session is rejected.
+	shared.onMessage(ctx, Message{JSONRPC: "2.0", ID: 2, Method: "elicitation/create", Params: json.RawMessage(`{}`)})
+	if len(received["first"]) != 2 || len(received["second"]) != 0 {
+		t.Fatalf("expected the request to go to no session, got %v", received)
+	}
+	wire.lock.Lock()
+	rejected := wire.sent[len(wire.sent)-1]
+	wire.lock.Unlock()
+	if MessageIDString(rejected.ID) != "2" || rejected.Error == nil {
+		t.Fatalf("expected the request to be rejected, got %+v", rejected)
+	}
+
+	// With one session left, the requests are that session's.
+	clients[0].Close(false)
+	shared.onMessage(ctx, Message{JSONRPC: "2.0", ID: 3, Method: "roots/list"})
+	if !slices.Equal(received["second"], []string{"roots/list"}) {
+		t.Fatalf("expected the request to go to the only session, got %v", received)
+	}
+}
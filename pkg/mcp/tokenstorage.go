@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/adrg/xdg"
 	"golang.org/x/oauth2"
@@ -101,3 +103,23 @@ func (l *localTokenStorage) DeleteTokenConfig(_ context.Context, url string) err
 	delete(m, url)
 	return l.writeFile(m)
 }
+
+func (l *localTokenStorage) ListTokenConfigs(context.Context) ([]StoredToken, error) {
+	m, err := l.readFile()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]StoredToken, 0, len(m))
+	for url, d := range m {
+		result = append(result, StoredToken{
+			URL:    url,
+			Config: d.Config,
+			Token:  d.Token,
+		})
+	}
+	slices.SortFunc(result, func(a, b StoredToken) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebSocketTransport(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		lock        sync.Mutex
+		initialized int
+	)
+	handler := MessageHandlerFunc(func(ctx context.Context, msg Message) {
+		switch msg.Method {
+		case "initialize":
+			lock.Lock()
+			initialized++
+			lock.Unlock()
+			_ = msg.Reply(ctx, InitializeResult{
+				ProtocolVersion: "2025-06-18",
+				ServerInfo:      ServerInfo{Name: "test"},
+			})
+		case "ping":
+			// The notification is sent over the same connection as the response.
+			_ = msg.Session.SendPayload(ctx, "notifications/message", LoggingMessage{Level: "info", Data: "pong"})
+			_ = msg.Reply(ctx, PingResult{})
+		}
+	})
+
+	server, err := NewHTTPServer(ctx, nil, handler, HTTPServerOptions{WebSocket: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	logs := make(chan LoggingMessage, 10)
+	client, err := NewClient(ctx, "test", Server{
+		BaseURL: "ws" + strings.TrimPrefix(ts.URL, "http"),
+	}, ClientOption{
+		OnLogging: func(_ context.Context, msg LoggingMessage) error {
+			logs <- msg
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close(true)
+
+	wire, ok := client.Session.wire.(*WebSocketClient)
+	if !ok {
+		t.Fatalf("expected a websocket wire, got %T", client.Session.wire)
+	}
+	sessionID := wire.SessionID()
+	if sessionID == "" {
+		t.Fatal("expected the server to assign a session ID")
+	}
+
+	ping := func() {
+		t.Helper()
+		if _, err := client.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-logs:
+			if msg.Data != "pong" {
+				t.Fatalf("unexpected notification %v", msg.Data)
+			}
+		case <-ctx.Done():
+			t.Fatal("expected a notification from the server")
+		}
+	}
+	ping()
+
+	// A dropped connection is dialed again and resumes the session.
+	wire.lock.Lock()
+	conn := wire.conn
+	wire.lock.Unlock()
+	wire.disconnected(conn)
+	ping()
+
+	if id := wire.SessionID(); id != sessionID {
+		t.Fatalf("expected session %s to be resumed, got %s", sessionID, id)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if initialized != 1 {
+		t.Fatalf("expected the session to be initialized once, got %d", initialized)
+	}
+}
@@ -0,0 +1,354 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/nanobot-ai/nanobot/pkg/log"
+)
+
+const (
+	// webSocketSubprotocol is negotiated by the WebSocket transport, every text message is one
+	// JSON-RPC message.
+	webSocketSubprotocol = "mcp"
+
+	// maxWebSocketMessageSize limits the size of one message read from a WebSocket.
+	maxWebSocketMessageSize = 64 << 20
+)
+
+var _ Wire = (*WebSocketClient)(nil)
+
+// isWebSocketURL returns whether an MCP server URL uses the WebSocket transport.
+func isWebSocketURL(u string) bool {
+	return strings.HasPrefix(u, "ws://") || strings.HasPrefix(u, "wss://")
+}
+
+// httpURL returns the HTTP URL of a WebSocket URL, other URLs are returned unchanged.
+func httpURL(u string) string {
+	if rest, ok := strings.CutPrefix(u, "ws://"); ok {
+		return "http://" + rest
+	}
+	if rest, ok := strings.CutPrefix(u, "wss://"); ok {
+		return "https://" + rest
+	}
+	return u
+}
+
+// WebSocketClient is a wire to an MCP server over a WebSocket. The handshake carries the same
+// headers, exchanged tokens and OAuth tokens as the HTTP client, which is also used to delete the
+// session. A dropped connection is dialed again with the session ID, and the session is initialized
+// again if the server does not know it anymore.
+type WebSocketClient struct {
+	client        *HTTPClient
+	url           string
+	watchesEvents bool
+
+	initLock sync.Mutex
+
+	lock     sync.Mutex
+	conn     *websocket.Conn
+	ready    bool
+	reinitID string
+	reinit   chan Message
+}
+
+func newWebSocketClient(serverName string, config Server, opts HTTPClientOptions, sessionState *SessionState, headers map[string]string, watchesEvents bool) (*WebSocketClient, error) {
+	wsURL := config.BaseURL
+	// OAuth metadata discovery, token storage and session deletion use the HTTP URL of the server.
+	config.BaseURL = httpURL(config.BaseURL)
+	client, err := newHTTPClient(serverName, config, opts, sessionState, headers, false)
+	if err != nil {
+		return nil, err
+	}
+	return &WebSocketClient{
+		client:        client,
+		url:           wsURL,
+		watchesEvents: watchesEvents,
+	}, nil
+}
+
+func (s *WebSocketClient) SetOAuthCallbackHandler(handler CallbackHandler) {
+	s.client.SetOAuthCallbackHandler(handler)
+}
+
+func (s *WebSocketClient) SessionID() string {
+	return s.client.SessionID()
+}
+
+func (s *WebSocketClient) Start(ctx context.Context, handler WireHandler) error {
+	if err := s.client.Start(ctx, handler); err != nil {
+		return err
+	}
+
+	if s.watchesEvents && s.SessionID() != "" {
+		go func() {
+			if _, err := s.connect(s.client.ctx); err != nil {
+				slog.Error("failed to reconnect websocket", "server", s.client.serverName, "error", err)
+			}
+		}()
+	}
+	return nil
+}
+
+func (s *WebSocketClient) Wait() {
+	s.client.Wait()
+}
+
+func (s *WebSocketClient) Close(deleteSession bool) {
+	// Closing the client first stops the connection from being dialed again.
+	s.client.Close(deleteSession)
+
+	s.lock.Lock()
+	conn := s.conn
+	s.conn = nil
+	s.lock.Unlock()
+
+	if conn != nil {
+		_ = conn.Close(websocket.StatusNormalClosure, "")
+	}
+}
+
+func (s *WebSocketClient) Send(ctx context.Context, msg Message) error {
+	return s.client.retrySend(ctx, msg, s.send)
+}
+
+func (s *WebSocketClient) send(ctx context.Context, msg Message) error {
+	if msg.Method == "initialize" {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return err
+		}
+
+		s.client.initializeLock.Lock()
+		s.client.initializeRequest = &msg
+		s.client.initializeLock.Unlock()
+
+		s.lock.Lock()
+		s.ready = true
+		s.lock.Unlock()
+		return s.write(ctx, conn, msg)
+	}
+
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, conn, msg)
+}
+
+func (s *WebSocketClient) write(ctx context.Context, conn *websocket.Conn, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	log.Messages(ctx, s.client.serverName, true, data)
+
+	if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+		s.disconnected(conn)
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// connect returns a connection with an initialized session, dialing and initializing the session
+// again if needed.
+func (s *WebSocketClient) connect(ctx context.Context) (*websocket.Conn, error) {
+	s.initLock.Lock()
+	defer s.initLock.Unlock()
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	ready := s.ready
+	s.lock.Unlock()
+	if ready {
+		return conn, nil
+	}
+
+	s.client.initializeLock.RLock()
+	initializeRequest := s.client.initializeRequest
+	s.client.initializeLock.RUnlock()
+	if initializeRequest == nil {
+		return nil, fmt.Errorf("cannot send message because client is not initialized, must send InitializeRequest first")
+	}
+
+	if err := s.reinitialize(ctx, conn, *initializeRequest); err != nil {
+		return nil, fmt.Errorf("failed to initialize client: %w", err)
+	}
+
+	s.lock.Lock()
+	s.ready = s.conn == conn
+	s.lock.Unlock()
+	return conn, nil
+}
+
+// dial opens the connection if it is not open. The session is ready if the server resumed it.
+func (s *WebSocketClient) dial(ctx context.Context) (*websocket.Conn, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	if err := s.client.ctx.Err(); err != nil {
+		return nil, fmt.Errorf("websocket client is closed: %w", context.Cause(s.client.ctx))
+	}
+
+	sessionID := s.SessionID()
+
+	// The request is only used for its headers, which include the session ID and exchanged tokens.
+	req, err := s.client.newRequest(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client.clientLock.RLock()
+	httpClient := s.client.httpClient
+	s.client.clientLock.RUnlock()
+
+	dialCtx, cancel := context.WithTimeout(s.client.ctx, 30*time.Second)
+	defer cancel()
+
+	conn, resp, err := websocket.Dial(dialCtx, s.url, &websocket.DialOptions{
+		HTTPClient:   httpClient,
+		HTTPHeader:   req.Header,
+		Subprotocols: []string{webSocketSubprotocol},
+	})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return nil, AuthRequiredErr{
+				ProtectedResourceValue: resp.Header.Get("WWW-Authenticate"),
+				Err:                    fmt.Errorf("failed to connect to websocket: %w", err),
+			}
+		}
+		if resp != nil && resp.StatusCode == http.StatusNotFound && sessionID != "" {
+			return nil, SessionNotFoundErr{
+				SessionID: sessionID,
+				Err:       fmt.Errorf("failed to connect to websocket: %w", err),
+			}
+		}
+		return nil, fmt.Errorf("failed to connect to websocket %s: %w", s.url, err)
+	}
+	conn.SetReadLimit(maxWebSocketMessageSize)
+
+	newSessionID := resp.Header.Get(SessionIDHeader)
+	s.client.initializeLock.Lock()
+	if newSessionID == "" {
+		s.client.sessionID = nil
+	} else {
+		s.client.sessionID = &newSessionID
+	}
+	s.client.initializeLock.Unlock()
+
+	s.conn = conn
+	s.ready = sessionID != "" && sessionID == newSessionID
+	slog.Info("mcp client websocket connected", "server", s.client.serverName, "session_id", newSessionID, "resumed", s.ready)
+
+	go s.read(conn)
+	return conn, nil
+}
+
+// reinitialize sends the initialize request of the session on a new connection.
+func (s *WebSocketClient) reinitialize(ctx context.Context, conn *websocket.Conn, req Message) error {
+	reinit := make(chan Message, 1)
+	req.ID = nextMessageID()
+
+	s.lock.Lock()
+	s.reinitID, s.reinit = MessageIDString(req.ID), reinit
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.reinitID, s.reinit = "", nil
+		s.lock.Unlock()
+	}()
+
+	slog.Info("mcp client reinitializing websocket session", "server", s.client.serverName)
+	if err := s.write(ctx, conn, req); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-reinit:
+		if resp.Error != nil {
+			return fmt.Errorf("failed to initialize: %w", resp.Error)
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.client.ctx.Done():
+		return context.Cause(s.client.ctx)
+	}
+
+	return s.write(ctx, conn, Message{
+		JSONRPC: "2.0",
+		Method:  "notifications/initialized",
+	})
+}
+
+func (s *WebSocketClient) read(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.Read(s.client.ctx)
+		if err != nil {
+			s.disconnected(conn)
+			if s.client.ctx.Err() != nil {
+				return
+			}
+			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
+				// The server closed the session, the next message starts a new one.
+				return
+			}
+			if !errors.Is(err, context.Canceled) {
+				slog.Warn("mcp client websocket disconnected", "server", s.client.serverName, "error", err)
+			}
+			if s.watchesEvents {
+				// Reconnect to keep receiving notifications from the server.
+				if _, err := s.connect(s.client.ctx); err != nil {
+					slog.Error("failed to reconnect websocket", "server", s.client.serverName, "error", err)
+				}
+			}
+			return
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			slog.Error("failed to decode websocket message", "server", s.client.serverName, "error", err)
+			continue
+		}
+		log.Messages(s.client.ctx, s.client.serverName, false, data)
+
+		if msg.ID != nil && msg.Method == "" {
+			s.lock.Lock()
+			reinit := s.reinit
+			isReinit := reinit != nil && MessageIDString(msg.ID) == s.reinitID
+			s.lock.Unlock()
+			if isReinit {
+				reinit <- msg
+				continue
+			}
+		}
+
+		s.client.handler(s.client.ctx, msg)
+	}
+}
+
+// disconnected forgets a connection that failed, the next message dials again.
+func (s *WebSocketClient) disconnected(conn *websocket.Conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn == conn {
+		s.conn = nil
+		s.ready = false
+	}
+	_ = conn.CloseNow()
+}
@@ -0,0 +1,241 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"github.com/tidwall/gjson"
+)
+
+func isWebSocketUpgrade(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
+
+// serveWebSocket runs an MCP session over a WebSocket. The session lives in the same session store
+// as the HTTP sessions, so a client can connect again with its session ID and keep using it. The
+// session ID of a new connection is sent in the handshake response, the session is created by the
+// first message, which must be an initialize request.
+func (h *HTTPServer) serveWebSocket(rw http.ResponseWriter, req *http.Request, auditLog auditlogs.MCPAuditLog) {
+	id := auditLog.SessionID
+
+	var session *ServerSession
+	if id != "" {
+		var (
+			ok  bool
+			err error
+		)
+		session, ok, err = h.sessions.Acquire(req.Context(), h.MessageHandler, id)
+		if err != nil {
+			slog.Error("mcp server failed to acquire session for websocket", "session_id", id, "error", err)
+			http.Error(rw, `{"http_error": "Failed to load session"}`, http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			auditLog.ResponseStatus = http.StatusNotFound
+			auditLog.Error = "Session not found"
+			h.auditLogCollector.CollectMCPAuditEntry(auditLog)
+
+			http.Error(rw, `{"http_error": "Session not found"}`, http.StatusNotFound)
+			return
+		}
+		defer h.sessions.Release(session)
+		session.session.sessionManager = h.sessions
+	} else {
+		id = uuid.String()
+	}
+
+	rw.Header().Set(SessionIDHeader, id)
+	conn, err := websocket.Accept(rw, req, &websocket.AcceptOptions{
+		Subprotocols: []string{webSocketSubprotocol},
+	})
+	if err != nil {
+		slog.Warn("mcp server failed to accept websocket", "session_id", id, "error", err)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(maxWebSocketMessageSize)
+
+	auditLog.SessionID = id
+	auditLog.ResponseStatus = http.StatusSwitchingProtocols
+	h.auditLogCollector.CollectMCPAuditEntry(auditLog)
+
+	slog.Debug("mcp server opened websocket", "session_id", id, "path", req.URL.Path)
+	defer slog.Debug("mcp server closed websocket", "session_id", id, "path", req.URL.Path)
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	if session != nil {
+		go h.writeWebSocketEvents(ctx, conn, session)
+	}
+
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			if status := websocket.CloseStatus(err); status != websocket.StatusNormalClosure && status != websocket.StatusGoingAway && ctx.Err() == nil {
+				slog.Debug("mcp server websocket read failed", "session_id", id, "error", err)
+			}
+			return
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			slog.Warn("mcp server failed to decode websocket message", "session_id", id, "error", err)
+			_ = conn.Close(websocket.StatusUnsupportedData, "failed to decode message")
+			return
+		}
+
+		if session == nil {
+			// The session is created by the initialize request before any other message is read.
+			if msg.Method != "initialize" {
+				slog.Warn("mcp server rejected non-initialize websocket message without session", "method", msg.Method, "request_id", MessageIDString(msg.ID))
+				_ = conn.Close(websocket.StatusPolicyViolation, "session is not initialized")
+				return
+			}
+			session, err = h.newWebSocketSession(ctx, conn, req, id, msg, data)
+			if err != nil {
+				_ = conn.Close(websocket.StatusInternalError, "failed to initialize session")
+				return
+			}
+			defer h.sessions.Release(session)
+			go h.writeWebSocketEvents(ctx, conn, session)
+			continue
+		}
+
+		go h.handleWebSocketMessage(ctx, conn, req, session, msg, data)
+	}
+}
+
+func (h *HTTPServer) newWebSocketSession(ctx context.Context, conn *websocket.Conn, req *http.Request, id string, msg Message, data []byte) (*ServerSession, error) {
+	auditLog := h.webSocketAuditLog(req, id, msg, data)
+	ctx = WithAuditLog(ctx, &auditLog)
+
+	session, err := NewExistingServerSession(h.ctx, SessionState{ID: id}, h.MessageHandler, ServerSessionOptions{
+		DefaultAgent: req.Header.Get("X-Nanobot-Default-Agent"),
+	})
+	if err != nil {
+		slog.Error("mcp server failed to create websocket session", "session_id", id, "error", err)
+		return nil, err
+	}
+
+	session.session.sessionManager = h.sessions
+	session.session.SetEnv(h.getEnv(req))
+
+	if desc := req.Header.Get("X-Nanobot-Description"); desc != "" {
+		session.session.Set("description", desc)
+	}
+	if taskURI := req.Header.Get("X-Nanobot-Task-URI"); taskURI != "" {
+		session.session.Set("taskURI", taskURI)
+	}
+
+	resp, err := session.Exchange(ctx, msg)
+	if err != nil {
+		slog.Error("mcp server failed to initialize websocket session", "request_id", MessageIDString(msg.ID), "error", err)
+		session.Close(true)
+		return nil, err
+	}
+
+	if err := h.sessions.Store(ctx, id, session); err != nil {
+		slog.Error("mcp server failed to persist session", "session_id", id, "error", err)
+		session.Close(true)
+		return nil, err
+	}
+
+	auditLog.ClientName = session.session.InitializeRequest.ClientInfo.Name
+	auditLog.ClientVersion = session.session.InitializeRequest.ClientInfo.Version
+	h.writeWebSocketResponse(ctx, conn, &auditLog, resp)
+	return session, nil
+}
+
+func (h *HTTPServer) handleWebSocketMessage(ctx context.Context, conn *websocket.Conn, req *http.Request, session *ServerSession, msg Message, data []byte) {
+	auditLog := h.webSocketAuditLog(req, session.ID(), msg, data)
+	ctx = WithAuditLog(ctx, &auditLog)
+
+	session.session.SetEnv(h.getEnv(req))
+	session.session.Set("subject", auditLog.Subject)
+	session.session.Set("clientIP", auditLog.ClientIP)
+	session.session.Set("apiKey", auditLog.APIKey)
+
+	auditLog.ClientName = session.session.InitializeRequest.ClientInfo.Name
+	auditLog.ClientVersion = session.session.InitializeRequest.ClientInfo.Version
+
+	response, err := session.Exchange(ctx, msg)
+	if errors.Is(err, ErrNoResponse) {
+		auditLog.ResponseStatus = http.StatusAccepted
+		auditLog.ProcessingTimeMs = time.Since(auditLog.CreatedAt).Milliseconds()
+		h.auditLogCollector.CollectMCPAuditEntry(auditLog)
+		_ = h.sessions.Store(ctx, session.ID(), session)
+		return
+	} else if err != nil {
+		slog.Error("mcp server failed to handle message", "method", msg.Method, "request_id", MessageIDString(msg.ID), "session_id", session.ID(), "error", err)
+		response = Message{
+			JSONRPC: msg.JSONRPC,
+			ID:      msg.ID,
+			Error:   ErrRPCInternal.WithError(err),
+		}
+	}
+
+	h.writeWebSocketResponse(ctx, conn, &auditLog, response)
+	_ = h.sessions.Store(ctx, session.ID(), session)
+}
+
+func (h *HTTPServer) writeWebSocketResponse(ctx context.Context, conn *websocket.Conn, auditLog *auditlogs.MCPAuditLog, response Message) {
+	data, err := json.Marshal(response)
+	if err == nil {
+		err = conn.Write(ctx, websocket.MessageText, data)
+	}
+	if err != nil {
+		auditLog.Error = err.Error()
+		slog.Debug("mcp server failed to write websocket response", "session_id", auditLog.SessionID, "error", err)
+	}
+
+	auditLog.ResponseStatus = http.StatusOK
+	auditLog.ResponseBody = data
+	auditLog.ProcessingTimeMs = time.Since(auditLog.CreatedAt).Milliseconds()
+	h.auditLogCollector.CollectMCPAuditEntry(*auditLog)
+}
+
+// writeWebSocketEvents sends the messages from the server to the client, until the connection or
+// the session is closed.
+func (h *HTTPServer) writeWebSocketEvents(ctx context.Context, conn *websocket.Conn, session *ServerSession) {
+	ch, done := session.Subscribe(ctx)
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(msg)
+			if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+				return
+			}
+		case <-done:
+			_ = conn.Close(websocket.StatusNormalClosure, "session closed")
+			return
+		}
+	}
+}
+
+func (h *HTTPServer) webSocketAuditLog(req *http.Request, sessionID string, msg Message, data []byte) auditlogs.MCPAuditLog {
+	auditLog := buildAuditLog(req, msg.Method, sessionID)
+	auditLog.Subject = UserFromContext(req.Context()).Sub
+	auditLog.RequestBody = data
+	if msg.ID != nil {
+		auditLog.RequestID = MessageIDString(msg.ID)
+	}
+	switch msg.Method {
+	case "resources/read":
+		auditLog.CallIdentifier = gjson.GetBytes(msg.Params, "uri").String()
+	case "tools/call", "prompts/get":
+		auditLog.CallIdentifier = gjson.GetBytes(msg.Params, "name").String()
+	}
+	return auditLog
+}
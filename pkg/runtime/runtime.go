@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/nanobot-ai/nanobot/pkg/a2a"
 	"github.com/nanobot-ai/nanobot/pkg/agents"
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/llm"
@@ -17,6 +18,7 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/servers/artifacts"
 	"github.com/nanobot-ai/nanobot/pkg/servers/meta"
 	"github.com/nanobot-ai/nanobot/pkg/servers/obotmcp"
+	"github.com/nanobot-ai/nanobot/pkg/servers/scripts"
 	"github.com/nanobot-ai/nanobot/pkg/servers/skills"
 	"github.com/nanobot-ai/nanobot/pkg/servers/system"
 	"github.com/nanobot-ai/nanobot/pkg/servers/tasks"
@@ -50,6 +52,9 @@ type Options struct {
 	DefaultModel              string
 	ConfigDir                 string
 	LoopbackURL               string
+	ReplicaID                 string
+	SandboxBackend            string
+	EncryptionKey             string
 }
 
 func (o Options) Merge(other Options) (result Options) {
@@ -68,6 +73,9 @@ func (o Options) Merge(other Options) (result Options) {
 	result.DefaultModel = complete.Last(o.DefaultModel, other.DefaultModel)
 	result.ConfigDir = complete.Last(o.ConfigDir, other.ConfigDir)
 	result.LoopbackURL = complete.Last(o.LoopbackURL, other.LoopbackURL)
+	result.ReplicaID = complete.Last(o.ReplicaID, other.ReplicaID)
+	result.SandboxBackend = complete.Last(o.SandboxBackend, other.SandboxBackend)
+	result.EncryptionKey = complete.Last(o.EncryptionKey, other.EncryptionKey)
 	return
 }
 
@@ -96,6 +104,7 @@ func NewRuntime(ctx context.Context, cfg llm.Config, opts ...Options) (*Runtime,
 		TokenExchangeClientID:     opt.TokenExchangeClientID,
 		TokenExchangeClientSecret: opt.TokenExchangeClientSecret,
 		AuditLogCollector:         opt.AuditLogCollector,
+		SandboxBackend:            opt.SandboxBackend,
 	})
 	agentsService := agents.New(completer, registry)
 	sampler := sampling.NewSampler(agentsService)
@@ -110,7 +119,7 @@ func NewRuntime(ctx context.Context, cfg llm.Config, opts ...Options) (*Runtime,
 	}
 
 	registry.AddServer("nanobot.meta", func(string) mcp.MessageHandler {
-		return meta.NewServer(sessiondata.NewData(r), opt.ConfigDir)
+		return meta.NewServer(sessiondata.NewData(r), r, opt.ConfigDir)
 	})
 
 	registry.AddServer("nanobot.agent", func(name string) mcp.MessageHandler {
@@ -126,7 +135,7 @@ func NewRuntime(ctx context.Context, cfg llm.Config, opts ...Options) (*Runtime,
 	})
 
 	registry.AddServer("nanobot.workflow-tools", func(string) mcp.MessageHandler {
-		return workflows.NewToolsServer()
+		return workflows.NewToolsServer(registry)
 	})
 
 	registry.AddServer("nanobot.artifacts", func(string) mcp.MessageHandler {
@@ -137,12 +146,22 @@ func NewRuntime(ctx context.Context, cfg llm.Config, opts ...Options) (*Runtime,
 		return skills.NewServer(opt.ConfigDir)
 	})
 
+	registry.AddServer(types.ScriptsServer, func(string) mcp.MessageHandler {
+		return scripts.NewServer(registry)
+	})
+
+	registry.AddServer(types.A2AServer, func(name string) mcp.MessageHandler {
+		return a2a.NewToolServer(name)
+	})
+
 	registry.AddServer("nanobot.obot-mcp-cli", func(string) mcp.MessageHandler {
 		return obotmcp.NewServer(opt.ConfigDir)
 	})
 
 	if opt.LoopbackURL != "" && opt.Store != nil {
-		taskServer, err := tasks.NewServer(ctx, opt.Store, opt.LoopbackURL)
+		taskServer, err := tasks.NewServer(ctx, opt.Store, opt.LoopbackURL, tasks.Options{
+			ReplicaID: opt.ReplicaID,
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to start task server: %w", err)
 		}
@@ -34,12 +34,71 @@ type scored struct {
 	model string
 }
 
-func (s *Sampler) sortModels(config types.Config, preferences mcp.ModelPreferences) []string {
-	var scoredModels []scored
+// modelRequirements are the capabilities a sampling request needs from the model that handles it.
+type modelRequirements struct {
+	toolCalls bool
+	mimeTypes []string
+	maxTokens int
+}
+
+func newModelRequirements(req *mcp.CreateMessageRequest, tools []mcp.Tool) modelRequirements {
+	result := modelRequirements{
+		toolCalls: len(tools) > 0,
+		maxTokens: req.MaxTokens,
+	}
+	for _, msg := range req.Messages {
+		for _, content := range msg.Content {
+			switch content.Type {
+			case "image", "audio":
+				result.mimeTypes = append(result.mimeTypes, content.MIMEType)
+			case "tool_use", "tool_result":
+				result.toolCalls = true
+			}
+		}
+	}
+	return result
+}
+
+func (m modelRequirements) satisfiedBy(model types.ModelInfo) bool {
+	if m.toolCalls && !model.SupportsToolCalls() {
+		return false
+	}
+	if m.maxTokens > 0 && model.MaxOutputTokens > 0 && m.maxTokens > model.MaxOutputTokens {
+		return false
+	}
+	for _, mimeType := range m.mimeTypes {
+		if !model.SupportsMimeType(mimeType) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Sampler) sortModels(config types.Config, preferences mcp.ModelPreferences, requirements modelRequirements) []string {
+	var (
+		scoredModels []scored
+		maxPrice     float64
+		prices       = map[string]float64{}
+	)
+
+	for _, modelKey := range slices.Sorted(maps.Keys(config.Agents)) {
+		if info, ok := config.LookupModel(config.Agents[modelKey].Model); ok && info.Pricing != nil {
+			prices[modelKey] = info.Pricing.Input + info.Pricing.Output
+			maxPrice = max(maxPrice, prices[modelKey])
+		}
+	}
 
 	for _, modelKey := range slices.Sorted(maps.Keys(config.Agents)) {
 		model := config.Agents[modelKey]
+		info, _ := config.LookupModel(model.Model)
+		if !requirements.satisfiedBy(info) {
+			continue
+		}
 		cost := model.Cost
+		if price, ok := prices[modelKey]; ok && cost == 0 && maxPrice > 0 {
+			// Cheaper models score higher, matching how a configured cost is interpreted.
+			cost = 1 - price/maxPrice
+		}
 		if preferences.CostPriority != nil {
 			cost *= *preferences.CostPriority
 		}
@@ -68,7 +127,7 @@ func (s *Sampler) sortModels(config types.Config, preferences mcp.ModelPreferenc
 	return models
 }
 
-func (s *Sampler) getMatchingModel(config types.Config, req *mcp.CreateMessageRequest) (string, bool) {
+func (s *Sampler) getMatchingModel(config types.Config, req *mcp.CreateMessageRequest, tools []mcp.Tool) (string, bool) {
 	// Agent by name
 	for _, model := range req.ModelPreferences.Hints {
 		if _, ok := config.Agents[model.Name]; ok {
@@ -85,7 +144,7 @@ func (s *Sampler) getMatchingModel(config types.Config, req *mcp.CreateMessageRe
 		}
 	}
 
-	models := s.sortModels(config, req.ModelPreferences)
+	models := s.sortModels(config, req.ModelPreferences, newModelRequirements(req, tools))
 	if len(models) == 0 {
 		return "", false
 	}
@@ -119,7 +178,7 @@ func (s *Sampler) Sample(ctx context.Context, req mcp.CreateMessageRequest, opts
 	opt := complete.Complete(opts...)
 	config := types.ConfigFromContext(ctx)
 
-	model, ok := s.getMatchingModel(config, &req)
+	model, ok := s.getMatchingModel(config, &req, opt.Tools)
 	if !ok {
 		return nil, ErrNoMatchingModel
 	}
@@ -280,6 +339,8 @@ func CompletionResponseToCallResult(resp *types.CompletionResponse, includeMessa
 			Type: "text",
 			Text: resp.Error,
 		})
+	} else if resp.StructuredContent != nil {
+		result.StructuredContent = resp.StructuredContent
 	}
 
 	if len(result.Content) == 0 {
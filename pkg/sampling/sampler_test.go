@@ -84,3 +84,41 @@ func TestSamplePreservesAttachmentHiddenMessageBoundary(t *testing.T) {
 		t.Fatalf("unexpected second message text: %q", complete.lastReq.Input[1].Items[0].Content.Text)
 	}
 }
+
+func TestSortModelsUsesModelRegistry(t *testing.T) {
+	config := types.Config{
+		LLMProviders: map[string]types.LLMProvider{
+			"local": {
+				Models: map[string]types.ModelInfo{
+					"text-only": {
+						ToolCalls: new(false),
+						Vision:    new(false),
+					},
+				},
+			},
+		},
+		Agents: map[string]types.Agent{
+			"expensive": {HookAgent: types.HookAgent{Model: "claude-opus-4-1"}},
+			"cheap":     {HookAgent: types.HookAgent{Model: "gpt-4.1-nano"}},
+			"limited":   {HookAgent: types.HookAgent{Model: "local/text-only", Cost: 10}},
+		},
+	}
+
+	costPriority := 1.0
+	s := NewSampler(nil)
+
+	models := s.sortModels(config, mcp.ModelPreferences{CostPriority: &costPriority}, modelRequirements{})
+	if len(models) != 3 || models[0] != "limited" || models[1] != "cheap" {
+		t.Fatalf("expected configured cost then cheapest priced model first, got %v", models)
+	}
+
+	models = s.sortModels(config, mcp.ModelPreferences{CostPriority: &costPriority}, modelRequirements{toolCalls: true})
+	if len(models) != 2 || models[0] != "cheap" {
+		t.Fatalf("expected model without tool support to be excluded, got %v", models)
+	}
+
+	models = s.sortModels(config, mcp.ModelPreferences{}, modelRequirements{maxTokens: 64_000})
+	if len(models) != 1 || models[0] != "limited" {
+		t.Fatalf("expected models with smaller max output to be excluded, got %v", models)
+	}
+}
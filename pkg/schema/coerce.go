@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Coerce converts the values in value, decoded from JSON, that obviously mismatch the type in the
+// JSON schema, like the string "5" for an integer or a number for a string. It returns the converted
+// value and a description of each change, like `/count: converted "5" to 5`. Values that can't be
+// converted are left for validation to report.
+func Coerce(schema json.RawMessage, value any) (any, []string) {
+	var doc map[string]any
+	if err := json.Unmarshal(schema, &doc); err != nil {
+		return value, nil
+	}
+	var changes []string
+	return coerce(doc, value, "", &changes), changes
+}
+
+func coerce(schema map[string]any, value any, location string, changes *[]string) any {
+	types := schemaTypes(schema)
+	if len(types) > 0 && !matchesAny(types, value) {
+		for _, t := range types {
+			if converted, ok := convert(value, t); ok {
+				*changes = append(*changes, fmt.Sprintf("%s: converted %s to %s", orRoot(location), jsonString(value), jsonString(converted)))
+				value = converted
+				break
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, key := range slices.Sorted(maps.Keys(properties)) {
+			propertySchema, ok := properties[key].(map[string]any)
+			if propertyValue, set := v[key]; ok && set {
+				v[key] = coerce(propertySchema, propertyValue, location+"/"+key, changes)
+			}
+		}
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			break
+		}
+		for i := range v {
+			v[i] = coerce(items, v[i], location+"/"+strconv.Itoa(i), changes)
+		}
+	}
+	return value
+}
+
+func schemaTypes(schema map[string]any) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesAny(types []string, value any) bool {
+	for _, t := range types {
+		if matches(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(t string, value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return t == "null"
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	case map[string]any:
+		return t == "object"
+	case []any:
+		return t == "array"
+	}
+	return false
+}
+
+func convert(value any, t string) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		switch t {
+		case "integer":
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return float64(i), true
+			}
+		case "number":
+			if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				return f, true
+			}
+		case "boolean":
+			switch strings.ToLower(s) {
+			case "true":
+				return true, true
+			case "false":
+				return false, true
+			}
+		case "object", "array":
+			// Arguments that are JSON encoded as a string.
+			var decoded any
+			if err := json.Unmarshal([]byte(s), &decoded); err == nil && matches(t, decoded) {
+				return decoded, true
+			}
+		}
+	case float64:
+		if t == "string" {
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		}
+	case bool:
+		if t == "string" {
+			return strconv.FormatBool(v), true
+		}
+	}
+	return nil, false
+}
+
+func orRoot(location string) string {
+	if location == "" {
+		return "/"
+	}
+	return location
+}
+
+func jsonString(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// EncryptedPrefix starts a value encrypted with Encrypt.
+const EncryptedPrefix = "enc:"
+
+// IsEncrypted returns whether a value was encrypted with Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
+
+// Encrypt encrypts a value with AES-GCM and a key derived from the encryption key. The result is
+// enc: followed by the base64 encoded nonce and ciphertext.
+func Encrypt(key, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value encrypted with Encrypt.
+func Decrypt(key, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, EncryptedPrefix)
+	if !ok {
+		return "", fmt.Errorf("value is not encrypted, it must start with %s", EncryptedPrefix)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode encrypted value: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted value is too short")
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value, check the encryption key: %w", err)
+	}
+
+	Register(string(plain))
+	return string(plain), nil
+}
+
+func newGCM(key string) (cipher.AEAD, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("encryption key is not set")
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// Mask replaces the resolved secrets.
+	Mask = "[REDACTED]"
+
+	// minSecretLength keeps short values, which would match everywhere, from being redacted.
+	minSecretLength = 4
+)
+
+var (
+	redactLock sync.RWMutex
+	resolved   []string
+)
+
+// Register adds a resolved value to the values that are redacted.
+func Register(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+
+	forms := []string{value}
+	// The JSON encoded form is redacted too, for secrets in message and audit bodies.
+	if data, err := json.Marshal(value); err == nil {
+		if escaped := string(data[1 : len(data)-1]); escaped != value {
+			forms = append(forms, escaped)
+		}
+	}
+
+	redactLock.Lock()
+	defer redactLock.Unlock()
+	for _, form := range forms {
+		if !slices.Contains(resolved, form) {
+			resolved = append(resolved, form)
+		}
+	}
+	// Longer values first, so a secret that contains another one is replaced as a whole.
+	slices.SortFunc(resolved, func(a, b string) int {
+		return len(b) - len(a)
+	})
+}
+
+// IsSecret returns whether a value is a resolved secret.
+func IsSecret(value string) bool {
+	redactLock.RLock()
+	defer redactLock.RUnlock()
+	return slices.Contains(resolved, value)
+}
+
+// Redact replaces the resolved secrets in a string.
+func Redact(s string) string {
+	redactLock.RLock()
+	defer redactLock.RUnlock()
+	for _, secret := range resolved {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Mask)
+		}
+	}
+	return s
+}
+
+// RedactBytes replaces the resolved secrets in data, it returns data if there are none.
+func RedactBytes(data []byte) []byte {
+	redactLock.RLock()
+	defer redactLock.RUnlock()
+	for _, secret := range resolved {
+		if bytes.Contains(data, []byte(secret)) {
+			data = bytes.ReplaceAll(data, []byte(secret), []byte(Mask))
+		}
+	}
+	return data
+}
+
+func hasSecrets() bool {
+	redactLock.RLock()
+	defer redactLock.RUnlock()
+	return len(resolved) > 0
+}
+
+// Handler redacts the resolved secrets from the messages and attributes of log records.
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler wraps a log handler to redact the resolved secrets.
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if !hasSecrets() {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	redacted := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, redactAttr(attr))
+	}
+	return &Handler{Handler: h.Handler.WithAttrs(redacted)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, 0, len(group))
+		for _, a := range group {
+			redacted = append(redacted, redactAttr(a))
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, Redact(v.Error()))
+		case []byte:
+			return slog.String(attr.Key, string(RedactBytes(v)))
+		}
+		// Other values are logged as JSON if they contain a secret.
+		data, err := json.Marshal(value.Any())
+		if err != nil {
+			return attr
+		}
+		if redacted := RedactBytes(data); !bytes.Equal(redacted, data) {
+			return slog.String(attr.Key, string(redacted))
+		}
+	}
+	return attr
+}
@@ -0,0 +1,139 @@
+// Package secrets resolves the secret references of the configuration, ${file:/path} and
+// ${exec:command args}, decrypts the enc: values encrypted with the encryption key, and keeps
+// the resolved values so they can be redacted from logs and audit logs.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	filePrefix = "file:"
+	execPrefix = "exec:"
+
+	// execCacheTTL is how long the output of a command is used before it is run again.
+	execCacheTTL = 5 * time.Minute
+	// execTimeout limits how long a command may run.
+	execTimeout = 30 * time.Second
+)
+
+var (
+	lock      sync.Mutex
+	fileCache = map[string]fileEntry{}
+	execCache = map[string]execEntry{}
+)
+
+type fileEntry struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+type execEntry struct {
+	expires time.Time
+	value   string
+}
+
+// IsReference returns whether the name of a ${...} expression is a secret reference.
+func IsReference(name string) bool {
+	return strings.HasPrefix(name, filePrefix) || strings.HasPrefix(name, execPrefix)
+}
+
+// Resolve returns the value of a secret reference. file:/path is the content of the file, read
+// again when it changes. exec:command args is the output of the command, which is split on
+// white space and not run by a shell, and is cached for five minutes. A trailing newline is
+// removed from both.
+func Resolve(ctx context.Context, ref string) (string, error) {
+	var (
+		value string
+		err   error
+	)
+	if path, ok := strings.CutPrefix(ref, filePrefix); ok {
+		value, err = resolveFile(strings.TrimSpace(path))
+	} else if command, ok := strings.CutPrefix(ref, execPrefix); ok {
+		value, err = resolveExec(ctx, command)
+	} else {
+		return "", fmt.Errorf("unknown secret reference %q", ref)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	Register(value)
+	return value, nil
+}
+
+func resolveFile(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("secret reference file: requires a path")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+	}
+
+	lock.Lock()
+	entry, ok := fileCache[path]
+	lock.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.value, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+
+	lock.Lock()
+	fileCache[path] = fileEntry{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		value:   value,
+	}
+	lock.Unlock()
+	return value, nil
+}
+
+func resolveExec(ctx context.Context, command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("secret reference exec: requires a command")
+	}
+
+	lock.Lock()
+	entry, ok := execCache[command]
+	lock.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// The output is not part of the error, it may be a secret.
+		return "", fmt.Errorf("failed to run secret command %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	value := strings.TrimRight(string(out), "\r\n")
+
+	lock.Lock()
+	execCache[command] = execEntry{
+		expires: time.Now().Add(execCacheTTL),
+		value:   value,
+	}
+	lock.Unlock()
+	return value, nil
+}
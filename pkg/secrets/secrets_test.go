@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	encrypted, err := Encrypt("key", "s3cret-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "s3cret-value") {
+		t.Fatalf("unexpected encrypted value %s", encrypted)
+	}
+
+	decrypted, err := Decrypt(" key\n", encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != "s3cret-value" {
+		t.Fatalf("expected the value to be decrypted, got %s", decrypted)
+	}
+
+	if _, err := Decrypt("other", encrypted); err == nil {
+		t.Fatal("expected an error decrypting with another key")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := Resolve(ctx, "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "from-file-1" {
+		t.Fatalf("unexpected value %q", value)
+	}
+
+	// A changed file is read again.
+	if err := os.WriteFile(path, []byte("from-file-22\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if value, err = Resolve(ctx, "file:"+path); err != nil || value != "from-file-22" {
+		t.Fatalf("unexpected value %q: %v", value, err)
+	}
+
+	// The output of a command is cached.
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "counter")
+	script := filepath.Join(dir, "secret.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho run >> \"$1\"\necho from-exec\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	command := "exec:" + script + " " + counter
+	for range 2 {
+		if value, err = Resolve(ctx, command); err != nil || value != "from-exec" {
+			t.Fatalf("unexpected value %q: %v", value, err)
+		}
+	}
+	runs, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(runs), "run"); n != 1 {
+		t.Fatalf("expected the command to run once, ran %d times", n)
+	}
+
+	if _, err := Resolve(ctx, "exec:"); err == nil {
+		t.Fatal("expected an error for an empty command")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	Register(`tok"en-1234`)
+
+	if got := Redact(`Bearer tok"en-1234`); got != "Bearer "+Mask {
+		t.Fatalf("unexpected redacted string %q", got)
+	}
+	if got := string(RedactBytes([]byte(`{"token":"tok\"en-1234"}`))); got != `{"token":"`+Mask+`"}` {
+		t.Fatalf("unexpected redacted JSON %s", got)
+	}
+	if !IsSecret(`tok"en-1234`) || IsSecret("tok") {
+		t.Fatal("expected only the registered value to be a secret")
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewTextHandler(&buf, nil))).With("header", `Bearer tok"en-1234`)
+	logger.Info(`using tok"en-1234`, "headers", map[string]string{"Authorization": `tok"en-1234`})
+	if strings.Contains(buf.String(), "1234") {
+		t.Fatalf("expected the secret to be redacted from the log, got %s", buf.String())
+	}
+}
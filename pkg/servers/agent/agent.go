@@ -3,17 +3,21 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"slices"
+	"strings"
 
 	"github.com/nanobot-ai/nanobot/pkg/agents"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/sampling"
+	"github.com/nanobot-ai/nanobot/pkg/session"
 	"github.com/nanobot-ai/nanobot/pkg/sessiondata"
 	"github.com/nanobot-ai/nanobot/pkg/tools"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/version"
+	"gorm.io/gorm"
 )
 
 type Server struct {
@@ -197,6 +201,37 @@ func (s *Server) readPendingElicitation(ctx context.Context) ([]mcp.ResourceCont
 	}, nil
 }
 
+// readRun returns the status and output of an agent run of the session.
+func (s *Server) readRun(ctx context.Context, runID string) ([]mcp.ResourceContent, error) {
+	var (
+		manager     session.Manager
+		chatSession = mcp.SessionFromContext(ctx).Root()
+	)
+	if !chatSession.Get(session.ManagerSessionKey, &manager) || manager.DB == nil {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("agent runs require a session store")
+	}
+
+	run, err := manager.DB.GetAgentRun(ctx, chatSession.ID(), runID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("run %s not found", runID)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get run %s: %w", runID, err)
+	}
+
+	data, err := json.Marshal(run.Result())
+	if err != nil {
+		return nil, err
+	}
+
+	return []mcp.ResourceContent{
+		{
+			URI:      fmt.Sprintf(types.RunURI, runID),
+			MIMEType: types.RunMimeType,
+			Text:     new(string(data)),
+		},
+	}, nil
+}
+
 func (s *Server) promptGet(ctx context.Context, _ mcp.Message, payload mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
 	c := types.ConfigFromContext(ctx)
 	agent := c.Agents[s.agentName]
@@ -264,6 +299,16 @@ func (s *Server) resourcesRead(ctx context.Context, _ mcp.Message, request mcp.R
 		}, nil
 	}
 
+	if runID, ok := strings.CutPrefix(request.URI, types.RunURIPrefix); ok {
+		contents, err = s.readRun(ctx, runID)
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.ReadResourceResult{
+			Contents: contents,
+		}, nil
+	}
+
 	ctx, err = s.withConfig(ctx)
 	if err != nil {
 		return nil, err
@@ -299,6 +344,13 @@ func (s *Server) resourcesTemplatesList(ctx context.Context, _ mcp.Message, _ mc
 		result.ResourceTemplates = append(result.ResourceTemplates, resource.Target.ResourceTemplate)
 	}
 
+	result.ResourceTemplates = append(result.ResourceTemplates, mcp.ResourceTemplate{
+		URITemplate: types.RunURIPrefix + "{runId}",
+		Name:        "agent-run",
+		Description: "The status and output of an agent run, such as a run started in the background.",
+		MimeType:    types.RunMimeType,
+	})
+
 	return result, nil
 }
 
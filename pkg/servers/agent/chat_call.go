@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
 	"github.com/nanobot-ai/nanobot/pkg/tools"
 	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
 )
 
 const progressSessionKey = "progress"
@@ -197,6 +200,17 @@ func (c chatCall) Invoke(ctx context.Context, msg mcp.Message, payload mcp.CallT
 		}
 	}
 
+	resumeRunID, _ := msg.Meta()[types.ResumeRunMetaKey].(string)
+	if resumeRunID != "" {
+		if err := claimRun(ctx, resumeRunID); err != nil {
+			return nil, err
+		}
+	}
+
+	if background := msg.Meta()[types.BackgroundMetaKey]; background == "true" || background == true {
+		return c.startBackground(ctx, msg, payload, resumeRunID)
+	}
+
 	async := msg.Meta()[types.AsyncMetaKey]
 	if (async == "true" || async == true) && msg.ProgressToken() != nil {
 		nctx := types.NanobotContext(ctx)
@@ -224,6 +238,116 @@ func (c chatCall) Invoke(ctx context.Context, msg mcp.Message, payload mcp.CallT
 	return c.chatInvoke(ctx, msg, payload)
 }
 
+// claimRun marks an interrupted or failed run as running before the call that resumes it starts, so
+// that a run is resumed only once and async calls fail right away if it can't be.
+func claimRun(ctx context.Context, runID string) error {
+	var (
+		chatSession = mcp.SessionFromContext(ctx).Root()
+		manager     session.Manager
+	)
+	if !chatSession.Get(session.ManagerSessionKey, &manager) || manager.DB == nil {
+		return mcp.ErrRPCInvalidRequest.WithMessage("runs can't be resumed without a session store")
+	}
+
+	claimed, err := manager.DB.ClaimAgentRun(ctx, chatSession.ID(), runID, manager.ReplicaID(), manager.RunOwner())
+	if err != nil {
+		return fmt.Errorf("failed to resume run %s: %w", runID, err)
+	} else if claimed {
+		return nil
+	}
+
+	run, err := manager.DB.GetAgentRun(ctx, chatSession.ID(), runID)
+	if err != nil {
+		return mcp.ErrRPCInvalidParams.WithMessage("run %s not found: %v", runID, err)
+	}
+	return mcp.ErrRPCInvalidRequest.WithMessage("run %s is %s, only interrupted and failed runs can be resumed", runID, run.Status)
+}
+
+// startBackground starts the chat call as a background agent run and returns its ID. The run is
+// tracked with the run resource, and canceled with the run ID as the request ID. A resumed run
+// continues in the background under its own ID, it was claimed with claimRun.
+func (c chatCall) startBackground(ctx context.Context, msg mcp.Message, payload mcp.CallToolRequest, resumeRunID string) (*mcp.CallToolResult, error) {
+	var (
+		nctx        = types.NanobotContext(ctx)
+		chatSession = mcp.SessionFromContext(ctx)
+		manager     session.Manager
+	)
+	if !chatSession.Root().Get(session.ManagerSessionKey, &manager) || manager.DB == nil {
+		return nil, mcp.ErrRPCInvalidRequest.WithMessage("background runs require a session store")
+	}
+
+	run := session.AgentRun{
+		RunID:      uuid.String(),
+		SessionID:  chatSession.Root().ID(),
+		Agent:      c.s.agentName,
+		Status:     session.AgentRunRunning,
+		Background: true,
+		ReplicaID:  manager.ReplicaID(),
+		Owner:      manager.RunOwner(),
+	}
+	if resumeRunID != "" {
+		resumed, err := manager.DB.GetAgentRun(ctx, run.SessionID, resumeRunID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get run %s: %w", resumeRunID, err)
+		}
+		run = *resumed
+		run.Background = true
+	}
+	// Record the run before starting it, so it can be polled as soon as its ID is returned.
+	if err := manager.DB.SaveAgentRun(ctx, &run); err != nil {
+		return nil, fmt.Errorf("failed to record background run: %w", err)
+	}
+
+	if msg.ProgressToken() == nil {
+		if err := msg.SetProgressToken(run.RunID); err != nil {
+			return nil, fmt.Errorf("failed to set progress token: %w", err)
+		}
+	}
+
+	runCtx := mcp.WithRequestID(chatSession.Context(), run.RunID)
+	runCtx = types.WithRunID(types.WithNanobotContext(runCtx, nctx), run.RunID)
+	chatSession.Go(runCtx, msg, func(ctx context.Context, m mcp.Message) {
+		if _, err := c.chatInvoke(ctx, m, payload); err != nil {
+			failBackground(context.WithoutCancel(ctx), manager.DB, run, err)
+		}
+	})
+
+	uri := fmt.Sprintf(types.RunURI, run.RunID)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("The agent run %s was started in the background. You can track its status and result in the resource %s, "+
+					"and cancel it with the run ID as the request ID.", run.RunID, uri),
+			},
+			{
+				Type:     "resource_link",
+				URI:      uri,
+				MIMEType: types.RunMimeType,
+			},
+		},
+		StructuredContent: map[string]any{
+			"runId":  run.RunID,
+			"status": run.Status,
+			"uri":    uri,
+		},
+	}, nil
+}
+
+// failBackground records the error of a background run that failed before the agent run started,
+// a run that started records its own result.
+func failBackground(ctx context.Context, store *session.Store, run session.AgentRun, err error) {
+	current, getErr := store.GetAgentRun(ctx, run.SessionID, run.RunID)
+	if getErr != nil || current.Status != session.AgentRunRunning {
+		return
+	}
+	current.Status = session.AgentRunFailed
+	current.Error = err.Error()
+	if err := store.SaveAgentRun(ctx, current); err != nil {
+		slog.Error("failed to record background run failure", "run_id", run.RunID, "error", err)
+	}
+}
+
 func (c chatCall) chatInvoke(ctx context.Context, msg mcp.Message, payload mcp.CallToolRequest) (_ *mcp.CallToolResult, retErr error) {
 	session := mcp.SessionFromContext(ctx).Parent
 
@@ -238,6 +362,10 @@ func (c chatCall) chatInvoke(ctx context.Context, msg mcp.Message, payload mcp.C
 		ProgressToken: msg.ProgressToken(),
 	})
 
+	if runID, _ := msg.Meta()[types.ResumeRunMetaKey].(string); runID != "" {
+		ctx = types.WithResumeRun(ctx, runID)
+	}
+
 	result, err := c.s.runtime.Call(ctx, c.s.agentName, c.s.agentName, payload.Arguments, tools.CallOptions{
 		ProgressToken: msg.ProgressToken(),
 		LogData: map[string]any{
@@ -272,28 +400,12 @@ func (c chatCall) chatInvoke(ctx context.Context, msg mcp.Message, payload mcp.C
 }
 
 func GetMessages(ctx context.Context) ([]types.Message, error) {
-	var (
-		run         types.Execution
-		allMessages []types.Message
-	)
+	var run types.Execution
 
 	session := mcp.SessionFromContext(ctx)
 	session.Get(types.PreviousExecutionKey, &run)
 
-	// Prepend archived pre-compaction messages
-	if len(run.CompactedMessages) > 0 {
-		allMessages = append(allMessages, run.CompactedMessages...)
-	}
-
-	// Add current input (includes compaction summary in its natural position)
-	if run.PopulatedRequest != nil {
-		allMessages = append(allMessages, run.PopulatedRequest.Input...)
-	}
-	if run.Response != nil {
-		allMessages = append(allMessages, run.Response.Output)
-	}
-
-	return types.ConsolidateTools(allMessages), nil
+	return types.ConsolidateTools(run.Messages()), nil
 }
 
 type progressPayload struct {
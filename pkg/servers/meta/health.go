@@ -0,0 +1,44 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+const healthURI = "health:///servers"
+
+// healthResource describes the resource reporting the state of the MCP servers.
+func (s *Server) healthResource() mcp.Resource {
+	return mcp.Resource{
+		URI:         healthURI,
+		Name:        "servers",
+		Description: "State of the MCP server processes: running, restarting, failed or stopped, with their last stderr lines",
+		MimeType:    "application/json",
+	}
+}
+
+// readHealthResource returns the state of the MCP servers as JSON.
+func (s *Server) readHealthResource() (*mcp.ReadResourceResult, error) {
+	health := []mcp.ServerHealth{}
+	if s.status != nil {
+		health = s.status.ServerHealth()
+	}
+
+	data, err := json.Marshal(health)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal server health: %w", err)
+	}
+
+	return &mcp.ReadResourceResult{
+		Contents: []mcp.ResourceContent{
+			{
+				URI:      healthURI,
+				Name:     "servers",
+				MIMEType: "application/json",
+				Text:     new(string(data)),
+			},
+		},
+	}, nil
+}
@@ -12,9 +12,15 @@ import (
 	"log/slog"
 )
 
+// ServerStatus reports the health of the MCP servers run by nanobot.
+type ServerStatus interface {
+	ServerHealth() []mcp.ServerHealth
+}
+
 type Server struct {
 	tools           mcp.ServerTools
 	data            *sessiondata.Data
+	status          ServerStatus
 	configDir       string
 	subscriptions   *fswatch.SubscriptionManager
 	workflowWatcher *fswatch.Watcher
@@ -24,9 +30,10 @@ type Server struct {
 	watcherInitErr  error
 }
 
-func NewServer(data *sessiondata.Data, configDir string) *Server {
+func NewServer(data *sessiondata.Data, status ServerStatus, configDir string) *Server {
 	s := &Server{
 		data:          data,
+		status:        status,
 		configDir:     configDir,
 		subscriptions: fswatch.NewSubscriptionManager(context.Background()),
 	}
@@ -34,6 +41,7 @@ func NewServer(data *sessiondata.Data, configDir string) *Server {
 	s.tools = mcp.NewServerTools(
 		mcp.NewServerTool("list_chats", "Returns all previous chat threads", s.listChats),
 		mcp.NewServerTool("update_chat", "Update fields of a give chat thread", s.updateChat),
+		mcp.NewServerTool("search_chats", "Search the messages of previous chat threads", s.searchChats),
 		mcp.NewServerTool("list_agents", "List available agents and their meta data", s.listAgents),
 	)
 
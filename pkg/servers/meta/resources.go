@@ -41,6 +41,9 @@ func (s *Server) resourcesList(ctx context.Context, _ mcp.Message, _ mcp.ListRes
 		resources = append(resources, skillResources...)
 	}
 
+	// Add the health of the MCP servers
+	resources = append(resources, s.healthResource())
+
 	// Add cross-session file resources
 	fileResources, err := s.listFileResourcesAllSessions(ctx)
 	if err != nil {
@@ -60,6 +63,8 @@ func (s *Server) resourcesRead(ctx context.Context, _ mcp.Message, request mcp.R
 		return s.readSkillResource(ctx, request.URI)
 	} else if strings.HasPrefix(request.URI, "file:///") {
 		return s.readFileResource(ctx, request.URI)
+	} else if request.URI == healthURI {
+		return s.readHealthResource()
 	}
 	return nil, mcp.ErrRPCInvalidParams.WithMessage("unsupported resource URI: %s", request.URI)
 }
@@ -9,8 +9,9 @@ import (
 )
 
 func (s *Server) updateChat(ctx context.Context, data struct {
-	ID    string `json:"chatId"`
-	Title string `json:"title"`
+	ID      string `json:"chatId"`
+	Title   string `json:"title"`
+	Starred *bool  `json:"starred,omitempty"`
 }) (*types.Chat, error) {
 	mcpSession := mcp.SessionFromContext(ctx)
 	manager, accountID, err := s.getManagerAndAccountID(mcpSession)
@@ -23,17 +24,25 @@ func (s *Server) updateChat(ctx context.Context, data struct {
 		return nil, err
 	}
 
-	if data.Title != "" && chatSession.Description != data.Title {
+	titleChanged := data.Title != "" && chatSession.Description != data.Title
+	starredChanged := data.Starred != nil && chatSession.Starred != *data.Starred
+	if titleChanged || starredChanged {
 		session, err := manager.DB.Get(ctx, data.ID)
 		if err != nil {
 			return nil, err
 		}
 
-		session.Description = data.Title
+		if titleChanged {
+			session.Description = data.Title
+		}
+		if starredChanged {
+			session.Starred = *data.Starred
+		}
 		if err := manager.DB.Update(ctx, session); err != nil {
 			return nil, err
 		}
-		chatSession.Description = data.Title
+		chatSession.Description = session.Description
+		chatSession.Starred = session.Starred
 	}
 
 	workflowURIs, err := manager.DB.ListWorkflowURIs(ctx, chatSession.SessionID)
@@ -100,6 +109,42 @@ func (s *Server) listChats(ctx context.Context, _ struct{}) (*types.ChatList, er
 	}, nil
 }
 
+func (s *Server) searchChats(ctx context.Context, data struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}) (*types.ChatSearchResults, error) {
+	if data.Query == "" {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("query is required")
+	}
+
+	mcpSession := mcp.SessionFromContext(ctx)
+	manager, accountID, err := s.getManagerAndAccountID(mcpSession)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := manager.DB.Search(ctx, accountID, data.Query, data.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	chatResults := make([]types.ChatSearchResult, 0, len(results))
+	for _, r := range results {
+		chatResults = append(chatResults, types.ChatSearchResult{
+			ChatID:    r.SessionID,
+			Title:     r.Title,
+			MessageID: r.MessageID,
+			Role:      r.Role,
+			Created:   r.Created,
+			Snippet:   r.Snippet,
+		})
+	}
+
+	return &types.ChatSearchResults{
+		Results: chatResults,
+	}, nil
+}
+
 func chatFromSession(s *session.Session, currentAccountID string, workflowURIs []string) types.Chat {
 	return types.Chat{
 		ID:           s.SessionID,
@@ -108,5 +153,6 @@ func chatFromSession(s *session.Session, currentAccountID string, workflowURIs [
 		ReadOnly:     s.AccountID != currentAccountID,
 		TaskURI:      s.TaskURI,
 		WorkflowURIs: workflowURIs,
+		Starred:      s.Starred,
 	}
 }
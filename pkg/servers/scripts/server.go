@@ -0,0 +1,75 @@
+package scripts
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/version"
+)
+
+// Runner runs the scripts of the config.
+type Runner interface {
+	RunScript(ctx context.Context, name string, args map[string]any) (*mcp.CallToolResult, error)
+}
+
+// Server publishes the scripts of the config as tools.
+type Server struct {
+	runner Runner
+}
+
+func NewServer(runner Runner) *Server {
+	return &Server{
+		runner: runner,
+	}
+}
+
+func (s *Server) OnMessage(ctx context.Context, msg mcp.Message) {
+	switch msg.Method {
+	case "initialize":
+		mcp.Invoke(ctx, msg, s.initialize)
+	case "notifications/initialized":
+		// nothing to do
+	case "notifications/cancelled":
+		mcp.HandleCancelled(ctx, msg)
+	case "tools/list":
+		mcp.Invoke(ctx, msg, s.toolsList)
+	case "tools/call":
+		mcp.Invoke(ctx, msg, s.toolsCall)
+	default:
+		msg.SendError(ctx, mcp.ErrRPCMethodNotFound.WithMessage("%v", msg.Method))
+	}
+}
+
+func (s *Server) initialize(_ context.Context, _ mcp.Message, params mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+	return &mcp.InitializeResult{
+		ProtocolVersion: params.ProtocolVersion,
+		Capabilities: mcp.ServerCapabilities{
+			Tools: &mcp.ToolsServerCapability{},
+		},
+		ServerInfo: mcp.ServerInfo{
+			Name:    version.Name,
+			Version: version.Get().String(),
+		},
+	}, nil
+}
+
+func (s *Server) toolsList(ctx context.Context, _ mcp.Message, _ mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	scripts := types.ConfigFromContext(ctx).Scripts
+	result := &mcp.ListToolsResult{
+		Tools: make([]mcp.Tool, 0, len(scripts)),
+	}
+	for _, name := range slices.Sorted(maps.Keys(scripts)) {
+		result.Tools = append(result.Tools, scripts[name].ToTool(name))
+	}
+	return result, nil
+}
+
+func (s *Server) toolsCall(ctx context.Context, _ mcp.Message, payload mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if _, ok := types.ConfigFromContext(ctx).Scripts[payload.Name]; !ok {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("script %s not found", payload.Name)
+	}
+	return s.runner.RunScript(ctx, payload.Name, payload.Arguments)
+}
@@ -0,0 +1,122 @@
+package tasks
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/complete"
+)
+
+const leaderLeaseName = "tasks/leader"
+
+// Options configure how the task server coordinates with other replicas sharing the same database.
+type Options struct {
+	// ReplicaID enables leader election when set. Only the replica holding the leader lease runs
+	// scheduled tasks.
+	ReplicaID string
+	// LeaseTTL is how long the leader lease stays valid without being renewed.
+	LeaseTTL time.Duration
+}
+
+func (o Options) Merge(other Options) (result Options) {
+	result.ReplicaID = complete.Last(o.ReplicaID, other.ReplicaID)
+	result.LeaseTTL = complete.Last(o.LeaseTTL, other.LeaseTTL)
+	return
+}
+
+func (o Options) Complete() Options {
+	if o.LeaseTTL == 0 {
+		o.LeaseTTL = 30 * time.Second
+	}
+	return o
+}
+
+// electLeader competes for the leader lease until the server is shut down. While this replica is
+// the leader it periodically syncs the scheduled jobs with the database so that tasks created or
+// changed on other replicas are picked up.
+func (s *Server) electLeader() {
+	ticker := time.NewTicker(s.opt.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		_, ok, err := s.db.AcquireLease(s.ctx, leaderLeaseName, s.opt.ReplicaID, "", s.opt.LeaseTTL)
+		if err != nil {
+			slog.Error("scheduled task: failed to acquire leader lease", "error", err)
+			ok = false
+		}
+
+		s.setLeader(ok)
+		if ok {
+			if err := s.syncTasks(s.ctx); err != nil {
+				slog.Error("scheduled task: failed to sync tasks", "error", err)
+			}
+		}
+
+		select {
+		case <-s.ctx.Done():
+			s.setLeader(false)
+			// Use a fresh context, ours is already canceled.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.db.ReleaseLease(ctx, leaderLeaseName, s.opt.ReplicaID); err != nil {
+				slog.Error("scheduled task: failed to release leader lease", "error", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) setLeader(leader bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.leader == leader {
+		return
+	}
+	s.leader = leader
+
+	if leader {
+		slog.Info("scheduled task: this replica is now the leader", "replica", s.opt.ReplicaID)
+		return
+	}
+
+	slog.Info("scheduled task: this replica is no longer the leader", "replica", s.opt.ReplicaID)
+	for taskURI, j := range s.jobs {
+		j.cancel()
+		delete(s.jobs, taskURI)
+	}
+}
+
+// syncTasks schedules all enabled tasks in the database, reschedules the ones that changed since
+// they were scheduled and stops the ones that were disabled or deleted.
+func (s *Server) syncTasks(ctx context.Context) error {
+	tasks, err := s.db.ListScheduledTasks(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enabled := make(map[string]struct{}, len(tasks))
+	for _, task := range tasks {
+		if !task.Enabled {
+			continue
+		}
+		enabled[task.TaskURI] = struct{}{}
+		if j, ok := s.jobs[task.TaskURI]; !ok || !j.updatedAt.Equal(task.UpdatedAt) {
+			s.scheduleTaskLocked(task.TaskURI, task.UpdatedAt)
+		}
+	}
+
+	for taskURI, j := range s.jobs {
+		if _, ok := enabled[taskURI]; !ok {
+			j.cancel()
+			delete(s.jobs, taskURI)
+		}
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/fswatch"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/session"
@@ -68,20 +69,24 @@ type Server struct {
 	wg          sync.WaitGroup
 	mu          sync.Mutex
 	jobs        map[string]*job
+	opt         Options
+	leader      bool
 }
 
 type job struct {
 	reschedule chan struct{}
 	cancel     context.CancelFunc
+	updatedAt  time.Time
 }
 
 // NewServer creates the task server, sets the DB, and loads persisted tasks.
-func NewServer(ctx context.Context, db *session.Store, loopbackURL string) (*Server, error) {
+func NewServer(ctx context.Context, db *session.Store, loopbackURL string, opts ...Options) (*Server, error) {
 	s := &Server{
 		SubscriptionManager: fswatch.NewSubscriptionManager(ctx),
 		loopbackURL:         loopbackURL,
 		jobs:                make(map[string]*job),
 		db:                  db,
+		opt:                 complete.Complete(opts...),
 	}
 	s.ctx, s.cancel = context.WithCancel(ctx)
 	s.tools = mcp.NewServerTools(
@@ -92,15 +97,13 @@ func NewServer(ctx context.Context, db *session.Store, loopbackURL string) (*Ser
 		mcp.NewServerTool("startScheduledTask", "Start a scheduled task now", s.startTask),
 	)
 
-	tasks, err := db.ListScheduledTasks(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, task := range tasks {
-		if task.Enabled {
-			s.scheduleTask(task.TaskURI)
+	if s.opt.ReplicaID == "" {
+		s.leader = true
+		if err := s.syncTasks(ctx); err != nil {
+			return nil, err
 		}
+	} else {
+		s.wg.Go(s.electLeader)
 	}
 
 	context.AfterFunc(ctx, func() {
@@ -205,7 +208,7 @@ func (s *Server) createTask(ctx context.Context, params struct {
 	}
 
 	if task.Enabled {
-		s.scheduleTask(taskURI)
+		s.scheduleTask(taskURI, task.UpdatedAt)
 	}
 	s.SendListChangedNotification()
 
@@ -276,7 +279,7 @@ func (s *Server) updateTask(ctx context.Context, params struct {
 	}
 
 	if task.Enabled {
-		s.scheduleTask(task.TaskURI)
+		s.scheduleTask(task.TaskURI, task.UpdatedAt)
 	} else {
 		s.cancelTask(task.TaskURI)
 	}
@@ -410,11 +413,21 @@ func (s *Server) resourcesUnsubscribe(ctx context.Context, _ mcp.Message, req mc
 }
 
 // scheduleTask reschedules an existing goroutine to re-read from DB, or spawns a new one.
-func (s *Server) scheduleTask(taskURI string) {
+// Only the leader runs scheduled tasks, other replicas pick up changes when they become leader.
+// updatedAt is when the task was last stored, the leader reschedules jobs that don't match it.
+func (s *Server) scheduleTask(taskURI string, updatedAt time.Time) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.scheduleTaskLocked(taskURI, updatedAt)
+}
+
+func (s *Server) scheduleTaskLocked(taskURI string, updatedAt time.Time) {
+	if !s.leader {
+		return
+	}
 
 	if j, ok := s.jobs[taskURI]; ok {
+		j.updatedAt = updatedAt
 		select {
 		case j.reschedule <- struct{}{}:
 		default:
@@ -424,7 +437,7 @@ func (s *Server) scheduleTask(taskURI string) {
 
 	ctx, cancel := context.WithCancel(s.ctx)
 	reschedule := make(chan struct{}, 1)
-	s.jobs[taskURI] = &job{reschedule: reschedule, cancel: cancel}
+	s.jobs[taskURI] = &job{reschedule: reschedule, cancel: cancel, updatedAt: updatedAt}
 	s.wg.Go(func() {
 		defer func() {
 			s.mu.Lock()
@@ -440,6 +453,13 @@ func (s *Server) scheduleTask(taskURI string) {
 				return
 			}
 
+			// Runs update the stored task too, keep the job in sync so that it isn't rescheduled.
+			s.mu.Lock()
+			if j, ok := s.jobs[taskURI]; ok && j.reschedule == reschedule {
+				j.updatedAt = task.UpdatedAt
+			}
+			s.mu.Unlock()
+
 			spec, loc, err := parseSchedule(task.Schedule, task.Timezone)
 			if err != nil {
 				return
@@ -467,10 +487,18 @@ func (s *Server) scheduleTask(taskURI string) {
 				return
 			}
 
+			// Claim the run so that it fires exactly once, even if leadership moved to this
+			// replica while the previous leader was already running it.
+			dueAt := next.UTC()
 			now := time.Now().UTC()
 			next = nextRunAt(spec, loc, task.ExpiresAt, now)
-			if err := s.db.RecordScheduledTaskRun(ctx, taskURI, now, next); err != nil {
+			claimed, err := s.db.ClaimScheduledTaskRun(ctx, taskURI, dueAt, now, next)
+			if err != nil {
 				slog.Error("scheduled task: failed to record run", "task_uri", taskURI, "error", err)
+				continue
+			} else if !claimed {
+				slog.Debug("scheduled task: run already claimed by another replica", "task_uri", taskURI, "due_at", dueAt)
+				continue
 			}
 
 			s.SendResourceUpdatedNotification(taskURI)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/session"
@@ -191,3 +192,80 @@ func TestContiguousTaskURIsAfterDelete(t *testing.T) {
 		t.Fatalf("third.URI = %q, want %q", third.URI, "task:///daily-summary-3")
 	}
 }
+
+func TestLeaderElection(t *testing.T) {
+	store, err := session.NewStoreFromDSN(fmt.Sprintf("sqlite:file:%s?mode=memory&cache=shared",
+		strings.NewReplacer("/", "-", " ", "-").Replace(t.Name())))
+	if err != nil {
+		t.Fatalf("failed to create session store: %v", err)
+	}
+
+	var servers []*Server
+	for _, replica := range []string{"replica-1", "replica-2"} {
+		srv, err := NewServer(t.Context(), store, "", Options{
+			ReplicaID: replica,
+			LeaseTTL:  30 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("failed to start %s: %v", replica, err)
+		}
+		servers = append(servers, srv)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		var leaders int
+		for _, srv := range servers {
+			srv.mu.Lock()
+			if srv.leader {
+				leaders++
+			}
+			srv.mu.Unlock()
+		}
+		if leaders == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected exactly one leader, got %d", leaders)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScheduledTaskKeepsStoredUpdateTime(t *testing.T) {
+	srv := testServer(t)
+	ctx := context.Background()
+
+	srv.mu.Lock()
+	srv.leader = true
+	srv.mu.Unlock()
+
+	if _, err := srv.createTask(ctx, struct {
+		Name       string `json:"name"`
+		Prompt     string `json:"prompt"`
+		Schedule   string `json:"schedule"`
+		Timezone   string `json:"timezone"`
+		Expiration string `json:"expiration,omitempty"`
+		Enabled    bool   `json:"enabled,omitempty"`
+	}{
+		Name: "Daily Summary", Prompt: "Summarize.", Schedule: "0 9 * * *", Timezone: "UTC", Enabled: true,
+	}); err != nil {
+		t.Fatalf("createTask: %v", err)
+	}
+
+	stored, err := srv.db.GetScheduledTask(ctx, "task:///daily-summary")
+	if err != nil {
+		t.Fatalf("GetScheduledTask: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	j, ok := srv.jobs["task:///daily-summary"]
+	if !ok {
+		t.Fatal("expected the task to be scheduled")
+	}
+	// syncTasks reschedules jobs whose update time doesn't match the stored task.
+	if !j.updatedAt.Equal(stored.UpdatedAt) {
+		t.Fatalf("job updatedAt = %v, want %v", j.updatedAt, stored.UpdatedAt)
+	}
+}
@@ -0,0 +1,429 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/skillformat"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+)
+
+const (
+	runStatusRunning   = "running"
+	runStatusFailed    = "failed"
+	runStatusCompleted = "completed"
+)
+
+// Caller calls the tools and agents of the steps of a workflow.
+type Caller interface {
+	Call(ctx context.Context, server, tool string, args any, opts ...tools.CallOptions) (*types.CallResult, error)
+}
+
+type runWorkflowRequest struct {
+	URI    string         `json:"uri" jsonschema:"The URI of the workflow to run"`
+	Inputs map[string]any `json:"inputs,omitempty" jsonschema:"The inputs of the workflow, available to the steps as inputs"`
+	RunID  string         `json:"runId,omitempty" jsonschema:"The ID of a failed or abandoned run to resume from the step that did not complete"`
+}
+
+type runWorkflowResult struct {
+	RunID  string         `json:"runId"`
+	Status string         `json:"status"`
+	Output any            `json:"output,omitempty"`
+	Steps  map[string]any `json:"steps,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// runWorkflowTool needs the message to send progress notifications, so it
+// implements mcp.ServerTool instead of using mcp.NewServerTool.
+type runWorkflowTool struct {
+	s    *ToolsServer
+	tool mcp.Tool
+}
+
+func newRunWorkflowTool(s *ToolsServer) *runWorkflowTool {
+	inSchema, err := jsonschema.For[runWorkflowRequest](nil)
+	if err != nil {
+		panic(err)
+	}
+	inputData, err := json.Marshal(inSchema)
+	if err != nil {
+		panic(err)
+	}
+	return &runWorkflowTool{
+		s: s,
+		tool: mcp.Tool{
+			Name:        "runWorkflow",
+			Description: "Run the steps of a workflow that defines steps in its frontmatter, or resume a failed or abandoned run",
+			InputSchema: inputData,
+		},
+	}
+}
+
+func (t *runWorkflowTool) Definition() mcp.Tool {
+	return t.tool
+}
+
+func (t *runWorkflowTool) Invoke(ctx context.Context, msg mcp.Message, call mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var request runWorkflowRequest
+	if err := mcp.JSONCoerce(call.Arguments, &request); err != nil {
+		return nil, err
+	}
+	return t.s.runWorkflow(ctx, msg, request)
+}
+
+// readWorkflowSteps returns the steps of the frontmatter of a workflow.
+func readWorkflowSteps(workflowName string) ([]skillformat.Step, error) {
+	workflowPath := filepath.Join(".", skillformat.WorkflowsDir, workflowName, skillformat.SkillMainFile)
+	content, err := os.ReadFile(workflowPath)
+	if err != nil {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("workflow not found: %s", workflowName)
+	}
+	fm, _, err := skillformat.ParseFrontmatter(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workflow %s: %w", workflowName, err)
+	}
+	if len(fm.Steps) == 0 {
+		return nil, mcp.ErrRPCInvalidParams.WithMessage("workflow %s has no steps, follow its instructions instead", workflowName)
+	}
+	if err := skillformat.ValidateSteps(fm.Steps); err != nil {
+		return nil, fmt.Errorf("invalid steps in workflow %s: %w", workflowName, err)
+	}
+	return fm.Steps, nil
+}
+
+func (s *ToolsServer) runWorkflow(ctx context.Context, msg mcp.Message, request runWorkflowRequest) (*mcp.CallToolResult, error) {
+	workflowName, err := parseWorkflowURI(request.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse workflow URI: %w", err)
+	}
+
+	steps, err := readWorkflowSteps(workflowName)
+	if err != nil {
+		return nil, err
+	}
+
+	workflowSession := mcp.SessionFromContext(ctx).Root()
+
+	// Without a session store the run still works, it just can't be resumed.
+	var manager session.Manager
+	checkpoints := workflowSession.Get(session.ManagerSessionKey, &manager) && manager.DB != nil
+
+	checkpoint := &session.WorkflowCheckpoint{
+		RunID:       uuid.String(),
+		WorkflowURI: request.URI,
+		Status:      runStatusRunning,
+		State: session.WorkflowState{
+			Inputs: request.Inputs,
+		},
+	}
+	if workflowSession != nil {
+		checkpoint.SessionID = workflowSession.ID()
+	}
+	if checkpoints {
+		checkpoint.Owner = manager.RunOwner()
+	}
+
+	if request.RunID != "" {
+		if !checkpoints {
+			return nil, mcp.ErrRPCInvalidRequest.WithMessage("runs can't be resumed without a session store")
+		}
+		checkpoint, err = manager.DB.GetWorkflowCheckpoint(ctx, workflowSession.ID(), request.RunID)
+		if err != nil {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("run %s not found: %v", request.RunID, err)
+		}
+		if checkpoint.WorkflowURI != request.URI {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("run %s is a run of %s", request.RunID, checkpoint.WorkflowURI)
+		}
+		switch checkpoint.Status {
+		case runStatusFailed:
+		case runStatusRunning:
+			// A run that is still running was abandoned if the process running it is gone, like when
+			// it exited in the middle of a step.
+			alive, err := manager.RunOwnerAlive(ctx, checkpoint.Owner)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resume run %s: %w", request.RunID, err)
+			} else if alive {
+				return nil, mcp.ErrRPCInvalidParams.WithMessage("run %s is still running", request.RunID)
+			}
+		default:
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("run %s is %s, only failed and abandoned runs can be resumed", request.RunID, checkpoint.Status)
+		}
+		claimed, err := manager.DB.ClaimWorkflowCheckpoint(ctx, checkpoint, runStatusRunning, manager.RunOwner())
+		if err != nil {
+			return nil, fmt.Errorf("failed to resume run %s: %w", request.RunID, err)
+		} else if !claimed {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("run %s is already being resumed", request.RunID)
+		}
+		checkpoint.State.Error = ""
+	}
+	if checkpoint.State.Steps == nil {
+		checkpoint.State.Steps = map[string]any{}
+	}
+
+	// Checkpoints are saved even if the call is canceled, so that the run records the step it
+	// failed at and can be resumed.
+	save := func() error {
+		if !checkpoints {
+			return nil
+		}
+		if err := manager.DB.SaveWorkflowCheckpoint(context.WithoutCancel(ctx), checkpoint); err != nil {
+			return fmt.Errorf("failed to save workflow checkpoint: %w", err)
+		}
+		return nil
+	}
+
+	if checkpoints {
+		if _, err := s.recordWorkflowRun(ctx, struct {
+			URI string `json:"uri"`
+		}{URI: request.URI}); err != nil {
+			return nil, err
+		}
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+
+	e := &engine{
+		caller:  s.caller,
+		session: workflowSession,
+		env:     workflowSession.GetEnvMap(),
+	}
+
+	for checkpoint.State.Next < len(steps) {
+		step := steps[checkpoint.State.Next]
+		sendProgress(ctx, msg, checkpoint.State.Next, len(steps), fmt.Sprintf("Running step %s", step.ID))
+
+		result, err := e.runStep(ctx, step, checkpoint.State)
+		if err != nil {
+			checkpoint.Status = runStatusFailed
+			checkpoint.State.Error = fmt.Sprintf("step %s failed: %v", step.ID, err)
+			if err := save(); err != nil {
+				return nil, err
+			}
+			return runResult(checkpoint, steps), nil
+		}
+
+		checkpoint.State.Steps[step.ID] = result
+		checkpoint.State.Next++
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+
+	checkpoint.Status = runStatusCompleted
+	if err := save(); err != nil {
+		return nil, err
+	}
+	sendProgress(ctx, msg, len(steps), len(steps), "Completed")
+
+	return runResult(checkpoint, steps), nil
+}
+
+func runResult(checkpoint *session.WorkflowCheckpoint, steps []skillformat.Step) *mcp.CallToolResult {
+	result := runWorkflowResult{
+		RunID:  checkpoint.RunID,
+		Status: checkpoint.Status,
+		Steps:  checkpoint.State.Steps,
+		Error:  checkpoint.State.Error,
+	}
+	if checkpoint.Status == runStatusCompleted {
+		last, _ := checkpoint.State.Steps[steps[len(steps)-1].ID].(map[string]any)
+		result.Output = last["output"]
+	}
+
+	var structured map[string]any
+	_ = mcp.JSONCoerce(result, &structured)
+	data, _ := json.Marshal(result)
+
+	text := string(data)
+	if result.Error != "" {
+		text = fmt.Sprintf("Workflow run %s failed: %s. Call runWorkflow with the runId %s to resume it from the failed step.",
+			result.RunID, result.Error, result.RunID)
+	}
+
+	return &mcp.CallToolResult{
+		IsError: result.Status == runStatusFailed,
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: text,
+			},
+		},
+		StructuredContent: structured,
+	}
+}
+
+func sendProgress(ctx context.Context, msg mcp.Message, progress, total int, message string) {
+	progressToken := msg.ProgressToken()
+	if progressToken == nil || msg.Session == nil {
+		return
+	}
+	totalNumber := json.Number(fmt.Sprint(total))
+	_ = msg.Session.SendPayload(ctx, "notifications/progress", mcp.NotificationProgressRequest{
+		ProgressToken: progressToken,
+		Progress:      json.Number(fmt.Sprint(progress)),
+		Total:         &totalNumber,
+		Message:       message,
+	})
+}
+
+// engine runs the steps of a workflow. The data of the expressions of a step
+// is the inputs of the run and the results of the previous steps.
+type engine struct {
+	caller  Caller
+	session *mcp.Session
+	env     map[string]string
+}
+
+// runStep returns the result of a step, which is {"output": ...} or
+// {"skipped": true} if its condition is false.
+func (e *engine) runStep(ctx context.Context, step skillformat.Step, state session.WorkflowState) (map[string]any, error) {
+	data := map[string]any{
+		"inputs": state.Inputs,
+		"steps":  state.Steps,
+	}
+
+	if step.If != "" {
+		ok, err := expr.EvalBool(ctx, e.env, data, step.If)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate if: %w", err)
+		}
+		if !ok {
+			return map[string]any{"skipped": true}, nil
+		}
+	}
+
+	if step.Foreach == "" {
+		output, err := e.run(ctx, step, data)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"output": output}, nil
+	}
+
+	items, err := expr.EvalList(ctx, e.env, data, step.Foreach)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate foreach: %w", err)
+	}
+
+	outputs := make([]any, 0, len(items))
+	for i, item := range items {
+		itemData := maps.Clone(data)
+		itemData["item"] = item
+		itemData["index"] = i
+		output, err := e.run(ctx, step, itemData)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		outputs = append(outputs, output)
+	}
+	return map[string]any{"output": outputs}, nil
+}
+
+func (e *engine) run(ctx context.Context, step skillformat.Step, data map[string]any) (any, error) {
+	switch step.Kind() {
+	case "tool":
+		args, err := expr.EvalObject(ctx, e.env, data, step.Args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate args: %w", err)
+		}
+		server, tool, _ := strings.Cut(step.Tool, "/")
+		return e.call(ctx, server, tool, args)
+	case "agent":
+		prompt, err := expr.EvalString(ctx, e.env, data, step.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate prompt: %w", err)
+		}
+		return e.call(ctx, step.Agent, "", map[string]any{
+			"prompt": prompt,
+		})
+	case "script":
+		output, err := expr.RunScript(ctx, data, step.Script)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run script: %w", err)
+		}
+		return output, nil
+	case "input":
+		return e.input(ctx, step.Input, data)
+	}
+	return nil, fmt.Errorf("step %s has nothing to run", step.ID)
+}
+
+// call calls a tool or an agent. The output is the structured content of the
+// result, or its text.
+func (e *engine) call(ctx context.Context, server, tool string, args any) (any, error) {
+	if e.caller == nil {
+		return nil, fmt.Errorf("tools can't be called")
+	}
+
+	result, err := e.caller.Call(ctx, server, tool, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var texts []string
+	for _, content := range result.Content {
+		if content.Type == "text" {
+			texts = append(texts, content.Text)
+		}
+	}
+	text := strings.Join(texts, "\n")
+
+	if result.IsError {
+		return nil, fmt.Errorf("%s", text)
+	}
+	if result.StructuredContent != nil {
+		return result.StructuredContent, nil
+	}
+	return text, nil
+}
+
+// input asks the user to answer the fields of an input step.
+func (e *engine) input(ctx context.Context, input *skillformat.StepInput, data map[string]any) (any, error) {
+	if e.session == nil {
+		return nil, fmt.Errorf("there is no user to ask for input")
+	}
+
+	message, err := expr.EvalString(ctx, e.env, data, input.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate message: %w", err)
+	}
+
+	properties := make(map[string]mcp.PrimitiveProperty, len(input.Fields))
+	for name, description := range input.Fields {
+		properties[name] = mcp.PrimitiveProperty{
+			Type:        "string",
+			Title:       name,
+			Description: description,
+		}
+	}
+
+	var result mcp.ElicitResult
+	if err := e.session.Exchange(ctx, "elicitation/create", mcp.ElicitRequest{
+		Message: message,
+		RequestedSchema: mcp.PrimitiveSchema{
+			Type:       "object",
+			Properties: properties,
+			Required:   slices.Sorted(maps.Keys(properties)),
+		},
+	}, &result); err != nil {
+		return nil, fmt.Errorf("failed to ask the user for input: %w", err)
+	}
+
+	if result.Action != "accept" {
+		return nil, fmt.Errorf("the user did not answer (%s)", result.Action)
+	}
+	return result.Content, nil
+}
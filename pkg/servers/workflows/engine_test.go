@@ -0,0 +1,173 @@
+package workflows
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/session"
+	"github.com/nanobot-ai/nanobot/pkg/skillformat"
+	"github.com/nanobot-ai/nanobot/pkg/tools"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+const stepsWorkflow = `---
+name: release-notes
+description: Write release notes
+steps:
+  - id: list
+    tool: git/log
+    args:
+      since: ${inputs.since}
+  - id: upper
+    foreach: ${steps.list.output.commits}
+    script: |
+      return item.toUpperCase() + " #" + index
+  - id: skipped
+    if: ${inputs.since == "never"}
+    script: return "not run"
+  - id: notes
+    agent: writer
+    prompt: "Write notes for ${steps.upper.output}"
+---
+# Release notes
+`
+
+type fakeCaller struct {
+	calls []string
+	fail  bool
+}
+
+func (f *fakeCaller) Call(_ context.Context, server, tool string, args any, _ ...tools.CallOptions) (*types.CallResult, error) {
+	f.calls = append(f.calls, server+"/"+tool)
+	if server == "git" {
+		if args.(map[string]any)["since"] != "v1" {
+			return nil, mcp.ErrRPCInvalidParams.WithMessage("unexpected args %v", args)
+		}
+		return &types.CallResult{
+			StructuredContent: map[string]any{"commits": []any{"fix a", "add b"}},
+		}, nil
+	}
+	if f.fail {
+		return &types.CallResult{
+			IsError: true,
+			Content: []mcp.Content{{Type: "text", Text: "model unavailable"}},
+		}, nil
+	}
+	return &types.CallResult{
+		Content: []mcp.Content{{Type: "text", Text: "notes for " + args.(map[string]any)["prompt"].(string)}},
+	}, nil
+}
+
+func TestRunWorkflow(t *testing.T) {
+	tempDir := t.TempDir()
+	restore := withWorkingDir(t, tempDir)
+	defer restore()
+
+	workflowDir := filepath.Join(tempDir, skillformat.WorkflowsDir, "release-notes")
+	if err := os.MkdirAll(workflowDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workflowDir, skillformat.SkillMainFile), []byte(stepsWorkflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := session.NewStoreFromDSN("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := session.NewManager(store)
+
+	serverSession, err := mcp.NewExistingServerSession(t.Context(), mcp.SessionState{ID: "test-session"}, mcp.MessageHandlerFunc(func(context.Context, mcp.Message) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverSession.Close(false)
+	serverSession.GetSession().Set(session.ManagerSessionKey, manager)
+	ctx := mcp.WithSession(t.Context(), serverSession.GetSession())
+
+	caller := &fakeCaller{fail: true}
+	s := NewToolsServer(caller)
+	request := runWorkflowRequest{
+		URI:    "workflow:///release-notes",
+		Inputs: map[string]any{"since": "v1"},
+	}
+
+	result, err := s.runWorkflow(ctx, mcp.Message{}, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || result.StructuredContent["error"] != "step notes failed: model unavailable" {
+		t.Fatalf("expected the run to fail, got %v", result.StructuredContent)
+	}
+
+	// Resuming runs the failed step again, without the steps that completed.
+	caller.fail = false
+	caller.calls = nil
+	request.RunID = result.StructuredContent["runId"].(string)
+	result, err = s.runWorkflow(ctx, mcp.Message{}, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError || result.StructuredContent["status"] != runStatusCompleted {
+		t.Fatalf("expected the run to complete, got %v", result.StructuredContent)
+	}
+	if !reflect.DeepEqual(caller.calls, []string{"writer/"}) {
+		t.Fatalf("unexpected calls %v", caller.calls)
+	}
+	if output := result.StructuredContent["output"]; output != `notes for Write notes for ["FIX A #0","ADD B #1"]` {
+		t.Fatalf("unexpected output %v", output)
+	}
+
+	steps := result.StructuredContent["steps"].(map[string]any)
+	if skipped := steps["skipped"].(map[string]any)["skipped"]; skipped != true {
+		t.Fatalf("expected the step to be skipped, got %v", steps["skipped"])
+	}
+
+	checkpoint, err := store.GetWorkflowCheckpoint(ctx, "test-session", request.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Status != runStatusCompleted || checkpoint.State.Next != 4 {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+
+	// Only failed runs can be resumed.
+	if _, err := s.runWorkflow(ctx, mcp.Message{}, request); err == nil {
+		t.Fatal("expected resuming a completed run to fail")
+	}
+
+	// A run left running can be resumed once the process running it is gone.
+	checkpoint.Status = runStatusRunning
+	checkpoint.Owner = manager.RunOwner()
+	checkpoint.State.Next = 3
+	if err := store.SaveWorkflowCheckpoint(ctx, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.runWorkflow(ctx, mcp.Message{}, request); err == nil {
+		t.Fatal("expected resuming a run of a live process to fail")
+	}
+	checkpoint.Owner = "gone"
+	if err := store.SaveWorkflowCheckpoint(ctx, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	caller.calls = nil
+	result, err = s.runWorkflow(ctx, mcp.Message{}, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError || result.StructuredContent["status"] != runStatusCompleted || !reflect.DeepEqual(caller.calls, []string{"writer/"}) {
+		t.Fatalf("expected the abandoned run to complete, got %v, %v", result.StructuredContent, caller.calls)
+	}
+
+	uris, err := store.ListWorkflowURIs(ctx, "test-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(uris["test-session"], []string{request.URI}) {
+		t.Fatalf("expected the run to be recorded, got %v", uris)
+	}
+}
@@ -13,15 +13,19 @@ import (
 )
 
 type ToolsServer struct {
-	tools mcp.ServerTools
+	tools  mcp.ServerTools
+	caller Caller
 }
 
-func NewToolsServer() *ToolsServer {
-	s := &ToolsServer{}
+func NewToolsServer(caller Caller) *ToolsServer {
+	s := &ToolsServer{
+		caller: caller,
+	}
 
 	s.tools = mcp.NewServerTools(
 		mcp.NewServerTool("recordWorkflowRun", "Record that a workflow was executed in the current chat session", s.recordWorkflowRun),
 		mcp.NewServerTool("deleteWorkflow", "Delete a workflow by its URI", s.deleteWorkflow),
+		newRunWorkflowTool(s),
 	)
 
 	return s
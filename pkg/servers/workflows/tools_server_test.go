@@ -14,7 +14,7 @@ import (
 )
 
 func TestRecordWorkflowRun_DeduplicatesURI(t *testing.T) {
-	s := NewToolsServer()
+	s := NewToolsServer(nil)
 	ctx := t.Context()
 	store, err := session.NewStoreFromDSN("sqlite::memory:")
 	if err != nil {
@@ -80,7 +80,7 @@ func TestDeleteWorkflow_RemovesDirectory(t *testing.T) {
 		t.Fatalf("failed to write workflow file: %v", err)
 	}
 
-	s := NewToolsServer()
+	s := NewToolsServer(nil)
 	if _, err := s.deleteWorkflow(t.Context(), struct {
 		URI string `json:"uri"`
 	}{URI: "workflow:///to-delete"}); err != nil {
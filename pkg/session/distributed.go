@@ -0,0 +1,167 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	sessionLeasePrefix = "session/"
+	forwardedByHeader  = "X-Nanobot-Forwarded-By"
+)
+
+// NotOwnerError is returned when a session is owned by another replica and could not be taken over.
+type NotOwnerError struct {
+	SessionID string
+	Owner     string
+	Address   string
+}
+
+func (e *NotOwnerError) Error() string {
+	return fmt.Sprintf("session %s is owned by replica %s", e.SessionID, e.Owner)
+}
+
+// acquireLease ensures this replica owns the session. If wait is true and another replica owns the
+// session, it waits up to LeaseWait for the other replica to release it or for the lease to expire.
+// This is a no-op outside distributed mode.
+func (m *Manager) acquireLease(ctx context.Context, id string, wait bool) error {
+	if m.opt.ReplicaID == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(m.opt.LeaseWait)
+	for {
+		lease, ok, err := m.DB.AcquireLease(ctx, sessionLeasePrefix+id, m.opt.ReplicaID, m.opt.Address, m.opt.LeaseTTL)
+		if err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+
+		if !wait || time.Now().After(deadline) {
+			return &NotOwnerError{
+				SessionID: id,
+				Owner:     lease.Holder,
+				Address:   lease.Address,
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(time.Second, time.Until(deadline))):
+		}
+	}
+}
+
+func (m *Manager) releaseLease(id string) {
+	if m.opt.ReplicaID == "" {
+		return
+	}
+	if err := m.DB.ReleaseLease(m.ctx, sessionLeasePrefix+id, m.opt.ReplicaID); err != nil {
+		slog.Error("failed to release session lease", "session_id", id, "error", err)
+	}
+}
+
+// renewLeases periodically extends the leases of all live sessions. Sessions whose lease was taken
+// over by another replica are closed locally so that they are not saved by two replicas.
+func (m *Manager) renewLeases() {
+	ticker := time.NewTicker(m.opt.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		m.liveSessionsLock.Lock()
+		names := make([]string, 0, len(m.liveSessions))
+		for id := range m.liveSessions {
+			names = append(names, sessionLeasePrefix+id)
+		}
+		m.liveSessionsLock.Unlock()
+
+		lost, err := m.DB.RenewLeases(m.ctx, m.opt.ReplicaID, m.opt.LeaseTTL, names...)
+		if err != nil {
+			slog.Error("failed to renew session leases", "error", err)
+			continue
+		}
+
+		for _, name := range lost {
+			id := name[len(sessionLeasePrefix):]
+			slog.Warn("lost session lease to another replica, closing local session", "session_id", id)
+
+			m.liveSessionsLock.Lock()
+			if live, ok := m.liveSessions[id]; ok {
+				delete(m.liveSessions, id)
+				if live.cancel != nil {
+					live.cancel()
+				}
+				live.session.Close(false)
+			}
+			m.liveSessionsLock.Unlock()
+		}
+	}
+}
+
+// Forward returns a handler that proxies requests for sessions owned by another replica to that
+// replica. Requests for unowned sessions, or sessions owned by this replica, are passed to next.
+// Outside distributed mode next is returned as is.
+func (m *Manager) Forward(next http.Handler) http.Handler {
+	if m.opt.ReplicaID == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		id := m.ExtractID(req)
+		if id == "" || req.Header.Get(forwardedByHeader) != "" {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		m.liveSessionsLock.Lock()
+		_, live := m.liveSessions[id]
+		m.liveSessionsLock.Unlock()
+		if live {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		lease, err := m.DB.GetLease(req.Context(), sessionLeasePrefix+id)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && (lease.Expired() || lease.Holder == m.opt.ReplicaID || lease.Address == "")) {
+			next.ServeHTTP(rw, req)
+			return
+		} else if err != nil {
+			http.Error(rw, "failed to look up session owner: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		target, err := url.Parse(lease.Address)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("invalid address %q for replica %s: %v", lease.Address, lease.Holder, err), http.StatusBadGateway)
+			return
+		}
+
+		slog.Debug("forwarding session request to owning replica", "session_id", id, "replica", lease.Holder, "address", lease.Address)
+		(&httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				r.SetURL(target)
+				r.SetXForwarded()
+				r.Out.Host = r.In.Host
+				r.Out.Header.Set(forwardedByHeader, m.opt.ReplicaID)
+			},
+			// Flush immediately so that SSE streams are not buffered.
+			FlushInterval: -1,
+		}).ServeHTTP(rw, req)
+	})
+}
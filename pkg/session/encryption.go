@@ -0,0 +1,317 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// encryptedPrefix starts the values that are encrypted with a keyring. The key ID, the wrapped data
+// key and the sealed data follow, separated by colons.
+const encryptedPrefix = "nbenc:v1:"
+
+var keyring atomic.Pointer[Keyring]
+
+// Keyring encrypts the session state and the stored tokens. Every value is sealed with a new data
+// key, which is wrapped with the primary key. The other keys only decrypt, so values written before
+// a key rotation can still be read until they are re-encrypted.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring returns a keyring for the keys, the first key encrypts and all keys decrypt.
+func NewKeyring(keys ...string) (*Keyring, error) {
+	k := &Keyring{
+		keys: map[string]cipher.AEAD{},
+	}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		hash := sha256.Sum256([]byte(key))
+		gcm, err := newGCM(hash[:])
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(hash[:])
+		if k.primary == "" {
+			k.primary = id
+		}
+		k.keys[id] = gcm
+	}
+	if k.primary == "" {
+		return nil, fmt.Errorf("no encryption key is set")
+	}
+	return k, nil
+}
+
+// ReadKeyFile returns the keys of a key file, one per line. Empty lines and lines starting with #
+// are skipped.
+func ReadKeyFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+	}
+	var keys []string
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key file %s has no keys", path)
+	}
+	return keys, nil
+}
+
+// SetKeyring sets the keyring that encrypts the state and tokens written by the stores of the
+// process. Without a keyring they are written in plain text, encrypted values can't be read.
+func SetKeyring(k *Keyring) {
+	keyring.Store(k)
+}
+
+// Encrypted returns whether a keyring is set.
+func Encrypted() bool {
+	return keyring.Load() != nil
+}
+
+func keyID(key []byte) string {
+	hash := sha256.Sum256(append([]byte("nanobot-session-key:"), key...))
+	return hex.EncodeToString(hash[:4])
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(gcm cipher.AEAD, data []byte) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+func open(gcm cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted value is too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+func (k *Keyring) encrypt(data []byte) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrapped, err := seal(k.keys[k.primary], dataKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(gcm, data)
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + k.primary + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (k *Keyring) decrypt(value string) ([]byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid encrypted value")
+	}
+	kek, ok := k.keys[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("value is encrypted with the unknown key %s", parts[0])
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted value: %w", err)
+	}
+	dataKey, err := open(kek, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := open(gcm, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return data, nil
+}
+
+// encryptString encrypts a value with the keyring, it returns the value as is if there is none.
+func encryptString(value string) (string, error) {
+	k := keyring.Load()
+	if k == nil || value == "" {
+		return value, nil
+	}
+	return k.encrypt([]byte(value))
+}
+
+// decryptString decrypts a value written by encryptString, values in plain text are returned as is.
+func decryptString(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	k := keyring.Load()
+	if k == nil {
+		return "", fmt.Errorf("value is encrypted but no state encryption key is set")
+	}
+	data, err := k.decrypt(value)
+	return string(data), err
+}
+
+// encryptJSON encrypts the JSON of a json column. The result is a JSON string, so it is still
+// valid for the column type.
+func encryptJSON(data []byte) ([]byte, error) {
+	k := keyring.Load()
+	if k == nil {
+		return data, nil
+	}
+	encrypted, err := k.encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encrypted)
+}
+
+// decryptJSON decrypts the JSON of a json column written by encryptJSON.
+func decryptJSON(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(`"`+encryptedPrefix)) {
+		return data, nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	decrypted, err := decryptString(value)
+	return []byte(decrypted), err
+}
+
+// ReencryptResult counts the rows rewritten by Reencrypt.
+type ReencryptResult struct {
+	Sessions            int `json:"sessions"`
+	Tokens              int `json:"tokens"`
+	WorkflowCheckpoints int `json:"workflowCheckpoints"`
+	AgentRuns           int `json:"agentRuns"`
+	SearchEntries       int `json:"searchEntries"`
+}
+
+// Reencrypt rewrites the state of all sessions, the workflow checkpoints, the agent runs and all
+// tokens with the primary key of the keyring. It encrypts the values written in plain text, and the ones encrypted
+// with a previous key, so that key can be removed afterward. The search entries are deleted, they would keep the messages in
+// plain text. Without a keyring the values are decrypted instead.
+func (s *Store) Reencrypt(ctx context.Context) (result ReencryptResult, err error) {
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var sessions []Session
+		err := tx.Unscoped().Select("id", "state").FindInBatches(&sessions, 100, func(*gorm.DB, int) error {
+			for _, session := range sessions {
+				if err := tx.Model(&Session{}).Unscoped().Where("id = ?", session.ID).
+					UpdateColumn("state", session.State).Error; err != nil {
+					return fmt.Errorf("failed to update session %d: %w", session.ID, err)
+				}
+			}
+			result.Sessions += len(sessions)
+			return nil
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt sessions: %w", err)
+		}
+
+		var tokens []Token
+		err = tx.Unscoped().Select("id", "url", "data").FindInBatches(&tokens, 100, func(*gorm.DB, int) error {
+			for _, token := range tokens {
+				data, err := decryptString(token.Data)
+				if err != nil {
+					return fmt.Errorf("failed to decrypt token for %s: %w", token.URL, err)
+				}
+				if data, err = encryptString(data); err != nil {
+					return fmt.Errorf("failed to encrypt token for %s: %w", token.URL, err)
+				}
+				if err := tx.Model(&Token{}).Unscoped().Where("id = ?", token.ID).
+					UpdateColumn("data", data).Error; err != nil {
+					return fmt.Errorf("failed to update token for %s: %w", token.URL, err)
+				}
+			}
+			result.Tokens += len(tokens)
+			return nil
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt tokens: %w", err)
+		}
+
+		var checkpoints []WorkflowCheckpoint
+		err = tx.Select("run_id", "state").FindInBatches(&checkpoints, 100, func(*gorm.DB, int) error {
+			for _, checkpoint := range checkpoints {
+				if err := tx.Model(&WorkflowCheckpoint{}).Where("run_id = ?", checkpoint.RunID).
+					UpdateColumn("state", checkpoint.State).Error; err != nil {
+					return fmt.Errorf("failed to update workflow checkpoint %s: %w", checkpoint.RunID, err)
+				}
+			}
+			result.WorkflowCheckpoints += len(checkpoints)
+			return nil
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt workflow checkpoints: %w", err)
+		}
+
+		var runs []AgentRun
+		err = tx.Select("run_id", "execution").FindInBatches(&runs, 100, func(*gorm.DB, int) error {
+			for _, run := range runs {
+				if err := tx.Model(&AgentRun{}).Where("run_id = ?", run.RunID).
+					UpdateColumn("execution", run.Execution).Error; err != nil {
+					return fmt.Errorf("failed to update agent run %s: %w", run.RunID, err)
+				}
+			}
+			result.AgentRuns += len(runs)
+			return nil
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt agent runs: %w", err)
+		}
+
+		if Encrypted() {
+			deleted := tx.Where("1 = 1").Delete(&SearchEntry{})
+			if deleted.Error != nil {
+				return fmt.Errorf("failed to delete search entries: %w", deleted.Error)
+			}
+			result.SearchEntries = int(deleted.RowsAffected)
+		}
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"golang.org/x/oauth2"
+)
+
+func rawColumn(t *testing.T, store *Store, query string) string {
+	t.Helper()
+	var value string
+	if err := store.db.Raw(query).Scan(&value).Error; err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func setKeyring(t *testing.T, keys ...string) {
+	t.Helper()
+	if len(keys) == 0 {
+		SetKeyring(nil)
+		return
+	}
+	k, err := NewKeyring(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(k)
+	t.Cleanup(func() {
+		SetKeyring(nil)
+	})
+}
+
+func TestEncryption(t *testing.T) {
+	store := testStore(t)
+	ctx := mcp.WithSession(t.Context(), mcp.NewEmptySession(t.Context()))
+	mcp.SessionFromContext(ctx).Set(types.AccountIDSessionKey, "alice")
+
+	// Written in plain text before a key is configured.
+	if err := store.Create(ctx, &Session{SessionID: "chat-1", State: State{
+		Attributes: map[string]any{"env": map[string]string{"API_KEY": "s3cret-value"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetTokenConfig(ctx, "https://example.com/mcp", &oauth2.Config{ClientID: "client"},
+		&oauth2.Token{AccessToken: "access-token-value"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IndexMessages(ctx, "chat-1", []types.Message{textMessage("m1", "user", "my s3cret-value")}); err != nil {
+		t.Fatal(err)
+	}
+
+	setKeyring(t, "old-key")
+	result, err := store.Reencrypt(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sessions != 1 || result.Tokens != 1 || result.SearchEntries != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	state := rawColumn(t, store, "SELECT state FROM sessions")
+	data := rawColumn(t, store, "SELECT data FROM tokens")
+	if strings.Contains(state, "s3cret-value") || strings.Contains(data, "access-token-value") {
+		t.Fatalf("expected the state and token to be encrypted, got %s and %s", state, data)
+	}
+
+	// The new key encrypts, the old one still decrypts until the values are re-encrypted.
+	setKeyring(t, "new-key", "old-key")
+	session, err := store.Get(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env, _ := session.State.Attributes["env"].(map[string]any); env["API_KEY"] != "s3cret-value" {
+		t.Fatalf("unexpected state %v", session.State.Attributes)
+	}
+	if _, token, err := store.GetTokenConfig(ctx, "https://example.com/mcp"); err != nil || token.AccessToken != "access-token-value" {
+		t.Fatalf("unexpected token %v: %v", token, err)
+	}
+	if _, err := store.Reencrypt(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	setKeyring(t, "new-key")
+	if _, err := store.Get(ctx, "chat-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Search(ctx, "alice", "s3cret", 10); err == nil {
+		t.Fatal("expected search to fail on an encrypted store")
+	}
+
+	setKeyring(t, "old-key")
+	if _, err := store.Get(ctx, "chat-1"); err == nil {
+		t.Fatal("expected an error reading the state with a removed key")
+	}
+
+	setKeyring(t)
+	if _, _, err := store.GetTokenConfig(ctx, "https://example.com/mcp"); err == nil {
+		t.Fatal("expected an error reading the token without a key")
+	}
+}
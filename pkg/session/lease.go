@@ -0,0 +1,122 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lease is a time bound claim by one replica on a named resource, such as a live session or the
+// scheduled task leadership. Leases are stored in the shared database so that multiple replicas
+// running against the same DSN agree on who owns what.
+type Lease struct {
+	Name      string    `json:"name" gorm:"primaryKey"`
+	Holder    string    `json:"holder" gorm:"not null"`
+	Address   string    `json:"address,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"index;not null"`
+}
+
+// Expired reports whether the lease is no longer held by anyone.
+func (l *Lease) Expired() bool {
+	return l == nil || time.Now().After(l.ExpiresAt)
+}
+
+// AcquireLease attempts to acquire or renew the named lease for the holder. If another holder owns an
+// unexpired lease, the current lease is returned along with false.
+func (s *Store) AcquireLease(ctx context.Context, name, holder, address string, ttl time.Duration) (*Lease, bool, error) {
+	now := time.Now().UTC()
+	lease := Lease{
+		Name:      name,
+		Holder:    holder,
+		Address:   address,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&lease)
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to create lease %s: %w", name, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return &lease, true, nil
+	}
+
+	// The lease exists, take it over if it is ours or has expired. This is a single conditional
+	// update so two replicas can never both succeed.
+	result = s.db.WithContext(ctx).
+		Model(&Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]any{
+			"holder":     holder,
+			"address":    address,
+			"expires_at": lease.ExpiresAt,
+		})
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to update lease %s: %w", name, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return &lease, true, nil
+	}
+
+	current, err := s.GetLease(ctx, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Released between our create and update, try again.
+		return s.AcquireLease(ctx, name, holder, address, ttl)
+	} else if err != nil {
+		return nil, false, err
+	}
+	return current, current.Holder == holder, nil
+}
+
+// GetLease returns the current lease for the given name.
+func (s *Store) GetLease(ctx context.Context, name string) (*Lease, error) {
+	var lease Lease
+	err := s.db.WithContext(ctx).Where("name = ?", name).First(&lease).Error
+	return &lease, err
+}
+
+// RenewLeases extends the expiration of the named leases that are still owned by the holder and
+// returns the names of the leases that were not renewed because the holder lost them.
+func (s *Store) RenewLeases(ctx context.Context, holder string, ttl time.Duration, names ...string) (lost []string, _ error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	err := s.db.WithContext(ctx).
+		Model(&Lease{}).
+		Where("holder = ? AND name IN ?", holder, names).
+		Update("expires_at", time.Now().UTC().Add(ttl)).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to renew leases: %w", err)
+	}
+
+	var held []string
+	err = s.db.WithContext(ctx).
+		Model(&Lease{}).
+		Where("holder = ? AND name IN ?", holder, names).
+		Pluck("name", &held).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list renewed leases: %w", err)
+	}
+
+	heldSet := make(map[string]struct{}, len(held))
+	for _, name := range held {
+		heldSet[name] = struct{}{}
+	}
+	for _, name := range names {
+		if _, ok := heldSet[name]; !ok {
+			lost = append(lost, name)
+		}
+	}
+	return lost, nil
+}
+
+// ReleaseLease gives up the named lease if it is owned by the holder.
+func (s *Store) ReleaseLease(ctx context.Context, name, holder string) error {
+	return s.db.WithContext(ctx).
+		Where("name = ? AND holder = ?", name, holder).
+		Delete(&Lease{}).Error
+}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+
+	store, err := NewStoreFromDSN(fmt.Sprintf("sqlite:file:%s?mode=memory&cache=shared",
+		strings.NewReplacer("/", "-", " ", "-").Replace(t.Name())))
+	if err != nil {
+		t.Fatalf("failed to create session store: %v", err)
+	}
+	return store
+}
+
+func TestAcquireLease(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	lease, ok, err := store.AcquireLease(ctx, "session/a", "replica-1", "http://one", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected replica-1 to acquire lease, got ok=%v err=%v", ok, err)
+	}
+	if lease.Holder != "replica-1" {
+		t.Fatalf("expected holder replica-1, got %s", lease.Holder)
+	}
+
+	// Re-acquiring our own lease renews it.
+	if _, ok, err := store.AcquireLease(ctx, "session/a", "replica-1", "http://one", time.Minute); err != nil || !ok {
+		t.Fatalf("expected replica-1 to renew lease, got ok=%v err=%v", ok, err)
+	}
+
+	lease, ok, err = store.AcquireLease(ctx, "session/a", "replica-2", "http://two", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected replica-2 to not acquire a lease held by replica-1")
+	}
+	if lease.Holder != "replica-1" || lease.Address != "http://one" {
+		t.Fatalf("expected current lease to be held by replica-1 at http://one, got %s at %s", lease.Holder, lease.Address)
+	}
+
+	if err := store.ReleaseLease(ctx, "session/a", "replica-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.AcquireLease(ctx, "session/a", "replica-2", "http://two", time.Minute); ok {
+		t.Fatal("expected release by a non-holder to be ignored")
+	}
+
+	if err := store.ReleaseLease(ctx, "session/a", "replica-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := store.AcquireLease(ctx, "session/a", "replica-2", "http://two", time.Minute); err != nil || !ok {
+		t.Fatalf("expected replica-2 to acquire released lease, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestAcquireExpiredLease(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	if _, ok, err := store.AcquireLease(ctx, "tasks/leader", "replica-1", "", -time.Second); err != nil || !ok {
+		t.Fatalf("expected replica-1 to acquire lease, got ok=%v err=%v", ok, err)
+	}
+
+	lease, ok, err := store.AcquireLease(ctx, "tasks/leader", "replica-2", "", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected replica-2 to take over expired lease, got ok=%v err=%v", ok, err)
+	}
+	if lease.Holder != "replica-2" {
+		t.Fatalf("expected holder replica-2, got %s", lease.Holder)
+	}
+}
+
+func TestRenewLeases(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	for _, name := range []string{"session/a", "session/b"} {
+		if _, ok, err := store.AcquireLease(ctx, name, "replica-1", "", -time.Second); err != nil || !ok {
+			t.Fatalf("expected replica-1 to acquire %s, got ok=%v err=%v", name, ok, err)
+		}
+	}
+	if _, ok, err := store.AcquireLease(ctx, "session/b", "replica-2", "", time.Minute); err != nil || !ok {
+		t.Fatalf("expected replica-2 to take over session/b, got ok=%v err=%v", ok, err)
+	}
+
+	lost, err := store.RenewLeases(ctx, "replica-1", time.Minute, "session/a", "session/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lost) != 1 || lost[0] != "session/b" {
+		t.Fatalf("expected session/b to be lost, got %v", lost)
+	}
+
+	lease, err := store.GetLease(ctx, "session/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Expired() {
+		t.Fatal("expected session/a to be renewed")
+	}
+}
+
+func TestClaimScheduledTaskRun(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	if err := store.CreateScheduledTask(ctx, &ScheduledTask{
+		TaskURI:  "task:///daily",
+		Name:     "daily",
+		Schedule: "0 9 * * *",
+		Timezone: "UTC",
+		Enabled:  true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dueAt := time.Now().UTC().Truncate(time.Minute)
+	next := dueAt.Add(24 * time.Hour)
+
+	claimed, err := store.ClaimScheduledTaskRun(ctx, "task:///daily", dueAt, time.Now().UTC(), &next)
+	if err != nil || !claimed {
+		t.Fatalf("expected first claim to succeed, got claimed=%v err=%v", claimed, err)
+	}
+
+	claimed, err = store.ClaimScheduledTaskRun(ctx, "task:///daily", dueAt, time.Now().UTC(), &next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed {
+		t.Fatal("expected second claim for the same run to fail")
+	}
+
+	claimed, err = store.ClaimScheduledTaskRun(ctx, "task:///daily", next, next.Add(time.Second), nil)
+	if err != nil || !claimed {
+		t.Fatalf("expected claim for the next run to succeed, got claimed=%v err=%v", claimed, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"maps"
 	"net/http"
 	"os"
@@ -12,21 +13,78 @@ import (
 	"sync"
 	"time"
 
+	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
 	"gorm.io/gorm"
 )
 
-func NewManager(store *Store) *Manager {
+// ManagerOptions configure how a Manager coordinates with other replicas that share the same database.
+type ManagerOptions struct {
+	// ReplicaID enables distributed mode when set. In distributed mode a replica must hold the lease
+	// for a session before loading or saving it, so only one replica owns a session at a time.
+	ReplicaID string
+	// Address is the base URL other replicas use to forward requests for sessions owned by this replica.
+	Address string
+	// LeaseTTL is how long a session lease stays valid without being renewed.
+	LeaseTTL time.Duration
+	// LeaseWait is how long to wait for another replica to give up a session before failing.
+	LeaseWait time.Duration
+	// Retention enables a background sweeper that deletes sessions according to the policy.
+	Retention RetentionPolicy
+	// SweepInterval is how often the retention sweeper runs.
+	SweepInterval time.Duration
+}
+
+func (o ManagerOptions) Merge(other ManagerOptions) (result ManagerOptions) {
+	result.ReplicaID = complete.Last(o.ReplicaID, other.ReplicaID)
+	result.Address = complete.Last(o.Address, other.Address)
+	result.LeaseTTL = complete.Last(o.LeaseTTL, other.LeaseTTL)
+	result.LeaseWait = complete.Last(o.LeaseWait, other.LeaseWait)
+	result.Retention = complete.Last(o.Retention, other.Retention)
+	result.SweepInterval = complete.Last(o.SweepInterval, other.SweepInterval)
+	return
+}
+
+func (o ManagerOptions) Complete() ManagerOptions {
+	if o.LeaseTTL == 0 {
+		o.LeaseTTL = 30 * time.Second
+	}
+	if o.LeaseWait == 0 {
+		o.LeaseWait = o.LeaseTTL
+	}
+	if o.SweepInterval == 0 {
+		o.SweepInterval = time.Hour
+	}
+	return o
+}
+
+func NewManager(store *Store, opts ...ManagerOptions) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Manager{
+	m := &Manager{
 		ctx:          ctx,
 		close:        cancel,
 		DB:           store,
 		root:         &Session{},
 		liveSessions: make(map[string]liveSession),
+		runs: &runRegistry{
+			cancels: map[string]context.CancelCauseFunc{},
+		},
+		owner: uuid.String(),
+		opt:   complete.Complete(opts...),
+	}
+	if store != nil {
+		m.acquireRunnerLease()
+		go m.holdRunnerLease()
+	}
+	if m.opt.ReplicaID != "" {
+		go m.renewLeases()
 	}
+	if m.opt.Retention.Enabled() {
+		go m.sweep()
+	}
+	return m
 }
 
 type Manager struct {
@@ -34,9 +92,13 @@ type Manager struct {
 	close context.CancelFunc
 	DB    *Store
 	root  *Session
+	opt   ManagerOptions
+	// owner identifies this process as the owner of the runs it runs.
+	owner string
 
 	liveSessionsLock sync.Mutex
 	liveSessions     map[string]liveSession
+	runs             *runRegistry
 }
 
 type liveSession struct {
@@ -109,6 +171,10 @@ func (m *Manager) Store(ctx context.Context, id string, session *mcp.ServerSessi
 	}
 	stored.State = *(*State)(state)
 
+	if err := m.acquireLease(ctx, id, false); err != nil {
+		return err
+	}
+
 	if create {
 		if err := m.DB.Create(ctx, stored); err != nil {
 			return fmt.Errorf("failed to create session record: %w", err)
@@ -137,10 +203,23 @@ func (m *Manager) Store(ctx context.Context, id string, session *mcp.ServerSessi
 		}
 	}
 
+	m.indexMessages(ctx, id, session)
 	m.loadAttributesFromRecord(stored, session)
 	return nil
 }
 
+// indexMessages updates the search index with the messages of the session's thread. Indexing
+// failures are logged rather than failing the save.
+func (m *Manager) indexMessages(ctx context.Context, id string, session *mcp.ServerSession) {
+	var run types.Execution
+	if !session.GetSession().Get(types.PreviousExecutionKey, &run) {
+		return
+	}
+	if err := m.DB.IndexMessages(ctx, id, run.Messages()); err != nil {
+		slog.Error("failed to index session messages", "session_id", id, "error", err)
+	}
+}
+
 func (m *Manager) ExtractID(req *http.Request) string {
 	id := req.Header.Get("Mcp-Session-Id")
 	if id != "" {
@@ -200,12 +279,18 @@ func (m *Manager) Acquire(ctx context.Context, server mcp.MessageHandler, id str
 	}
 	m.liveSessionsLock.Unlock()
 
+	if err := m.acquireLease(ctx, id, true); err != nil {
+		return nil, false, err
+	}
+
 	serverSession, ok, err := m.loadSessionFromDatabase(ctx, server, id)
 	if err != nil || !ok {
+		m.releaseLease(id)
 		return nil, false, err
 	}
 
 	if !checkAccount(ctx, serverSession) {
+		m.releaseLease(id)
 		return nil, false, nil
 	}
 
@@ -257,6 +342,7 @@ func (m *Manager) Release(session *mcp.ServerSession) {
 				if ok && live.count == 0 {
 					delete(m.liveSessions, sessionID)
 					live.session.Close(false)
+					m.releaseLease(sessionID)
 				}
 			}(ctx, session.ID())
 		} else if live.cancel != nil {
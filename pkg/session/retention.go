@@ -0,0 +1,230 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	sessionsDir = "sessions"
+	pruneBatch  = 500
+)
+
+// RetentionPolicy decides which sessions are deleted by Prune. A zero value keeps everything.
+type RetentionPolicy struct {
+	// MaxIdleAge deletes sessions that have not been updated for longer than this.
+	MaxIdleAge time.Duration
+	// MaxSessionsPerAccount keeps only this many of the most recently updated sessions per account.
+	MaxSessionsPerAccount int
+	// KeepStarred exempts starred sessions from both limits.
+	KeepStarred bool
+	// DeletedGracePeriod purges sessions that were soft deleted longer ago than this. Soft deleted
+	// sessions are kept if it is zero.
+	DeletedGracePeriod time.Duration
+}
+
+// Enabled reports whether the policy would ever delete a session.
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxIdleAge > 0 || p.MaxSessionsPerAccount > 0 || p.DeletedGracePeriod > 0
+}
+
+// PrunedSession describes a session that was, or in a dry run would be, deleted by Prune.
+type PrunedSession struct {
+	SessionID string    `json:"sessionId"`
+	AccountID string    `json:"accountId,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Reason    string    `json:"reason"`
+	Dir       string    `json:"dir,omitempty"`
+}
+
+// PruneOptions control a single Prune run.
+type PruneOptions struct {
+	// DryRun only reports what would be deleted.
+	DryRun bool
+	// Keep is consulted for every candidate, returning true keeps the session regardless of the policy.
+	// This is used to protect sessions that are currently in use.
+	Keep func(sessionID string) bool
+}
+
+// ExpiredSessions returns the sessions that should be deleted according to the policy. Sessions that
+// were soft deleted are returned once their grace period is over so that their rows and directories are
+// purged.
+func (s *Store) ExpiredSessions(ctx context.Context, policy RetentionPolicy, now time.Time) ([]PrunedSession, error) {
+	var sessions []Session
+	err := s.db.WithContext(ctx).
+		Unscoped().
+		Select("session_id", "account_id", "updated_at", "deleted_at", "starred", "cwd").
+		Order("account_id ASC, updated_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	var (
+		result []PrunedSession
+		counts = map[string]int{}
+	)
+	for _, session := range sessions {
+		var reason string
+		switch {
+		case session.DeletedAt.Valid:
+			if policy.DeletedGracePeriod <= 0 || now.Sub(session.DeletedAt.Time) <= policy.DeletedGracePeriod {
+				continue
+			}
+			reason = "deleted"
+		case policy.KeepStarred && session.Starred:
+			continue
+		case policy.MaxIdleAge > 0 && now.Sub(session.UpdatedAt) > policy.MaxIdleAge:
+			reason = "idle"
+		default:
+			counts[session.AccountID]++
+			if policy.MaxSessionsPerAccount <= 0 || counts[session.AccountID] <= policy.MaxSessionsPerAccount {
+				continue
+			}
+			reason = "limit"
+		}
+
+		result = append(result, PrunedSession{
+			SessionID: session.SessionID,
+			AccountID: session.AccountID,
+			UpdatedAt: session.UpdatedAt,
+			Reason:    reason,
+			Dir:       sessionDir(session.Cwd, session.SessionID),
+		})
+	}
+
+	return result, nil
+}
+
+// Prune deletes the sessions selected by the policy together with their workflow runs, search
+// entries, leases and on-disk session directories. The returned list contains everything that was
+// deleted, or would be in a dry run.
+func (s *Store) Prune(ctx context.Context, policy RetentionPolicy, opts PruneOptions) ([]PrunedSession, error) {
+	candidates, err := s.ExpiredSessions(ctx, policy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := candidates[:0]
+	for _, candidate := range candidates {
+		if opts.Keep == nil || !opts.Keep(candidate.SessionID) {
+			pruned = append(pruned, candidate)
+		}
+	}
+
+	if opts.DryRun || len(pruned) == 0 {
+		return pruned, nil
+	}
+
+	for batch := range slices.Chunk(pruned, pruneBatch) {
+		ids := make([]string, 0, len(batch))
+		for _, session := range batch {
+			ids = append(ids, session.SessionID)
+		}
+
+		if err := s.deleteSessions(ctx, ids); err != nil {
+			return nil, err
+		}
+
+		for _, session := range batch {
+			if session.Dir == "" {
+				continue
+			}
+			if err := os.RemoveAll(session.Dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Error("failed to remove session directory", "session_id", session.SessionID, "dir", session.Dir, "error", err)
+			}
+		}
+	}
+
+	return pruned, nil
+}
+
+func (s *Store) deleteSessions(ctx context.Context, ids []string) error {
+	leases := make([]string, 0, len(ids))
+	for _, id := range ids {
+		leases = append(leases, sessionLeasePrefix+id)
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id IN ?", ids).Delete(&WorkflowRun{}).Error; err != nil {
+			return fmt.Errorf("failed to delete workflow runs: %w", err)
+		}
+		if err := tx.Where("session_id IN ?", ids).Delete(&WorkflowCheckpoint{}).Error; err != nil {
+			return fmt.Errorf("failed to delete workflow checkpoints: %w", err)
+		}
+		if err := tx.Where("session_id IN ?", ids).Delete(&AgentRun{}).Error; err != nil {
+			return fmt.Errorf("failed to delete agent runs: %w", err)
+		}
+		if err := tx.Where("session_id IN ?", ids).Delete(&SearchEntry{}).Error; err != nil {
+			return fmt.Errorf("failed to delete search entries: %w", err)
+		}
+		if err := tx.Where("name IN ?", leases).Delete(&Lease{}).Error; err != nil {
+			return fmt.Errorf("failed to delete session leases: %w", err)
+		}
+		if err := tx.Unscoped().Where("session_id IN ?", ids).Delete(&Session{}).Error; err != nil {
+			return fmt.Errorf("failed to delete sessions: %w", err)
+		}
+		return nil
+	})
+}
+
+// sessionDir returns the on-disk directory of a session, which lives under the working directory the
+// session was created in. An empty string is returned if the ID could escape the sessions directory.
+func sessionDir(cwd, sessionID string) string {
+	if sessionID == "" || sessionID == "." || sessionID == ".." || filepath.Base(sessionID) != sessionID {
+		return ""
+	}
+	if cwd == "" {
+		var err error
+		if cwd, err = os.Getwd(); err != nil {
+			return ""
+		}
+	}
+	return filepath.Join(cwd, sessionsDir, sessionID)
+}
+
+// sweep periodically prunes sessions according to the retention policy until the manager is closed.
+func (m *Manager) sweep() {
+	ticker := time.NewTicker(m.opt.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := m.DB.Prune(m.ctx, m.opt.Retention, PruneOptions{
+			Keep: m.inUse,
+		})
+		if err != nil {
+			slog.Error("failed to prune sessions", "error", err)
+		}
+		for _, session := range pruned {
+			slog.Info("pruned session", "session_id", session.SessionID, "account_id", session.AccountID,
+				"updated_at", session.UpdatedAt, "reason", session.Reason, "dir", session.Dir)
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// inUse reports whether the session is live on this replica or, in distributed mode, on another one.
+func (m *Manager) inUse(sessionID string) bool {
+	m.liveSessionsLock.Lock()
+	_, live := m.liveSessions[sessionID]
+	m.liveSessionsLock.Unlock()
+	if live || m.opt.ReplicaID == "" {
+		return live
+	}
+
+	lease, err := m.DB.GetLease(m.ctx, sessionLeasePrefix+sessionID)
+	return err == nil && !lease.Expired()
+}
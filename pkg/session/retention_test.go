@@ -0,0 +1,151 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+	cwd := t.TempDir()
+	now := time.Now()
+
+	for _, s := range []struct {
+		id      string
+		account string
+		age     time.Duration
+		starred bool
+	}{
+		{id: "old", account: "alice", age: 48 * time.Hour},
+		{id: "old-starred", account: "alice", age: 48 * time.Hour, starred: true},
+		{id: "recent-1", account: "bob", age: time.Minute},
+		{id: "recent-2", account: "bob", age: 2 * time.Minute},
+		{id: "recent-3", account: "bob", age: 3 * time.Minute},
+	} {
+		record := &Session{
+			SessionID: s.id,
+			AccountID: s.account,
+			Cwd:       cwd,
+			Starred:   s.starred,
+		}
+		if err := store.Create(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.db.Model(record).UpdateColumn("updated_at", now.Add(-s.age)).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := store.AddWorkflowRun(ctx, s.id, "workflow:///test"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(cwd, sessionsDir, s.id), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy := RetentionPolicy{
+		MaxIdleAge:            24 * time.Hour,
+		MaxSessionsPerAccount: 2,
+		KeepStarred:           true,
+	}
+
+	pruned, err := store.Prune(ctx, policy, PruneOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := prunedIDs(pruned); !slices.Equal(got, []string{"old", "recent-3"}) {
+		t.Fatalf("unexpected dry run result: %v", got)
+	}
+	if _, err := store.Get(ctx, "old"); err != nil {
+		t.Fatalf("expected dry run to keep session: %v", err)
+	}
+
+	pruned, err = store.Prune(ctx, policy, PruneOptions{
+		Keep: func(id string) bool { return id == "recent-3" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := prunedIDs(pruned); !slices.Equal(got, []string{"old"}) {
+		t.Fatalf("unexpected prune result: %v", got)
+	}
+
+	if _, err := store.Get(ctx, "old"); err == nil {
+		t.Fatal("expected old session to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(cwd, sessionsDir, "old")); !os.IsNotExist(err) {
+		t.Fatalf("expected old session directory to be deleted, got %v", err)
+	}
+	runs, err := store.ListWorkflowURIs(ctx, "old", "old-starred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs["old"]) != 0 || len(runs["old-starred"]) != 1 {
+		t.Fatalf("unexpected workflow runs after prune: %v", runs)
+	}
+	for _, id := range []string{"old-starred", "recent-1", "recent-2", "recent-3"} {
+		if _, err := store.Get(ctx, id); err != nil {
+			t.Fatalf("expected session %s to be kept: %v", id, err)
+		}
+	}
+}
+
+func TestPruneDeletedSessions(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+	now := time.Now()
+
+	for _, s := range []struct {
+		id  string
+		age time.Duration
+	}{
+		{id: "deleted-old", age: 48 * time.Hour},
+		{id: "deleted-recent", age: time.Hour},
+	} {
+		record := &Session{SessionID: s.id, AccountID: "alice", Cwd: t.TempDir()}
+		if err := store.Create(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.db.Model(record).UpdateColumn("deleted_at", now.Add(-s.age)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.db.Create(&Token{AccountID: "alice", URL: "https://example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := store.Prune(ctx, RetentionPolicy{MaxIdleAge: 24 * time.Hour}, PruneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 0 {
+		t.Fatalf("expected deleted sessions to be kept without a grace period, got %v", prunedIDs(pruned))
+	}
+
+	pruned, err = store.Prune(ctx, RetentionPolicy{DeletedGracePeriod: 24 * time.Hour}, PruneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := prunedIDs(pruned); !slices.Equal(got, []string{"deleted-old"}) {
+		t.Fatalf("unexpected prune result: %v", got)
+	}
+
+	var tokens int64
+	if err := store.db.Model(&Token{}).Where("account_id = ?", "alice").Count(&tokens).Error; err != nil {
+		t.Fatal(err)
+	}
+	if tokens != 1 {
+		t.Fatalf("expected the tokens of the account to be kept, got %d", tokens)
+	}
+}
+
+func prunedIDs(pruned []PrunedSession) (ids []string) {
+	for _, p := range pruned {
+		ids = append(ids, p.SessionID)
+	}
+	slices.Sort(ids)
+	return ids
+}
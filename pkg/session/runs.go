@@ -0,0 +1,341 @@
+package session
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"gorm.io/gorm"
+)
+
+const (
+	AgentRunRunning     = "running"
+	AgentRunCompleted   = "completed"
+	AgentRunFailed      = "failed"
+	AgentRunInterrupted = "interrupted"
+	AgentRunStopped     = "stopped"
+)
+
+// runnerLeasePrefix is the prefix of the leases processes hold while they are alive, named after
+// their run owner ID.
+const runnerLeasePrefix = "runner/"
+
+// runStoppedReason is the reason of the cancellation of a run stopped with StopAgentRun.
+const runStoppedReason = "the run was stopped"
+
+// interruptedReason is the output of the tool calls that never completed because the process
+// running them stopped.
+const interruptedReason = "The tool call was interrupted before it completed because the server stopped. " +
+	"It may or may not have had an effect, check before calling it again."
+
+// AgentRun is the checkpoint of an agent run of a chat session. It is saved after every response of
+// the model and every tool output, so a run interrupted by a restart can be resumed from where it
+// stopped or closed.
+type AgentRun struct {
+	RunID     string `json:"runId" gorm:"primaryKey"`
+	SessionID string `json:"sessionId" gorm:"index;not null"`
+	Agent     string `json:"agent,omitempty"`
+	Status    string `json:"status" gorm:"index"`
+	Error     string `json:"error,omitempty"`
+	// Background is set for runs started in the background, that the caller polls for the result.
+	Background bool `json:"background,omitempty"`
+	// ReplicaID is the replica that runs or last ran the run.
+	ReplicaID string `json:"replicaId,omitempty"`
+	// Owner is the process that runs or last ran the run, see Manager.RunOwner.
+	Owner string `json:"-" gorm:"index"`
+	// ExecutionKey is the session attribute the execution of the thread of the run is stored in.
+	ExecutionKey string `json:"-"`
+	// InputID is the ID of the input message of the run, the messages after it are the output of
+	// the run.
+	InputID   string       `json:"-"`
+	Execution RunExecution `json:"-" gorm:"type:json"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+type RunExecution types.Execution
+
+func (r RunExecution) Value() (driver.Value, error) {
+	return encryptedValue(r)
+}
+
+func (r *RunExecution) Scan(value any) error {
+	return scanEncrypted(value, r)
+}
+
+// AgentRunResult is an agent run with its output: the messages of the run so far, and the final
+// response once the run completed.
+type AgentRunResult struct {
+	AgentRun
+	InProgress bool            `json:"inProgress"`
+	Messages   []types.Message `json:"messages,omitempty"`
+	Result     *types.Message  `json:"result,omitempty"`
+}
+
+// Result returns the run with its output.
+func (r *AgentRun) Result() AgentRunResult {
+	result := AgentRunResult{
+		AgentRun:   *r,
+		InProgress: r.Status == AgentRunRunning,
+	}
+
+	execution := types.Execution(r.Execution)
+	messages := execution.Messages()
+	if i := slices.IndexFunc(messages, func(msg types.Message) bool {
+		return msg.ID == r.InputID
+	}); r.InputID != "" && i >= 0 {
+		result.Messages = types.ConsolidateTools(messages[i+1:])
+	}
+
+	if r.Status == AgentRunCompleted && execution.Response != nil {
+		result.Result = &execution.Response.Output
+	}
+	return result
+}
+
+// SaveAgentRun creates or updates the checkpoint of an agent run.
+func (s *Store) SaveAgentRun(ctx context.Context, run *AgentRun) error {
+	if run.RunID == "" {
+		return fmt.Errorf("run ID cannot be empty")
+	}
+	if run.SessionID == "" {
+		return fmt.Errorf("session ID cannot be empty")
+	}
+	return s.db.WithContext(ctx).Save(run).Error
+}
+
+// GetAgentRun returns an agent run of a session.
+func (s *Store) GetAgentRun(ctx context.Context, sessionID, runID string) (*AgentRun, error) {
+	var run AgentRun
+	err := s.db.WithContext(ctx).Where("session_id = ? AND run_id = ?", sessionID, runID).First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ClaimAgentRun marks an interrupted or failed run of a session as running on the replica and owner
+// again, so that it is resumed only once. It returns false if the run has another status, like when
+// it is being resumed already.
+func (s *Store) ClaimAgentRun(ctx context.Context, sessionID, runID, replicaID, owner string) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&AgentRun{}).
+		Where("session_id = ? AND run_id = ? AND status IN ?", sessionID, runID, []string{AgentRunInterrupted, AgentRunFailed}).
+		Updates(map[string]any{
+			"status":     AgentRunRunning,
+			"error":      "",
+			"replica_id": replicaID,
+			"owner":      owner,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ListAgentRuns returns the agent runs of a session, newest first, without their executions.
+func (s *Store) ListAgentRuns(ctx context.Context, sessionID string) ([]AgentRun, error) {
+	var runs []AgentRun
+	err := s.db.WithContext(ctx).Omit("execution").
+		Where("session_id = ?", sessionID).
+		Order("created_at DESC").
+		Find(&runs).Error
+	return runs, err
+}
+
+// runRegistry has the cancel functions of the runs in progress in this process.
+type runRegistry struct {
+	lock    sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}
+
+// ReplicaID returns the ID of this replica, it is empty when not in distributed mode.
+func (m *Manager) ReplicaID() string {
+	return m.opt.ReplicaID
+}
+
+// RunOwner returns the ID of this process as the owner of the runs it runs. Unlike the replica ID it
+// is unique to the process, and the process holds the runner lease of it while it is alive.
+func (m *Manager) RunOwner() string {
+	return m.owner
+}
+
+// RunOwnerAlive reports whether the process that owns runs still holds its runner lease. Runs of an
+// owner that is not alive were abandoned and can be recovered.
+func (m *Manager) RunOwnerAlive(ctx context.Context, owner string) (bool, error) {
+	if owner == "" {
+		return false, nil
+	}
+	lease, err := m.DB.GetLease(ctx, runnerLeasePrefix+owner)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get runner lease of %s: %w", owner, err)
+	}
+	return !lease.Expired(), nil
+}
+
+// acquireRunnerLease acquires or renews the runner lease of this process.
+func (m *Manager) acquireRunnerLease() {
+	if _, _, err := m.DB.AcquireLease(m.ctx, runnerLeasePrefix+m.owner, m.owner, "", m.opt.LeaseTTL); err != nil && m.ctx.Err() == nil {
+		slog.Error("failed to acquire runner lease", "owner", m.owner, "error", err)
+	}
+}
+
+// holdRunnerLease renews the runner lease of this process until the manager is closed.
+func (m *Manager) holdRunnerLease() {
+	ticker := time.NewTicker(m.opt.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			if err := m.DB.ReleaseLease(context.Background(), runnerLeasePrefix+m.owner, m.owner); err != nil {
+				slog.Error("failed to release runner lease", "owner", m.owner, "error", err)
+			}
+			return
+		case <-ticker.C:
+			m.acquireRunnerLease()
+		}
+	}
+}
+
+// TrackAgentRun registers the cancel function of a run in progress, so StopAgentRun can stop it.
+// The returned function unregisters it.
+func (m *Manager) TrackAgentRun(runID string, cancel context.CancelCauseFunc) func() {
+	if m.runs == nil {
+		return func() {}
+	}
+	m.runs.lock.Lock()
+	defer m.runs.lock.Unlock()
+	m.runs.cancels[runID] = cancel
+	return func() {
+		m.runs.lock.Lock()
+		defer m.runs.lock.Unlock()
+		delete(m.runs.cancels, runID)
+	}
+}
+
+// StopAgentRun stops an agent run of a session. A run in progress in this process is canceled,
+// it records itself as stopped when it returns. A run that is not in progress, because it was
+// interrupted or failed, is closed with an output for the tool calls that never completed.
+func (m *Manager) StopAgentRun(ctx context.Context, sessionID, runID string) (*AgentRun, error) {
+	run, err := m.DB.GetAgentRun(ctx, sessionID, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.runs != nil {
+		m.runs.lock.Lock()
+		cancel := m.runs.cancels[runID]
+		m.runs.lock.Unlock()
+		if cancel != nil {
+			cancel(&mcp.RequestCancelledError{Reason: runStoppedReason})
+			return run, nil
+		}
+	}
+
+	switch run.Status {
+	case AgentRunCompleted, AgentRunStopped:
+		return run, nil
+	}
+
+	if err := m.CloseAgentRun(ctx, run, AgentRunStopped, "The tool call was stopped before it completed."); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// CloseAgentRun ends a run that is not in progress. The tool calls without an output get one with
+// the reason, and the execution is saved to the session as the last execution of the thread, so
+// the conversation continues from it.
+func (m *Manager) CloseAgentRun(ctx context.Context, run *AgentRun, status, reason string) error {
+	execution := types.Execution(run.Execution)
+	if execution.Response != nil {
+		execution.CloseToolCalls(reason)
+		execution.Done = true
+
+		if err := m.setExecution(ctx, run.SessionID, run.ExecutionKey, &execution); err != nil {
+			return fmt.Errorf("failed to save execution of run %s: %w", run.RunID, err)
+		}
+	}
+
+	run.Execution = RunExecution(execution)
+	run.Status = status
+	return m.DB.SaveAgentRun(ctx, run)
+}
+
+// setExecution sets the execution of a thread in the session, in the live session if it is
+// loaded so it isn't overwritten when the live session is saved.
+func (m *Manager) setExecution(ctx context.Context, sessionID, key string, execution *types.Execution) error {
+	if key == "" {
+		key = types.PreviousExecutionKey
+	}
+
+	m.liveSessionsLock.Lock()
+	live, ok := m.liveSessions[sessionID]
+	m.liveSessionsLock.Unlock()
+	if ok && live.session != nil {
+		live.session.GetSession().Set(key, execution)
+	}
+
+	stored, err := m.DB.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if stored.State.Attributes == nil {
+		stored.State.Attributes = map[string]any{}
+	}
+	stored.State.Attributes[key] = execution
+	return m.DB.Update(ctx, stored)
+}
+
+// RecoverAgentRuns finds the runs that were in progress when the process running them stopped: the
+// runs of owners that no longer hold their runner lease. They are marked as interrupted so they can
+// be resumed, or closed if closeRuns is set. Runs recorded without an owner are recovered if they
+// are of this replica, or of sessions no replica holds the lease of.
+func (m *Manager) RecoverAgentRuns(ctx context.Context, closeRuns bool) ([]AgentRun, error) {
+	var running []AgentRun
+	if err := m.DB.db.WithContext(ctx).Where("status = ?", AgentRunRunning).Find(&running).Error; err != nil {
+		return nil, fmt.Errorf("failed to find running agent runs: %w", err)
+	}
+
+	var recovered []AgentRun
+	for _, run := range running {
+		if run.Owner != "" {
+			alive, err := m.RunOwnerAlive(ctx, run.Owner)
+			if err != nil {
+				return recovered, err
+			} else if alive {
+				continue
+			}
+		} else if run.ReplicaID != m.opt.ReplicaID {
+			lease, err := m.DB.GetLease(ctx, sessionLeasePrefix+run.SessionID)
+			if err == nil && !lease.Expired() {
+				continue
+			} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return recovered, fmt.Errorf("failed to get lease of session %s: %w", run.SessionID, err)
+			}
+		}
+
+		if closeRuns {
+			if err := m.CloseAgentRun(ctx, &run, AgentRunInterrupted, interruptedReason); err != nil {
+				return recovered, err
+			}
+		} else {
+			run.Status = AgentRunInterrupted
+			if err := m.DB.db.WithContext(ctx).Model(&AgentRun{}).Where("run_id = ?", run.RunID).
+				UpdateColumn("status", AgentRunInterrupted).Error; err != nil {
+				return recovered, fmt.Errorf("failed to mark run %s as interrupted: %w", run.RunID, err)
+			}
+		}
+
+		slog.Info("recovered interrupted agent run", "run_id", run.RunID, "session_id", run.SessionID, "closed", closeRuns)
+		recovered = append(recovered, run)
+	}
+	return recovered, nil
+}
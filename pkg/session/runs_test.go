@@ -0,0 +1,265 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func runExecution() RunExecution {
+	return RunExecution{
+		Response: &types.CompletionResponse{
+			Output: types.Message{
+				Role: "assistant",
+				Items: []types.CompletionItem{
+					{ID: "1", ToolCall: &types.ToolCall{CallID: "call-1", Name: "deploy"}},
+					{ID: "2", ToolCall: &types.ToolCall{CallID: "call-2", Name: "notify"}},
+				},
+			},
+		},
+		ToolOutputs: map[string]types.ToolOutput{
+			"call-1": {Done: true},
+		},
+	}
+}
+
+func TestRecoverAgentRuns(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	for _, id := range []string{"chat-1", "chat-2", "chat-3"} {
+		if err := store.Create(ctx, &Session{SessionID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// chat-3 is held by another replica that is still alive, so its run is still running.
+	if _, _, err := store.AcquireLease(ctx, sessionLeasePrefix+"chat-3", "replica-2", "http://two", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := []AgentRun{
+		{RunID: "run-1", SessionID: "chat-1", Status: AgentRunRunning, ReplicaID: "replica-1", Execution: runExecution()},
+		{RunID: "run-2", SessionID: "chat-2", Status: AgentRunRunning, ReplicaID: "replica-2", Execution: runExecution()},
+		{RunID: "run-3", SessionID: "chat-3", Status: AgentRunRunning, ReplicaID: "replica-2", Execution: runExecution()},
+		{RunID: "run-4", SessionID: "chat-1", Status: AgentRunCompleted, ReplicaID: "replica-1"},
+	}
+	for i := range runs {
+		if err := store.SaveAgentRun(ctx, &runs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager := NewManager(store, ManagerOptions{ReplicaID: "replica-1"})
+	defer manager.close()
+
+	recovered, err := manager.RecoverAgentRuns(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered) != 2 {
+		t.Fatalf("expected 2 recovered runs, got %d", len(recovered))
+	}
+
+	for runID, status := range map[string]string{
+		"run-1": AgentRunInterrupted,
+		"run-2": AgentRunInterrupted,
+		"run-3": AgentRunRunning,
+		"run-4": AgentRunCompleted,
+	} {
+		sessionID := map[string]string{"run-1": "chat-1", "run-2": "chat-2", "run-3": "chat-3", "run-4": "chat-1"}[runID]
+		run, err := store.GetAgentRun(ctx, sessionID, runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run.Status != status {
+			t.Errorf("expected %s to be %s, got %s", runID, status, run.Status)
+		}
+	}
+
+	// Marking runs as interrupted keeps their checkpoint so they can be resumed.
+	run, err := store.GetAgentRun(ctx, "chat-1", "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Execution.ToolOutputs["call-2"].Done {
+		t.Fatal("expected the checkpoint of an interrupted run to be unchanged")
+	}
+}
+
+func TestRecoverAgentRunsOfLiveOwners(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	if err := store.Create(ctx, &Session{SessionID: "chat-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two processes share the store outside distributed mode, so the runs have no replica ID.
+	running := NewManager(store)
+	defer running.close()
+
+	runs := []AgentRun{
+		{RunID: "live", SessionID: "chat-1", Status: AgentRunRunning, Owner: running.RunOwner(), Execution: runExecution()},
+		{RunID: "abandoned", SessionID: "chat-1", Status: AgentRunRunning, Owner: "gone", Execution: runExecution()},
+	}
+	for i := range runs {
+		if err := store.SaveAgentRun(ctx, &runs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager := NewManager(store)
+	defer manager.close()
+
+	recovered, err := manager.RecoverAgentRuns(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered) != 1 || recovered[0].RunID != "abandoned" {
+		t.Fatalf("expected only the run of the owner that is gone to be recovered, got %+v", recovered)
+	}
+
+	// The runs of a process are recovered once it stops.
+	running.close()
+	if err := store.ReleaseLease(ctx, runnerLeasePrefix+running.RunOwner(), running.RunOwner()); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err = manager.RecoverAgentRuns(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered) != 1 || recovered[0].RunID != "live" {
+		t.Fatalf("expected the run of the stopped process to be recovered, got %+v", recovered)
+	}
+}
+
+func TestClaimAgentRun(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	for _, run := range []AgentRun{
+		{RunID: "failed", SessionID: "chat-1", Status: AgentRunFailed, Error: "model unavailable"},
+		{RunID: "completed", SessionID: "chat-1", Status: AgentRunCompleted},
+	} {
+		if err := store.SaveAgentRun(ctx, &run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if claimed, err := store.ClaimAgentRun(ctx, "chat-1", "failed", "replica-1", "owner-1"); err != nil || !claimed {
+		t.Fatalf("expected the failed run to be claimed, got %v, %v", claimed, err)
+	}
+	run, err := store.GetAgentRun(ctx, "chat-1", "failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != AgentRunRunning || run.Error != "" || run.ReplicaID != "replica-1" || run.Owner != "owner-1" {
+		t.Fatalf("unexpected claimed run %+v", run)
+	}
+
+	// A run is only claimed once.
+	if claimed, err := store.ClaimAgentRun(ctx, "chat-1", "failed", "replica-2", "owner-2"); err != nil || claimed {
+		t.Fatalf("expected the running run to not be claimed again, got %v, %v", claimed, err)
+	}
+	if claimed, err := store.ClaimAgentRun(ctx, "chat-1", "completed", "replica-1", "owner-1"); err != nil || claimed {
+		t.Fatalf("expected the completed run to not be claimed, got %v, %v", claimed, err)
+	}
+}
+
+func TestCloseAgentRun(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	if err := store.Create(ctx, &Session{SessionID: "chat-1"}); err != nil {
+		t.Fatal(err)
+	}
+	run := AgentRun{
+		RunID:        "run-1",
+		SessionID:    "chat-1",
+		Status:       AgentRunRunning,
+		ExecutionKey: "thread-execution",
+		Execution:    runExecution(),
+	}
+	if err := store.SaveAgentRun(ctx, &run); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewManager(store)
+	defer manager.close()
+
+	recovered, err := manager.RecoverAgentRuns(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered) != 1 || recovered[0].Status != AgentRunInterrupted {
+		t.Fatalf("expected the run to be closed as interrupted, got %+v", recovered)
+	}
+
+	stored, err := store.Get(ctx, "chat-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(stored.State.Attributes["thread-execution"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var execution types.Execution
+	if err := json.Unmarshal(data, &execution); err != nil {
+		t.Fatal(err)
+	}
+	if !execution.Done {
+		t.Fatal("expected the execution of the session to be done")
+	}
+	output := execution.ToolOutputs["call-2"]
+	if !output.Done || len(output.Output.Items) != 1 || output.Output.Items[0].ToolCallResult.Output.Content[0].Text != interruptedReason {
+		t.Fatalf("expected the tool call to be closed with the interrupted reason, got %+v", output)
+	}
+	if execution.ToolOutputs["call-1"].Output.Items != nil {
+		t.Fatal("expected the completed tool call to be unchanged")
+	}
+}
+
+func TestAgentRunResult(t *testing.T) {
+	run := AgentRun{
+		RunID:   "run-1",
+		Status:  AgentRunRunning,
+		InputID: "input",
+		Execution: RunExecution{
+			PopulatedRequest: &types.CompletionRequest{
+				Input: []types.Message{
+					{ID: "earlier", Role: "user"},
+					{ID: "input", Role: "user"},
+					{ID: "call", Role: "assistant", Items: []types.CompletionItem{
+						{ID: "1", ToolCall: &types.ToolCall{CallID: "call-1", Name: "search"}},
+					}},
+				},
+			},
+			Response: &types.CompletionResponse{
+				Output: types.Message{ID: "answer", Role: "assistant", Items: []types.CompletionItem{
+					{ID: "2", Content: &mcp.Content{Type: "text", Text: "done"}},
+				}},
+			},
+		},
+	}
+
+	result := run.Result()
+	if !result.InProgress || result.Result != nil {
+		t.Fatalf("expected a running run to be in progress without a result, got %+v", result)
+	}
+	var ids []string
+	for _, msg := range result.Messages {
+		ids = append(ids, msg.ID)
+	}
+	if len(ids) != 2 || ids[0] != "call" || ids[1] != "answer" {
+		t.Fatalf("expected the messages after the input, got %v", ids)
+	}
+
+	run.Status = AgentRunCompleted
+	result = run.Result()
+	if result.InProgress || result.Result == nil || result.Result.ID != "answer" {
+		t.Fatalf("expected a completed run to have the final response as its result, got %+v", result)
+	}
+}
@@ -0,0 +1,310 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nanobot-ai/nanobot/pkg/types"
+	"gorm.io/gorm"
+)
+
+const (
+	searchDialectFTS5     = "fts5"
+	searchDialectTSVector = "tsvector"
+
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	snippetRadius      = 60
+)
+
+// SearchEntry is the searchable text of one message in a session. On SQLite the entries are indexed
+// by an FTS5 table and on Postgres by a tsvector GIN index. Other databases fall back to LIKE.
+type SearchEntry struct {
+	ID        uint       `json:"-" gorm:"primaryKey"`
+	SessionID string     `json:"sessionId" gorm:"uniqueIndex:idx_search_entries_message;not null"`
+	MessageID string     `json:"messageId" gorm:"uniqueIndex:idx_search_entries_message;not null"`
+	Role      string     `json:"role,omitempty"`
+	Created   *time.Time `json:"created,omitempty"`
+	Text      string     `json:"text" gorm:"type:text"`
+	Hash      string     `json:"-"`
+}
+
+// SearchResult is a message that matched a search query.
+type SearchResult struct {
+	SessionID string     `json:"sessionId"`
+	Title     string     `json:"title,omitempty"`
+	MessageID string     `json:"messageId"`
+	Role      string     `json:"role,omitempty"`
+	Created   *time.Time `json:"created,omitempty"`
+	Snippet   string     `json:"snippet"`
+	Text      string     `json:"-"`
+}
+
+// migrateSearch creates the dialect specific full-text index over the search entries and returns
+// which one is in use. An empty string means searches fall back to LIKE.
+func migrateSearch(tx *gorm.DB) string {
+	switch tx.Dialector.Name() {
+	case "sqlite":
+		for _, stmt := range []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS search_entries_fts USING fts5(text, content='search_entries', content_rowid='id')`,
+			`CREATE TRIGGER IF NOT EXISTS search_entries_ai AFTER INSERT ON search_entries BEGIN
+				INSERT INTO search_entries_fts(rowid, text) VALUES (new.id, new.text);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS search_entries_ad AFTER DELETE ON search_entries BEGIN
+				INSERT INTO search_entries_fts(search_entries_fts, rowid, text) VALUES ('delete', old.id, old.text);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS search_entries_au AFTER UPDATE ON search_entries BEGIN
+				INSERT INTO search_entries_fts(search_entries_fts, rowid, text) VALUES ('delete', old.id, old.text);
+				INSERT INTO search_entries_fts(rowid, text) VALUES (new.id, new.text);
+			END`,
+		} {
+			// Use a savepoint so that a SQLite build without FTS5 doesn't abort the whole migration.
+			if err := tx.Transaction(func(tx *gorm.DB) error {
+				return tx.Exec(stmt).Error
+			}); err != nil {
+				slog.Warn("full-text search index is not available, falling back to LIKE", "error", err)
+				return ""
+			}
+		}
+		return searchDialectFTS5
+	case "postgres":
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Exec(`CREATE INDEX IF NOT EXISTS idx_search_entries_fts ON search_entries USING GIN (to_tsvector('simple', text))`).Error
+		})
+		if err != nil {
+			slog.Warn("full-text search index is not available, falling back to LIKE", "error", err)
+			return ""
+		}
+		return searchDialectTSVector
+	}
+	return ""
+}
+
+// IndexMessages updates the search entries of a session to match the given messages. Only messages
+// whose text changed are written.
+func (s *Store) IndexMessages(ctx context.Context, sessionID string, messages []types.Message) error {
+	if Encrypted() {
+		// The index would keep the messages in plain text next to the encrypted state.
+		return nil
+	}
+
+	entries := map[string]SearchEntry{}
+	for _, msg := range messages {
+		text := searchText(msg)
+		if msg.ID == "" || text == "" {
+			continue
+		}
+		entries[msg.ID] = SearchEntry{
+			SessionID: sessionID,
+			MessageID: msg.ID,
+			Role:      msg.Role,
+			Created:   msg.Created,
+			Text:      text,
+			Hash:      searchHash(msg.Role, text),
+		}
+	}
+
+	var existing []SearchEntry
+	err := s.db.WithContext(ctx).
+		Select("id", "message_id", "hash").
+		Where("session_id = ?", sessionID).
+		Find(&existing).Error
+	if err != nil {
+		return fmt.Errorf("failed to list search entries: %w", err)
+	}
+
+	var (
+		create  []SearchEntry
+		update  []SearchEntry
+		remove  []uint
+		current = make(map[string]SearchEntry, len(existing))
+	)
+	for _, entry := range existing {
+		current[entry.MessageID] = entry
+		if _, ok := entries[entry.MessageID]; !ok {
+			remove = append(remove, entry.ID)
+		}
+	}
+	for id, entry := range entries {
+		old, ok := current[id]
+		if !ok {
+			create = append(create, entry)
+		} else if old.Hash != entry.Hash {
+			entry.ID = old.ID
+			update = append(update, entry)
+		}
+	}
+
+	if len(create) == 0 && len(update) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(remove) > 0 {
+			if err := tx.Delete(&SearchEntry{}, remove).Error; err != nil {
+				return fmt.Errorf("failed to delete search entries: %w", err)
+			}
+		}
+		for _, entry := range update {
+			if err := tx.Save(&entry).Error; err != nil {
+				return fmt.Errorf("failed to update search entry: %w", err)
+			}
+		}
+		if len(create) > 0 {
+			if err := tx.CreateInBatches(create, 100).Error; err != nil {
+				return fmt.Errorf("failed to create search entries: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Search returns the messages in the account's sessions that match the query, best match first.
+func (s *Store) Search(ctx context.Context, accountID, query string, limit int) ([]SearchResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+	if Encrypted() {
+		return nil, fmt.Errorf("search is not available when the session state is encrypted")
+	}
+
+	var (
+		results []SearchResult
+		db      = s.db.WithContext(ctx)
+	)
+
+	switch s.searchDialect {
+	case searchDialectFTS5:
+		err := db.Raw(`SELECT e.session_id, s.description AS title, e.message_id, e.role, e.created,
+				snippet(search_entries_fts, 0, '**', '**', '…', 16) AS snippet
+			FROM search_entries_fts
+			JOIN search_entries e ON e.id = search_entries_fts.rowid
+			JOIN sessions s ON s.session_id = e.session_id AND s.deleted_at IS NULL
+			WHERE search_entries_fts MATCH ? AND s.account_id = ?
+			ORDER BY rank
+			LIMIT ?`, fts5Query(terms), accountID, limit).Scan(&results).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
+	case searchDialectTSVector:
+		err := db.Raw(`SELECT e.session_id, s.description AS title, e.message_id, e.role, e.created,
+				ts_headline('simple', e.text, q, 'StartSel=**, StopSel=**, MaxWords=24, MinWords=8') AS snippet
+			FROM search_entries e
+			JOIN sessions s ON s.session_id = e.session_id AND s.deleted_at IS NULL,
+				plainto_tsquery('simple', ?) q
+			WHERE to_tsvector('simple', e.text) @@ q AND s.account_id = ?
+			ORDER BY ts_rank(to_tsvector('simple', e.text), q) DESC
+			LIMIT ?`, strings.Join(terms, " "), accountID, limit).Scan(&results).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
+	default:
+		tx := db.Table("search_entries AS e").
+			Select("e.session_id, s.description AS title, e.message_id, e.role, e.created, e.text").
+			Joins("JOIN sessions s ON s.session_id = e.session_id AND s.deleted_at IS NULL").
+			Where("s.account_id = ?", accountID)
+		for _, term := range terms {
+			tx = tx.Where(`LOWER(e.text) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(term))+"%")
+		}
+		if err := tx.Order("e.created DESC").Limit(limit).Scan(&results).Error; err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
+		for i := range results {
+			results[i].Snippet = snippet(results[i].Text, terms)
+		}
+	}
+
+	return results, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE, so that search terms match literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// searchText returns the text of a message that is indexed: the text content and the names of the
+// tools that were called.
+func searchText(msg types.Message) string {
+	var parts []string
+	for _, item := range msg.Items {
+		if item.Content != nil && item.Content.Text != "" {
+			parts = append(parts, item.Content.Text)
+		}
+		if item.ToolCall != nil && item.ToolCall.Name != "" {
+			parts = append(parts, item.ToolCall.Name)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func searchHash(role, text string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(role))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(text))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// fts5Query turns free text into an FTS5 query that matches all terms as prefixes, quoting them so
+// that FTS5 operators in user input are not interpreted.
+func fts5Query(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// snippet returns the text around the first matching term, with the term highlighted the same way
+// as the full-text backends do.
+func snippet(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Case folding changed byte offsets, match case sensitively instead.
+		lower = text
+	}
+	start, length := -1, 0
+	for _, term := range terms {
+		if i := strings.Index(lower, strings.ToLower(term)); i >= 0 && (start == -1 || i < start) {
+			start, length = i, len(term)
+		}
+	}
+	if start == -1 {
+		start = 0
+	}
+
+	from, to := max(start-snippetRadius, 0), min(start+length+snippetRadius, len(text))
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	if length > 0 {
+		b.WriteString(text[from:start])
+		b.WriteString("**")
+		b.WriteString(text[start : start+length])
+		b.WriteString("**")
+		b.WriteString(text[start+length : to])
+	} else {
+		b.WriteString(text[from:to])
+	}
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
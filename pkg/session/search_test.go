@@ -0,0 +1,163 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func textMessage(id, role, text string) types.Message {
+	return types.Message{
+		ID:   id,
+		Role: role,
+		Items: []types.CompletionItem{
+			{Content: &mcp.Content{Type: "text", Text: text}},
+		},
+	}
+}
+
+func TestSearch(t *testing.T) {
+	for _, dialect := range []string{searchDialectFTS5, ""} {
+		name := dialect
+		if name == "" {
+			name = "like"
+		}
+		t.Run(name, func(t *testing.T) {
+			store := testStore(t)
+			if store.searchDialect != searchDialectFTS5 {
+				t.Fatalf("expected sqlite store to use FTS5, got %q", store.searchDialect)
+			}
+			store.searchDialect = dialect
+			ctx := t.Context()
+
+			for _, s := range []struct{ id, account string }{{"chat-1", "alice"}, {"chat-2", "alice"}, {"chat-3", "bob"}} {
+				if err := store.Create(ctx, &Session{SessionID: s.id, AccountID: s.account, Description: "Title " + s.id}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := store.IndexMessages(ctx, "chat-1", []types.Message{
+				textMessage("m1", "user", "How do I configure the kubernetes ingress controller?"),
+				{
+					ID:   "m2",
+					Role: "assistant",
+					Items: []types.CompletionItem{
+						{ToolCall: &types.ToolCall{Name: "search_docs", Arguments: `{"q":"ingress"}`}},
+					},
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.IndexMessages(ctx, "chat-2", []types.Message{
+				textMessage("m3", "user", "Write a poem about the ocean"),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.IndexMessages(ctx, "chat-3", []types.Message{
+				textMessage("m4", "user", "Kubernetes ingress for bob"),
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := store.Search(ctx, "alice", "kubernetes ingress", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].SessionID != "chat-1" || results[0].MessageID != "m1" {
+				t.Fatalf("unexpected results: %+v", results)
+			}
+			if results[0].Title != "Title chat-1" || !strings.Contains(results[0].Snippet, "**") {
+				t.Fatalf("expected title and highlighted snippet, got %+v", results[0])
+			}
+
+			results, err = store.Search(ctx, "alice", "search_docs", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].MessageID != "m2" {
+				t.Fatalf("expected tool call name to match, got %+v", results)
+			}
+
+			// Reindexing replaces changed messages and drops removed ones.
+			if err := store.IndexMessages(ctx, "chat-1", []types.Message{
+				textMessage("m1", "user", "How do I configure a load balancer?"),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			results, err = store.Search(ctx, "alice", "ingress", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 0 {
+				t.Fatalf("expected no results after reindex, got %+v", results)
+			}
+
+			// Deleted sessions are not searchable.
+			if err := store.Delete(ctx, "chat-2"); err != nil {
+				t.Fatal(err)
+			}
+			results, err = store.Search(ctx, "alice", "ocean", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 0 {
+				t.Fatalf("expected deleted session to be excluded, got %+v", results)
+			}
+		})
+	}
+}
+
+func TestFTS5QueryEscapesOperators(t *testing.T) {
+	store := testStore(t)
+	ctx := t.Context()
+
+	if err := store.Create(ctx, &Session{SessionID: "chat", AccountID: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IndexMessages(ctx, "chat", []types.Message{textMessage("m1", "user", `say "hello" OR NOT`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{`"hello`, `OR NOT`, `hel*`, `(hello`} {
+		if _, err := store.Search(ctx, "alice", query, 0); err != nil {
+			t.Errorf("query %q: %v", query, err)
+		}
+	}
+}
+
+func TestLikeSearchEscapesWildcards(t *testing.T) {
+	store := testStore(t)
+	store.searchDialect = ""
+	ctx := t.Context()
+
+	if err := store.Create(ctx, &Session{SessionID: "chat", AccountID: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IndexMessages(ctx, "chat", []types.Message{
+		textMessage("m1", "user", "the disk is 100% full"),
+		textMessage("m2", "user", "1000 widgets in abc"),
+		textMessage("m3", "user", `a_b and c\d`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for query, expected := range map[string]string{"100%": "m1", "a_b": "m3", `c\d`: "m3"} {
+		results, err := store.Search(ctx, "alice", query, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].MessageID != expected {
+			t.Errorf("expected %q to only match %s, got %+v", query, expected, results)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("lorem ", 30) + "needle" + strings.Repeat(" ipsum", 30)
+	got := snippet(text, []string{"NEEDLE"})
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "**needle**") {
+		t.Fatalf("unexpected snippet: %q", got)
+	}
+}
@@ -23,7 +23,8 @@ const (
 )
 
 type Store struct {
-	db *gorm.DB
+	db            *gorm.DB
+	searchDialect string
 }
 
 func NewStore(db *gorm.DB) *Store {
@@ -45,7 +46,7 @@ func NewStoreFromDSN(dsn string) (store *Store, err error) {
 		}
 	}()
 
-	if err := tx.AutoMigrate(&Session{}, &Token{}, &WorkflowRun{}, &ScheduledTask{}); err != nil {
+	if err := tx.AutoMigrate(&Session{}, &Token{}, &WorkflowRun{}, &WorkflowCheckpoint{}, &AgentRun{}, &ScheduledTask{}, &Lease{}, &SearchEntry{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate schema: %w", err)
 	}
 
@@ -53,7 +54,7 @@ func NewStoreFromDSN(dsn string) (store *Store, err error) {
 		return nil, fmt.Errorf("failed to migrate session workflow URIs: %w", err)
 	}
 
-	return &Store{db: db}, nil
+	return &Store{db: db, searchDialect: migrateSearch(tx)}, nil
 }
 
 func (s *Store) Create(ctx context.Context, session *Session) error {
@@ -160,6 +161,19 @@ func (s *Store) RecordScheduledTaskRun(ctx context.Context, taskURI string, last
 		}).Error
 }
 
+// ClaimScheduledTaskRun records a run of a scheduled task that was due at dueAt, but only if no run at
+// or after dueAt has been recorded yet. It returns false if another replica already claimed the run.
+func (s *Store) ClaimScheduledTaskRun(ctx context.Context, taskURI string, dueAt, lastRunAt time.Time, nextRunAt *time.Time) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&ScheduledTask{}).
+		Where("task_uri = ? AND (last_run_at IS NULL OR last_run_at < ?)", taskURI, dueAt).
+		Updates(map[string]any{
+			"last_run_at": lastRunAt,
+			"next_run_at": nextRunAt,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
 // DeleteScheduledTask deletes a scheduled task by its task URI.
 func (s *Store) DeleteScheduledTask(ctx context.Context, taskURI string) error {
 	return s.db.WithContext(ctx).
@@ -256,6 +270,45 @@ func (s *Store) AddWorkflowRun(ctx context.Context, sessionID, workflowURI strin
 	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&run).Error
 }
 
+// SaveWorkflowCheckpoint creates or updates the checkpoint of a workflow run.
+func (s *Store) SaveWorkflowCheckpoint(ctx context.Context, checkpoint *WorkflowCheckpoint) error {
+	if checkpoint.RunID == "" {
+		return fmt.Errorf("run ID cannot be empty")
+	}
+	if checkpoint.SessionID == "" {
+		return fmt.Errorf("session ID cannot be empty")
+	}
+	return s.db.WithContext(ctx).Save(checkpoint).Error
+}
+
+// GetWorkflowCheckpoint returns the checkpoint of a workflow run of a session.
+func (s *Store) GetWorkflowCheckpoint(ctx context.Context, sessionID, runID string) (*WorkflowCheckpoint, error) {
+	var checkpoint WorkflowCheckpoint
+	err := s.db.WithContext(ctx).Where("session_id = ? AND run_id = ?", sessionID, runID).First(&checkpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// ClaimWorkflowCheckpoint changes the status and owner of a workflow run, if the run still has the
+// status and owner of the checkpoint. It returns false if the run changed since the checkpoint was
+// read, like when it was resumed already.
+func (s *Store) ClaimWorkflowCheckpoint(ctx context.Context, checkpoint *WorkflowCheckpoint, status, owner string) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&WorkflowCheckpoint{}).
+		Where("session_id = ? AND run_id = ? AND status = ? AND owner = ?", checkpoint.SessionID, checkpoint.RunID, checkpoint.Status, checkpoint.Owner).
+		Updates(map[string]any{
+			"status": status,
+			"owner":  owner,
+		})
+	if result.RowsAffected == 1 {
+		checkpoint.Status = status
+		checkpoint.Owner = owner
+	}
+	return result.RowsAffected == 1, result.Error
+}
+
 func (s *Store) List(ctx context.Context) ([]Session, error) {
 	var sessions []Session
 	err := s.db.WithContext(ctx).Order("updated_at desc").Find(&sessions).Error
@@ -280,7 +333,12 @@ func (s *Store) GetTokenConfig(ctx context.Context, url string) (*oauth2.Config,
 		return nil, nil, err
 	}
 
-	err = json.Unmarshal([]byte(token.Data), &struct {
+	data, err := decryptString(token.Data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt token for %s: %w", url, err)
+	}
+
+	err = json.Unmarshal([]byte(data), &struct {
 		Config *oauth2.Config `json:"config,omitempty"`
 		Token  *oauth2.Token  `json:"token,omitempty"`
 	}{
@@ -318,13 +376,52 @@ func (s *Store) SetTokenConfig(ctx context.Context, url string, oauth2Config *oa
 		return fmt.Errorf("failed to marshal token data: %w", err)
 	}
 
-	token.Data = string(tokenData)
+	token.Data, err = encryptString(string(tokenData))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token data: %w", err)
+	}
 	if token.ID == 0 {
 		return s.db.WithContext(ctx).Create(&token).Error
 	}
 	return s.db.WithContext(ctx).Save(&token).Error
 }
 
+// ListTokenConfigs returns the tokens of the account of the session in the context, or of every
+// account if there is no session.
+func (s *Store) ListTokenConfigs(ctx context.Context) ([]mcp.StoredToken, error) {
+	var tokens []Token
+	db := s.db.WithContext(ctx).Order("url, account_id")
+	var accountID string
+	if mcp.SessionFromContext(ctx).Get(types.AccountIDSessionKey, &accountID) {
+		db = db.Where("account_id = ?", accountID)
+	}
+	if err := db.Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	result := make([]mcp.StoredToken, 0, len(tokens))
+	for _, token := range tokens {
+		var data struct {
+			Config *oauth2.Config `json:"config,omitempty"`
+			Token  *oauth2.Token  `json:"token,omitempty"`
+		}
+		decrypted, err := decryptString(token.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt token for %s: %w", token.URL, err)
+		}
+		if err := json.Unmarshal([]byte(decrypted), &data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal token for %s: %w", token.URL, err)
+		}
+		result = append(result, mcp.StoredToken{
+			URL:       token.URL,
+			AccountID: token.AccountID,
+			Config:    data.Config,
+			Token:     data.Token,
+		})
+	}
+	return result, nil
+}
+
 func (s *Store) DeleteTokenConfig(ctx context.Context, url string) error {
 	var accountID string
 	session := mcp.SessionFromContext(ctx)
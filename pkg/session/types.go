@@ -24,21 +24,45 @@ func (c *ConfigWrapper) Scan(value any) error {
 type Env map[string]string
 
 func (e Env) Value() (driver.Value, error) {
-	return json.Marshal(e)
+	return encryptedValue(e)
 }
 
 func (e *Env) Scan(value any) error {
-	return scan(value, e)
+	return scanEncrypted(value, e)
 }
 
 type State mcp.SessionState
 
 func (m State) Value() (driver.Value, error) {
-	return json.Marshal(m)
+	return encryptedValue(m)
 }
 
 func (m *State) Scan(value any) error {
-	return scan(value, m)
+	return scanEncrypted(value, m)
+}
+
+// encryptedValue returns the JSON of obj, encrypted if a keyring is set.
+func encryptedValue(obj any) (driver.Value, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return encryptJSON(data)
+}
+
+// scanEncrypted is scan for the values written by encryptedValue.
+func scanEncrypted(value any, obj any) error {
+	if data, ok := value.(string); ok {
+		value = []byte(data)
+	}
+	if data, ok := value.([]byte); ok {
+		decrypted, err := decryptJSON(data)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %T: %w", obj, err)
+		}
+		value = decrypted
+	}
+	return scan(value, obj)
 }
 
 func scan(value any, obj any) error {
@@ -64,6 +88,7 @@ type Session struct {
 	State       State         `json:"state" gorm:"type:json"`
 	Config      ConfigWrapper `json:"config,omitempty" gorm:"type:json"`
 	Cwd         string        `json:"cwd,omitempty"`
+	Starred     bool          `json:"starred,omitempty" gorm:"not null;default:false"`
 }
 
 // WorkflowRun records that a workflow was executed within a session.
@@ -72,6 +97,37 @@ type WorkflowRun struct {
 	WorkflowURI string `json:"workflowURI" gorm:"primaryKey;not null"`
 }
 
+// WorkflowCheckpoint is the progress of a run of a workflow with steps. It is
+// saved after every step, so a failed or interrupted run can be resumed.
+type WorkflowCheckpoint struct {
+	RunID       string `json:"runId" gorm:"primaryKey"`
+	SessionID   string `json:"sessionId" gorm:"index;not null"`
+	WorkflowURI string `json:"workflowURI" gorm:"not null"`
+	Status      string `json:"status"`
+	// Owner is the process that runs or last ran the run, see Manager.RunOwner.
+	Owner     string        `json:"-"`
+	State     WorkflowState `json:"state" gorm:"type:json"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// WorkflowState is the inputs of a workflow run and the outputs of the steps
+// that completed, Next is the index of the step to run next.
+type WorkflowState struct {
+	Inputs map[string]any `json:"inputs,omitempty"`
+	Steps  map[string]any `json:"steps,omitempty"`
+	Next   int            `json:"next"`
+	Error  string         `json:"error,omitempty"`
+}
+
+func (w WorkflowState) Value() (driver.Value, error) {
+	return encryptedValue(w)
+}
+
+func (w *WorkflowState) Scan(value any) error {
+	return scanEncrypted(value, w)
+}
+
 type Token struct {
 	gorm.Model
 	AccountID string `json:"accountID,omitempty"`
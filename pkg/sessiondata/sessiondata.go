@@ -409,11 +409,9 @@ func (d *Data) getPublishedMCPServers(ctx context.Context) (result []string) {
 	)
 	session.Get(types.ConfigSessionKey, &c)
 
-	for _, e := range c.Publish.Entrypoint {
-		if _, ok := c.Agents[e]; ok {
-			result = append(result, e)
-		}
-	}
+	// The A2A endpoint calls its agents through the chat tools of this server, so the agents
+	// published over A2A are published here too.
+	result = append(result, c.A2AAgents()...)
 
 	result = append(result, c.Publish.MCPServers...)
 	return result
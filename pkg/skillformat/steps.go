@@ -0,0 +1,91 @@
+package skillformat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Step is a step of a workflow. Exactly one of Tool, Agent, Script and Input
+// must be set. String values can use ${...} expressions, which see the inputs
+// of the run as inputs, the outputs of the previous steps as
+// steps.<id>.output and, in a foreach loop, the current item and index.
+type Step struct {
+	ID string `yaml:"id"`
+	// If is an expression, the step is skipped when it is false.
+	If string `yaml:"if,omitempty"`
+	// Foreach is an expression that returns a list, the step runs once for
+	// every item and its output is the list of the outputs.
+	Foreach string `yaml:"foreach,omitempty"`
+	// Tool is the tool to call in the form server/tool, with Args.
+	Tool string         `yaml:"tool,omitempty"`
+	Args map[string]any `yaml:"args,omitempty"`
+	// Agent is the name of the agent to call with Prompt.
+	Agent  string `yaml:"agent,omitempty"`
+	Prompt string `yaml:"prompt,omitempty"`
+	// Script is JavaScript run as the body of a function, the value it
+	// returns is the output.
+	Script string `yaml:"script,omitempty"`
+	// Input asks the user to fill in fields, the output is the answers.
+	Input *StepInput `yaml:"input,omitempty"`
+}
+
+// StepInput is a question to the user.
+type StepInput struct {
+	Message string `yaml:"message"`
+	// Fields are the names of the answers and their descriptions.
+	Fields map[string]string `yaml:"fields,omitempty"`
+}
+
+// Kind returns the kind of the step: tool, agent, script or input.
+func (s Step) Kind() string {
+	switch {
+	case s.Tool != "":
+		return "tool"
+	case s.Agent != "":
+		return "agent"
+	case s.Script != "":
+		return "script"
+	case s.Input != nil:
+		return "input"
+	}
+	return ""
+}
+
+// ValidateSteps checks that every step has a unique ID and exactly one kind.
+func ValidateSteps(steps []Step) error {
+	var (
+		errs []error
+		ids  = map[string]struct{}{}
+	)
+	for i, step := range steps {
+		if step.ID == "" {
+			errs = append(errs, fmt.Errorf("step %d: id must not be empty", i))
+		} else if _, ok := ids[step.ID]; ok {
+			errs = append(errs, fmt.Errorf("step %d: duplicate id %q", i, step.ID))
+		} else if strings.ContainsAny(step.ID, ". ") {
+			errs = append(errs, fmt.Errorf("step %d: id %q must not contain dots or spaces", i, step.ID))
+		}
+		ids[step.ID] = struct{}{}
+
+		kinds := 0
+		for _, set := range []bool{step.Tool != "", step.Agent != "", step.Script != "", step.Input != nil} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			errs = append(errs, fmt.Errorf("step %d: exactly one of tool, agent, script and input must be set", i))
+		}
+		if step.Tool != "" && !strings.Contains(step.Tool, "/") {
+			errs = append(errs, fmt.Errorf("step %d: tool %q must be in the form server/tool", i, step.Tool))
+		}
+		if step.Agent != "" && step.Prompt == "" {
+			errs = append(errs, fmt.Errorf("step %d: prompt must be set for an agent", i))
+		}
+		if step.Input != nil && step.Input.Message == "" {
+			errs = append(errs, fmt.Errorf("step %d: input message must not be empty", i))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -26,6 +26,9 @@ type Frontmatter struct {
 	Compatibility string            `yaml:"compatibility,omitempty"`
 	Metadata      map[string]string `yaml:"metadata,omitempty"`
 	AllowedTools  string            `yaml:"allowed-tools,omitempty"`
+	// Steps are run in order by the workflow engine instead of leaving the
+	// execution of a workflow to the model. Only used by workflows.
+	Steps []Step `yaml:"steps,omitempty"`
 }
 
 var nameRegexp = regexp.MustCompile(`^[a-z0-9-]+$`)
@@ -78,6 +81,9 @@ func ValidateFrontmatter(fm Frontmatter) error {
 	if fm.Compatibility != "" && len(fm.Compatibility) > 500 {
 		errs = append(errs, fmt.Errorf("compatibility must be at most 500 characters, got %d", len(fm.Compatibility)))
 	}
+	if err := ValidateSteps(fm.Steps); err != nil {
+		errs = append(errs, fmt.Errorf("invalid steps: %w", err))
+	}
 	return errors.Join(errs...)
 }
 
@@ -175,6 +181,9 @@ func FrontmatterToMeta(fm Frontmatter) map[string]any {
 	if fm.AllowedTools != "" {
 		meta["allowedTools"] = fm.AllowedTools
 	}
+	if len(fm.Steps) > 0 {
+		meta["steps"] = len(fm.Steps)
+	}
 	for k, v := range fm.Metadata {
 		meta[k] = v
 	}
@@ -159,6 +159,32 @@ func TestValidateFrontmatter_MultipleErrors(t *testing.T) {
 	}
 }
 
+func TestValidateSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []Step
+		expectErr bool
+	}{
+		{"no steps", nil, false},
+		{"valid", []Step{{ID: "a", Tool: "git/log"}, {ID: "b", Agent: "writer", Prompt: "hi"}, {ID: "c", Script: "return 1"}, {ID: "d", Input: &StepInput{Message: "ok?"}}}, false},
+		{"missing id", []Step{{Tool: "git/log"}}, true},
+		{"duplicate id", []Step{{ID: "a", Tool: "git/log"}, {ID: "a", Script: "return 1"}}, true},
+		{"id with dot", []Step{{ID: "a.b", Tool: "git/log"}}, true},
+		{"no kind", []Step{{ID: "a"}}, true},
+		{"two kinds", []Step{{ID: "a", Tool: "git/log", Script: "return 1"}}, true},
+		{"tool without server", []Step{{ID: "a", Tool: "log"}}, true},
+		{"agent without prompt", []Step{{ID: "a", Agent: "writer"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSteps(tt.steps)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("ValidateSteps() error = %v, expectErr = %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
 func TestValidateNameMatchesDir(t *testing.T) {
 	tests := []struct {
 		name      string
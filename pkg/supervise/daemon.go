@@ -15,7 +15,12 @@ func Daemon() error {
 		return err
 	}
 
-	cmd := exec.CommandContext(ctx, os.Args[2], os.Args[3:]...)
+	if err := applyDaemonLimits(); err != nil {
+		return err
+	}
+
+	command, args := daemonCommand(os.Args[2], os.Args[3:])
+	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	configureDaemonCommand(cmd)
@@ -0,0 +1,153 @@
+package supervise
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/nanobot-ai/nanobot/pkg/system"
+)
+
+const (
+	// openFilesEnv passes the open files limit to the _exec supervisor, which sets it before
+	// starting the command.
+	openFilesEnv = "NANOBOT_EXEC_OPEN_FILES"
+	// memoryEnv and processesEnv pass the memory and process limits that are enforced with rlimits
+	// instead of a cgroup. The _exec supervisor starts the command with _rlimit, which sets them
+	// right before it executes the command, see ExecLimited.
+	memoryEnv    = "NANOBOT_EXEC_MEMORY"
+	processesEnv = "NANOBOT_EXEC_PROCESSES"
+)
+
+// Limits are the resource limits of a supervised command and all of its children. Zero values are
+// unlimited.
+type Limits struct {
+	// Memory is the maximum memory in bytes.
+	Memory int64
+	// CPUShares is the relative CPU weight, 1024 being the default of other processes.
+	CPUShares int
+	// Pids is the maximum number of processes and threads.
+	Pids int
+	// OpenFiles is the maximum number of open files per process.
+	OpenFiles int
+}
+
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// Limiter enforces Limits on a command created by Cmd.
+type Limiter struct {
+	limits Limits
+	group  *cgroup
+}
+
+// Limit enforces the limits on cmd, which must be created by Cmd and not be started yet. Memory, CPU
+// and process limits are enforced with a cgroup v2 on Linux. Without cgroups, memory and process
+// limits fall back to rlimits on Linux, which are weaker: the memory limit is of the address space of
+// each process, and the process limit counts all the processes of the user. An error is returned if
+// the limits can not be enforced. Close must be called once the command has exited.
+func Limit(cmd *exec.Cmd, name string, limits Limits) (*Limiter, error) {
+	limiter := &Limiter{
+		limits: limits,
+	}
+	if limits.OpenFiles > 0 {
+		if runtime.GOOS == "windows" {
+			return nil, fmt.Errorf("open files limits are not supported on %s", runtime.GOOS)
+		}
+		setLimitEnv(cmd, openFilesEnv, int64(limits.OpenFiles))
+	}
+	if limits.Memory == 0 && limits.CPUShares == 0 && limits.Pids == 0 {
+		return limiter, nil
+	}
+
+	group, err := newCgroup(name, limits)
+	if err == nil {
+		limiter.group = group
+		group.apply(cmd)
+		return limiter, nil
+	}
+	if !rlimitFallback || limits.CPUShares > 0 {
+		return nil, fmt.Errorf("memory, CPU and process limits can not be enforced: %w", err)
+	}
+
+	slog.Info("cgroups are not available, limiting memory and processes with rlimits", "command", name, "reason", err)
+	if limits.Memory > 0 {
+		setLimitEnv(cmd, memoryEnv, limits.Memory)
+	}
+	if limits.Pids > 0 {
+		setLimitEnv(cmd, processesEnv, int64(limits.Pids))
+	}
+	return limiter, nil
+}
+
+func setLimitEnv(cmd *exec.Cmd, key string, value int64) {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, key+"="+strconv.FormatInt(value, 10))
+}
+
+// Violation returns the limit that made the command fail, if any. It must be called after the
+// command exited and before Close.
+func (l *Limiter) Violation() (limit, message string) {
+	if l == nil || l.group == nil {
+		return "", ""
+	}
+	return l.group.violation(l.limits)
+}
+
+// Close kills whatever is left of the command and releases the resources used to enforce the limits.
+func (l *Limiter) Close() {
+	if l == nil || l.group == nil {
+		return
+	}
+	l.group.close()
+}
+
+// applyDaemonLimits sets the limits passed by Limit on the _exec supervisor so that the command
+// inherits them.
+func applyDaemonLimits() error {
+	value, ok := os.LookupEnv(openFilesEnv)
+	if !ok {
+		return nil
+	}
+	_ = os.Unsetenv(openFilesEnv)
+
+	openFiles, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", openFilesEnv, err)
+	}
+	return setOpenFilesLimit(openFiles)
+}
+
+// daemonCommand returns the command the _exec supervisor starts. A command with rlimits is started
+// with _rlimit, so that the rlimits don't apply to the supervisor.
+func daemonCommand(command string, args []string) (string, []string) {
+	_, memory := os.LookupEnv(memoryEnv)
+	_, processes := os.LookupEnv(processesEnv)
+	if !memory && !processes {
+		return command, args
+	}
+	return system.Bin(), append([]string{"_rlimit", command}, args...)
+}
+
+// FormatBytes formats a number of bytes for a limit in messages.
+func FormatBytes(n int64) string {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"GiB", 1 << 30},
+		{"MiB", 1 << 20},
+		{"KiB", 1 << 10},
+	} {
+		if n >= unit.size && n%unit.size == 0 {
+			return strconv.FormatInt(n/unit.size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
@@ -0,0 +1,280 @@
+//go:build linux
+
+package supervise
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/uuid"
+	"golang.org/x/sys/unix"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// rlimitFallback is whether memory and process limits are enforced with rlimits without cgroups.
+const rlimitFallback = true
+
+// cgroup is a cgroup v2 that a command and all of its children are started in.
+type cgroup struct {
+	dir string
+	fd  *os.File
+}
+
+func newCgroup(name string, limits Limits) (*cgroup, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not mounted at %s", cgroupRoot)
+	}
+
+	parent, err := cgroupParent()
+	if err != nil {
+		return nil, err
+	}
+
+	var controllers []string
+	if limits.Memory > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.CPUShares > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if limits.Pids > 0 {
+		controllers = append(controllers, "pids")
+	}
+	if err := delegateControllers(parent, controllers); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(parent, "nanobot-"+sanitizeCgroupName(name)+"-"+strings.Split(uuid.String(), "-")[0])
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	group := &cgroup{dir: dir}
+
+	var settings [][2]string
+	if limits.Memory > 0 {
+		settings = append(settings, [2]string{"memory.max", strconv.FormatInt(limits.Memory, 10)})
+		if _, err := os.Stat(filepath.Join(dir, "memory.swap.max")); err == nil {
+			settings = append(settings, [2]string{"memory.swap.max", "0"})
+		}
+	}
+	if limits.CPUShares > 0 {
+		settings = append(settings, [2]string{"cpu.weight", strconv.Itoa(cpuWeight(limits.CPUShares))})
+	}
+	if limits.Pids > 0 {
+		settings = append(settings, [2]string{"pids.max", strconv.Itoa(limits.Pids)})
+	}
+	for _, setting := range settings {
+		if err := os.WriteFile(filepath.Join(dir, setting[0]), []byte(setting[1]), 0644); err != nil {
+			group.close()
+			return nil, fmt.Errorf("failed to set %s: %w", setting[0], err)
+		}
+	}
+
+	group.fd, err = os.Open(dir)
+	if err != nil {
+		group.close()
+		return nil, fmt.Errorf("failed to open cgroup: %w", err)
+	}
+	return group, nil
+}
+
+// cgroupParent is the cgroup the cgroups of commands are created in, the cgroup this process runs in.
+var cgroupParent = sync.OnceValues(ownCgroup)
+
+// ownCgroup returns the directory of the cgroup this process is in.
+func ownCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("failed to read own cgroup: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return filepath.Join(cgroupRoot, path), nil
+		}
+	}
+	return "", fmt.Errorf("process is not in a cgroup v2")
+}
+
+// cgroupLock serializes delegating the controllers of the parent cgroup.
+var cgroupLock sync.Mutex
+
+// delegateControllers makes the controllers available to the children of parent. A cgroup with
+// processes can not delegate controllers, so when parent has processes, which it does when it is
+// the cgroup nanobot runs in, they are moved to a leaf cgroup first and the cgroups of commands are
+// created next to it.
+func delegateControllers(parent string, controllers []string) error {
+	cgroupLock.Lock()
+	defer cgroupLock.Unlock()
+
+	err := enableControllers(parent, controllers)
+	if !errors.Is(err, syscall.EBUSY) {
+		return err
+	}
+	if err := moveToLeaf(parent); err != nil {
+		return err
+	}
+	return enableControllers(parent, controllers)
+}
+
+// moveToLeaf moves all the processes of parent to its child cgroup nanobot. The processes of
+// parent are the ones of nanobot, and of anything else started in the same cgroup, like the other
+// processes of a container.
+func moveToLeaf(parent string) error {
+	leaf := filepath.Join(parent, "nanobot")
+	if err := os.Mkdir(leaf, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("failed to create leaf cgroup: %w", err)
+	}
+
+	// Processes started while moving stay in parent, move until there are none left.
+	for range 10 {
+		procs, err := os.ReadFile(filepath.Join(parent, "cgroup.procs"))
+		if err != nil {
+			return fmt.Errorf("failed to read processes of cgroup %s: %w", parent, err)
+		}
+		pids := strings.Fields(string(procs))
+		if len(pids) == 0 {
+			return nil
+		}
+		for _, pid := range pids {
+			err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(pid), 0644)
+			if err != nil && !errors.Is(err, syscall.ESRCH) {
+				return fmt.Errorf("failed to move process %s to cgroup %s: %w", pid, leaf, err)
+			}
+		}
+	}
+	return fmt.Errorf("failed to move the processes of cgroup %s to cgroup %s", parent, leaf)
+}
+
+// enableControllers makes the controllers available to the children of parent. It fails with
+// syscall.EBUSY if parent has processes.
+func enableControllers(parent string, controllers []string) error {
+	enabled, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return fmt.Errorf("failed to read cgroup controllers: %w", err)
+	}
+
+	var missing []string
+	for _, controller := range controllers {
+		if !strings.Contains(" "+strings.TrimSpace(string(enabled))+" ", " "+controller+" ") {
+			missing = append(missing, "+"+controller)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	subtreeControl := filepath.Join(parent, "cgroup.subtree_control")
+	err = os.WriteFile(subtreeControl, []byte(strings.Join(missing, " ")), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to enable cgroup controllers %s: %w", strings.Join(missing, " "), err)
+	}
+	return nil
+}
+
+// cpuWeight converts CPU shares to a cgroup v2 CPU weight the same way container runtimes do.
+func cpuWeight(shares int) int {
+	shares = min(max(shares, 2), 262144)
+	return 1 + ((shares-2)*9999)/262142
+}
+
+func sanitizeCgroupName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// apply starts cmd directly in the cgroup so that none of its processes can escape the limits.
+func (c *cgroup) apply(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.fd.Fd())
+}
+
+func (c *cgroup) violation(limits Limits) (string, string) {
+	if limits.Memory > 0 && eventCount(filepath.Join(c.dir, "memory.events"), "oom_kill") > 0 {
+		return "memory", fmt.Sprintf("memory limit of %s exceeded", FormatBytes(limits.Memory))
+	}
+	if limits.Pids > 0 && eventCount(filepath.Join(c.dir, "pids.events"), "max") > 0 {
+		return "pids", fmt.Sprintf("process limit of %d reached", limits.Pids)
+	}
+	return "", ""
+}
+
+func eventCount(file, event string) int {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	for line := range strings.Lines(string(data)) {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), event+" "); ok {
+			n, _ := strconv.Atoi(value)
+			return n
+		}
+	}
+	return 0
+}
+
+func (c *cgroup) close() {
+	if c.fd != nil {
+		_ = c.fd.Close()
+	}
+	_ = os.WriteFile(filepath.Join(c.dir, "cgroup.kill"), []byte("1"), 0644)
+	for range 50 {
+		if err := os.Remove(c.dir); err == nil || errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// ExecLimited is the _rlimit entrypoint that the _exec supervisor starts commands with when their
+// memory and process limits are enforced with rlimits. It sets the rlimits and replaces itself with
+// the command.
+func ExecLimited(args []string) error {
+	command, err := exec.LookPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, limit := range []struct {
+		env      string
+		resource int
+		name     string
+	}{
+		{memoryEnv, unix.RLIMIT_AS, "memory"},
+		{processesEnv, unix.RLIMIT_NPROC, "process"},
+	} {
+		value, ok := os.LookupEnv(limit.env)
+		if !ok {
+			continue
+		}
+		_ = os.Unsetenv(limit.env)
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s limit %q: %w", limit.name, value, err)
+		}
+		if err := unix.Setrlimit(limit.resource, &unix.Rlimit{Cur: n, Max: n}); err != nil {
+			return fmt.Errorf("failed to set %s limit: %w", limit.name, err)
+		}
+	}
+
+	return syscall.Exec(command, args, os.Environ())
+}
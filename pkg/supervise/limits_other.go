@@ -0,0 +1,31 @@
+//go:build !linux
+
+package supervise
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// rlimitFallback is whether memory and process limits are enforced with rlimits without cgroups.
+const rlimitFallback = false
+
+type cgroup struct{}
+
+func newCgroup(string, Limits) (*cgroup, error) {
+	return nil, fmt.Errorf("cgroups are not supported on %s", runtime.GOOS)
+}
+
+func (*cgroup) apply(*exec.Cmd) {}
+
+func (*cgroup) violation(Limits) (string, string) {
+	return "", ""
+}
+
+func (*cgroup) close() {}
+
+// ExecLimited is the _rlimit entrypoint, which is only used on Linux.
+func ExecLimited([]string) error {
+	return fmt.Errorf("rlimits are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package supervise
+
+func setOpenFilesLimit(uint64) error {
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
@@ -269,3 +270,21 @@ func killIfAlive(t *testing.T, pid int) {
 		t.Logf("failed to kill leaked process %d: %v", pid, err)
 	}
 }
+
+func TestExecSupervisorAppliesRlimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("rlimits are only used on linux")
+	}
+	repoRoot := superviseRepoRoot(t)
+	binPath := buildNanobotBinary(t, repoRoot)
+
+	cmd := exec.Command(binPath, "_exec", "sh", "-c", `ulimit -v; env | grep -c NANOBOT_EXEC_MEMORY || true`)
+	cmd.Env = append(os.Environ(), "NANOBOT_BIN="+binPath, "NANOBOT_EXEC_MEMORY=1073741824")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("supervisor failed: %v", err)
+	}
+	if lines := strings.Fields(string(output)); len(lines) != 2 || lines[0] != "1048576" || lines[1] != "0" {
+		t.Fatalf("expected a 1048576 KiB address space limit and no limit env, got %q", output)
+	}
+}
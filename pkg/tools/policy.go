@@ -0,0 +1,355 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nanobot-ai/nanobot/pkg/envvar"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// policyDecision is the result of evaluating the policies for a tool call.
+type policyDecision struct {
+	Action    types.PolicyAction
+	Reason    string
+	Arguments map[string]any
+	Rewritten bool
+}
+
+// evaluatePolicies returns the decision of the first matching allow, deny or approve rule, with the
+// arguments changed by the matching rewrite rules before it. Relative paths in the arguments are
+// resolved from dir, the working directory of the server.
+func evaluatePolicies(policies []types.ToolPolicy, server, tool, dir string, args any) (policyDecision, error) {
+	decision := policyDecision{
+		Action: types.PolicyActionAllow,
+	}
+	if args != nil {
+		if err := mcp.JSONCoerce(args, &decision.Arguments); err != nil {
+			return decision, fmt.Errorf("failed to read arguments: %w", err)
+		}
+	}
+
+	for _, policy := range policies {
+		if !policy.MatchesTool(server, tool) {
+			continue
+		}
+		matches, err := matchArguments(policy.Arguments, dir, decision.Arguments)
+		if err != nil {
+			return decision, err
+		} else if !matches {
+			continue
+		}
+
+		if policy.Action == types.PolicyActionRewrite {
+			if decision.Arguments == nil {
+				decision.Arguments = map[string]any{}
+			}
+			for key, value := range policy.Rewrite {
+				setArgument(decision.Arguments, key, value)
+			}
+			decision.Rewritten = true
+			continue
+		}
+
+		decision.Action = policy.Action
+		decision.Reason = policy.Reason
+		return decision, nil
+	}
+
+	return decision, nil
+}
+
+func matchArguments(conditions map[string]types.ArgumentCondition, dir string, args map[string]any) (bool, error) {
+	for key, condition := range conditions {
+		value, ok := getArgument(args, key)
+		if !ok {
+			return false, nil
+		}
+		matches, err := matchCondition(condition, dir, argumentString(value))
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchCondition(condition types.ArgumentCondition, dir, value string) (bool, error) {
+	if condition.Match != "" {
+		matched, err := regexp.MatchString(condition.Match, value)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	if condition.NotMatch != "" {
+		matched, err := regexp.MatchString(condition.NotMatch, value)
+		if err != nil || matched {
+			return false, err
+		}
+	}
+	if len(condition.Under) > 0 && !isUnder(value, dir, condition.Under) {
+		return false, nil
+	}
+	if len(condition.NotUnder) > 0 && isUnder(value, dir, condition.NotUnder) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// isUnder returns whether a path is one of the directories or within them. A relative path is
+// resolved from cwd, relative directories from the working directory of nanobot. Symlinks are
+// followed so that a link can't point out of the directories.
+func isUnder(path, cwd string, dirs []string) bool {
+	path, err := resolvePath(path, cwd)
+	if err != nil {
+		return false
+	}
+	for _, dir := range dirs {
+		dir, err := resolvePath(dir, "")
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath makes path absolute from cwd, or the working directory of nanobot if empty, and
+// follows the symlinks in the part of it that exists. The rest of the path is cleaned.
+func resolvePath(path, cwd string) (string, error) {
+	if !filepath.IsAbs(path) {
+		cwd, err := filepath.Abs(cwd)
+		if err != nil {
+			return "", err
+		}
+		// Not joined, ".." must only be applied after the symlinks before it are followed.
+		path = cwd + string(filepath.Separator) + path
+	}
+
+	volume := filepath.VolumeName(path)
+	resolved := volume + string(filepath.Separator)
+	parts := strings.Split(filepath.ToSlash(path[len(volume):]), "/")
+	for i, part := range parts {
+		if part == "" || part == "." {
+			continue
+		}
+		// resolved has no symlinks, so ".." can be applied to it lexically.
+		next, err := filepath.EvalSymlinks(filepath.Join(resolved, part))
+		if err != nil {
+			return filepath.Join(append([]string{resolved}, parts[i:]...)...), nil
+		}
+		resolved = next
+	}
+	return resolved, nil
+}
+
+func argumentString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func getArgument(args map[string]any, key string) (any, bool) {
+	var value any = args
+	for part := range strings.SplitSeq(key, ".") {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func setArgument(args map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		obj, ok := args[part].(map[string]any)
+		if !ok {
+			obj = map[string]any{}
+			args[part] = obj
+		}
+		args = obj
+	}
+	args[parts[len(parts)-1]] = value
+}
+
+// CheckPolicies evaluates the policies for a call that is not made with Call, like a delegate call
+// and the tasks it runs on the agents. It returns the arguments to make the call with, or the
+// result to return instead if the call is not allowed.
+func (s *Service) CheckPolicies(ctx context.Context, server, tool string, args any) (any, *types.CallResult) {
+	return s.checkPolicies(ctx, types.ConfigFromContext(ctx), server, tool, args)
+}
+
+// checkPolicies evaluates the policies of the config and the current agent for a tool call. It
+// returns the arguments to call the tool with, or the result to return instead if the call is not
+// allowed.
+func (s *Service) checkPolicies(ctx context.Context, config types.Config, server, tool string, args any) (any, *types.CallResult) {
+	agent := types.CurrentAgent(ctx)
+	policies := slices.Concat(config.Policies, config.Agents[agent].Policies)
+	if len(policies) == 0 {
+		return args, nil
+	}
+
+	target := server
+	if tool != "" {
+		target = server + "/" + tool
+	}
+
+	decision, err := evaluatePolicies(policies, server, tool, serverCwd(ctx, config, server), args)
+	if err != nil {
+		return args, s.policyDenied(ctx, target, args, fmt.Sprintf("failed to evaluate policies: %v", err))
+	}
+
+	if decision.Rewritten {
+		slog.Debug("tool call arguments rewritten by policy", "target", target)
+		s.policyAuditLog(ctx, target, args, decision, "", http.StatusOK)
+		args = decision.Arguments
+	}
+
+	switch decision.Action {
+	case types.PolicyActionDeny:
+		return args, s.policyDenied(ctx, target, args, decision.Reason)
+	case types.PolicyActionApprove:
+		if err := approveToolCall(ctx, agent, target, args, decision.Reason); err != nil {
+			return args, s.policyDenied(ctx, target, args, err.Error())
+		}
+		s.policyAuditLog(ctx, target, args, decision, "", http.StatusOK)
+	}
+
+	return args, nil
+}
+
+// serverCwd returns the working directory of the command of an MCP server, empty for the working
+// directory of nanobot.
+func serverCwd(ctx context.Context, config types.Config, server string) string {
+	mcpServer := config.MCPServers[server]
+	cwd := mcpServer.Cwd
+	if mcpServer.Sandboxed {
+		cwd = mcpServer.Workdir
+	}
+	if session := mcp.SessionFromContext(ctx); session != nil {
+		cwd = envvar.ReplaceString(session.GetEnvMap(), cwd)
+	}
+	return cwd
+}
+
+func (s *Service) policyDenied(ctx context.Context, target string, args any, reason string) *types.CallResult {
+	if reason == "" {
+		reason = "the call is not allowed by policy"
+	}
+	s.policyAuditLog(ctx, target, args, policyDecision{Action: types.PolicyActionDeny, Reason: reason}, reason, http.StatusForbidden)
+
+	return &types.CallResult{
+		IsError: true,
+		Content: []mcp.Content{
+			{
+				Type: "text",
+				Text: fmt.Sprintf("Tool call %s was denied: %s", target, reason),
+			},
+		},
+		StructuredContent: map[string]any{
+			"error":  "policy_denied",
+			"tool":   target,
+			"reason": reason,
+		},
+	}
+}
+
+// policyAuditLog records a policy decision in the audit log.
+func (s *Service) policyAuditLog(ctx context.Context, target string, args any, decision policyDecision, errMsg string, status int) {
+	if s.auditLogCollector == nil {
+		return
+	}
+
+	params, _ := json.Marshal(map[string]any{
+		"name":      target,
+		"arguments": args,
+	})
+	msg := &mcp.Message{
+		JSONRPC: "2.0",
+		Method:  "tools/call",
+		Params:  params,
+	}
+	auditLog := &auditlogs.MCPAuditLog{
+		CreatedAt: time.Now(),
+		CallType:  msg.Method,
+	}
+	if session := mcp.SessionFromContext(ctx); session != nil {
+		auditLog = buildAuditLog(msg, session)
+	} else {
+		auditLog.RequestBody, _ = json.Marshal(msg)
+	}
+	auditLog.CallIdentifier = target
+	auditLog.Error = errMsg
+	auditLog.ResponseStatus = status
+	auditLog.ResponseBody, _ = json.Marshal(map[string]string{
+		"policy": string(decision.Action),
+		"reason": decision.Reason,
+	})
+	if decision.Rewritten {
+		auditLog.MutatedRequestBody, _ = json.Marshal(decision.Arguments)
+	}
+	s.collectAuditLog(auditLog)
+}
+
+// approveToolCall asks the user to approve a tool call with an elicitation.
+func approveToolCall(ctx context.Context, agent, target string, args any, reason string) error {
+	session := mcp.SessionFromContext(ctx).Root()
+	if session == nil {
+		return fmt.Errorf("the call requires approval but there is no user to approve it")
+	}
+
+	argsData, _ := json.MarshalIndent(args, "", "  ")
+	message := fmt.Sprintf("Allow %s to call %s with the arguments:\n%s", agent, target, argsData)
+	if agent == "" {
+		message = fmt.Sprintf("Allow the call to %s with the arguments:\n%s", target, argsData)
+	}
+	if reason != "" {
+		message = reason + "\n\n" + message
+	}
+
+	meta, _ := json.Marshal(map[string]any{
+		types.MetaPrefix + "tool-approval": map[string]any{
+			"tool":      target,
+			"arguments": args,
+		},
+	})
+
+	var result mcp.ElicitResult
+	if err := session.Exchange(ctx, "elicitation/create", mcp.ElicitRequest{
+		Message: message,
+		RequestedSchema: mcp.PrimitiveSchema{
+			Type:       "object",
+			Properties: map[string]mcp.PrimitiveProperty{},
+		},
+		Meta: meta,
+	}, &result); err != nil {
+		return fmt.Errorf("the call requires approval, failed to ask the user: %w", err)
+	}
+
+	switch result.Action {
+	case "accept":
+		return nil
+	case "decline", "reject":
+		return fmt.Errorf("the user declined the call")
+	default:
+		return fmt.Errorf("the user canceled the call")
+	}
+}
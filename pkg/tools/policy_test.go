@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestEvaluatePolicies(t *testing.T) {
+	out, err := filepath.Abs("out")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policies := []types.ToolPolicy{
+		{
+			Tools:     []string{"shell/bash"},
+			Arguments: map[string]types.ArgumentCondition{"command": {Match: `rm\s+-rf`}},
+			Action:    types.PolicyActionDeny,
+			Reason:    "no recursive deletes",
+		},
+		{
+			Tools:     []string{"fs/write*"},
+			Arguments: map[string]types.ArgumentCondition{"path": {NotUnder: []string{"./out"}}},
+			Action:    types.PolicyActionDeny,
+		},
+		{
+			Tools:     []string{"shell"},
+			Arguments: map[string]types.ArgumentCondition{"options.timeout": {NotMatch: `^\d+$`}},
+			Action:    types.PolicyActionRewrite,
+			Rewrite:   map[string]any{"options.timeout": 30},
+		},
+		{
+			Tools:  []string{"shell/*"},
+			Action: types.PolicyActionApprove,
+		},
+	}
+
+	for _, test := range []struct {
+		name, server, tool string
+		args               map[string]any
+		action             types.PolicyAction
+		rewritten          bool
+	}{
+		{"deny command", "shell", "bash", map[string]any{"command": "rm -rf /"}, types.PolicyActionDeny, false},
+		{"approve other commands", "shell", "bash", map[string]any{"command": "ls"}, types.PolicyActionApprove, false},
+		{"write under out", "fs", "write_file", map[string]any{"path": filepath.Join(out, "a.txt")}, types.PolicyActionAllow, false},
+		{"write relative under out", "fs", "write_file", map[string]any{"path": "out/b/../a.txt"}, types.PolicyActionAllow, false},
+		{"write outside out", "fs", "write_file", map[string]any{"path": "out/../secrets.txt"}, types.PolicyActionDeny, false},
+		{"missing argument", "fs", "write_file", map[string]any{}, types.PolicyActionAllow, false},
+		{"rewrite", "shell", "bash", map[string]any{"command": "ls", "options": map[string]any{"timeout": "forever"}}, types.PolicyActionApprove, true},
+		{"other tool", "search", "bash", map[string]any{"command": "rm -rf /"}, types.PolicyActionAllow, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			decision, err := evaluatePolicies(policies, test.server, test.tool, "", test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Action != test.action || decision.Rewritten != test.rewritten {
+				t.Fatalf("expected %s (rewritten %v), got %s (rewritten %v)", test.action, test.rewritten, decision.Action, decision.Rewritten)
+			}
+			if test.rewritten {
+				if timeout := decision.Arguments["options"].(map[string]any)["timeout"]; timeout != 30 {
+					t.Fatalf("expected the timeout to be rewritten, got %v", timeout)
+				}
+				if decision.Arguments["command"] != "ls" {
+					t.Fatalf("expected the other arguments to be kept, got %v", decision.Arguments)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPolicies(t *testing.T) {
+	s := &Service{}
+	config := types.Config{
+		Policies: []types.ToolPolicy{
+			{
+				Tools:  []string{"fs/delete"},
+				Action: types.PolicyActionDeny,
+				Reason: "deleting files is not allowed",
+			},
+			{
+				Tools:  []string{"fs/write"},
+				Action: types.PolicyActionApprove,
+			},
+		},
+	}
+
+	args := map[string]any{"path": "a.txt"}
+	if result, denied := s.checkPolicies(t.Context(), config, "fs", "read", args); denied != nil {
+		t.Fatalf("expected the call to be allowed, got %v", denied)
+	} else if result.(map[string]any)["path"] != "a.txt" {
+		t.Fatalf("unexpected arguments %v", result)
+	}
+
+	_, denied := s.checkPolicies(t.Context(), config, "fs", "delete", args)
+	if denied == nil || !denied.IsError {
+		t.Fatalf("expected the call to be denied, got %v", denied)
+	}
+	if denied.StructuredContent["reason"] != "deleting files is not allowed" {
+		t.Fatalf("unexpected denial %v", denied.StructuredContent)
+	}
+
+	// Approvals are denied when there is no session to ask.
+	if _, denied := s.checkPolicies(t.Context(), config, "fs", "write", args); denied == nil {
+		t.Fatal("expected the call to be denied without a user to approve it")
+	}
+}
+
+func TestIsUnderResolvesPaths(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	secrets := filepath.Join(root, "secrets")
+	for _, dir := range []string{allowed, secrets} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(secrets, filepath.Join(allowed, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name, path, cwd string
+		under           bool
+	}{
+		{"file in dir", filepath.Join(allowed, "a.txt"), "", true},
+		{"relative to server cwd", "a.txt", allowed, true},
+		{"relative out of server cwd", "../secrets/a.txt", allowed, false},
+		{"symlink out of dir", filepath.Join(allowed, "link", "a.txt"), "", false},
+		{"parent of symlink target", "link/../secrets/a.txt", allowed, false},
+		{"new directory", "new/b/../a.txt", allowed, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if under := isUnder(test.path, test.cwd, []string{allowed}); under != test.under {
+				t.Fatalf("expected under %v, got %v", test.under, under)
+			}
+		})
+	}
+}
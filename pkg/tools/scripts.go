@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nanobot-ai/nanobot/pkg/expr"
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+// RunScript runs a script of the config with the arguments of a tool call. A string result is
+// returned as text and an object as structured content. Errors of the script are returned as an
+// error result, so the model can see them.
+func (s *Service) RunScript(ctx context.Context, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	script, ok := types.ConfigFromContext(ctx).Scripts[name]
+	if !ok {
+		return nil, fmt.Errorf("script %s not found", name)
+	}
+
+	if args == nil {
+		args = map[string]any{}
+	}
+
+	result, err := expr.RunScript(ctx, s.newGlobals(ctx, map[string]any{
+		"args": args,
+	}), script.Script)
+	if err != nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{{Type: "text", Text: fmt.Sprintf("script %s failed: %v", name, err)}},
+		}, nil
+	}
+
+	switch v := result.(type) {
+	case nil:
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{},
+		}, nil
+	case string:
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{{Type: "text", Text: v}},
+		}, nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the result of script %s: %w", name, err)
+	}
+	callResult := &mcp.CallToolResult{
+		Content: []mcp.Content{{Type: "text", Text: string(data)}},
+	}
+	if obj, ok := result.(map[string]any); ok {
+		callResult.StructuredContent = obj
+	}
+	return callResult, nil
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nanobot-ai/nanobot/pkg/types"
+)
+
+func TestRunScript(t *testing.T) {
+	s := &Service{}
+	ctx := types.WithConfig(t.Context(), types.Config{
+		Scripts: map[string]types.Script{
+			"add": {
+				Script: `return {sum: args.a + args.b}`,
+			},
+			"greet": {
+				Script: `return "hello " + args.name`,
+			},
+			"fail": {
+				Script: `throw new Error("boom")`,
+			},
+			"delete": {
+				Script: `const result = call("fs/delete", {path: args.path}); return {denied: result.isError, reason: result.structuredContent.reason}`,
+			},
+		},
+		Policies: []types.ToolPolicy{
+			{
+				Tools:  []string{"fs/delete"},
+				Action: types.PolicyActionDeny,
+				Reason: "read only",
+			},
+		},
+	})
+
+	result, err := s.RunScript(ctx, "add", map[string]any{"a": 1, "b": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError || result.StructuredContent["sum"] != int64(3) || result.Content[0].Text != `{"sum":3}` {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	result, err = s.RunScript(ctx, "greet", map[string]any{"name": "nanobot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StructuredContent != nil || result.Content[0].Text != "hello nanobot" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	result, err = s.RunScript(ctx, "fail", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "boom") {
+		t.Fatalf("expected an error result, got %+v", result)
+	}
+
+	// Calls from the script go through the tools service, including the policies.
+	result, err = s.RunScript(ctx, "delete", map[string]any{"path": "a.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StructuredContent["denied"] != true || result.StructuredContent["reason"] != "read only" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	if _, err := s.RunScript(ctx, "missing", nil); err == nil {
+		t.Fatal("expected an error for a missing script")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/nanobot-ai/nanobot/pkg/fileuri"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
 	"github.com/nanobot-ai/nanobot/pkg/mcp/auditlogs"
+	"github.com/nanobot-ai/nanobot/pkg/mcp/sandbox"
 	"github.com/nanobot-ai/nanobot/pkg/sampling"
 	"github.com/nanobot-ai/nanobot/pkg/types"
 	"github.com/nanobot-ai/nanobot/pkg/uuid"
@@ -55,6 +56,7 @@ type Options struct {
 	TokenExchangeClientID     string
 	TokenExchangeClientSecret string
 	AuditLogCollector         *auditlogs.Collector
+	SandboxBackend            string
 }
 
 func (r Options) Merge(other Options) (result Options) {
@@ -67,6 +69,7 @@ func (r Options) Merge(other Options) (result Options) {
 	result.TokenExchangeClientID = complete.Last(r.TokenExchangeClientID, other.TokenExchangeClientID)
 	result.TokenExchangeClientSecret = complete.Last(r.TokenExchangeClientSecret, other.TokenExchangeClientSecret)
 	result.AuditLogCollector = complete.Last(r.AuditLogCollector, other.AuditLogCollector)
+	result.SandboxBackend = complete.Last(r.SandboxBackend, other.SandboxBackend)
 	return result
 }
 
@@ -79,7 +82,7 @@ func (r Options) Complete() Options {
 
 func NewToolsService(opts ...Options) *Service {
 	opt := complete.Complete(opts...)
-	return &Service{
+	s := &Service{
 		roots:                     opt.Roots,
 		concurrency:               opt.Concurrency,
 		oauthRedirectURL:          opt.OAuthRedirectURL,
@@ -89,7 +92,12 @@ func NewToolsService(opts ...Options) *Service {
 		tokenExchangeClientID:     opt.TokenExchangeClientID,
 		tokenExchangeClientSecret: opt.TokenExchangeClientSecret,
 		auditLogCollector:         opt.AuditLogCollector,
+		runner: mcp.Runner{
+			SandboxBackend: opt.SandboxBackend,
+		},
 	}
+	s.runner.OnLimitViolation = s.limitViolation
+	return s
 }
 
 func (s *Service) GetAgentAttributes(_ context.Context, name string) (agentConfigName string, agentAttribute map[string]any, _ error) {
@@ -104,6 +112,11 @@ func (s *Service) AddServer(name string, factory func(name string) mcp.MessageHa
 	s.serverFactories[name] = factory
 }
 
+// ServerHealth returns the state of the stdio MCP servers started by the service.
+func (s *Service) ServerHealth() []mcp.ServerHealth {
+	return s.runner.Health()
+}
+
 func (s *Service) SetSampler(sampler Sampler) {
 	s.sampler = sampler
 }
@@ -143,6 +156,39 @@ func (s *Service) collectAuditLog(auditLog *auditlogs.MCPAuditLog) {
 	s.auditLogCollector.CollectMCPAuditEntry(*auditLog)
 }
 
+// limitViolation records an MCP server exceeding one of its limits in the audit log.
+func (s *Service) limitViolation(server string, violation *sandbox.LimitError) {
+	auditLog := &auditlogs.MCPAuditLog{
+		CreatedAt:      time.Now(),
+		CallType:       "limits/violation",
+		CallIdentifier: server,
+		Error:          violation.Error(),
+	}
+	auditLog.ResponseBody, _ = json.Marshal(map[string]string{
+		"server":  server,
+		"limit":   violation.Limit,
+		"message": violation.Message,
+	})
+	s.collectAuditLog(auditLog)
+}
+
+// outputSchemaViolation records a tool result that doesn't match the output schema of the tool in the
+// audit log.
+func (s *Service) outputSchemaViolation(violation *mcp.OutputSchemaError) {
+	auditLog := &auditlogs.MCPAuditLog{
+		CreatedAt:      time.Now(),
+		CallType:       "tools/outputSchemaViolation",
+		CallIdentifier: violation.Server + "/" + violation.Tool,
+		Error:          violation.Error(),
+	}
+	auditLog.ResponseBody, _ = json.Marshal(map[string]any{
+		"server":   violation.Server,
+		"tool":     violation.Tool,
+		"problems": violation.Problems,
+	})
+	s.collectAuditLog(auditLog)
+}
+
 func (s *Service) GetDynamicInstruction(ctx context.Context, instruction types.DynamicInstructions) (string, error) {
 	if !instruction.IsSet() {
 		return "", nil
@@ -326,6 +372,9 @@ func (s *Service) newClient(ctx context.Context, name string, state *mcp.Session
 	serverFactory, ok = s.serverFactories[name]
 	if !ok {
 		mcpConfig, ok = config.MCPServers[name]
+		if ok && mcpConfig.A2A != "" {
+			serverFactory = s.serverFactories[types.A2AServer]
+		}
 	}
 	if !ok {
 		_, ok = config.Agents[name]
@@ -741,6 +790,11 @@ func (s *Service) Call(ctx context.Context, server, tool string, args any, opts
 		}
 	}
 
+	args, denied := s.checkPolicies(ctx, config, server, tool, args)
+	if denied != nil {
+		return denied, nil
+	}
+
 	if _, ok := config.Agents[server]; ok && tool != types.AgentTool+server {
 		return s.sampleCall(ctx, server, args, SampleCallOptions{
 			ProgressToken: opt.ProgressToken,
@@ -760,15 +814,50 @@ func (s *Service) Call(ctx context.Context, server, tool string, args any, opts
 		ProgressToken: opt.ProgressToken,
 		Meta:          opt.Meta,
 	})
-	if err != nil {
+	if violation, ok := errors.AsType[*sandbox.LimitError](err); ok {
+		// The server was stopped for exceeding its limits, let the caller know why.
+		return &types.CallResult{
+			IsError: true,
+			Content: []mcp.Content{{Type: "text", Text: fmt.Sprintf("MCP server %s was stopped: %v", server, violation)}},
+		}, nil
+	} else if violation, ok := errors.AsType[*mcp.OutputSchemaError](err); ok {
+		// The server is configured to fail results that break its output schema.
+		s.outputSchemaViolation(violation)
+		return &types.CallResult{
+			IsError: true,
+			Content: []mcp.Content{{Type: "text", Text: violation.Error()}},
+		}, nil
+	} else if err != nil {
 		return nil, err
 	}
-	return addHookMutationContent(&types.CallResult{
+
+	if problems, ok := mcpCallResult.Meta[mcp.OutputSchemaViolationsMetaKey].([]string); ok {
+		s.outputSchemaViolation(&mcp.OutputSchemaError{
+			Server:   server,
+			Tool:     tool,
+			Problems: problems,
+		})
+	}
+	return addOutputSchemaWarning(addHookMutationContent(&types.CallResult{
 		Meta:              mcpCallResult.Meta,
 		StructuredContent: mcpCallResult.StructuredContent,
 		Content:           mcpCallResult.Content,
 		IsError:           mcpCallResult.IsError,
-	}), nil
+	})), nil
+}
+
+// addOutputSchemaWarning appends a warning to a result with structured content that doesn't match
+// the output schema of the tool.
+func addOutputSchemaWarning(response *types.CallResult) *types.CallResult {
+	problems, ok := response.Meta[mcp.OutputSchemaViolationsMetaKey].([]string)
+	if !ok || len(problems) == 0 {
+		return response
+	}
+	response.Content = append(response.Content, mcp.Content{
+		Type: "text",
+		Text: "Warning: the structured content of the result does not match the output schema of the tool: " + strings.Join(problems, "; "),
+	})
+	return response
 }
 
 func addHookMutationContent(response *types.CallResult) *types.CallResult {
@@ -0,0 +1,5 @@
+package types
+
+// A2AServer is the built-in MCP server that publishes the skills of a remote A2A agent, configured
+// with the a2a field of an MCP server, as tools.
+const A2AServer = "nanobot.a2a"
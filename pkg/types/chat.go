@@ -87,6 +87,20 @@ type Chat struct {
 	ReadOnly     bool      `json:"readonly,omitempty"`
 	TaskURI      string    `json:"taskURI,omitempty"`
 	WorkflowURIs []string  `json:"workflowURIs,omitempty"`
+	Starred      bool      `json:"starred,omitempty"`
+}
+
+type ChatSearchResults struct {
+	Results []ChatSearchResult `json:"results"`
+}
+
+type ChatSearchResult struct {
+	ChatID    string     `json:"chatId"`
+	Title     string     `json:"title,omitempty"`
+	MessageID string     `json:"messageId"`
+	Role      string     `json:"role,omitempty"`
+	Created   *time.Time `json:"created,omitempty"`
+	Snippet   string     `json:"snippet"`
 }
 
 type AgentList struct {
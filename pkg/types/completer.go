@@ -270,6 +270,10 @@ type CompletionResponse struct {
 	// by the proxy due to a policy violation. The value is the explanation to return as
 	// error tool_results instead of executing the tools.
 	ToolCallPolicyViolation string `json:"toolCallPolicyViolation,omitempty"`
+
+	// StructuredContent is the output of the agent, decoded and validated against the output
+	// schema of the request.
+	StructuredContent map[string]any `json:"structuredContent,omitempty"`
 }
 
 func (c *CompletionResponse) Serialize() (any, error) {
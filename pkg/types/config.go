@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"iter"
+	"maps"
 	"regexp"
 	"slices"
 	"strings"
 
 	"github.com/nanobot-ai/nanobot/pkg/complete"
 	"github.com/nanobot-ai/nanobot/pkg/mcp"
+	"github.com/nanobot-ai/nanobot/pkg/mcp/sandbox"
+	"github.com/nanobot-ai/nanobot/pkg/secrets"
 	"gopkg.in/yaml.v3"
 )
 
@@ -70,10 +73,11 @@ func CurrentAgent(ctx context.Context) string {
 }
 
 type LLMProvider struct {
-	Dialect Dialect           `json:"dialect,omitempty"`
-	APIKey  string            `json:"apiKey,omitempty"`
-	BaseURL string            `json:"baseURL,omitempty"`
-	Headers map[string]string `json:"headers,omitempty"`
+	Dialect Dialect              `json:"dialect,omitempty"`
+	APIKey  string               `json:"apiKey,omitempty"`
+	BaseURL string               `json:"baseURL,omitempty"`
+	Headers map[string]string    `json:"headers,omitempty"`
+	Models  map[string]ModelInfo `json:"models,omitempty"`
 }
 
 type Config struct {
@@ -86,7 +90,9 @@ type Config struct {
 	MCPServers       map[string]mcp.Server  `json:"mcpServers,omitempty"`
 	Profiles         map[string]Config      `json:"profiles,omitempty"`
 	Prompts          map[string]Prompt      `json:"prompts,omitempty"`
+	Scripts          map[string]Script      `json:"scripts,omitempty"`
 	Hooks            mcp.Hooks              `json:"hooks,omitempty"`
+	Policies         []ToolPolicy           `json:"policies,omitempty"`
 	WorkspaceID      string                 `json:"workspaceId,omitempty"`
 	WorkspaceBaseURI string                 `json:"workspaceBaseUri,omitempty"`
 }
@@ -96,31 +102,66 @@ type ConfigFactory func(ctx context.Context, profiles string) (Config, error)
 func (c Config) Redacted() Config {
 	redacted := c
 
+	redacted.Env = maps.Clone(c.Env)
 	for i, env := range redacted.Env {
-		if env.Sensitive == nil || *env.Sensitive {
-			env.Default = fmt.Sprintf("%s...", env.Default[:min(10, len(env.Default)/2)])
+		if env.Sensitive == nil || *env.Sensitive || secrets.IsSecret(env.Default) {
+			env.Default = redactValue(env.Default)
 			redacted.Env[i] = env
 		}
 	}
 
-	for _, mcpServer := range redacted.MCPServers {
-		for key, val := range mcpServer.Env {
-			mcpServer.Env[key] = fmt.Sprintf("%s...", val[:min(10, len(val)/2)])
-		}
+	if c.Auth != nil {
+		auth := *c.Auth
+		auth.OAuthClientSecret = redactValue(auth.OAuthClientSecret)
+		auth.EncryptionKey = redactValue(auth.EncryptionKey)
+		redacted.Auth = &auth
+	}
 
-		for key, val := range mcpServer.Headers {
-			mcpServer.Headers[key] = fmt.Sprintf("%s...", val[:min(10, len(val)/2)])
-		}
+	redacted.LLMProviders = maps.Clone(c.LLMProviders)
+	for name, provider := range redacted.LLMProviders {
+		provider.APIKey = redactValue(provider.APIKey)
+		provider.Headers = redactMap(provider.Headers)
+		redacted.LLMProviders[name] = provider
+	}
 
+	redacted.MCPServers = maps.Clone(c.MCPServers)
+	for name, mcpServer := range redacted.MCPServers {
+		mcpServer.Env = redactMap(mcpServer.Env)
+		mcpServer.Headers = redactMap(mcpServer.Headers)
+		mcpServer.Auth.ClientSecret = redactValue(mcpServer.Auth.ClientSecret)
+		redacted.MCPServers[name] = mcpServer
 	}
 
-	for key, val := range c.Profiles {
-		c.Profiles[key] = val.Redacted()
+	redacted.Profiles = maps.Clone(c.Profiles)
+	for key, val := range redacted.Profiles {
+		redacted.Profiles[key] = val.Redacted()
 	}
 
 	return redacted
 }
 
+// redactValue keeps the start of a value, unless it is a resolved secret, which is never shown.
+func redactValue(val string) string {
+	if val == "" {
+		return ""
+	}
+	if secrets.IsSecret(val) {
+		return secrets.Mask
+	}
+	return fmt.Sprintf("%s...", val[:min(10, len(val)/2)])
+}
+
+func redactMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]string, len(m))
+	for key, val := range m {
+		result[key] = redactValue(val)
+	}
+	return result
+}
+
 func (c Config) Validate(allowLocal bool) error {
 	var (
 		errs      []error
@@ -137,6 +178,16 @@ func (c Config) Validate(allowLocal bool) error {
 		}
 	}
 
+	for _, name := range c.Publish.A2A {
+		if _, ok := c.Agents[name]; !ok {
+			errs = append(errs, fmt.Errorf("publish.a2a: agent %q not found", name))
+		}
+	}
+
+	if err := validatePolicies(c.Policies); err != nil {
+		errs = append(errs, fmt.Errorf("invalid policies: %w", err))
+	}
+
 	for agentName, agent := range c.Agents {
 		if err := checkDup(seenNames, "agents", agentName); err != nil {
 			errs = append(errs, err)
@@ -146,6 +197,12 @@ func (c Config) Validate(allowLocal bool) error {
 		}
 	}
 
+	for scriptName, script := range c.Scripts {
+		if err := script.validate(scriptName); err != nil {
+			errs = append(errs, fmt.Errorf("script %q: %w", scriptName, err))
+		}
+	}
+
 	for mcpServerName, mcpServer := range c.MCPServers {
 		if err := checkDup(seenNames, "mcpServers", mcpServerName); err != nil {
 			errs = append(errs, err)
@@ -159,6 +216,50 @@ func (c Config) Validate(allowLocal bool) error {
 }
 
 func validateMCPServer(mcpServerName string, mcpServer mcp.Server, allowLocal bool) error {
+	if err := sandbox.Validate(mcpServer.SandboxBackend, mcpServer.SandboxNetwork); err != nil {
+		return fmt.Errorf("mcpServer %q: %w", mcpServerName, err)
+	}
+
+	if _, _, err := mcpServer.Limits.Parse(); err != nil {
+		return fmt.Errorf("mcpServer %q: %w", mcpServerName, err)
+	}
+	if err := mcpServer.Restart.Validate(); err != nil {
+		return fmt.Errorf("mcpServer %q: %w", mcpServerName, err)
+	}
+	if err := mcpServer.Lifecycle.Validate(); err != nil {
+		return fmt.Errorf("mcpServer %q: %w", mcpServerName, err)
+	}
+	if mcpServer.Lifecycle != (mcp.Lifecycle{}) && mcpServer.BaseURL != "" {
+		return fmt.Errorf("mcpServer %q: lifecycle only applies to servers run from a command over stdio", mcpServerName)
+	}
+	if err := mcpServer.Auth.Validate(); err != nil {
+		return fmt.Errorf("mcpServer %q: %w", mcpServerName, err)
+	}
+	if mcpServer.Auth.Type != "" && mcpServer.BaseURL == "" {
+		return fmt.Errorf("mcpServer %q: auth only applies to remote servers with a url", mcpServerName)
+	}
+	if mcpServer.A2A != "" && (mcpServer.BaseURL != "" || mcpServer.Command != "") {
+		return fmt.Errorf("mcpServer %q: a2a can not be used with a url or command", mcpServerName)
+	}
+	switch mcpServer.OutputValidation {
+	case "", mcp.OutputValidationWarn, mcp.OutputValidationStrict, mcp.OutputValidationOff:
+	default:
+		return fmt.Errorf("mcpServer %q: invalid outputValidation %q, must be %s, %s or %s", mcpServerName, mcpServer.OutputValidation,
+			mcp.OutputValidationWarn, mcp.OutputValidationStrict, mcp.OutputValidationOff)
+	}
+	if err := sandbox.ValidateEgress(mcpServer.Limits.Egress); err != nil {
+		return fmt.Errorf("mcpServer %q: %w", mcpServerName, err)
+	}
+	if len(mcpServer.Limits.Egress) > 0 {
+		// Only the namespace backend can route all connections of the server through the egress proxy.
+		if !mcpServer.Sandboxed || (mcpServer.SandboxBackend != "" && mcpServer.SandboxBackend != sandbox.BackendNamespace) {
+			return fmt.Errorf("mcpServer %q: an egress allowlist can only be enforced for servers in the %s sandbox", mcpServerName, sandbox.BackendNamespace)
+		}
+		if mcpServer.SandboxNetwork != "" || len(mcpServer.Ports) > 0 || len(mcpServer.ReversePorts) > 0 {
+			return fmt.Errorf("mcpServer %q: an egress allowlist can not be used with sandboxNetwork, ports or reversePorts", mcpServerName)
+		}
+	}
+
 	if allowLocal {
 		return nil
 	}
@@ -239,6 +340,18 @@ type Publish struct {
 	ResourceTemplates StringList          `json:"resourceTemplates,omitzero"`
 	MCPServers        StringList          `json:"mcpServers,omitzero"`
 	Entrypoint        StringList          `json:"entrypoint,omitempty"`
+	A2A               StringList          `json:"a2a,omitzero"`
+}
+
+// A2AAgents returns the agents served over the A2A protocol, the entrypoint agents followed by the
+// agents of publish.a2a.
+func (c Config) A2AAgents() (result []string) {
+	for _, name := range append(slices.Clone(c.Publish.Entrypoint), c.Publish.A2A...) {
+		if _, ok := c.Agents[name]; ok && !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	return result
 }
 
 func (p Publish) IsSingleServerProxy() bool {
@@ -676,12 +789,23 @@ func (a Agent) validate(agentName string, c Config) error {
 		}
 	}
 
+	if err := validatePolicies(a.Policies); err != nil {
+		errs = append(errs, fmt.Errorf("agent %q has invalid policies: %w", agentName, err))
+	}
+
 	if !unknownNames && a.ToolChoice != "" && a.ToolChoice != "none" && a.ToolChoice != "auto" {
 		if _, ok := resolvedToolNames[a.ToolChoice]; !ok {
 			errs = append(errs, fmt.Errorf("agent %q has tool choice %q that is not defined in tools", agentName, a.ToolChoice))
 		}
 	}
 
+	switch a.ToolArguments {
+	case "", ToolArgumentsStrict, ToolArgumentsLenient, ToolArgumentsOff:
+	default:
+		errs = append(errs, fmt.Errorf("agent %q has invalid tool arguments %q, must be %q, %q or %q", agentName, a.ToolArguments,
+			ToolArgumentsStrict, ToolArgumentsLenient, ToolArgumentsOff))
+	}
+
 	return errors.Join(errs...)
 }
 
@@ -721,12 +845,26 @@ func (a DynamicInstructions) MarshalJSON() ([]byte, error) {
 	return json.Marshal(Alias(a))
 }
 
+// DefaultOutputMaxRepairs is how many times the model is asked to fix a response that does not
+// match the output schema by default.
+const DefaultOutputMaxRepairs = 2
+
 type OutputSchema struct {
 	Name        string           `json:"name,omitempty"`
 	Description string           `json:"description,omitempty"`
 	Schema      json.RawMessage  `json:"schema,omitzero"`
 	Strict      bool             `json:"strict,omitempty"`
 	Fields      map[string]Field `json:"fields,omitempty"`
+	// MaxRepairs is how many times the model is asked to fix a response that does not match the
+	// schema before the response fails, DefaultOutputMaxRepairs if not set.
+	MaxRepairs *int `json:"maxRepairs,omitempty"`
+}
+
+func (o *OutputSchema) GetMaxRepairs() int {
+	if o == nil || o.MaxRepairs == nil {
+		return DefaultOutputMaxRepairs
+	}
+	return max(*o.MaxRepairs, 0)
 }
 
 type Field struct {
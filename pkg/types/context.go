@@ -14,6 +14,9 @@ type Context struct {
 
 type contextKey struct{}
 type internalLLMRequestTypeKey struct{}
+type resumeRunKey struct{}
+type runIDKey struct{}
+type delegationDepthKey struct{}
 
 const (
 	InternalLLMRequestTypeHeader = "X-Nanobot-Internal-Request-Type"
@@ -44,3 +47,35 @@ func InternalLLMRequestType(ctx context.Context) string {
 func WithThreadTitleRequest(ctx context.Context) context.Context {
 	return WithInternalLLMRequestType(ctx, ThreadTitleRequestType)
 }
+
+// WithResumeRun sets the ID of the agent run that the completion of the context resumes, an empty
+// ID clears it.
+func WithResumeRun(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, resumeRunKey{}, runID)
+}
+
+func ResumeRun(ctx context.Context) string {
+	runID, _ := ctx.Value(resumeRunKey{}).(string)
+	return runID
+}
+
+// WithRunID sets the ID the agent run of the completion of the context is recorded as, an empty ID
+// clears it.
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+func RunID(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDKey{}).(string)
+	return runID
+}
+
+// WithDelegationDepth sets how many delegations deep the completion of the context is.
+func WithDelegationDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, delegationDepthKey{}, depth)
+}
+
+func DelegationDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(delegationDepthKey{}).(int)
+	return depth
+}
@@ -0,0 +1,38 @@
+package types
+
+const (
+	// DelegateTool is the built-in tool of agents with sub-agents that runs tasks on the
+	// sub-agents concurrently.
+	DelegateTool = "delegate"
+	// DelegateServer is the MCP server name of the tool mapping of the delegate tool. The tool is
+	// handled by the agent runtime, there is no server with this name.
+	DelegateServer = "nanobot.delegate"
+
+	DefaultDelegateMaxConcurrency = 4
+	DefaultDelegateMaxDepth       = 3
+)
+
+// AgentDelegation configures the delegate tool of an agent with sub-agents.
+type AgentDelegation struct {
+	// Disabled removes the delegate tool, the sub-agents are still available as separate tools.
+	Disabled bool `json:"disabled,omitempty"`
+	// MaxConcurrency is how many tasks run at the same time.
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// MaxDepth is how many delegations deep a task of this agent can be, so agents that delegate
+	// to each other stop.
+	MaxDepth int `json:"maxDepth,omitempty"`
+}
+
+func (a *AgentDelegation) GetMaxConcurrency() int {
+	if a == nil || a.MaxConcurrency <= 0 {
+		return DefaultDelegateMaxConcurrency
+	}
+	return a.MaxConcurrency
+}
+
+func (a *AgentDelegation) GetMaxDepth() int {
+	if a == nil || a.MaxDepth <= 0 {
+		return DefaultDelegateMaxDepth
+	}
+	return a.MaxDepth
+}
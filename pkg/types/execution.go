@@ -14,6 +14,25 @@ type Execution struct {
 	CompactedMessages []Message             `json:"compactedMessages,omitempty"`
 }
 
+// Messages returns the full conversation of the execution in order, including messages that were
+// archived by compaction and the final response.
+func (e *Execution) Messages() []Message {
+	var messages []Message
+
+	// Archived pre-compaction messages come first
+	messages = append(messages, e.CompactedMessages...)
+
+	// Current input (includes compaction summary in its natural position)
+	if e.PopulatedRequest != nil {
+		messages = append(messages, e.PopulatedRequest.Input...)
+	}
+	if e.Response != nil {
+		messages = append(messages, e.Response.Output)
+	}
+
+	return messages
+}
+
 func (e *Execution) Serialize() (any, error) {
 	return e, nil
 }
@@ -22,6 +41,50 @@ func (e *Execution) Deserialize(data any) (any, error) {
 	return e, mcp.JSONCoerce(data, e)
 }
 
+// CloseToolCalls adds an error output with the reason for every tool call of the response that
+// has no output yet, so the conversation stays valid when the calls will never complete. It
+// returns the number of tool calls closed.
+func (e *Execution) CloseToolCalls(reason string) int {
+	if e.Response == nil {
+		return 0
+	}
+
+	var closed int
+	for _, item := range e.Response.Output.Items {
+		if item.ToolCall == nil || e.ToolOutputs[item.ToolCall.CallID].Done {
+			continue
+		}
+		if e.ToolOutputs == nil {
+			e.ToolOutputs = make(map[string]ToolOutput)
+		}
+		e.ToolOutputs[item.ToolCall.CallID] = ToolOutput{
+			Output: Message{
+				Role: "user",
+				Items: []CompletionItem{
+					{
+						ID: item.ID,
+						ToolCallResult: &ToolCallResult{
+							CallID: item.ToolCall.CallID,
+							Output: CallResult{
+								Content: []mcp.Content{
+									{
+										Type: "text",
+										Text: reason,
+									},
+								},
+								IsError: true,
+							},
+						},
+					},
+				},
+			},
+			Done: true,
+		}
+		closed++
+	}
+	return closed
+}
+
 type ToolOutput struct {
 	Output Message `json:"output,omitempty"`
 	Done   bool    `json:"done,omitempty"`
@@ -26,9 +26,11 @@ type HookAgent struct {
 	Instructions    DynamicInstructions       `json:"instructions,omitzero"`
 	Model           string                    `json:"model,omitempty"`
 	Permissions     *AgentPermissions         `json:"permissions,omitempty"`
+	Policies        []ToolPolicy              `json:"policies,omitempty"`
 	MCPServers      StringList                `json:"mcpServers,omitempty"`
 	Tools           StringList                `json:"tools,omitempty"`
 	Agents          StringList                `json:"agents,omitempty"`
+	Delegation      *AgentDelegation          `json:"delegation,omitempty"`
 	Prompts         StringList                `json:"prompts,omitzero"`
 	Resources       StringList                `json:"resources,omitzero"`
 	Reasoning       *AgentReasoning           `json:"reasoning,omitempty"`
@@ -36,6 +38,7 @@ type HookAgent struct {
 	Chat            *bool                     `json:"chat,omitempty"`
 	ToolExtensions  map[string]map[string]any `json:"toolExtensions,omitempty"`
 	ToolChoice      string                    `json:"toolChoice,omitempty"`
+	ToolArguments   string                    `json:"toolArguments,omitempty"`
 	Temperature     *json.Number              `json:"temperature,omitempty"`
 	TopP            *json.Number              `json:"topP,omitempty"`
 	Truncation      string                    `json:"truncation,omitempty"`
@@ -52,6 +55,17 @@ type HookAgent struct {
 	Intelligence float64  `json:"intelligence,omitempty"`
 }
 
+const (
+	// ToolArgumentsStrict rejects tool calls with arguments that don't match the input schema of
+	// the tool, it is the default.
+	ToolArgumentsStrict = "strict"
+	// ToolArgumentsLenient converts arguments that obviously mismatch the input schema, like "5"
+	// for an integer, before the arguments are validated.
+	ToolArgumentsLenient = "lenient"
+	// ToolArgumentsOff sends the arguments to the tool without validating them.
+	ToolArgumentsOff = "off"
+)
+
 type AgentConfigHookMCPServer struct {
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
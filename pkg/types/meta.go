@@ -14,6 +14,15 @@ var (
 	ToolCallConfirmType = "toolcall/confirm"
 
 	AsyncMetaKey = "ai.nanobot.async"
+	// ResumeRunMetaKey is the ID of an interrupted agent run for a chat call to resume instead of
+	// starting a new run.
+	ResumeRunMetaKey = "ai.nanobot.resume-run"
+	// BackgroundMetaKey runs a chat call in the background. The call returns the ID of the agent
+	// run immediately, the run is tracked with the chat://runs/{runId} resource.
+	BackgroundMetaKey = "ai.nanobot.background"
+	// CoercedArgumentsMetaKey lists the changes made to the arguments of a tool call to match the
+	// input schema of the tool, it is set on the result of the call.
+	CoercedArgumentsMetaKey = "ai.nanobot.coerced-arguments"
 )
 
 type ToolCallConfirm struct {
@@ -13,12 +13,15 @@ const (
 	AgentMimeType       = "application/vnd.nanobot.agent+json"
 	SessionMimeType     = "application/vnd.nanobot.session+json"
 	ElicitationMimeType = "application/vnd.nanobot.elicitation+json"
+	RunMimeType         = "application/vnd.nanobot.run+json"
 	MetaNanobot         = "ai.nanobot"
 
 	MessageURI     = "chat://message/%s"
 	HistoryURI     = "chat://history"
 	ProgressURI    = "chat://progress"
 	ElicitationURI = "chat://elicitation"
+	RunURI         = "chat://runs/%s"
+	RunURIPrefix   = "chat://runs/"
 )
 
 var (
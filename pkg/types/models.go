@@ -0,0 +1,312 @@
+package types
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ModelInfo describes the capabilities and limits of an LLM model. Built-in entries are provided for
+// well-known models and can be extended or overridden per provider through llmProviders.<name>.models.
+type ModelInfo struct {
+	ContextWindow   int           `json:"contextWindow,omitempty"`
+	MaxOutputTokens int           `json:"maxOutputTokens,omitempty"`
+	Vision          *bool         `json:"vision,omitempty"`
+	Audio           *bool         `json:"audio,omitempty"`
+	Reasoning       *bool         `json:"reasoning,omitempty"`
+	ToolCalls       *bool         `json:"toolCalls,omitempty"`
+	Pricing         *ModelPricing `json:"pricing,omitempty"`
+
+	// Encoding is the tiktoken encoding used to estimate token counts. It is only known for built-in models.
+	Encoding string `json:"-"`
+}
+
+// ModelPricing is the price of a model in USD per million tokens.
+type ModelPricing struct {
+	Input       float64 `json:"input,omitempty"`
+	Output      float64 `json:"output,omitempty"`
+	CachedInput float64 `json:"cachedInput,omitempty"`
+}
+
+// SupportsVision returns false only if the model is known to not accept image input.
+func (m ModelInfo) SupportsVision() bool {
+	return m.Vision == nil || *m.Vision
+}
+
+// SupportsAudio returns false only if the model is known to not accept audio input.
+func (m ModelInfo) SupportsAudio() bool {
+	return m.Audio == nil || *m.Audio
+}
+
+// SupportsToolCalls returns false only if the model is known to not support tool calls.
+func (m ModelInfo) SupportsToolCalls() bool {
+	return m.ToolCalls == nil || *m.ToolCalls
+}
+
+// SupportsMimeType reports whether content of the given MIME type can be sent to the model.
+func (m ModelInfo) SupportsMimeType(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		if _, ok := TextMimeTypes[mimeType]; ok {
+			return true
+		}
+		return m.SupportsVision()
+	case strings.HasPrefix(mimeType, "audio/"):
+		return m.SupportsAudio()
+	}
+	return true
+}
+
+// Merge overlays the fields set in other on top of m.
+func (m ModelInfo) Merge(other ModelInfo) ModelInfo {
+	if other.ContextWindow != 0 {
+		m.ContextWindow = other.ContextWindow
+	}
+	if other.MaxOutputTokens != 0 {
+		m.MaxOutputTokens = other.MaxOutputTokens
+	}
+	if other.Vision != nil {
+		m.Vision = other.Vision
+	}
+	if other.Audio != nil {
+		m.Audio = other.Audio
+	}
+	if other.Reasoning != nil {
+		m.Reasoning = other.Reasoning
+	}
+	if other.ToolCalls != nil {
+		m.ToolCalls = other.ToolCalls
+	}
+	if other.Pricing != nil {
+		m.Pricing = other.Pricing
+	}
+	return m
+}
+
+// LookupModel returns the model info for the given model. The model may be in the "{provider}/{model}"
+// format, in which case models defined on that provider take precedence. Without a provider, the
+// model defined on the first provider sorted by name is used. Models defined on providers
+// are merged on top of the built-in entry for the same model. The boolean result is false if nothing
+// is known about the model.
+func (c Config) LookupModel(model string) (ModelInfo, bool) {
+	return LookupModel(c.LLMProviders, model)
+}
+
+// LookupModel returns the model info for the given model using the built-in registry extended by
+// the models defined on the given providers. See Config.LookupModel.
+func LookupModel(providers map[string]LLMProvider, model string) (ModelInfo, bool) {
+	var (
+		providerName, name, hasProvider = strings.Cut(model, "/")
+		info, found                     = ModelInfo{}, false
+	)
+	if !hasProvider {
+		name = model
+	}
+
+	if builtin, ok := lookupBuiltinModel(name); ok {
+		info, found = builtin, true
+	}
+
+	if hasProvider {
+		if override, ok := providers[providerName].Models[name]; ok {
+			return info.Merge(override), true
+		}
+		return info, found
+	}
+
+	for _, providerName := range slices.Sorted(maps.Keys(providers)) {
+		if override, ok := providers[providerName].Models[name]; ok {
+			return info.Merge(override), true
+		}
+	}
+
+	return info, found
+}
+
+// lookupBuiltinModel finds the built-in entry for a model by exact name or, failing that, by the
+// longest matching name prefix so that dated snapshots (e.g. gpt-4.1-2025-04-14) resolve to their family.
+func lookupBuiltinModel(model string) (ModelInfo, bool) {
+	if info, ok := builtinModels[model]; ok {
+		return info, true
+	}
+
+	var (
+		match string
+		info  ModelInfo
+	)
+	for prefix, candidate := range builtinModels {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(match) {
+			match, info = prefix, candidate
+		}
+	}
+	return info, match != ""
+}
+
+const (
+	encodingCL100K = "cl100k_base"
+	encodingO200K  = "o200k_base"
+)
+
+// builtinModels is keyed by model name prefix.
+var builtinModels = map[string]ModelInfo{
+	"claude-opus-4": {
+		ContextWindow:   200_000,
+		MaxOutputTokens: 32_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 15, Output: 75, CachedInput: 1.5},
+		Encoding:        encodingCL100K,
+	},
+	"claude-opus-4-5": {
+		ContextWindow:   200_000,
+		MaxOutputTokens: 64_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 5, Output: 25, CachedInput: 0.5},
+		Encoding:        encodingCL100K,
+	},
+	"claude-sonnet-4": {
+		ContextWindow:   200_000,
+		MaxOutputTokens: 64_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 3, Output: 15, CachedInput: 0.3},
+		Encoding:        encodingCL100K,
+	},
+	"claude-haiku-4": {
+		ContextWindow:   200_000,
+		MaxOutputTokens: 64_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 1, Output: 5, CachedInput: 0.1},
+		Encoding:        encodingCL100K,
+	},
+	"claude-3-7-sonnet": {
+		ContextWindow:   200_000,
+		MaxOutputTokens: 64_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 3, Output: 15, CachedInput: 0.3},
+		Encoding:        encodingCL100K,
+	},
+	"gpt-4o": {
+		ContextWindow:   128_000,
+		MaxOutputTokens: 16_384,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(false),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 2.5, Output: 10, CachedInput: 1.25},
+		Encoding:        encodingO200K,
+	},
+	"gpt-4o-mini": {
+		ContextWindow:   128_000,
+		MaxOutputTokens: 16_384,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(false),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 0.15, Output: 0.6, CachedInput: 0.075},
+		Encoding:        encodingO200K,
+	},
+	"gpt-4.1": {
+		ContextWindow:   1_047_576,
+		MaxOutputTokens: 32_768,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(false),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 2, Output: 8, CachedInput: 0.5},
+		Encoding:        encodingO200K,
+	},
+	"gpt-4.1-mini": {
+		ContextWindow:   1_047_576,
+		MaxOutputTokens: 32_768,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(false),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 0.4, Output: 1.6, CachedInput: 0.1},
+		Encoding:        encodingO200K,
+	},
+	"gpt-4.1-nano": {
+		ContextWindow:   1_047_576,
+		MaxOutputTokens: 32_768,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(false),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 0.1, Output: 0.4, CachedInput: 0.025},
+		Encoding:        encodingO200K,
+	},
+	"gpt-5": {
+		ContextWindow:   400_000,
+		MaxOutputTokens: 128_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 1.25, Output: 10, CachedInput: 0.125},
+		Encoding:        encodingO200K,
+	},
+	"gpt-5-mini": {
+		ContextWindow:   400_000,
+		MaxOutputTokens: 128_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 0.25, Output: 2, CachedInput: 0.025},
+		Encoding:        encodingO200K,
+	},
+	"gpt-5-nano": {
+		ContextWindow:   400_000,
+		MaxOutputTokens: 128_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 0.05, Output: 0.4, CachedInput: 0.005},
+		Encoding:        encodingO200K,
+	},
+	"o3": {
+		ContextWindow:   200_000,
+		MaxOutputTokens: 100_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 2, Output: 8, CachedInput: 0.5},
+		Encoding:        encodingO200K,
+	},
+	"o3-mini": {
+		ContextWindow:   200_000,
+		MaxOutputTokens: 100_000,
+		Vision:          new(false),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 1.1, Output: 4.4, CachedInput: 0.55},
+		Encoding:        encodingO200K,
+	},
+	"o4-mini": {
+		ContextWindow:   200_000,
+		MaxOutputTokens: 100_000,
+		Vision:          new(true),
+		Audio:           new(false),
+		Reasoning:       new(true),
+		ToolCalls:       new(true),
+		Pricing:         &ModelPricing{Input: 1.1, Output: 4.4, CachedInput: 0.275},
+		Encoding:        encodingO200K,
+	},
+}
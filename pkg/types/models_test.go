@@ -0,0 +1,92 @@
+package types
+
+import "testing"
+
+func TestLookupModel(t *testing.T) {
+	config := Config{
+		LLMProviders: map[string]LLMProvider{
+			"custom": {
+				Models: map[string]ModelInfo{
+					"my-model": {
+						ContextWindow: 32_000,
+						Vision:        new(false),
+					},
+					"gpt-4.1": {
+						ContextWindow: 100_000,
+					},
+				},
+			},
+			"zeta": {
+				Models: map[string]ModelInfo{
+					"my-model": {
+						ContextWindow: 64_000,
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name              string
+		model             string
+		wantFound         bool
+		wantContextWindow int
+		wantMaxOutput     int
+		wantVision        bool
+	}{
+		{"exact builtin", "gpt-4o", true, 128_000, 16_384, true},
+		{"longest prefix", "gpt-4.1-mini-2025-04-14", true, 1_047_576, 32_768, true},
+		{"dated snapshot", "claude-sonnet-4-5-20250929", true, 200_000, 64_000, true},
+		{"provider prefix", "anthropic/claude-opus-4-5", true, 200_000, 64_000, true},
+		{"custom model", "custom/my-model", true, 32_000, 0, false},
+		{"custom model without provider", "my-model", true, 32_000, 0, false},
+		{"custom model of other provider", "zeta/my-model", true, 64_000, 0, true},
+		{"custom override merges builtin", "custom/gpt-4.1", true, 100_000, 32_768, true},
+		{"other provider ignores override", "openai/gpt-4.1", true, 1_047_576, 32_768, true},
+		{"unknown", "unknown-model", false, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, found := config.LookupModel(tt.model)
+			if found != tt.wantFound {
+				t.Fatalf("LookupModel(%q) found = %v, want %v", tt.model, found, tt.wantFound)
+			}
+			if info.ContextWindow != tt.wantContextWindow {
+				t.Errorf("ContextWindow = %d, want %d", info.ContextWindow, tt.wantContextWindow)
+			}
+			if info.MaxOutputTokens != tt.wantMaxOutput {
+				t.Errorf("MaxOutputTokens = %d, want %d", info.MaxOutputTokens, tt.wantMaxOutput)
+			}
+			if info.SupportsVision() != tt.wantVision {
+				t.Errorf("SupportsVision() = %v, want %v", info.SupportsVision(), tt.wantVision)
+			}
+		})
+	}
+}
+
+func TestModelInfoSupportsMimeType(t *testing.T) {
+	textOnly := ModelInfo{Vision: new(false), Audio: new(false)}
+	unknown := ModelInfo{}
+
+	tests := []struct {
+		mimeType string
+		model    ModelInfo
+		want     bool
+	}{
+		{"image/png", textOnly, false},
+		{"audio/wav", textOnly, false},
+		{"image/svg+xml", textOnly, true},
+		{"application/pdf", textOnly, true},
+		{"image/png", unknown, true},
+		{"audio/wav", unknown, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mimeType, func(t *testing.T) {
+			if got := tt.model.SupportsMimeType(tt.mimeType); got != tt.want {
+				t.Errorf("SupportsMimeType(%q) = %v, want %v", tt.mimeType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type PolicyAction string
+
+const (
+	PolicyActionAllow   PolicyAction = "allow"
+	PolicyActionDeny    PolicyAction = "deny"
+	PolicyActionApprove PolicyAction = "approve"
+	PolicyActionRewrite PolicyAction = "rewrite"
+)
+
+// ToolPolicy is a rule evaluated before a tool is called. The rules of the config are evaluated
+// before the rules of the agent, and the first matching allow, deny or approve rule decides. A
+// matching rewrite rule changes the arguments and the evaluation continues with the next rule.
+// Tool calls that match no rule are allowed.
+type ToolPolicy struct {
+	// Tools are globs of the tool refs the rule applies to, in the form server/tool. A ref without
+	// a slash matches all the tools of the server. Empty matches all tools.
+	Tools StringList `json:"tools,omitempty"`
+	// Arguments are the conditions on the arguments, all must match for the rule to apply. The
+	// keys are argument names, nested arguments are separated by dots.
+	Arguments map[string]ArgumentCondition `json:"arguments,omitempty"`
+	Action    PolicyAction                 `json:"action,omitempty"`
+	// Reason is returned to the model on denials and shown to the user on approvals.
+	Reason string `json:"reason,omitempty"`
+	// Rewrite sets arguments for the rewrite action, the keys are the same as Arguments.
+	Rewrite map[string]any `json:"rewrite,omitempty"`
+}
+
+// ArgumentCondition matches the value of an argument, all the set fields must match. A condition
+// on an argument that is not set doesn't match. Values that are not strings are matched as JSON.
+type ArgumentCondition struct {
+	Match    string     `json:"match,omitempty"`
+	NotMatch string     `json:"notMatch,omitempty"`
+	Under    StringList `json:"under,omitempty"`
+	NotUnder StringList `json:"notUnder,omitempty"`
+}
+
+func (p ToolPolicy) Validate() error {
+	var errs []error
+
+	switch p.Action {
+	case PolicyActionAllow, PolicyActionDeny, PolicyActionApprove:
+	case PolicyActionRewrite:
+		if len(p.Rewrite) == 0 {
+			errs = append(errs, fmt.Errorf("rewrite is required for the rewrite action"))
+		}
+	case "":
+		errs = append(errs, fmt.Errorf("action is required"))
+	default:
+		errs = append(errs, fmt.Errorf("invalid action %q, must be allow, deny, approve or rewrite", p.Action))
+	}
+
+	for _, tool := range p.Tools {
+		if _, err := path.Match(tool, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid tool glob %q: %w", tool, err))
+		}
+	}
+
+	for name, condition := range p.Arguments {
+		for _, expr := range []string{condition.Match, condition.NotMatch} {
+			if expr == "" {
+				continue
+			}
+			if _, err := regexp.Compile(expr); err != nil {
+				errs = append(errs, fmt.Errorf("invalid expression for argument %s: %w", name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// MatchesTool returns whether the rule applies to a tool of a server.
+func (p ToolPolicy) MatchesTool(server, tool string) bool {
+	if len(p.Tools) == 0 {
+		return true
+	}
+	for _, glob := range p.Tools {
+		target := server + "/" + tool
+		if !strings.Contains(glob, "/") {
+			target = server
+		}
+		if ok, _ := path.Match(glob, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validatePolicies(policies []ToolPolicy) error {
+	var errs []error
+	for i, policy := range policies {
+		if err := policy.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("policy %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
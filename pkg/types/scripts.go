@@ -0,0 +1,47 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nanobot-ai/nanobot/pkg/mcp"
+)
+
+// ScriptsServer is the built-in MCP server that publishes the scripts of the config as tools.
+const ScriptsServer = "nanobot.scripts"
+
+// Script is a tool implemented in JavaScript. The script is the body of a function that gets the
+// arguments of the call as args and returns the result of the tool. It can use call(target, args)
+// to call tools and agents, and prompt(target, args) to render prompts.
+type Script struct {
+	Description string      `json:"description,omitempty"`
+	Input       InputSchema `json:"input,omitzero"`
+	Script      string      `json:"script,omitempty"`
+}
+
+func (s Script) ToTool(name string) mcp.Tool {
+	schema := s.Input.ToSchema()
+	if len(schema) == 0 {
+		schema = mcp.EmptyObjectSchema
+	}
+	return mcp.Tool{
+		Name:        name,
+		Description: s.Description,
+		InputSchema: schema,
+	}
+}
+
+func (s Script) validate(name string) error {
+	var errs []error
+	if strings.Contains(name, "/") {
+		errs = append(errs, fmt.Errorf("slashes are not allowed in the name"))
+	}
+	if strings.TrimSpace(s.Script) == "" {
+		errs = append(errs, fmt.Errorf("script is required"))
+	}
+	if len(s.Input.Fields) > 0 && len(s.Input.Schema) > 0 {
+		errs = append(errs, fmt.Errorf("input can not have both fields and schema"))
+	}
+	return errors.Join(errs...)
+}